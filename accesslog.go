@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log formats accepted by MINIO_ACCESS_LOG.
+const (
+	accessLogCLF  = "clf"
+	accessLogJSON = "json"
+	accessLogOff  = "off"
+)
+
+// parseAccessLogFormat validates a MINIO_ACCESS_LOG value; empty means clf.
+func parseAccessLogFormat(v string) (string, error) {
+	switch v {
+	case "":
+		return accessLogCLF, nil
+	case accessLogCLF, accessLogJSON, accessLogOff:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown access log format %q (use clf, json, or off)", v)
+}
+
+// statusRecorder captures the status code and body size a handler writes.
+// Unwrap lets http.ResponseController reach the underlying writer, and Flush
+// is forwarded so streaming handlers still work behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	UserAgent  string    `json:"user_agent"`
+	Referer    string    `json:"referer,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// accessLog writes one line per request to out in the given format: Apache
+// combined log format with the duration in milliseconds appended ("clf"),
+// or one JSON object per line ("json").
+func accessLog(format string, out io.Writer, next http.Handler) http.Handler {
+	if format == accessLogOff {
+		return next
+	}
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		e := accessLogEntry{
+			Time:       start,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Proto:      r.Proto,
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			UserAgent:  r.UserAgent(),
+			Referer:    r.Referer(),
+			RequestID:  requestID(r.Context()),
+		}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			e.RemoteAddr = host
+		}
+		if e.Status == 0 {
+			// The handler wrote nothing; net/http sends an empty 200.
+			e.Status = http.StatusOK
+		}
+
+		var line []byte
+		if format == accessLogJSON {
+			line, _ = json.Marshal(e)
+		} else {
+			line = fmt.Appendf(nil, "%s - - [%s] %s %d %d %s %s %.3f",
+				e.RemoteAddr, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+				strconv.Quote(e.Method+" "+e.Path+" "+e.Proto), e.Status, e.Bytes,
+				strconv.Quote(orDash(e.Referer)), strconv.Quote(orDash(e.UserAgent)), e.DurationMS)
+		}
+		mu.Lock()
+		out.Write(append(line, '\n'))
+		mu.Unlock()
+	})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/list?recursive=true", nil)
+		req.RemoteAddr = "203.0.113.9:5123"
+		req.Header.Set("User-Agent", "curl/8.0")
+		return req
+	}
+
+	var out bytes.Buffer
+	rec := httptest.NewRecorder()
+	accessLog(accessLogCLF, &out, h.routes()).ServeHTTP(rec, newReq())
+	clf := regexp.MustCompile(`^203\.0\.113\.9 - - \[[^\]]+\] "GET /list\?recursive=true HTTP/1\.1" 200 (\d+) "-" "curl/8\.0" [0-9.]+\n$`)
+	m := clf.FindStringSubmatch(out.String())
+	if m == nil {
+		t.Fatalf("clf line = %q", out.String())
+	}
+	if m[1] != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("clf bytes = %s, body was %d bytes", m[1], rec.Body.Len())
+	}
+
+	out.Reset()
+	accessLog(accessLogJSON, &out, h.routes()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/list", nil))
+	var e accessLogEntry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("json line %q: %v", out.String(), err)
+	}
+	if e.Method != http.MethodPost || e.Path != "/list" || e.Status != http.StatusMethodNotAllowed || e.Bytes == 0 {
+		t.Errorf("json entry = %+v", e)
+	}
+
+	out.Reset()
+	accessLog(accessLogOff, &out, h.routes()).ServeHTTP(httptest.NewRecorder(), newReq())
+	if out.Len() != 0 {
+		t.Errorf("off wrote %q", out.String())
+	}
+}
+
+func TestParseAccessLogFormat(t *testing.T) {
+	if f, err := parseAccessLogFormat(""); f != accessLogCLF || err != nil {
+		t.Errorf(`parse("") = %q, %v`, f, err)
+	}
+	if _, err := parseAccessLogFormat("xml"); err == nil {
+		t.Error(`parse("xml") succeeded`)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyPermissions are what a key may be allowed; each grants the scope
+// "files:<permission>" (see routeScope).
+var apiKeyPermissions = []string{"read", "write", "delete", "admin"}
+
+// apiKey is a stored API key. Only a SHA-256 hash of the secret is kept,
+// so the file can't be used to recover keys.
+type apiKey struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Hash        string    `json:"hash"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// apiKeyView is an API key as the /admin/keys endpoints show it. Key, the
+// secret, is only ever shown once, when the key is created.
+type apiKeyView struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	Key         string    `json:"key,omitempty"`
+}
+
+func (k *apiKey) view() apiKeyView {
+	return apiKeyView{ID: k.ID, Name: k.Name, Permissions: k.Permissions, CreatedAt: k.CreatedAt}
+}
+
+// claims returns the token claims a request made with the key carries.
+func (k *apiKey) claims() *tokenClaims {
+	scopes := make([]string, len(k.Permissions))
+	for i, p := range k.Permissions {
+		scopes[i] = "files:" + p
+	}
+	return &tokenClaims{Subject: "apikey:" + k.Name, Scope: strings.Join(scopes, " ")}
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// validPermissions checks perms against apiKeyPermissions and returns them
+// sorted without duplicates.
+func validPermissions(perms []string) ([]string, error) {
+	if len(perms) == 0 {
+		return nil, errors.New("permissions must list at least one of " + strings.Join(apiKeyPermissions, ", "))
+	}
+	out := slices.Clone(perms)
+	for _, p := range out {
+		if !slices.Contains(apiKeyPermissions, p) {
+			return nil, fmt.Errorf("unknown permission %q (use %s)", p, strings.Join(apiKeyPermissions, ", "))
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// apiKeyStore holds the API keys in a JSON file, MINIO_API_KEYS_FILE,
+// which is rewritten on every change.
+type apiKeyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys map[string]*apiKey
+	// byHash finds a key from the hash of the secret a request presents.
+	byHash map[string]*apiKey
+	// adminHash is the hash of MINIO_API_KEYS_ADMIN_KEY, a key outside the
+	// file with only the admin permission, to create the first keys with.
+	adminHash string
+}
+
+// loadAPIKeys reads the keys in path; a file that doesn't exist yet holds
+// no keys.
+func loadAPIKeys(path string) (*apiKeyStore, error) {
+	s := &apiKeyStore{path: path, keys: make(map[string]*apiKey), byHash: make(map[string]*apiKey)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*apiKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, k := range keys {
+		if k.ID == "" || k.Hash == "" {
+			return nil, fmt.Errorf("%s: every key needs an id and a hash", path)
+		}
+		if k.Permissions, err = validPermissions(k.Permissions); err != nil {
+			return nil, fmt.Errorf("%s: key %s: %w", path, k.ID, err)
+		}
+		s.keys[k.ID], s.byHash[k.Hash] = k, k
+	}
+	return s, nil
+}
+
+// saveLocked writes the keys to a temporary file and renames it over the
+// old one, so a crash never leaves a half-written file. s.mu must be held.
+func (s *apiKeyStore) saveLocked() error {
+	keys := make([]*apiKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".apikeys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// authenticate returns the claims of the key whose secret is secret.
+func (s *apiKeyStore) authenticate(secret string) (*tokenClaims, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hash := hashAPIKey(secret)
+	if s.adminHash != "" && hash == s.adminHash {
+		return &tokenClaims{Subject: "apikey:admin", Scope: scopeAdmin}, true
+	}
+	k, ok := s.byHash[hash]
+	if !ok {
+		return nil, false
+	}
+	return k.claims(), true
+}
+
+func (s *apiKeyStore) list() []apiKeyView {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]apiKeyView, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, k.view())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (s *apiKeyStore) get(id string) (apiKeyView, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return apiKeyView{}, false
+	}
+	return k.view(), true
+}
+
+// create stores a new key and returns it with its secret.
+func (s *apiKeyStore) create(name string, perms []string) (apiKeyView, error) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	secret := "mk_" + base64.RawURLEncoding.EncodeToString(b)
+	k := &apiKey{ID: newID(), Name: name, Hash: hashAPIKey(secret), Permissions: perms, CreatedAt: time.Now().UTC()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID], s.byHash[k.Hash] = k, k
+	if err := s.saveLocked(); err != nil {
+		delete(s.keys, k.ID)
+		delete(s.byHash, k.Hash)
+		return apiKeyView{}, err
+	}
+	v := k.view()
+	v.Key = secret
+	return v, nil
+}
+
+// update changes a key's name and permissions where given. ok is false for
+// an unknown key.
+func (s *apiKeyStore) update(id string, name *string, perms []string) (v apiKeyView, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return apiKeyView{}, false, nil
+	}
+	old := *k
+	if name != nil {
+		k.Name = *name
+	}
+	if perms != nil {
+		k.Permissions = perms
+	}
+	if err := s.saveLocked(); err != nil {
+		*k = old
+		return apiKeyView{}, true, err
+	}
+	return k.view(), true, nil
+}
+
+// remove deletes a key, reporting whether it existed.
+func (s *apiKeyStore) remove(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.keys, id)
+	delete(s.byHash, k.Hash)
+	if err := s.saveLocked(); err != nil {
+		s.keys[id], s.byHash[k.Hash] = k, k
+		return true, err
+	}
+	return true, nil
+}
+
+// apiKeysHandler lists the API keys (GET /admin/keys) or creates one (POST,
+// {"name": ..., "permissions": [...]}) and returns it with its secret, the
+// only time the secret is shown.
+func (h *MinioHandler) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, ok := h.apiKeys(w)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, r, http.StatusOK, keys.list())
+		return
+	}
+	var req struct {
+		Name        string   `json:"name"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, `Request body must be JSON like {"name": "billing", "permissions": ["read"]}`, http.StatusBadRequest)
+		return
+	}
+	perms, err := validPermissions(req.Permissions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := keys.create(strings.TrimSpace(req.Name), perms)
+	if err != nil {
+		logger(r.Context()).Error("Error saving API keys", "err", err)
+		http.Error(w, "Failed to save API key", http.StatusInternalServerError)
+		return
+	}
+	logger(r.Context()).Info("Created API key", "id", created.ID, "name", created.Name, "permissions", perms)
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// apiKeyHandler shows (GET), changes (PATCH, with the fields of a create,
+// each optional) or deletes (DELETE) /admin/keys/{id}. Changes take effect
+// on the next request made with the key.
+func (h *MinioHandler) apiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keys, ok := h.apiKeys(w)
+	if !ok {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/admin/keys/")
+	switch r.Method {
+	case http.MethodGet:
+		k, ok := keys.get(id)
+		if !ok {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, k)
+	case http.MethodPatch:
+		var req struct {
+			Name        *string  `json:"name"`
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Name != nil && strings.TrimSpace(*req.Name) == "") {
+			http.Error(w, `Request body must be JSON like {"name": "billing", "permissions": ["read", "write"]}`, http.StatusBadRequest)
+			return
+		}
+		var perms []string
+		if req.Permissions != nil {
+			var err error
+			if perms, err = validPermissions(req.Permissions); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			req.Name = &name
+		}
+		k, ok, err := keys.update(id, req.Name, perms)
+		switch {
+		case !ok:
+			http.Error(w, "API key not found", http.StatusNotFound)
+		case err != nil:
+			logger(r.Context()).Error("Error saving API keys", "err", err)
+			http.Error(w, "Failed to save API key", http.StatusInternalServerError)
+		default:
+			logger(r.Context()).Info("Updated API key", "id", k.ID, "name", k.Name, "permissions", k.Permissions)
+			writeJSON(w, r, http.StatusOK, k)
+		}
+	case http.MethodDelete:
+		ok, err := keys.remove(id)
+		switch {
+		case !ok:
+			http.Error(w, "API key not found", http.StatusNotFound)
+		case err != nil:
+			logger(r.Context()).Error("Error saving API keys", "err", err)
+			http.Error(w, "Failed to delete API key", http.StatusInternalServerError)
+		default:
+			logger(r.Context()).Info("Deleted API key", "id", id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// apiKeys returns the key store, answering 404 when API keys are off.
+func (h *MinioHandler) apiKeys(w http.ResponseWriter) (*apiKeyStore, bool) {
+	if h.auth == nil || h.auth.keys == nil {
+		http.Error(w, "API keys are not enabled; add apikey to MINIO_AUTH", http.StatusNotFound)
+		return nil, false
+	}
+	return h.auth.keys, true
+}
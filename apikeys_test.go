@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	h, store := newTestHandler(t)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	keys, err := loadAPIKeys(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.adminHash = hashAPIKey("bootstrap-secret")
+	h.auth = &authenticator{keys: keys}
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	call := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		return serve(h, req)
+	}
+
+	// The admin key manages keys but can't read files.
+	rec := call(http.MethodPost, "/admin/keys", "bootstrap-secret", `{"name": "billing", "permissions": ["read", "read"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	var created apiKeyView
+	decodeJSON(t, rec, &created)
+	if created.ID == "" || !strings.HasPrefix(created.Key, "mk_") || strings.Join(created.Permissions, ",") != "read" {
+		t.Fatalf("created = %+v", created)
+	}
+	if rec := call(http.MethodGet, "/list", "bootstrap-secret", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("admin key listing files: status %d", rec.Code)
+	}
+
+	// The secret is stored only as a hash.
+	data, _ := os.ReadFile(file)
+	if bytes.Contains(data, []byte(created.Key)) || !bytes.Contains(data, []byte(hashAPIKey(created.Key))) {
+		t.Errorf("keys file = %s", data)
+	}
+
+	if rec := call(http.MethodGet, "/list", created.Key, ""); rec.Code != http.StatusOK {
+		t.Errorf("read key listing: status %d", rec.Code)
+	}
+	if rec := call(http.MethodDelete, "/delete/a.txt", created.Key, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("read key deleting: status %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/list", "mk_unknown", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/list", "", ""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "ApiKey" {
+		t.Errorf("no key: status %d, WWW-Authenticate %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	// Granting delete takes effect on the next request.
+	rec = call(http.MethodPatch, "/admin/keys/"+created.ID, "bootstrap-secret", `{"permissions": ["read", "delete"]}`)
+	var updated apiKeyView
+	decodeJSON(t, rec, &updated)
+	if strings.Join(updated.Permissions, ",") != "delete,read" || updated.Name != "billing" || updated.Key != "" {
+		t.Errorf("updated = %+v", updated)
+	}
+	if rec := call(http.MethodDelete, "/delete/a.txt", created.Key, ""); rec.Code != http.StatusOK {
+		t.Errorf("delete key deleting: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Keys survive a restart.
+	reloaded, err := loadAPIKeys(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, ok := reloaded.authenticate(created.Key); !ok || claims.Scope != "files:delete files:read" || claims.Subject != "apikey:billing" {
+		t.Errorf("reloaded key = %+v, %v", claims, ok)
+	}
+
+	var listed []apiKeyView
+	decodeJSON(t, call(http.MethodGet, "/admin/keys", "bootstrap-secret", ""), &listed)
+	if len(listed) != 1 || listed[0].Key != "" {
+		t.Errorf("listed = %+v", listed)
+	}
+	if rec := call(http.MethodDelete, "/admin/keys/"+created.ID, "bootstrap-secret", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("revoke: status %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/list", created.Key, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/admin/keys/"+created.ID, "bootstrap-secret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("revoked key lookup: status %d", rec.Code)
+	}
+}
+
+func TestAPIKeyValidation(t *testing.T) {
+	h, _ := newTestHandler(t)
+	keys, _ := loadAPIKeys(filepath.Join(t.TempDir(), "keys.json"))
+	h.auth = &authenticator{keys: keys}
+	keys.adminHash = hashAPIKey("admin")
+
+	for _, body := range []string{
+		`{"name": "", "permissions": ["read"]}`,
+		`{"name": "x", "permissions": []}`,
+		`{"name": "x", "permissions": ["superuser"]}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin")
+		if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("create %s: status %d, want 400", body, rec.Code)
+		}
+	}
+
+	if _, err := loadAPIKeys(writeTemp(t, `[{"id": "1", "hash": "x", "permissions": ["root"]}]`)); err == nil {
+		t.Error("loadAPIKeys accepted an unknown permission")
+	}
+}
+
+func TestAPIKeysDisabled(t *testing.T) {
+	h, _ := newTestHandler(t)
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/admin/keys", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("without API keys: status %d, want 404", rec.Code)
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
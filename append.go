@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// minCopyPartSize is S3's minimum size of every multipart part but the
+	// last, so an object is only appended to by copy from this size on.
+	minCopyPartSize = 5 << 20
+	// maxCopyPartSize is S3's maximum part size; bigger objects are copied
+	// in several parts.
+	maxCopyPartSize = 5 << 30
+)
+
+type appendResponse struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	Appended     int64  `json:"appended"`
+	ETag         string `json:"etag"`
+	PreviousETag string `json:"previous_etag"`
+}
+
+// appendHandler adds the request body to the end of /append/{objectName}
+// without downloading it: a multipart upload copies the existing object
+// server-side into its first part(s) and takes the body as the last part,
+// and completing it replaces the object. Metadata and tags are kept. S3
+// requires every part but the last to be at least 5 MiB, so smaller objects
+// can't be appended to this way and get 409. The copy and the completion
+// are conditional on the ETag first read (or If-Match), so a concurrent
+// change fails with 412 instead of being lost.
+func (h *MinioHandler) appendHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/append/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /append/logs/app.log)", http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength < 0 {
+		http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength == 0 {
+		http.Error(w, "Request body must contain the bytes to append", http.StatusBadRequest)
+		return
+	}
+	limit := min(h.rawMaxBytes, maxCopyPartSize)
+	tooLarge := "Appended data exceeds the limit of " + strconv.FormatInt(limit, 10) + " bytes"
+	if r.ContentLength > limit {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx := r.Context()
+	info, err := h.statObject(ctx, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if match := strings.Trim(r.Header.Get("If-Match"), `"`); match != "" && match != "*" && match != info.ETag {
+		http.Error(w, "Object has changed (ETag does not match If-Match)", http.StatusPreconditionFailed)
+		return
+	}
+	if info.Size < minCopyPartSize {
+		http.Error(w, fmt.Sprintf("'%s' is %d bytes; appending by copy needs an object of at least %d bytes (5 MiB)", objectName, info.Size, minCopyPartSize), http.StatusConflict)
+		return
+	}
+
+	opts, err := h.preservingOptions(ctx, objectName, info)
+	if err != nil {
+		h.appendFailed(w, r, objectName, err)
+		return
+	}
+	uploadID, err := h.store.NewMultipartUpload(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		h.appendFailed(w, r, objectName, err)
+		return
+	}
+	// Parts are abandoned on any failure, including a client that goes away.
+	abort := func() {
+		h.store.AbortMultipartUpload(context.WithoutCancel(ctx), h.bucketName, objectName, uploadID)
+	}
+
+	conditions := map[string]string{"x-amz-copy-source-if-match": info.ETag}
+	copyParts := int((info.Size + maxCopyPartSize - 1) / maxCopyPartSize)
+	partSize := (info.Size + int64(copyParts) - 1) / int64(copyParts)
+	parts := make([]minio.CompletePart, 0, copyParts+1)
+	for part := 1; part <= copyParts; part++ {
+		offset := int64(part-1) * partSize
+		cp, err := h.store.CopyObjectPart(ctx, h.bucketName, objectName, h.bucketName, objectName,
+			uploadID, part, offset, min(partSize, info.Size-offset), conditions)
+		if err != nil {
+			abort()
+			h.appendFailed(w, r, objectName, err)
+			return
+		}
+		parts = append(parts, cp)
+	}
+
+	body := http.MaxBytesReader(w, r.Body, limit)
+	last, err := h.store.PutObjectPart(ctx, h.bucketName, objectName, uploadID, copyParts+1, body, r.ContentLength, minio.PutObjectPartOptions{})
+	if err != nil {
+		abort()
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if clientDisconnected(r, err) {
+			h.uploadAbandoned(w, r, err)
+			return
+		}
+		h.appendFailed(w, r, objectName, err)
+		return
+	}
+	parts = append(parts, minio.CompletePart{
+		PartNumber: last.PartNumber, ETag: last.ETag,
+		ChecksumCRC32: last.ChecksumCRC32, ChecksumCRC32C: last.ChecksumCRC32C,
+		ChecksumSHA1: last.ChecksumSHA1, ChecksumSHA256: last.ChecksumSHA256, ChecksumCRC64NVME: last.ChecksumCRC64NVME,
+	})
+
+	completeOpts := minio.PutObjectOptions{}
+	completeOpts.SetMatchETag(info.ETag)
+	uploaded, err := h.store.CompleteMultipartUpload(ctx, h.bucketName, objectName, uploadID, parts, completeOpts)
+	if err != nil {
+		abort()
+		h.appendFailed(w, r, objectName, err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	h.quotaAfterUpload(w, objectName, r.ContentLength)
+	writeJSON(w, r, http.StatusOK, appendResponse{
+		Key:          objectName,
+		Size:         info.Size + r.ContentLength,
+		Appended:     r.ContentLength,
+		ETag:         uploaded.ETag,
+		PreviousETag: info.ETag,
+	})
+}
+
+// appendFailed reports an error from an append, mapping a failed ETag
+// condition to 412.
+func (h *MinioHandler) appendFailed(w http.ResponseWriter, r *http.Request, objectName string, err error) {
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		http.Error(w, "Object changed while it was being appended to; retry the request", http.StatusPreconditionFailed)
+		return
+	}
+	logger(r.Context()).Error("Error appending to object", "object", objectName, "err", err)
+	h.storeFailed(w, "Failed to append to file", err)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestAppendHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.rawMaxBytes = 1 << 20
+	log := bytes.Repeat([]byte("line\n"), minCopyPartSize/5+1)
+	opts := minio.PutObjectOptions{ContentType: "text/plain", UserMetadata: map[string]string{"X-Amz-Meta-Owner": "ops"}}
+	if _, err := store.PutObject(t.Context(), testBucket, "app.log", bytes.NewReader(log), int64(len(log)), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/append/app.log", strings.NewReader("tail\n")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp appendResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Size != int64(len(log))+5 || resp.Appended != 5 {
+		t.Errorf("response = %+v", resp)
+	}
+	data, _ := store.object(testBucket, "app.log")
+	if !bytes.Equal(data, append(log, "tail\n"...)) {
+		t.Errorf("object ends with %q, want the appended line", data[len(data)-10:])
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "app.log", minio.StatObjectOptions{})
+	if info.ContentType != "text/plain" || info.UserMetadata["Owner"] != "ops" || info.ETag != resp.ETag {
+		t.Errorf("info = %+v, want the metadata kept", info)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/append/app.log", strings.NewReader("more\n"))
+	req.Header.Set("If-Match", `"`+resp.PreviousETag+`"`)
+	if rec := serve(h, req); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: status = %d, want 412", rec.Code)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("%d multipart uploads left behind", len(store.uploads))
+	}
+}
+
+func TestAppendHandlerRejects(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.rawMaxBytes = 1 << 20
+	store.put(testBucket, "small.log", []byte("short\n"), "text/plain")
+
+	unsized := httptest.NewRequest(http.MethodPost, "/append/small.log", strings.NewReader("x"))
+	unsized.ContentLength = -1
+	for _, tt := range []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"small object", httptest.NewRequest(http.MethodPost, "/append/small.log", strings.NewReader("x")), http.StatusConflict},
+		{"missing object", httptest.NewRequest(http.MethodPost, "/append/missing.log", strings.NewReader("x")), http.StatusNotFound},
+		{"empty body", httptest.NewRequest(http.MethodPost, "/append/small.log", nil), http.StatusBadRequest},
+		{"no length", unsized, http.StatusLengthRequired},
+		{"too large", httptest.NewRequest(http.MethodPost, "/append/small.log", bytes.NewReader(make([]byte, 1<<20+1))), http.StatusRequestEntityTooLarge},
+	} {
+		if rec := serve(h, tt.req); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
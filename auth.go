@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes a token needs, in its "scope" or "scp" claim, for each kind of
+// route; see routeScope. API keys get the scopes of their permissions.
+const (
+	scopeRead   = "files:read"
+	scopeWrite  = "files:write"
+	scopeDelete = "files:delete"
+	scopeAdmin  = "files:admin"
+)
+
+// readPosts are POST routes that only read objects, and so take
+// files:read.
+var readPosts = map[string]bool{
+	"/prefetch": true,
+	"/verify":   true,
+	"/concat":   true,
+	"/share":    true,
+}
+
+// deletePosts are POST routes that delete objects, and so take files:delete.
+var deletePosts = map[string]bool{
+	"/delete-batch": true,
+}
+
+// movePosts are POST routes that write objects and delete their sources,
+// and so take files:delete as well as files:write. /copy-by-tag only
+// deletes with "move", and asks for files:delete itself.
+var movePosts = map[string]bool{
+	"/move":     true,
+	"/rename":   true,
+	"/organize": true,
+	"/swap":     true,
+}
+
+// routeScope returns the scope a request to route needs: files:admin for
+// /admin/, /bucket/ and /debug/, files:read for other GET and HEAD requests
+// and the POSTs in readPosts, files:delete for DELETE and the POSTs in
+// deletePosts and movePosts, and files:write for the rest. The middleware
+// asks movePosts for files:write too. GETs that change the bucket,
+// such as /get-upload-link/, ask for files:write too, through
+// MinioHandler.writes.
+func routeScope(route, method string) string {
+	switch {
+	case strings.HasPrefix(route, "/admin/"), strings.HasPrefix(route, "/bucket/"), strings.HasPrefix(route, "/debug/"):
+		return scopeAdmin
+	case method == http.MethodGet, method == http.MethodHead, readPosts[route]:
+		return scopeRead
+	case strings.HasPrefix(route, "/tus/"), strings.HasPrefix(route, "/multipart/"):
+		// Abandoning an unfinished upload deletes no file.
+		return scopeWrite
+	case method == http.MethodDelete, deletePosts[route], movePosts[route]:
+		return scopeDelete
+	}
+	return scopeWrite
+}
+
+// publicRoute reports whether route is served without a token: health
+// checks for load balancers, and share links, whose token is the link.
+func publicRoute(route string) bool {
+	return route == "/healthz" || strings.HasPrefix(route, "/s/")
+}
+
+// routePath returns p without a /tenants/{tenant} or /b/{bucket} prefix,
+// as the routes of the selected handler see it.
+func routePath(p string) string {
+	for _, prefix := range []string{tenantPathPrefix, bucketPathPrefix} {
+		if rest, ok := strings.CutPrefix(p, prefix); ok {
+			_, after, _ := strings.Cut(rest, "/")
+			p = "/" + after
+		}
+	}
+	return p
+}
+
+// jwtAuth verifies the Bearer JWTs requests carry, signed with HS256 or
+// RS256 as MINIO_AUTH_JWT_ALG says.
+type jwtAuth struct {
+	alg string
+	// secret is the HS256 key; publicKey the RS256 one.
+	secret    []byte
+	publicKey *rsa.PublicKey
+	// issuer and audience, when set, must match the iss and aud claims.
+	issuer   string
+	audience string
+	// leeway allows for clock skew when checking exp and nbf.
+	leeway time.Duration
+}
+
+// authenticator checks the credentials of every request: Bearer JWTs,
+// API keys in X-API-Key, or both, as MINIO_AUTH lists.
+type authenticator struct {
+	jwt  *jwtAuth
+	keys *apiKeyStore
+}
+
+// loadAuth reads MINIO_AUTH, a list of "jwt" and "apikey"; unset or "none"
+// leaves the service open and returns nil. API keys are kept in
+// MINIO_API_KEYS_FILE, and MINIO_API_KEYS_ADMIN_KEY may manage them.
+func loadAuth() (*authenticator, error) {
+	a := &authenticator{}
+	for _, mode := range envList("MINIO_AUTH") {
+		var err error
+		switch mode {
+		case "none":
+		case "jwt":
+			a.jwt, err = loadJWTAuth()
+		case "apikey":
+			file := os.Getenv("MINIO_API_KEYS_FILE")
+			if file == "" {
+				return nil, errors.New("apikey needs MINIO_API_KEYS_FILE")
+			}
+			if a.keys, err = loadAPIKeys(file); err == nil {
+				if admin := os.Getenv("MINIO_API_KEYS_ADMIN_KEY"); admin != "" {
+					a.keys.adminHash = hashAPIKey(admin)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown MINIO_AUTH %q (use none, jwt, apikey or jwt,apikey)", mode)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.jwt == nil && a.keys == nil {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// loadJWTAuth reads the key tokens are verified with:
+// MINIO_AUTH_JWT_SECRET for HS256 (the default MINIO_AUTH_JWT_ALG), or the
+// PEM public key in MINIO_AUTH_JWT_PUBLIC_KEY_FILE for RS256.
+func loadJWTAuth() (*jwtAuth, error) {
+	a := &jwtAuth{
+		alg:      strings.ToUpper(os.Getenv("MINIO_AUTH_JWT_ALG")),
+		issuer:   os.Getenv("MINIO_AUTH_JWT_ISSUER"),
+		audience: os.Getenv("MINIO_AUTH_JWT_AUDIENCE"),
+		leeway:   envDuration("MINIO_AUTH_JWT_LEEWAY", 30*time.Second),
+	}
+	switch a.alg {
+	case "", "HS256":
+		a.alg = "HS256"
+		a.secret = []byte(os.Getenv("MINIO_AUTH_JWT_SECRET"))
+		// RFC 7518 requires an HS256 key at least as long as the hash.
+		if len(a.secret) < sha256.Size {
+			return nil, errors.New("MINIO_AUTH_JWT_SECRET must be at least 32 bytes for HS256")
+		}
+	case "RS256":
+		file := os.Getenv("MINIO_AUTH_JWT_PUBLIC_KEY_FILE")
+		if file == "" {
+			return nil, errors.New("RS256 needs MINIO_AUTH_JWT_PUBLIC_KEY_FILE")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if a.publicKey, err = parseRSAPublicKey(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported MINIO_AUTH_JWT_ALG %q (use HS256 or RS256)", a.alg)
+	}
+	return a, nil
+}
+
+// parseRSAPublicKey reads a PEM "PUBLIC KEY" (PKIX) or "RSA PUBLIC KEY"
+// (PKCS #1) block.
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// tokenClaims are the JWT claims the service checks.
+type tokenClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	// Scope is space-separated, as in RFC 8693; some issuers send scp
+	// instead, as a list or a string.
+	Scope string          `json:"scope"`
+	Scp   json.RawMessage `json:"scp"`
+}
+
+// stringOrList decodes a claim that may be a string or a list of strings.
+func stringOrList(raw json.RawMessage) []string {
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.Fields(s)
+	}
+	return nil
+}
+
+// scopes returns every scope the token grants.
+func (c *tokenClaims) scopes() []string {
+	return append(strings.Fields(c.Scope), stringOrList(c.Scp)...)
+}
+
+// verify checks token's signature, expiry and, when configured, issuer and
+// audience, returning its claims. Tokens must have an exp, and must be
+// signed with the configured algorithm: the alg header is never trusted to
+// pick one.
+func (a *jwtAuth) verify(token string, now time.Time) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if header.Alg != a.alg {
+		return nil, fmt.Errorf("token is signed with %q, not %s", header.Alg, a.alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch a.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return nil, errors.New("invalid token signature")
+		}
+	case "RS256":
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(a.publicKey, crypto.SHA256, digest[:], sig) != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(*claims.ExpiresAt), 0).Add(a.leeway)) {
+		return nil, errors.New("token has expired")
+	}
+	if claims.NotBefore != nil && now.Add(a.leeway).Before(time.Unix(int64(*claims.NotBefore), 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("token issuer %q is not %q", claims.Issuer, a.issuer)
+	}
+	if a.audience != "" && !slices.Contains(stringOrList(claims.Audience), a.audience) {
+		return nil, fmt.Errorf("token is not meant for audience %q", a.audience)
+	}
+	return &claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type claimsKey struct{}
+
+// requireScope answers 403 and returns false when the request's token
+// lacks scope. Requests without claims, because auth is off or the route is
+// public, pass.
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	claims, ok := r.Context().Value(claimsKey{}).(*tokenClaims)
+	if !ok || slices.Contains(claims.scopes(), scope) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+	http.Error(w, "Token lacks the "+scope+" scope", http.StatusForbidden)
+	return false
+}
+
+// credentials returns the claims of the API key or Bearer token r carries.
+// A request with neither gets an error with an empty scheme.
+func (a *authenticator) credentials(r *http.Request) (claims *tokenClaims, scheme string, err error) {
+	if key := r.Header.Get("X-API-Key"); key != "" && a.keys != nil {
+		claims, ok := a.keys.authenticate(key)
+		if !ok {
+			return nil, "ApiKey", errors.New("unknown API key")
+		}
+		return claims, "ApiKey", nil
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" || a.jwt == nil {
+		return nil, "", errors.New("no credentials")
+	}
+	claims, err = a.jwt.verify(strings.TrimSpace(token), time.Now())
+	return claims, "Bearer", err
+}
+
+// wrap returns next behind credential checks: every request but CORS
+// preflights and public routes needs a valid token or API key with the
+// route's scope. Its claims go in the request context, so handlers can ask
+// for more. A nil authenticator lets everything through.
+func (a *authenticator) wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routePath(r.URL.Path)
+		if r.Method == http.MethodOptions || publicRoute(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, scheme, err := a.credentials(r)
+		switch {
+		case scheme == "":
+			w.Header().Set("WWW-Authenticate", a.challenge())
+			http.Error(w, "Credentials required: "+a.challenge(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			logger(r.Context()).Info("Rejected credentials", "scheme", scheme, "method", r.Method, "path", r.URL.Path, "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid credentials: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims))
+		if !requireScope(w, r, routeScope(route, r.Method)) {
+			return
+		}
+		if movePosts[route] && !requireScope(w, r, scopeWrite) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// challenge describes the credentials a request may carry.
+func (a *authenticator) challenge() string {
+	switch {
+	case a.jwt != nil && a.keys != nil:
+		return "Bearer, ApiKey"
+	case a.keys != nil:
+		return "ApiKey"
+	}
+	return "Bearer"
+}
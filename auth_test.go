@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// signToken builds a JWT with the given claims, signed by sign over the
+// header and claims.
+func signToken(t *testing.T, alg string, claims map[string]any, sign func([]byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(data []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+}
+
+func TestJWTVerify(t *testing.T) {
+	a := &jwtAuth{alg: "HS256", secret: []byte(testJWTSecret), issuer: "https://id.example.com", audience: "files", leeway: time.Minute}
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{"sub": "svc", "iss": "https://id.example.com", "aud": []string{"files", "other"}, "exp": now.Add(time.Hour).Unix(), "scope": "files:read files:write"}
+	}
+	claims, err := a.verify(signToken(t, "HS256", valid(), hs256(testJWTSecret)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(claims.scopes(), " "); got != "files:read files:write" || claims.Subject != "svc" {
+		t.Errorf("claims = %+v, scopes %q", claims, got)
+	}
+
+	scp := valid()
+	delete(scp, "scope")
+	scp["scp"] = []string{"files:admin"}
+	if claims, err := a.verify(signToken(t, "HS256", scp, hs256(testJWTSecret)), now); err != nil || strings.Join(claims.scopes(), " ") != "files:admin" {
+		t.Errorf("scp list: %+v, %v", claims, err)
+	}
+
+	for name, tc := range map[string]struct {
+		alg    string
+		change func(map[string]any)
+		sign   func([]byte) []byte
+	}{
+		"wrong secret":      {"HS256", func(map[string]any) {}, hs256("another secret that is long enough")},
+		"alg none":          {"none", func(map[string]any) {}, func([]byte) []byte { return nil }},
+		"expired":           {"HS256", func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, hs256(testJWTSecret)},
+		"no expiry":         {"HS256", func(c map[string]any) { delete(c, "exp") }, hs256(testJWTSecret)},
+		"not yet valid":     {"HS256", func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() }, hs256(testJWTSecret)},
+		"other issuer":      {"HS256", func(c map[string]any) { c["iss"] = "https://evil.example.com" }, hs256(testJWTSecret)},
+		"other audience":    {"HS256", func(c map[string]any) { c["aud"] = "billing" }, hs256(testJWTSecret)},
+		"RS256 header only": {"RS256", func(map[string]any) {}, hs256(testJWTSecret)},
+	} {
+		c := valid()
+		tc.change(c)
+		if _, err := a.verify(signToken(t, tc.alg, c, tc.sign), now); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+	// Within the leeway, a just-expired token still passes.
+	c := valid()
+	c["exp"] = now.Add(-30 * time.Second).Unix()
+	if _, err := a.verify(signToken(t, "HS256", c, hs256(testJWTSecret)), now); err != nil {
+		t.Errorf("token within leeway: %v", err)
+	}
+}
+
+func TestJWTVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	file := filepath.Join(t.TempDir(), "jwt.pem")
+	os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	t.Setenv("MINIO_AUTH", "jwt")
+	t.Setenv("MINIO_AUTH_JWT_ALG", "RS256")
+	t.Setenv("MINIO_AUTH_JWT_PUBLIC_KEY_FILE", file)
+	a, err := loadJWTAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs256 := func(data []byte) []byte {
+		digest := sha256.Sum256(data)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	claims := map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": "files:read"}
+	if _, err := a.verify(signToken(t, "RS256", claims, rs256), time.Now()); err != nil {
+		t.Errorf("RS256 token: %v", err)
+	}
+	// An HS256 token keyed with the public key must not pass as RS256.
+	pemKey, _ := os.ReadFile(file)
+	if _, err := a.verify(signToken(t, "HS256", claims, hs256(string(pemKey))), time.Now()); err == nil {
+		t.Error("HS256 token signed with the public key was accepted")
+	}
+}
+
+func TestLoadAuth(t *testing.T) {
+	for _, v := range []string{"", "none"} {
+		t.Setenv("MINIO_AUTH", v)
+		if a, err := loadAuth(); a != nil || err != nil {
+			t.Errorf("MINIO_AUTH=%q: %v, %v; want open", v, a, err)
+		}
+	}
+	t.Setenv("MINIO_AUTH", "jwt")
+	t.Setenv("MINIO_AUTH_JWT_SECRET", "short")
+	if _, err := loadAuth(); err == nil {
+		t.Error("short HS256 secret accepted")
+	}
+	t.Setenv("MINIO_AUTH_JWT_SECRET", testJWTSecret)
+	t.Setenv("MINIO_AUTH", "jwt,apikey")
+	t.Setenv("MINIO_API_KEYS_FILE", "")
+	if _, err := loadAuth(); err == nil {
+		t.Error("apikey without MINIO_API_KEYS_FILE accepted")
+	}
+	t.Setenv("MINIO_API_KEYS_FILE", filepath.Join(t.TempDir(), "keys.json"))
+	if a, err := loadAuth(); err != nil || a.jwt == nil || a.keys == nil || a.challenge() != "Bearer, ApiKey" {
+		t.Errorf("jwt,apikey = %+v, %v", a, err)
+	}
+	t.Setenv("MINIO_AUTH", "basic")
+	if _, err := loadAuth(); err == nil {
+		t.Error("unknown MINIO_AUTH accepted")
+	}
+}
+
+func TestAuthMiddlewareScopes(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.auth = &authenticator{jwt: &jwtAuth{alg: "HS256", secret: []byte(testJWTSecret)}}
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	token := func(scope string) string {
+		return signToken(t, "HS256", map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": scope}, hs256(testJWTSecret))
+	}
+	reader, writer, admin := token("files:read"), token("files:read files:write"), token("files:admin")
+	deleter := token("files:delete")
+
+	for _, tc := range []struct {
+		method, target, token string
+		want                  int
+	}{
+		{http.MethodGet, "/list", "", http.StatusUnauthorized},
+		{http.MethodGet, "/list", "not.a.token", http.StatusUnauthorized},
+		{http.MethodGet, "/list", reader, http.StatusOK},
+		{http.MethodGet, "/get-download-link/a.txt", reader, http.StatusOK},
+		{http.MethodDelete, "/delete/a.txt", reader, http.StatusForbidden},
+		// GETs that write ask for files:write through h.writes.
+		{http.MethodGet, "/get-upload-link/b.txt?size=1", reader, http.StatusForbidden},
+		{http.MethodGet, "/get-upload-link/b.txt?size=1", writer, http.StatusOK},
+		{http.MethodPost, "/verify", reader, http.StatusBadRequest},
+		{http.MethodGet, "/admin/read-only", writer, http.StatusForbidden},
+		{http.MethodGet, "/admin/read-only", admin, http.StatusOK},
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+		{http.MethodOptions, "/list", "", http.StatusNoContent},
+		{http.MethodDelete, "/delete/a.txt", writer, http.StatusForbidden},
+		{http.MethodDelete, "/delete/a.txt", deleter, http.StatusOK},
+		{http.MethodPost, "/delete-batch", writer, http.StatusForbidden},
+		{http.MethodPost, "/delete-batch", deleter, http.StatusBadRequest},
+		{http.MethodPost, "/move", writer, http.StatusForbidden},
+		{http.MethodPost, "/move", deleter, http.StatusForbidden},
+		{http.MethodPost, "/move", token("files:write files:delete"), http.StatusBadRequest},
+		// Abandoning a tus upload deletes no file; it gets as far as the
+		// missing Tus-Resumable header.
+		{http.MethodDelete, "/tus/0123456789abcdef0123456789abcdef", writer, http.StatusPreconditionFailed},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := serve(h, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.target, rec.Code, tc.want, rec.Body)
+		}
+		if rec.Code == http.StatusForbidden && !strings.Contains(rec.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+			t.Errorf("%s %s: WWW-Authenticate = %q", tc.method, tc.target, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+// A copy-by-tag that moves deletes its sources, so it asks for files:delete
+// once the body says so.
+func TestCopyByTagMoveScope(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.auth = &authenticator{jwt: &jwtAuth{alg: "HS256", secret: []byte(testJWTSecret)}}
+	token := func(scope string) string {
+		return signToken(t, "HS256", map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": scope}, hs256(testJWTSecret))
+	}
+	for _, tc := range []struct {
+		scope, body string
+		want        int
+	}{
+		{"files:write", `{"tags": {"a": "b"}, "dest_prefix": "cold/"}`, http.StatusOK},
+		{"files:write", `{"tags": {"a": "b"}, "dest_prefix": "cold/", "move": true}`, http.StatusForbidden},
+		{"files:write files:delete", `{"tags": {"a": "b"}, "dest_prefix": "cold/", "move": true}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/copy-by-tag", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer "+token(tc.scope))
+		if rec := serve(h, req); rec.Code != tc.want {
+			t.Errorf("%s with %q: status %d, want %d: %s", tc.body, tc.scope, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestRouteScope(t *testing.T) {
+	for _, tc := range []struct {
+		route, method, want string
+	}{
+		{"/list", http.MethodGet, scopeRead},
+		{"/verify", http.MethodPost, scopeRead},
+		{"/upload", http.MethodPost, scopeWrite},
+		{"/copy", http.MethodPost, scopeWrite},
+		{"/copy-by-tag", http.MethodPost, scopeWrite},
+		{"/delete/a.txt", http.MethodDelete, scopeDelete},
+		{"/delete-batch", http.MethodPost, scopeDelete},
+		{"/move", http.MethodPost, scopeDelete},
+		{"/rename", http.MethodPost, scopeDelete},
+		{"/organize", http.MethodPost, scopeDelete},
+		{"/swap", http.MethodPost, scopeDelete},
+		{"/tus/abc", http.MethodDelete, scopeWrite},
+		{"/admin/read-only", http.MethodGet, scopeAdmin},
+	} {
+		if got := routeScope(tc.route, tc.method); got != tc.want {
+			t.Errorf("routeScope(%q, %s) = %q, want %q", tc.route, tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestRoutePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"/list":                     "/list",
+		"/tenants/acme/admin/scrub": "/admin/scrub",
+		"/b/media/upload":           "/upload",
+		"/tenants/acme/b/media/s/x": "/s/x",
+	} {
+		if got := routePath(in); got != want {
+			t.Errorf("routePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// throttleCodes are the S3 error codes MinIO uses to shed load.
+var throttleCodes = map[string]bool{
+	"SlowDown":             true,
+	"SlowDownRead":         true,
+	"SlowDownWrite":        true,
+	"ServiceUnavailable":   true,
+	"TooManyRequests":      true,
+	"RequestLimitExceeded": true,
+}
+
+// throttled reports whether err means the backend asked us to back off.
+func throttled(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return throttleCodes[resp.Code] ||
+		resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusTooManyRequests
+}
+
+// backpressure tracks how hard MinIO is pushing back. Its transport waits
+// out any Retry-After on a throttled response before minio-go retries the
+// request, and retryAfter tells our own clients how long to stay away once
+// the retries are exhausted: the backend's last Retry-After while it is
+// still in the future, otherwise an exponential backoff over the
+// consecutive throttled responses. A nil *backpressure suggests
+// defaultRetryAfter.
+type backpressure struct {
+	base    time.Duration
+	maxWait time.Duration
+
+	mu      sync.Mutex
+	until   time.Time
+	strikes int
+}
+
+// defaultRetryAfter is suggested to clients when no backpressure is tracked.
+const defaultRetryAfter = time.Second
+
+func newBackpressure(base, maxWait time.Duration) *backpressure {
+	return &backpressure{base: base, maxWait: maxWait}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// wrap returns a transport that records throttled responses from next and
+// sleeps for their Retry-After, capped at maxWait.
+func (b *backpressure) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		if resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusTooManyRequests {
+			if resp.StatusCode < 500 {
+				b.recover()
+			}
+			return resp, nil
+		}
+		wait := b.throttle(parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-req.Context().Done():
+			}
+		}
+		return resp, nil
+	})
+}
+
+// throttle records a throttled response carrying retryAfter (0 if none) and
+// returns how long to wait before the request may be retried.
+func (b *backpressure) throttle(retryAfter time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strikes++
+	if retryAfter <= 0 {
+		return 0
+	}
+	b.until = time.Now().Add(retryAfter)
+	return min(retryAfter, b.maxWait)
+}
+
+func (b *backpressure) recover() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strikes = 0
+	b.until = time.Time{}
+}
+
+// retryAfter is how long a client should wait before retrying a request
+// that failed because the backend is throttling us.
+func (b *backpressure) retryAfter() time.Duration {
+	if b == nil {
+		return defaultRetryAfter
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d := time.Until(b.until); d > 0 {
+		return d
+	}
+	shift := min(max(b.strikes-1, 0), 30)
+	return min(b.base<<shift, max(b.maxWait, b.base))
+}
+
+// parseRetryAfter reads a Retry-After header in either delta-seconds or
+// HTTP-date form; it returns 0 if the header is missing or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// storeFailed answers a failed store call: 503 with a Retry-After when
+// MinIO is throttling us or the circuit breaker has opened, so clients back
+// off too, or 500 with message.
+func (h *MinioHandler) storeFailed(w http.ResponseWriter, message string, err error) {
+	if !h.breaker.allow() {
+		h.breaker.reject(w)
+		return
+	}
+	if throttled(err) {
+		secs := int64(math.Ceil(h.backpressure.retryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(max(secs, 1), 10))
+		http.Error(w, "Storage backend is busy; retry later", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
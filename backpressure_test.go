@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"-3":                            0,
+		"soon":                          0,
+		"Wed, 01 May 2024 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 May 2024 11:00:00 GMT": 0,
+	} {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestBackpressureRetryAfter(t *testing.T) {
+	b := newBackpressure(time.Second, 8*time.Second)
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		b.throttle(0)
+		if got := b.retryAfter(); got != want {
+			t.Errorf("after %d strikes retryAfter = %v, want %v", i+1, got, want)
+		}
+	}
+	// The backend's own hint wins while it lasts.
+	if wait := b.throttle(time.Minute); wait != 8*time.Second {
+		t.Errorf("wait = %v, want the 8s cap", wait)
+	}
+	if got := b.retryAfter(); got < 59*time.Second {
+		t.Errorf("retryAfter = %v, want about a minute", got)
+	}
+	b.recover()
+	if got := b.retryAfter(); got != time.Second {
+		t.Errorf("after recovery retryAfter = %v, want 1s", got)
+	}
+}
+
+func TestBackpressureTransportWaits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	b := newBackpressure(time.Second, 50*time.Millisecond)
+	client := &http.Client{Transport: b.wrap(http.DefaultTransport)}
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %v, want the capped 50ms wait", elapsed)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want the backend's 503 passed through", resp.StatusCode)
+	}
+}
+
+// slowStore rejects every upload the way an overloaded MinIO does.
+type slowStore struct{ ObjectStore }
+
+func (slowStore) PutObject(context.Context, string, string, io.Reader, int64, minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return minio.UploadInfo{}, minio.ErrorResponse{Code: "SlowDown", Message: "Please reduce your request rate.", StatusCode: http.StatusServiceUnavailable}
+}
+
+func TestUploadThrottledReturnsRetryAfter(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.store = slowStore{store}
+	h.backpressure = newBackpressure(2*time.Second, time.Minute)
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/a.txt", strings.NewReader("a")))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Breaker states, as reported on /healthz and /debug/vars.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// breakerProbeTimeout bounds the request that checks whether MinIO is back.
+const breakerProbeTimeout = 5 * time.Second
+
+// Breaker state and trip count, published on /debug/vars.
+var (
+	breakerStateVar = expvar.NewString("breaker_state")
+	breakerTrips    = expvar.NewInt("breaker_trips")
+)
+
+// breakerExempt are the paths still served while the breaker is open; none
+// of them need MinIO.
+var breakerExempt = []string{"/healthz", "/debug/vars", "/metrics", "/admin/read-only", "/jobs/"}
+
+// breaker is a circuit breaker in front of MinIO, so an outage such as a
+// maintenance window is answered with 503 and a Retry-After instead of 500s
+// that clients retry straight away. Its transport counts consecutive failed
+// backend requests (transport errors and 5xx responses); after threshold of
+// them the breaker opens and requests are turned away for cooldown without
+// reaching MinIO. When the cooldown ends the breaker is half-open while
+// probe checks the backend: success closes it, failure opens it for another
+// cooldown. A nil *breaker is always closed.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	// probe checks that MinIO is serving requests; nil closes the breaker
+	// when the cooldown ends.
+	probe func(context.Context) error
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	until    time.Time // end of the current cooldown
+}
+
+// newBreaker returns a breaker that opens after threshold consecutive
+// failures, or nil when threshold is 0 and the breaker is disabled.
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	breakerStateVar.Set(breakerClosed)
+	return &breaker{threshold: threshold, cooldown: cooldown, state: breakerClosed}
+}
+
+// wrap returns a transport that records the outcome of every request to
+// MinIO made through next.
+func (b *breaker) wrap(next http.RoundTripper) http.RoundTripper {
+	if b == nil {
+		return next
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			// The caller gave up, which says nothing about the backend.
+		case err != nil || resp.StatusCode >= 500:
+			b.failure()
+		default:
+			b.success()
+		}
+		return resp, err
+	})
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerClosed {
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		slog.Error("MinIO failed too many requests in a row; opening the circuit breaker", "failures", b.failures, "cooldown", b.cooldown)
+		b.trip()
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerClosed {
+		b.failures = 0
+	}
+}
+
+// trip opens the breaker for a cooldown and schedules the probe that ends
+// it. b.mu must be held.
+func (b *breaker) trip() {
+	b.state, b.failures = breakerOpen, 0
+	b.until = time.Now().Add(b.cooldown)
+	breakerStateVar.Set(breakerOpen)
+	breakerTrips.Add(1)
+	time.AfterFunc(b.cooldown, b.halfOpen)
+}
+
+// halfOpen probes MinIO after a cooldown, closing the breaker if it answers
+// and opening it again if not. Requests are still turned away meanwhile.
+func (b *breaker) halfOpen() {
+	b.mu.Lock()
+	b.state = breakerHalfOpen
+	breakerStateVar.Set(breakerHalfOpen)
+	b.mu.Unlock()
+
+	var err error
+	if b.probe != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), breakerProbeTimeout)
+		err = b.probe(ctx)
+		cancel()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		slog.Warn("MinIO is still unavailable; keeping the circuit breaker open", "cooldown", b.cooldown, "err", err)
+		b.trip()
+		return
+	}
+	slog.Info("MinIO is reachable again; closing the circuit breaker")
+	b.state, b.failures = breakerClosed, 0
+	breakerStateVar.Set(breakerClosed)
+}
+
+// allow reports whether requests may reach MinIO.
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerClosed
+}
+
+// reject answers 503 with a Retry-After covering the rest of the cooldown.
+func (b *breaker) reject(w http.ResponseWriter) {
+	b.mu.Lock()
+	wait := max(time.Until(b.until), time.Second)
+	b.mu.Unlock()
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+	http.Error(w, "Storage backend is unavailable; retry later", http.StatusServiceUnavailable)
+}
+
+// breakerStatus is the breaker's state on /healthz.
+type breakerStatus struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// RetryAfter is the time left in the cooldown, in seconds.
+	RetryAfter int64 `json:"retry_after,omitempty"`
+}
+
+func (b *breaker) status() breakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := breakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == breakerOpen {
+		s.RetryAfter = int64(math.Ceil(max(time.Until(b.until), 0).Seconds()))
+	}
+	return s
+}
+
+// gate turns requests away while the breaker is open or half-open, except
+// for breakerExempt paths.
+func (b *breaker) gate(next http.Handler) http.Handler {
+	if b == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !b.allow() && !breakerExempted(r.URL.Path) {
+			b.reject(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func breakerExempted(p string) bool {
+	for _, exempt := range breakerExempt {
+		if p == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(p, exempt)) {
+			return true
+		}
+	}
+	return false
+}
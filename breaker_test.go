@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	h, _ := newTestHandler(t)
+	b := newBreaker(2, 20*time.Millisecond)
+	var probes atomic.Int32
+	b.probe = func(context.Context) error {
+		if probes.Add(1) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	h.breaker = b
+
+	failing := b.wrap(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	}))
+	for range 2 {
+		if _, err := failing.RoundTrip(httptest.NewRequest(http.MethodGet, "http://minio/", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("list while open = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var health struct {
+		Breaker breakerStatus `json:"breaker"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/healthz", nil)), &health)
+	if health.Breaker.State == breakerClosed {
+		t.Errorf("healthz breaker = %+v, want open", health.Breaker)
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); !b.allow() && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+	}
+	if !b.allow() {
+		t.Fatal("breaker did not close after a successful probe")
+	}
+	if n := probes.Load(); n != 2 {
+		t.Errorf("probes = %d, want 2 (one failed, one succeeded)", n)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)); rec.Code != http.StatusOK {
+		t.Errorf("list after recovery = %d", rec.Code)
+	}
+}
+
+func TestBreakerIgnoresCanceledRequests(t *testing.T) {
+	b := newBreaker(1, time.Hour)
+	transport := b.wrap(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, r.Context().Err()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://minio/", nil).WithContext(ctx))
+	if !b.allow() {
+		t.Error("a request the caller canceled opened the breaker")
+	}
+}
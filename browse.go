@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type browseFolder struct {
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+	// Usage is the folder's cached totals with ?stats=true. Pending is set
+	// instead while they are computed in the background.
+	Usage   *prefixUsage `json:"usage,omitempty"`
+	Pending bool         `json:"pending,omitempty"`
+}
+
+type browseFile struct {
+	Key          string    `json:"key"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type browseResponse struct {
+	Prefix string `json:"prefix"`
+	// Parent is the folder above Prefix, "" for the top of the bucket.
+	Parent    string         `json:"parent"`
+	Folders   []browseFolder `json:"folders"`
+	Files     []browseFile   `json:"files"`
+	Truncated bool           `json:"truncated"`
+	// Next is passed back as ?after= to continue a truncated listing.
+	Next string `json:"next,omitempty"`
+}
+
+// browseHandler lists one folder of the bucket (GET /browse?prefix=), with
+// "/" as the delimiter: the folders directly under prefix and its files,
+// separately. Pages hold MINIO_LIST_MAX_KEYS entries of either kind, or
+// fewer with ?max-keys=.
+//
+// Counting a folder's objects means listing everything beneath it, so
+// folder totals are only reported with ?stats=true, and then from the
+// usage cache: a folder seen for the first time is marked pending and
+// counted in the background, and a later request returns its totals.
+func (h *MinioHandler) browseHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := strings.TrimPrefix(q.Get("prefix"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	maxKeys := h.listLimits.maxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "max-keys must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if maxKeys == 0 || n < maxKeys {
+			maxKeys = n
+		}
+	}
+	stats := q.Get("stats") == "true" && h.usage != nil
+	after := q.Get("after")
+
+	resp := browseResponse{Prefix: prefix, Folders: []browseFolder{}, Files: []browseFile{}}
+	if prefix != "" {
+		if parent := path.Dir(strings.TrimSuffix(prefix, "/")); parent != "." {
+			resp.Parent = parent + "/"
+		}
+	}
+	entries, last, lastFolder := 0, "", false
+	for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: prefix, StartAfter: after}) {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing folder", "prefix", prefix, "err", object.Err)
+			h.storeFailed(w, "Failed to list files", object.Err)
+			return
+		}
+		// The folder's own placeholder object isn't an entry of it.
+		if object.Key == prefix || h.hiddenKey(object.Key) {
+			continue
+		}
+		if maxKeys > 0 && entries == maxKeys {
+			resp.Truncated = true
+			break
+		}
+		entries++
+		name := strings.TrimPrefix(object.Key, prefix)
+		last, lastFolder = object.Key, strings.HasSuffix(object.Key, "/")
+		if !lastFolder {
+			resp.Files = append(resp.Files, browseFile{Key: object.Key, Name: name, Size: object.Size, ETag: object.ETag, LastModified: object.LastModified})
+			continue
+		}
+		folder := browseFolder{Prefix: object.Key, Name: strings.TrimSuffix(name, "/")}
+		if stats {
+			if u, ok := h.cachedUsage(object.Key); ok {
+				folder.Usage = &u
+			} else {
+				folder.Pending = true
+			}
+		}
+		resp.Folders = append(resp.Folders, folder)
+	}
+	if resp.Truncated {
+		resp.Next = cursorAfter(last, lastFolder)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBrowseHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "photos/", nil, "application/x-directory")
+	store.put(testBucket, "photos/cover.jpg", []byte("jpeg"), "image/jpeg")
+	store.put(testBucket, "photos/2023/a.jpg", []byte("a"), "image/jpeg")
+	store.put(testBucket, "photos/2024/b.jpg", []byte("bb"), "image/jpeg")
+	store.put(testBucket, "photos/2024/trip/c.jpg", []byte("ccc"), "image/jpeg")
+	store.put(testBucket, "other.txt", []byte("x"), "text/plain")
+
+	var got browseResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos", nil)), &got)
+	if got.Prefix != "photos/" || got.Parent != "" {
+		t.Errorf("prefix %q, parent %q", got.Prefix, got.Parent)
+	}
+	if len(got.Folders) != 2 || got.Folders[0].Name != "2023" || got.Folders[1].Prefix != "photos/2024/" || got.Folders[1].Pending {
+		t.Errorf("folders = %+v", got.Folders)
+	}
+	if len(got.Files) != 1 || got.Files[0].Name != "cover.jpg" || got.Files[0].Size != 4 {
+		t.Errorf("files = %+v", got.Files)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos/2024/", nil)), &got)
+	if got.Parent != "photos/" || len(got.Folders) != 1 || got.Folders[0].Name != "trip" {
+		t.Errorf("photos/2024/: %+v", got)
+	}
+
+	// Pages count folders and files alike.
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos/&max-keys=2", nil)), &got)
+	if !got.Truncated || len(got.Folders) != 2 || len(got.Files) != 0 {
+		t.Fatalf("first page = %+v", got)
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos/&max-keys=2&after="+got.Next, nil)), &got)
+	if got.Truncated || len(got.Files) != 1 || len(got.Folders) != 0 {
+		t.Errorf("second page = %+v", got)
+	}
+}
+
+func TestBrowseFolderStats(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.usage = newUsageCache(time.Hour)
+	store.put(testBucket, "photos/2024/b.jpg", []byte("bb"), "image/jpeg")
+	store.put(testBucket, "photos/2024/trip/c.jpg", []byte("ccc"), "image/jpeg")
+
+	var got browseResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos/&stats=true", nil)), &got)
+	if len(got.Folders) != 1 || !got.Folders[0].Pending || got.Folders[0].Usage != nil {
+		t.Fatalf("first request folders = %+v, want pending", got.Folders)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/browse?prefix=photos/&stats=true", nil)), &got)
+		if u := got.Folders[0].Usage; u != nil {
+			if u.Objects != 2 || u.Bytes != 5 {
+				t.Errorf("usage = %+v, want 2 objects, 5 bytes", u)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("folder stats were never computed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
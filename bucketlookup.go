@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// bucketLookups are the addressing modes MINIO_BUCKET_LOOKUP accepts: path
+// style (endpoint/bucket/key), DNS or virtual-host style
+// (bucket.endpoint/key), or the SDK's guess from the endpoint.
+var bucketLookups = map[string]minio.BucketLookupType{
+	"path": minio.BucketLookupPath,
+	"dns":  minio.BucketLookupDNS,
+	"auto": minio.BucketLookupAuto,
+}
+
+func parseBucketLookup(name string) (minio.BucketLookupType, error) {
+	t, ok := bucketLookups[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown bucket lookup %q (use path, dns or auto)", name)
+	}
+	return t, nil
+}
+
+// bucketLookupRules picks the addressing mode per bucket, for gateways that
+// need path style for some buckets and virtual-host style for others.
+type bucketLookupRules struct {
+	def     minio.BucketLookupType
+	buckets map[string]minio.BucketLookupType
+}
+
+// loadBucketLookupRules reads MINIO_BUCKET_LOOKUP (default path, which the
+// Nginx proxy in front of MinIO needs) and MINIO_BUCKET_LOOKUP_OVERRIDES,
+// a comma-separated list of bucket=mode pairs.
+func loadBucketLookupRules() (bucketLookupRules, error) {
+	rules := bucketLookupRules{def: minio.BucketLookupPath, buckets: map[string]minio.BucketLookupType{}}
+	if v := os.Getenv("MINIO_BUCKET_LOOKUP"); v != "" {
+		t, err := parseBucketLookup(v)
+		if err != nil {
+			return rules, fmt.Errorf("MINIO_BUCKET_LOOKUP: %w", err)
+		}
+		rules.def = t
+	}
+	for _, item := range envList("MINIO_BUCKET_LOOKUP_OVERRIDES") {
+		bucket, mode, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(bucket) == "" {
+			return rules, fmt.Errorf("MINIO_BUCKET_LOOKUP_OVERRIDES: %q is not bucket=mode", item)
+		}
+		t, err := parseBucketLookup(mode)
+		if err != nil {
+			return rules, fmt.Errorf("MINIO_BUCKET_LOOKUP_OVERRIDES: %w", err)
+		}
+		rules.buckets[strings.TrimSpace(bucket)] = t
+	}
+	return rules, nil
+}
+
+// forBucket is a minio.Options.BucketLookupViaURL function.
+func (rules bucketLookupRules) forBucket(_ url.URL, bucket string) minio.BucketLookupType {
+	if t, ok := rules.buckets[bucket]; ok {
+		return t
+	}
+	return rules.def
+}
+
+// bucketLookupName is the MINIO_BUCKET_LOOKUP name of t.
+func bucketLookupName(t minio.BucketLookupType) string {
+	for name, lt := range bucketLookups {
+		if lt == t {
+			return name
+		}
+	}
+	return "auto"
+}
+
+// addressingHint explains errors that usually mean the gateway wants the
+// other addressing mode for bucket, or "" for any other error.
+func addressingHint(err error, bucket string, lookup minio.BucketLookupType) string {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return ""
+	case lookup == minio.BucketLookupDNS && errors.As(err, &dnsErr):
+		return fmt.Sprintf("virtual-host addressing needs DNS for %s; if the gateway expects path-style requests, set %s=path in MINIO_BUCKET_LOOKUP_OVERRIDES", dnsErr.Name, bucket)
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchBucket", "InvalidBucketName", "MethodNotAllowed", "InvalidRequest", "InvalidURI":
+		other := "dns"
+		if lookup == minio.BucketLookupDNS {
+			other = "path"
+		}
+		return fmt.Sprintf("bucket '%s' uses %s addressing; if the gateway expects %s, set %s=%s in MINIO_BUCKET_LOOKUP_OVERRIDES", bucket, bucketLookupName(lookup), other, bucket, other)
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestBucketLookupRules(t *testing.T) {
+	t.Setenv("MINIO_BUCKET_LOOKUP", "")
+	t.Setenv("MINIO_BUCKET_LOOKUP_OVERRIDES", "vhost-bucket=dns, legacy = path")
+	rules, err := loadBucketLookupRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for bucket, want := range map[string]minio.BucketLookupType{
+		"vhost-bucket": minio.BucketLookupDNS,
+		"legacy":       minio.BucketLookupPath,
+		"other":        minio.BucketLookupPath,
+	} {
+		if got := rules.forBucket(url.URL{}, bucket); got != want {
+			t.Errorf("forBucket(%q) = %v, want %v", bucket, got, want)
+		}
+	}
+
+	t.Setenv("MINIO_BUCKET_LOOKUP", "auto")
+	if rules, _ := loadBucketLookupRules(); rules.forBucket(url.URL{}, "other") != minio.BucketLookupAuto {
+		t.Error("MINIO_BUCKET_LOOKUP=auto was not the default")
+	}
+
+	for _, bad := range []string{"b=virtual", "nobucket", "=dns"} {
+		t.Setenv("MINIO_BUCKET_LOOKUP_OVERRIDES", bad)
+		if _, err := loadBucketLookupRules(); err == nil {
+			t.Errorf("MINIO_BUCKET_LOOKUP_OVERRIDES=%q was accepted", bad)
+		}
+	}
+}
+
+func TestAddressingHint(t *testing.T) {
+	dnsErr := &net.DNSError{Name: "files.gateway.local", Err: "no such host"}
+	if hint := addressingHint(&url.Error{Op: "Get", Err: dnsErr}, "files", minio.BucketLookupDNS); !strings.Contains(hint, "files=path") {
+		t.Errorf("DNS failure hint = %q", hint)
+	}
+	noBucket := minio.ErrorResponse{Code: "NoSuchBucket"}
+	if hint := addressingHint(noBucket, "files", minio.BucketLookupPath); !strings.Contains(hint, "files=dns") {
+		t.Errorf("path-style NoSuchBucket hint = %q", hint)
+	}
+	if hint := addressingHint(minio.ErrorResponse{Code: "AccessDenied"}, "files", minio.BucketLookupPath); hint != "" {
+		t.Errorf("unrelated error got hint %q", hint)
+	}
+}
+
+func TestTenantMissingBucket(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.tenants = newTenantRouter("X-Tenant", map[string]tenantConfig{"acme": {Bucket: "gone"}}, func(tenantConfig) (ObjectStore, error) {
+		return newFakeStore("acme-files"), nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	req.Header.Set("X-Tenant", "acme")
+	if rec := serve(h, req); rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+)
+
+// bucketPathPrefix selects a bucket by path: /b/logs/list is /list on
+// bucket "logs".
+const bucketPathPrefix = "/b/"
+
+// errNoBucket is returned for an allowed bucket that doesn't exist and
+// can't be created.
+var errNoBucket = errors.New("bucket does not exist")
+
+// bucketRouter serves /b/{bucket}/ paths from a view of the handler on that
+// bucket, for the buckets MINIO_BUCKETS allows. Buckets that don't exist
+// yet are created on first use unless autoCreate is off. Each bucket's
+// handler is kept, so its caches and share links last across requests.
+type bucketRouter struct {
+	// main is MINIO_BUCKET, served without the prefix.
+	main string
+	// allowed are path.Match patterns of bucket names, such as "logs-*".
+	allowed    []string
+	autoCreate bool
+
+	mu     sync.Mutex
+	routes map[string]http.Handler
+}
+
+// newBucketRouter validates the MINIO_BUCKETS patterns.
+func newBucketRouter(main string, allowed []string, autoCreate bool) (*bucketRouter, error) {
+	for _, p := range allowed {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad bucket pattern %q: %w", p, err)
+		}
+	}
+	return &bucketRouter{main: main, allowed: allowed, autoCreate: autoCreate, routes: make(map[string]http.Handler)}, nil
+}
+
+// allows reports whether bucket matches one of the allowed patterns.
+func (b *bucketRouter) allows(bucket string) bool {
+	for _, p := range b.allowed {
+		if ok, _ := path.Match(p, bucket); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handler returns the routes of bucket, making sure on first use that it
+// exists: a missing bucket is created, or errNoBucket returned when it
+// can't be (autoCreate is off or the service is read-only).
+func (b *bucketRouter) handler(ctx context.Context, base *MinioHandler, bucket string) (http.Handler, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h, ok := b.routes[bucket]; ok {
+		return h, nil
+	}
+	exists, err := base.store.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if !b.autoCreate || base.readOnly.Load() {
+			return nil, errNoBucket
+		}
+		err := base.store.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+		if err != nil && minio.ToErrorResponse(err).Code != "BucketAlreadyOwnedByYou" {
+			return nil, err
+		}
+		logger(ctx).Info("Created bucket on first use", "bucket", bucket)
+	}
+	view := base.bucketView(bucket)
+	if base.lifecycle != nil {
+		view.startTusSweeper(base.lifecycle.ctx)
+		view.startTrashPurger(base.lifecycle.ctx)
+	}
+	h := view.routes()
+	b.routes[bucket] = h
+	return h, nil
+}
+
+// wrap returns next with bucket routing in front of it. Requests outside
+// /b/ go to next, as do those naming the main bucket; a nil router routes
+// everything to next.
+func (b *bucketRouter) wrap(base *MinioHandler, next http.Handler) http.Handler {
+	if b == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, bucketPathPrefix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bucket, _, _ := strings.Cut(rest, "/")
+		prefix := bucketPathPrefix + bucket
+		if bucket == base.bucketName {
+			http.StripPrefix(prefix, next).ServeHTTP(w, r)
+			return
+		}
+		if err := s3utils.CheckValidBucketNameStrict(bucket); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid bucket name '%s': %v", bucket, err), http.StatusBadRequest)
+			return
+		}
+		if !b.allows(bucket) {
+			http.Error(w, fmt.Sprintf("Bucket '%s' is not served here", bucket), http.StatusForbidden)
+			return
+		}
+		h, err := b.handler(r.Context(), base, bucket)
+		switch {
+		case errors.Is(err, errNoBucket):
+			if b.autoCreate && base.rejectIfReadOnly(w) {
+				return
+			}
+			http.Error(w, fmt.Sprintf("Bucket '%s' not found", bucket), http.StatusNotFound)
+			return
+		case err != nil:
+			logger(r.Context()).Error("Error opening bucket", "bucket", bucket, "err", err)
+			base.storeFailed(w, "Failed to open bucket", err)
+			return
+		}
+		http.StripPrefix(prefix, h).ServeHTTP(w, r)
+	})
+}
+
+// bucketView returns a copy of h that serves bucket through the same store,
+// with the same settings, links and jobs. Like a tenant handler, it gets
+// fresh caches and share links, and the main bucket's visibility rules and
+// quotas don't apply.
+func (h *MinioHandler) bucketView(bucket string) *MinioHandler {
+	view := h.scopedView(h.store, bucket, h.basePath+bucketPathPrefix+bucket)
+	// Job IDs are unique, so /jobs/{id} finds a bucket's jobs from anywhere.
+	view.jobs = h.jobs
+	return view
+}
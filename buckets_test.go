@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestBucketRouting(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "main.txt", []byte("main"), "text/plain")
+	store.MakeBucket(t.Context(), "logs-web", minio.MakeBucketOptions{})
+	store.put("logs-web", "access.log", []byte("GET /"), "text/plain")
+	var err error
+	if h.buckets, err = newBucketRouter(testBucket, []string{"logs-*", "media"}, true); err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		return serve(h, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	if rec := get("/b/logs-web/stat/access.log"); rec.Code != http.StatusOK {
+		t.Errorf("allowed bucket: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/b/logs-web/stat/main.txt"); rec.Code != http.StatusNotFound {
+		t.Errorf("main bucket object through another bucket: status %d", rec.Code)
+	}
+	if rec := get("/b/" + testBucket + "/stat/main.txt"); rec.Code != http.StatusOK {
+		t.Errorf("main bucket by path: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/stat/main.txt"); rec.Code != http.StatusOK {
+		t.Errorf("no bucket: status %d", rec.Code)
+	}
+	if rec := get("/b/secrets/stat/x"); rec.Code != http.StatusForbidden {
+		t.Errorf("bucket not on the allowlist: status %d", rec.Code)
+	}
+	if rec := get("/b/Bad_Name/stat/x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid bucket name: status %d", rec.Code)
+	}
+	if _, err := newBucketRouter(testBucket, []string{"logs-["}, true); err == nil {
+		t.Error("newBucketRouter accepted a malformed pattern")
+	}
+}
+
+func TestBucketRoutingCreatesBucketsOnFirstUse(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.buckets, _ = newBucketRouter(testBucket, []string{"media"}, true)
+
+	req := newUploadRequest(t, http.MethodPost, "/b/media/upload", "cat.txt", "meow")
+	if rec := serve(h, req); rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+	}
+	if exists, _ := store.BucketExists(t.Context(), "media"); !exists {
+		t.Fatal("bucket was not created")
+	}
+	if _, ok := store.object("media", "cat.txt"); !ok {
+		t.Error("upload didn't land in the routed bucket")
+	}
+	if _, ok := store.object(testBucket, "cat.txt"); ok {
+		t.Error("upload landed in the main bucket")
+	}
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/b/media/list", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "cat.txt") {
+		t.Errorf("list: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBucketRoutingWithoutCreation(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.buckets, _ = newBucketRouter(testBucket, []string{"media"}, false)
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/b/media/list", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing bucket without auto-create: status %d", rec.Code)
+	}
+	if exists, _ := store.BucketExists(t.Context(), "media"); exists {
+		t.Error("bucket was created")
+	}
+
+	h.buckets, _ = newBucketRouter(testBucket, []string{"media"}, true)
+	h.setReadOnly(true)
+	defer h.setReadOnly(false)
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/b/media/list", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("missing bucket while read-only: status %d", rec.Code)
+	}
+	if exists, _ := store.BucketExists(t.Context(), "media"); exists {
+		t.Error("bucket was created while read-only")
+	}
+}
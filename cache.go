@@ -0,0 +1,207 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Cache counters, published on /debug/vars.
+var (
+	cacheHits      = expvar.NewInt("cache_hits")
+	cacheMisses    = expvar.NewInt("cache_misses")
+	cacheEvictions = expvar.NewInt("cache_evictions")
+)
+
+// diskCache is a size-bounded LRU of object bodies stored as files in dir.
+// Entries are keyed by object key and ETag, so a changed object is never
+// served stale: a lookup with a new ETag drops the old entry. A nil
+// *diskCache is valid and caches nothing.
+type diskCache struct {
+	dir          string
+	maxBytes     int64
+	maxItemBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *cacheEntry, most recently used at the front
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	etag string
+	size int64
+	path string
+}
+
+// newDiskCache prepares dir for use, discarding any files left behind by a
+// previous run since the index is kept only in memory.
+func newDiskCache(dir string, maxBytes, maxItemBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	stale, _ := filepath.Glob(filepath.Join(dir, "*.cache"))
+	for _, f := range stale {
+		os.Remove(f)
+	}
+	c := &diskCache{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		maxItemBytes: maxItemBytes,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+	return c, nil
+}
+
+// bytes returns the total size of the cached bodies, published on
+// /debug/vars as cache_bytes.
+func (c *diskCache) bytes() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// cacheable reports whether an object of the given size should be cached.
+func (c *diskCache) cacheable(size int64) bool {
+	return c != nil && size >= 0 && size <= c.maxItemBytes && size <= c.maxBytes
+}
+
+// open returns the cached body for key at etag, if present.
+func (c *diskCache) open(key, etag string) (*os.File, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if e.etag != etag {
+		c.removeLocked(el)
+		return nil, false
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		c.removeLocked(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return f, true
+}
+
+// put stores r as the body of key at etag, evicting least recently used
+// entries to stay under the size bound, and returns the cached file opened
+// for reading.
+func (c *diskCache) put(key, etag string, r io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp(c.dir, "fill-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(key + "\x00" + etag))
+	path := filepath.Join(c.dir, hex.EncodeToString(sum[:])+".cache")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		if old := el.Value.(*cacheEntry); old.path == path {
+			c.size -= old.size
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		} else {
+			c.removeLocked(el)
+		}
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, etag: etag, size: size, path: path})
+	c.size += size
+	for c.size > c.maxBytes && c.lru.Len() > 1 {
+		c.removeLocked(c.lru.Back())
+		cacheEvictions.Add(1)
+	}
+	return f, nil
+}
+
+// invalidate drops any cached body for key.
+func (c *diskCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+}
+
+func (c *diskCache) removeLocked(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	// An open reader keeps its data on Unix even after the file is removed.
+	os.Remove(e.path)
+}
+
+// openObject returns a reader for objectName along with its metadata,
+// serving the body from the disk cache when possible and filling the cache
+// on a miss for objects small enough to keep.
+func (h *MinioHandler) openObject(ctx context.Context, objectName string) (io.ReadCloser, minio.ObjectInfo, error) {
+	info, err := h.statObject(ctx, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, info, err
+	}
+	etag := strings.Trim(info.ETag, `"`)
+	if f, ok := h.cache.open(objectName, etag); ok {
+		cacheHits.Add(1)
+		return f, info, nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	opts.SetMatchETag(etag)
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		return nil, info, err
+	}
+	if !h.cache.cacheable(info.Size) {
+		return obj, info, nil
+	}
+	cacheMisses.Add(1)
+	f, err := h.cache.put(objectName, etag, obj)
+	obj.Close()
+	if err != nil {
+		// Caching is best effort; fall back to streaming from MinIO.
+		logger(ctx).Error("Error caching object", "object", objectName, "err", err)
+		obj, err := h.store.GetObject(ctx, h.bucketName, objectName, opts)
+		if err != nil {
+			return nil, info, err
+		}
+		return obj, info, nil
+	}
+	return f, info, nil
+}
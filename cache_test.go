@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCache(t *testing.T, maxBytes int64) *diskCache {
+	t.Helper()
+	c, err := newDiskCache(t.TempDir(), maxBytes, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// cached returns the body cached for key at etag, or false on a miss.
+func cached(t *testing.T, c *diskCache, key, etag string) (string, bool) {
+	t.Helper()
+	f, ok := c.open(key, etag)
+	if !ok {
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), true
+}
+
+func putCached(t *testing.T, c *diskCache, key, etag, body string) {
+	t.Helper()
+	f, err := c.put(key, etag, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	c := newTestCache(t, 1<<10)
+	f, err := c.put("a.txt", "e1", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "hello" {
+		t.Errorf("put returned %q", data)
+	}
+	if got, ok := cached(t, c, "a.txt", "e1"); !ok || got != "hello" {
+		t.Errorf("open = %q, %v", got, ok)
+	}
+	if c.bytes() != int64(5) {
+		t.Errorf("size = %v, want 5", c.bytes())
+	}
+
+	putCached(t, c, "a.txt", "e1", "hello")
+	if c.bytes() != int64(5) || c.lru.Len() != 1 {
+		t.Errorf("refilling the same entry: size = %v, %d entries", c.bytes(), c.lru.Len())
+	}
+}
+
+func TestDiskCacheETagMismatch(t *testing.T) {
+	c := newTestCache(t, 1<<10)
+	putCached(t, c, "a.txt", "e1", "old")
+
+	if _, ok := cached(t, c, "a.txt", "e2"); ok {
+		t.Fatal("open with another ETag was a hit")
+	}
+	// The stale entry is gone, so even the old ETag misses now.
+	if _, ok := cached(t, c, "a.txt", "e1"); ok {
+		t.Error("stale entry was kept")
+	}
+	if c.bytes() != int64(0) {
+		t.Errorf("size = %v, want 0", c.bytes())
+	}
+	if files, _ := filepath.Glob(filepath.Join(c.dir, "*.cache")); len(files) != 0 {
+		t.Errorf("stale files left: %v", files)
+	}
+}
+
+func TestDiskCacheInvalidate(t *testing.T) {
+	c := newTestCache(t, 1<<10)
+	putCached(t, c, "a.txt", "e1", "a")
+	putCached(t, c, "b.txt", "e1", "b")
+
+	c.invalidate("a.txt")
+	c.invalidate("missing.txt")
+	if _, ok := cached(t, c, "a.txt", "e1"); ok {
+		t.Error("invalidated entry still cached")
+	}
+	if _, ok := cached(t, c, "b.txt", "e1"); !ok {
+		t.Error("other entry was dropped")
+	}
+	if c.bytes() != int64(1) {
+		t.Errorf("size = %v, want 1", c.bytes())
+	}
+
+	var nilCache *diskCache
+	nilCache.invalidate("a.txt")
+	if _, ok := nilCache.open("a.txt", "e1"); ok {
+		t.Error("nil cache had an entry")
+	}
+}
+
+func TestDiskCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(t, 10)
+	putCached(t, c, "a.txt", "e", "aaaa")
+	putCached(t, c, "b.txt", "e", "bbbb")
+	// Reading a makes b the least recently used.
+	cached(t, c, "a.txt", "e")
+	putCached(t, c, "c.txt", "e", "cccc")
+
+	if _, ok := cached(t, c, "b.txt", "e"); ok {
+		t.Error("b.txt was kept; it was the least recently used")
+	}
+	for _, key := range []string{"a.txt", "c.txt"} {
+		if _, ok := cached(t, c, key, "e"); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if c.bytes() != int64(8) {
+		t.Errorf("size = %v, want 8", c.bytes())
+	}
+}
+
+func TestNewDiskCacheDiscardsStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "left-over.cache")
+	if err := os.WriteFile(stale, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newDiskCache(dir, 1<<10, 1<<10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale file kept: %v", err)
+	}
+}
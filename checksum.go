@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// checksumTypes are the x-amz-checksum algorithms an upload may request.
+var checksumTypes = map[string]minio.ChecksumType{
+	"crc32":     minio.ChecksumCRC32,
+	"crc32c":    minio.ChecksumCRC32C,
+	"sha1":      minio.ChecksumSHA1,
+	"sha256":    minio.ChecksumSHA256,
+	"crc64nvme": minio.ChecksumCRC64NVME,
+}
+
+// parseChecksum maps an algorithm name such as "crc32c" to its checksum
+// type. The empty string means no explicit checksum.
+func parseChecksum(name string) (minio.ChecksumType, error) {
+	if name == "" {
+		return minio.ChecksumNone, nil
+	}
+	t, ok := checksumTypes[strings.ToLower(name)]
+	if !ok {
+		return minio.ChecksumNone, fmt.Errorf("unsupported checksum %q (use crc32, crc32c, sha1, sha256, or crc64nvme)", name)
+	}
+	return t, nil
+}
+
+// uploadChecksum returns the checksum an upload should send with its data:
+// the ?checksum= parameter when given, otherwise MINIO_UPLOAD_CHECKSUM.
+func (h *MinioHandler) uploadChecksum(r *http.Request) (minio.ChecksumType, error) {
+	if name := r.URL.Query().Get("checksum"); name != "" {
+		return parseChecksum(name)
+	}
+	return h.checksum, nil
+}
+
+// objectChecksums returns the checksums stored with an object, keyed by
+// their x-amz-checksum-* header name.
+func objectChecksums(info minio.ObjectInfo) map[string]string {
+	return checksumMap(info.ChecksumCRC32, info.ChecksumCRC32C, info.ChecksumSHA1, info.ChecksumSHA256, info.ChecksumCRC64NVME)
+}
+
+// uploadChecksums is objectChecksums for the result of an upload.
+func uploadChecksums(info minio.UploadInfo) map[string]string {
+	return checksumMap(info.ChecksumCRC32, info.ChecksumCRC32C, info.ChecksumSHA1, info.ChecksumSHA256, info.ChecksumCRC64NVME)
+}
+
+func checksumMap(crc32, crc32c, sha1, sha256, crc64nvme string) map[string]string {
+	m := map[string]string{}
+	for t, v := range map[minio.ChecksumType]string{
+		minio.ChecksumCRC32:     crc32,
+		minio.ChecksumCRC32C:    crc32c,
+		minio.ChecksumSHA1:      sha1,
+		minio.ChecksumSHA256:    sha256,
+		minio.ChecksumCRC64NVME: crc64nvme,
+	} {
+		if v != "" {
+			m[t.Key()] = v
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
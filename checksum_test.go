@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestUploadChecksum(t *testing.T) {
+	h, _ := newTestHandler(t)
+	want := minio.ChecksumCRC32C.ChecksumBytes([]byte("hello")).Encoded()
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/hello.txt?checksum=crc32c", strings.NewReader("hello")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got rawUploadResponse
+	decodeJSON(t, rec, &got)
+	if got.Checksums["x-amz-checksum-crc32c"] != want {
+		t.Errorf("checksums = %v, want crc32c %s", got.Checksums, want)
+	}
+
+	head := serve(h, httptest.NewRequest(http.MethodHead, "/get-download-link/hello.txt", nil))
+	if sum := head.Header().Get("x-amz-checksum-crc32c"); sum != want {
+		t.Errorf("HEAD x-amz-checksum-crc32c = %q, want %q", sum, want)
+	}
+}
+
+func TestUploadChecksumDefaultAndInvalid(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.checksum = minio.ChecksumSHA256
+
+	if rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload", "a.txt", "abc")); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "a.txt", minio.StatObjectOptions{})
+	if info.ChecksumSHA256 == "" {
+		t.Error("default checksum was not sent")
+	}
+
+	if rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload?checksum=md4", "b.txt", "abc")); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown algorithm status = %d, want 400", rec.Code)
+	}
+}
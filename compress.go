@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// compressibleTypes are the response types compressResponses gzips: the
+// API's JSON, CSV exports and plain-text messages. Everything else, in
+// particular object bodies and text/event-stream, is sent as written.
+var compressibleTypes = map[string]bool{
+	"application/json": true,
+	"text/csv":         true,
+	"text/plain":       true,
+}
+
+// compressResponses gzips compressible responses of at least minBytes for
+// clients whose Accept-Encoding allows it. Smaller responses are held back
+// only until minBytes is reached or the handler returns or flushes, so
+// streaming handlers keep streaming, compressed. Handlers that send stored
+// object bytes opt out with skipCompression.
+func compressResponses(minBytes int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{
+			ResponseWriter: w,
+			accepts:        acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip"),
+			minBytes:       minBytes,
+		}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// skipCompression stops compressResponses from compressing the response
+// written to w. It must be called before the body is written.
+func skipCompression(w http.ResponseWriter) {
+	for {
+		if gw, ok := w.(*gzipResponseWriter); ok {
+			gw.skip = true
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// gzipResponseWriter decides whether to compress once the handler has
+// written minBytes, flushed or returned, holding back the status and body
+// until then.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	accepts  bool
+	minBytes int
+	skip     bool
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer // non-nil once compressing
+}
+
+// compressible reports whether the response as set up so far may be
+// compressed.
+func (g *gzipResponseWriter) compressible() bool {
+	switch g.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	h := g.Header()
+	if g.skip || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return compressibleTypes[mediaType]
+}
+
+// decide sends the status and anything held back, compressed if compress
+// is set.
+func (g *gzipResponseWriter) decide(compress bool) {
+	g.decided = true
+	h := g.Header()
+	if g.compressible() && !strings.Contains(strings.ToLower(h.Get("Vary")), "accept-encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compress {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		// The compressed body is a different representation.
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	if len(g.buf) > 0 {
+		if g.gz != nil {
+			g.gz.Write(g.buf)
+		} else {
+			g.ResponseWriter.Write(g.buf)
+		}
+		g.buf = nil
+	}
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	switch {
+	case code < http.StatusOK:
+		g.ResponseWriter.WriteHeader(code)
+	case g.decided:
+		if g.gz == nil {
+			g.ResponseWriter.WriteHeader(code)
+		}
+	case g.status == 0:
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.decided {
+		if !g.accepts || !g.compressible() {
+			g.decide(false)
+		} else {
+			g.buf = append(g.buf, p...)
+			if len(g.buf) >= g.minBytes {
+				g.decide(true)
+			}
+			return len(p), nil
+		}
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush sends what has been written so far, compressing a compressible
+// response even below minBytes since more of it is on the way.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(g.accepts && g.compressible())
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// close sends a response still held back, uncompressed as it is under
+// minBytes, and ends a compressed one.
+func (g *gzipResponseWriter) close() {
+	if !g.decided {
+		if g.status == 0 && len(g.buf) == 0 {
+			return
+		}
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// lastByteWriter remembers the last byte written through it.
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.last = p[n-1]
+	}
+	return n, err
+}
+
+// concatHandler streams the objects named in {"objects": [...]} back to
+// back as one NDJSON response, in the order given, adding a newline after
+// any object that doesn't end with one. Objects are streamed one at a time,
+// so memory use doesn't grow with their size; each should hold JSON on a
+// single line (or already be NDJSON).
+//
+// Every object is stat'ed before anything is sent, so a missing one fails
+// the request with 404, or with ?skip_missing=true is left out and named
+// in the X-Skipped-Objects header. An object removed or changed after that
+// can only end the response early.
+func (h *MinioHandler) concatHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Objects []string `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Objects) == 0 {
+		http.Error(w, `Request body must be JSON like {"objects": ["a.json", "b.json"]}`, http.StatusBadRequest)
+		return
+	}
+	if limit := h.concatMaxObjects; limit > 0 && len(req.Objects) > limit {
+		http.Error(w, fmt.Sprintf("At most %d objects can be concatenated per request", limit), http.StatusBadRequest)
+		return
+	}
+	skipMissing := r.URL.Query().Get("skip_missing") == "true"
+
+	var infos []minio.ObjectInfo
+	var skipped []string
+	for _, name := range req.Objects {
+		info, err := h.statObject(r.Context(), name, minio.StatObjectOptions{})
+		if err == nil && h.trash.holds(name) {
+			err = minio.ErrorResponse{Code: "NoSuchKey"}
+		}
+		if err != nil {
+			if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+				logger(r.Context()).Error("Error stating for concat", "object", name, "err", err)
+			}
+			if !skipMissing {
+				http.Error(w, "Object not found: "+name, http.StatusNotFound)
+				return
+			}
+			skipped = append(skipped, url.PathEscape(name))
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	if len(skipped) > 0 {
+		w.Header().Set("X-Skipped-Objects", strings.Join(skipped, ","))
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	out := &lastByteWriter{w: w, last: '\n'}
+	for _, info := range infos {
+		if err := h.copyConcatObject(r, out, info); err != nil {
+			logger(r.Context()).Error("Error streaming for concat", "object", info.Key, "err", err)
+			return
+		}
+	}
+}
+
+// copyConcatObject streams the object described by info to out, followed
+// by a newline unless it already ends with one.
+func (h *MinioHandler) copyConcatObject(r *http.Request, out *lastByteWriter, info minio.ObjectInfo) error {
+	opts := minio.GetObjectOptions{}
+	opts.SetMatchETag(info.ETag)
+	obj, err := h.store.GetObject(r.Context(), h.bucketName, info.Key, opts)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	n, err := io.Copy(out, obj)
+	if err != nil {
+		return err
+	}
+	if n != info.Size {
+		return fmt.Errorf("object changed while streaming: read %d of %d bytes", n, info.Size)
+	}
+	if out.last != '\n' {
+		_, err = out.Write([]byte("\n"))
+	}
+	return err
+}
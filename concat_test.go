@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "reports/a.json", []byte(`{"a":1}`), "application/json")
+	store.put(testBucket, "reports/b.ndjson", []byte("{\"b\":1}\n{\"b\":2}\n"), "application/x-ndjson")
+	store.put(testBucket, "reports/c.json", []byte(`{"c":1}`), "application/json")
+
+	body := `{"objects": ["reports/c.json", "reports/b.ndjson", "reports/missing.json", "reports/a.json"]}`
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/concat?skip_missing=true", strings.NewReader(body)))
+	if want := "{\"c\":1}\n{\"b\":1}\n{\"b\":2}\n{\"a\":1}\n"; rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("concat = %d %q, want %q", rec.Code, rec.Body, want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Header().Get("X-Skipped-Objects"); got != "reports%2Fmissing.json" {
+		t.Errorf("X-Skipped-Objects = %q", got)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/concat", strings.NewReader(body))); rec.Code != http.StatusNotFound || rec.Body.Len() == 0 || strings.Contains(rec.Body.String(), `"c"`) {
+		t.Errorf("strict concat = %d %q, want 404 before any content", rec.Code, rec.Body)
+	}
+
+	h.concatMaxObjects = 2
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/concat", strings.NewReader(body))); rec.Code != http.StatusBadRequest {
+		t.Errorf("over the limit: status = %d, want 400", rec.Code)
+	}
+}
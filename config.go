@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envList reads a comma-separated environment variable into a slice,
+// trimming whitespace and dropping empty entries.
+func envList(name string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// envInt64 reads an integer environment variable, falling back to def when
+// it is unset or malformed.
+func envInt64(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		slog.Warn("Invalid setting; using the default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a time.ParseDuration-style environment variable, falling
+// back to def when it is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("Invalid setting; using the default", "name", name, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
+// S3 multipart limits on the size of a single part.
+const (
+	minPartSize = 5 << 20
+	maxPartSize = 5 << 30
+)
+
+// loadUploadTuning reads MINIO_PART_SIZE (bytes) and MINIO_UPLOAD_THREADS.
+// Zero values leave minio-go's defaults in place.
+func loadUploadTuning() (partSize uint64, threads uint, err error) {
+	size := envInt64("MINIO_PART_SIZE", 0)
+	if size != 0 && (size < minPartSize || size > maxPartSize) {
+		return 0, 0, fmt.Errorf("MINIO_PART_SIZE must be between %d (5MiB) and %d (5GiB) bytes, got %d", minPartSize, maxPartSize, size)
+	}
+	n := envInt64("MINIO_UPLOAD_THREADS", 0)
+	if n < 0 {
+		return 0, 0, fmt.Errorf("MINIO_UPLOAD_THREADS must not be negative, got %d", n)
+	}
+	return uint64(size), uint(n), nil
+}
+
+// byteUnits are the size suffixes parseByteSize accepts, longest first so
+// "GiB" is tried before "B".
+var byteUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// parseByteSize parses a size such as "500MiB", "10GB" or "1048576".
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	factor := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToLower(s), strings.ToLower(u.suffix)) {
+			s, factor = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * factor, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// genericContentTypes are stored types that say nothing about the content,
+// as set by uploads that didn't send one or by backup tools.
+var genericContentTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+}
+
+// loadContentTypeOverrides reads MINIO_CONTENT_TYPES, a comma-separated list
+// of extension=type pairs such as ".md=text/markdown", consulted before the
+// system MIME table when a served type is inferred from a key.
+func loadContentTypeOverrides() (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, pair := range envList("MINIO_CONTENT_TYPES") {
+		ext, contentType, ok := strings.Cut(pair, "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !ok || !strings.HasPrefix(ext, ".") {
+			return nil, fmt.Errorf("%q must look like .md=text/markdown", pair)
+		}
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("%q: invalid content type: %v", pair, err)
+		}
+		overrides[ext] = strings.TrimSpace(contentType)
+	}
+	return overrides, nil
+}
+
+// inferredType returns the content type to serve objectName as in place of
+// a generic stored type, going by its extension (MINIO_CONTENT_TYPES first,
+// then the system MIME table). It never infers a type for objects under
+// MINIO_UNTRUSTED_PREFIXES or one on the untrusted type list, so a stored
+// "x.html" still isn't rendered by the browser.
+func (h *MinioHandler) inferredType(objectName, stored string) (string, bool) {
+	if !genericContentTypes[strings.ToLower(strings.TrimSpace(stored))] || h.untrusted.untrustedPrefix(objectName) {
+		return "", false
+	}
+	ext := strings.ToLower(path.Ext(objectName))
+	if ext == "" {
+		return "", false
+	}
+	contentType, ok := h.contentTypes[ext]
+	if !ok {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType == "" || h.untrusted.untrustedType(contentType) {
+		return "", false
+	}
+	return contentType, true
+}
+
+// applyInferredType replaces a generic info.ContentType with the type
+// inferred from objectName when the request asks for ?infer_type=true.
+func (h *MinioHandler) applyInferredType(r *http.Request, objectName string, info *minio.ObjectInfo) {
+	if r.URL.Query().Get("infer_type") != "true" {
+		return
+	}
+	if contentType, ok := h.inferredType(objectName, info.ContentType); ok {
+		info.ContentType = contentType
+	}
+}
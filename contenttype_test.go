@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestFetchInferType(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.contentTypes = map[string]string{".md": "text/markdown"}
+	h.untrusted = untrustedRules{types: []string{"text/html"}}
+	store.put(testBucket, "restored/photo.png", []byte("png"), "application/octet-stream")
+	store.put(testBucket, "restored/notes.md", []byte("# hi"), "binary/octet-stream")
+	store.put(testBucket, "restored/page.html", []byte("<p>"), "application/octet-stream")
+	store.put(testBucket, "restored/data.json", []byte("{}"), "text/plain")
+
+	for name, want := range map[string]string{
+		"restored/photo.png": "image/png",
+		"restored/notes.md":  "text/markdown",
+		// Untrusted types are never inferred, nor are specific stored types replaced.
+		"restored/page.html": "application/octet-stream",
+		"restored/data.json": "text/plain",
+	} {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/fetch/"+name+"?infer_type=true", nil))
+		if got := rec.Header().Get("Content-Type"); got != want {
+			t.Errorf("%s: Content-Type = %q, want %q", name, got, want)
+		}
+	}
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/fetch/restored/photo.png", nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("without infer_type: Content-Type = %q, want the stored type", got)
+	}
+}
+
+func TestDownloadLinkInferType(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "restored/photo.png", []byte("png"), "application/octet-stream")
+
+	var got map[string]string
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/restored/photo.png?infer_type=true", nil)), &got)
+	u, err := url.Parse(got["url"])
+	if err != nil || u.Query().Get("response-content-type") != "image/png" {
+		t.Errorf("url = %q, want response-content-type=image/png", got["url"])
+	}
+}
+
+func TestLoadContentTypeOverrides(t *testing.T) {
+	t.Setenv("MINIO_CONTENT_TYPES", ".MD=text/markdown, .geojson=application/geo+json")
+	got, err := loadContentTypeOverrides()
+	if err != nil || got[".md"] != "text/markdown" || got[".geojson"] != "application/geo+json" {
+		t.Errorf("overrides = %v, %v", got, err)
+	}
+	t.Setenv("MINIO_CONTENT_TYPES", "md=text/markdown")
+	if _, err := loadContentTypeOverrides(); err == nil {
+		t.Error("extension without a dot was accepted")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// convertedDir is the folder, next to each image, that holds its converted
+// variants: photos/cat.png as JPEG is photos/converted/cat.png.q85.jpg.
+const convertedDir = "converted"
+
+// sourceETagMeta records, on a converted variant, the ETag of the image it
+// was made from, so a variant of a replaced image isn't served.
+const sourceETagMeta = "Source-Etag"
+
+// convertFormat is an output format of /convert.
+type convertFormat struct {
+	ext         string
+	contentType string
+	// lossy formats take a quality, which is part of the variant's key.
+	lossy bool
+}
+
+// convertFormats are the formats /convert can produce. The standard library
+// has no WebP encoder, so WebP isn't among them.
+var convertFormats = map[string]convertFormat{
+	"png":  {ext: "png", contentType: "image/png"},
+	"jpeg": {ext: "jpg", contentType: "image/jpeg", lossy: true},
+	"jpg":  {ext: "jpg", contentType: "image/jpeg", lossy: true},
+	"gif":  {ext: "gif", contentType: "image/gif"},
+}
+
+// convertedKey returns where the variant of objectName in format f is
+// stored.
+func convertedKey(objectName string, f convertFormat, quality int) string {
+	dir, base := path.Split(objectName)
+	if f.lossy {
+		base += ".q" + strconv.Itoa(quality)
+	}
+	return dir + convertedDir + "/" + base + "." + f.ext
+}
+
+// encodeImage encodes img in format f. JPEG has no transparency, so images
+// are flattened onto white first rather than letting transparent pixels
+// turn black.
+func encodeImage(w io.Writer, img image.Image, f convertFormat, quality int) error {
+	switch f.ext {
+	case "jpg":
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		return jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// convertHandler serves /convert/{objectName}?to= (png, jpeg or gif) with
+// the image re-encoded in that format, JPEGs at ?quality= (1-100, default
+// MINIO_CONVERT_QUALITY). Each variant is stored under converted/ next to
+// the image and served from there until the image changes. Sources are
+// bounded like thumbnails, by MINIO_THUMBNAIL_MAX_BYTES and
+// MINIO_THUMBNAIL_MAX_PIXELS; anything that isn't a JPEG, PNG or GIF gets
+// 415.
+func (h *MinioHandler) convertHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/convert/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /convert/photos/cat.png?to=jpeg)", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	to := strings.ToLower(q.Get("to"))
+	format, ok := convertFormats[to]
+	if to == "webp" {
+		http.Error(w, "WebP output isn't supported, since Go's standard library can't encode it; use ?to=png, jpeg or gif", http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unsupported format %q; use ?to=png, jpeg or gif", to), http.StatusBadRequest)
+		return
+	}
+	quality := h.convertQuality
+	if v := q.Get("quality"); v != "" && format.lossy {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "quality must be a number from 1 to 100", http.StatusBadRequest)
+			return
+		}
+		quality = n
+	}
+
+	ctx := r.Context()
+	src, err := h.statObject(ctx, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		logger(r.Context()).Error("Error stating for conversion", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to read file", err)
+		return
+	}
+	key := convertedKey(objectName, format, quality)
+	if h.serveConverted(ctx, w, key, src.ETag) {
+		return
+	}
+
+	// Concurrent requests for the variant of this version of the image
+	// share one conversion, which outlives a caller that gives up.
+	v, err, _ := h.variantBuilds.Do(key+"@"+src.ETag, func() (any, error) {
+		return h.buildConverted(context.WithoutCancel(ctx), objectName, src, format, quality, key)
+	})
+	switch {
+	case errors.Is(err, errConvertTooLarge):
+		http.Error(w, fmt.Sprintf("'%s' is over the %d byte limit for conversion", objectName, h.thumbnails.maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, errPixelLimit):
+		http.Error(w, fmt.Sprintf("Can't convert '%s': %v", objectName, err), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, errNotImage):
+		http.Error(w, fmt.Sprintf("'%s' is not a JPEG, PNG or GIF image", objectName), http.StatusUnsupportedMediaType)
+		return
+	case minio.ToErrorResponse(err).Code == "PreconditionFailed":
+		http.Error(w, "Image changed while it was being converted; retry the request", http.StatusConflict)
+		return
+	case err != nil:
+		logger(r.Context()).Error("Error converting image", "object", objectName, "format", to, "err", err)
+		h.storeFailed(w, "Failed to convert image", err)
+		return
+	}
+	out := v.([]byte)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Write(out)
+}
+
+// errConvertTooLarge and errNotImage are why buildConverted refused a
+// source image.
+var (
+	errConvertTooLarge = errors.New("image is over the conversion size limit")
+	errNotImage        = errors.New("not a JPEG, PNG or GIF image")
+)
+
+// buildConverted converts objectName, as of the version src describes, to
+// format and stores the variant at key, returning the encoded bytes. A
+// variant that can't be stored is still returned; the next request
+// converts again.
+func (h *MinioHandler) buildConverted(ctx context.Context, objectName string, src minio.ObjectInfo, format convertFormat, quality int, key string) ([]byte, error) {
+	data, err := h.readImageSource(ctx, objectName, src)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errConvertTooLarge
+	}
+	img, _, err := decodeImage(data, h.thumbnails.maxPixels)
+	switch {
+	case errors.Is(err, errPixelLimit):
+		return nil, err
+	case err != nil:
+		return nil, errNotImage
+	}
+	var out bytes.Buffer
+	if err := encodeImage(&out, img, format, quality); err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", format.ext, err)
+	}
+
+	if !h.readOnly.Load() && h.isVariantSlot(ctx, key) {
+		opts, _ := h.uploadOptions(key, format.contentType)
+		if opts.UserMetadata == nil {
+			opts.UserMetadata = map[string]string{}
+		}
+		opts.UserMetadata["X-Amz-Meta-"+sourceETagMeta] = src.ETag
+		if _, err := h.store.PutObject(ctx, h.bucketName, key, bytes.NewReader(out.Bytes()), int64(out.Len()), opts); err != nil {
+			logger(ctx).Error("Error storing converted variant", "object", key, "err", err)
+		} else {
+			h.cache.invalidate(key)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// readImageSource reads objectName as of the version src describes. It
+// returns nil data when the image is over MINIO_THUMBNAIL_MAX_BYTES.
+func (h *MinioHandler) readImageSource(ctx context.Context, objectName string, src minio.ObjectInfo) ([]byte, error) {
+	if src.Size > h.thumbnails.maxBytes {
+		return nil, nil
+	}
+	opts := minio.GetObjectOptions{}
+	opts.SetMatchETag(src.ETag)
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(io.LimitReader(obj, h.thumbnails.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > h.thumbnails.maxBytes {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// isVariantSlot reports whether a variant may be stored at key: nothing is
+// there yet, or an earlier variant is. An object of the user's own at that
+// key is never overwritten; its variant is converted on every request.
+func (h *MinioHandler) isVariantSlot(ctx context.Context, key string) bool {
+	info, err := h.store.StatObject(ctx, h.bucketName, key, minio.StatObjectOptions{})
+	if err != nil {
+		return minio.ToErrorResponse(err).Code == "NoSuchKey"
+	}
+	if info.UserMetadata[sourceETagMeta] == "" {
+		logger(ctx).Warn("Not storing converted variant over an object that isn't one", "object", key)
+		return false
+	}
+	return true
+}
+
+// serveConverted streams the stored variant at key if it was made from the
+// image version with sourceETag, reporting whether it did.
+func (h *MinioHandler) serveConverted(ctx context.Context, w http.ResponseWriter, key, sourceETag string) bool {
+	obj, err := h.store.GetObject(ctx, h.bucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		return false
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil || info.UserMetadata[sourceETagMeta] != sourceETag {
+		return false
+	}
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if _, err := io.Copy(w, obj); err != nil {
+		logger(ctx).Error("Error streaming converted variant", "object", key, "err", err)
+	}
+	return true
+}
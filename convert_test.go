@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestConvertHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.convertQuality = 85
+	store.put(testBucket, "photos/cat.png", testPNG(t, 8, 6), "image/png")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/convert/photos/cat.png?to=jpeg", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("status = %d, Content-Type = %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil || img.Bounds() != image.Rect(0, 0, 8, 6) {
+		t.Fatalf("decoding the JPEG: %v (bounds %v)", err, img)
+	}
+	stored, ok := store.object(testBucket, "photos/converted/cat.png.q85.jpg")
+	if !ok || !bytes.Equal(stored, rec.Body.Bytes()) {
+		t.Fatal("the variant wasn't stored under converted/")
+	}
+
+	// The stored variant is served while the image is unchanged, and
+	// replaced once it changes.
+	store.PutObject(t.Context(), testBucket, "photos/converted/cat.png.q85.jpg", strings.NewReader("stale"), 5, minio.PutObjectOptions{
+		ContentType:  "image/jpeg",
+		UserMetadata: map[string]string{"X-Amz-Meta-" + sourceETagMeta: "old"},
+	})
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/convert/photos/cat.png?to=jpeg", nil)); rec.Body.String() == "stale" {
+		t.Error("served a variant that wasn't made from the current image")
+	}
+	first, _ := store.object(testBucket, "photos/converted/cat.png.q85.jpg")
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/convert/photos/cat.png?to=jpg", nil))
+	if !bytes.Equal(rec.Body.Bytes(), first) {
+		t.Error("the stored variant wasn't served")
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/convert/photos/cat.png?to=gif", nil)); rec.Header().Get("Content-Type") != "image/gif" {
+		t.Errorf("gif: Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	if _, ok := store.object(testBucket, "photos/converted/cat.png.gif"); !ok {
+		t.Error("the gif variant wasn't stored")
+	}
+}
+
+func TestConvertKeepsUserObjects(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "cat.png", testPNG(t, 4, 4), "image/png")
+	store.put(testBucket, "converted/cat.png.gif", []byte("mine"), "image/gif")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/convert/cat.png?to=gif", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() == "mine" {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body)
+	}
+	if data, _ := store.object(testBucket, "converted/cat.png.gif"); string(data) != "mine" {
+		t.Errorf("the object at the variant's key was overwritten with %q", data)
+	}
+}
+
+func TestConvertHandlerErrors(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.convertQuality = 85
+	store.put(testBucket, "cat.png", testPNG(t, 4, 4), "image/png")
+	store.put(testBucket, "notes.txt", []byte("hello"), "text/plain")
+	store.put(testBucket, "huge.png", testPNG(t, 2048, 1024), "image/png")
+
+	for _, tt := range []struct {
+		target string
+		want   int
+	}{
+		{"/convert/cat.png?to=webp", http.StatusBadRequest},
+		{"/convert/cat.png", http.StatusBadRequest},
+		{"/convert/cat.png?to=jpeg&quality=0", http.StatusBadRequest},
+		{"/convert/missing.png?to=png", http.StatusNotFound},
+		{"/convert/notes.txt?to=png", http.StatusUnsupportedMediaType},
+		{"/convert/huge.png?to=jpeg", http.StatusRequestEntityTooLarge},
+	} {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, tt.target, nil)); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// copyByTagResponse reports what /copy-by-tag did with each matching object.
+// Next is passed back as "after" to continue a truncated run.
+type copyByTagResponse struct {
+	Prefix     string            `json:"prefix"`
+	DestPrefix string            `json:"dest_prefix"`
+	Tags       map[string]string `json:"tags"`
+	Move       bool              `json:"move"`
+	DryRun     bool              `json:"dry_run"`
+	Scanned    int               `json:"scanned"`
+	Matched    int               `json:"matched"`
+	Failed     int               `json:"failed"`
+	Truncated  bool              `json:"truncated"`
+	Next       string            `json:"next,omitempty"`
+	Results    []organizeResult  `json:"results"`
+}
+
+// copyByTagHandler copies every object under "prefix" carrying all of the
+// given tags to "dest_prefix", keeping its path below prefix, as in
+// {"prefix": "docs/", "tags": {"archive": "true"}, "dest_prefix": "cold/"}.
+// Tags are read and objects copied up to bulkConcurrency at a time. With
+// "move" the sources are removed once copied; with "dry_run" nothing
+// changes. At most MINIO_TAG_SCAN_MAX objects are checked per request.
+func (h *MinioHandler) copyByTagHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prefix     string            `json:"prefix"`
+		Tags       map[string]string `json:"tags"`
+		DestPrefix string            `json:"dest_prefix"`
+		Move       bool              `json:"move"`
+		DryRun     bool              `json:"dry_run"`
+		After      string            `json:"after"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tags) == 0 || req.DestPrefix == "" {
+		http.Error(w, `Request body must be JSON like {"prefix": "docs/", "tags": {"archive": "true"}, "dest_prefix": "cold/"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Move && !requireScope(w, r, scopeDelete) {
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	enc := h.sse.forCopy(key)
+	dest := strings.TrimPrefix(req.DestPrefix, "/")
+	if !strings.HasSuffix(dest, "/") {
+		dest += "/"
+	}
+	limit := h.tagScanMax
+	if limit <= 0 {
+		limit = 10000
+	}
+	resp := copyByTagResponse{Prefix: req.Prefix, DestPrefix: dest, Tags: req.Tags, Move: req.Move, DryRun: req.DryRun, Results: []organizeResult{}}
+
+	var mu sync.Mutex
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	var listErr error
+	for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: req.Prefix, Recursive: true, StartAfter: req.After}) {
+		if object.Err != nil {
+			listErr = object.Err
+			break
+		}
+		// Earlier copies are skipped, so a destination under the prefix
+		// isn't copied into itself.
+		if strings.HasSuffix(object.Key, "/") || strings.HasPrefix(object.Key, dest) || h.hiddenKey(object.Key) {
+			continue
+		}
+		if resp.Scanned == limit {
+			resp.Truncated = true
+			break
+		}
+		resp.Scanned++
+		resp.Next = object.Key
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res, ok := h.copyIfTagged(r, object, req.Tags, dest+strings.TrimPrefix(object.Key, req.Prefix), req.DryRun, req.Move, enc)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			resp.Results = append(resp.Results, res)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if listErr != nil {
+		logger(r.Context()).Error("Error listing for copy by tag", "prefix", req.Prefix, "err", listErr)
+		h.storeFailed(w, "Failed to list files", listErr)
+		return
+	}
+	if !resp.Truncated {
+		resp.Next = ""
+	}
+
+	if req.Move && !req.DryRun {
+		h.removeCopiedSources(r.Context(), resp.Results)
+	}
+	sort.Slice(resp.Results, func(i, j int) bool { return resp.Results[i].Object < resp.Results[j].Object })
+	resp.Matched = len(resp.Results)
+	for _, res := range resp.Results {
+		// A "copied" result of a move is one whose source wasn't removed.
+		if res.Status == "failed" || (req.Move && res.Status == "copied") {
+			resp.Failed++
+		}
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// copyIfTagged copies object to dst if it carries every tag in want,
+// reporting false for objects that don't match.
+func (h *MinioHandler) copyIfTagged(r *http.Request, object minio.ObjectInfo, want map[string]string, dst string, dryRun, move bool, enc copyEncryption) (organizeResult, bool) {
+	res := organizeResult{Object: object.Key, Destination: dst}
+	t, err := h.store.GetObjectTagging(r.Context(), h.bucketName, object.Key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		logger(r.Context()).Error("Error reading tags", "object", object.Key, "err", err)
+		res.Status, res.Error = "failed", "reading tags: "+err.Error()
+		return res, true
+	}
+	tags := t.ToMap()
+	for k, v := range want {
+		if got, ok := tags[k]; !ok || got != v {
+			return res, false
+		}
+	}
+
+	if status, reason := h.uploadRules.check(h.bucketName, dst); status != 0 {
+		res.Status, res.Error = "failed", reason
+		return res, true
+	}
+	if dryRun {
+		res.Status = "would_copy"
+		if move {
+			res.Status = "would_move"
+		}
+		return res, true
+	}
+	_, err = h.store.CopyObject(r.Context(),
+		minio.CopyDestOptions{Bucket: h.bucketName, Object: dst, Encryption: enc.dst},
+		minio.CopySrcOptions{Bucket: h.bucketName, Object: object.Key, MatchETag: object.ETag, Encryption: enc.src})
+	if err != nil {
+		logger(r.Context()).Error("Error copying object", "object", object.Key, "destination", dst, "err", err)
+		res.Status, res.Error = "failed", err.Error()
+		return res, true
+	}
+	h.cache.invalidate(dst)
+	res.Status = "copied"
+	return res, true
+}
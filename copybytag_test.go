@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func putTagged(t *testing.T, store *fakeStore, key string, tags map[string]string) {
+	t.Helper()
+	_, err := store.PutObject(t.Context(), testBucket, key, strings.NewReader("x"), 1, minio.PutObjectOptions{UserTags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyByTag(t *testing.T) {
+	h, store := newTestHandler(t)
+	putTagged(t, store, "docs/a.pdf", map[string]string{"archive": "true", "team": "x"})
+	putTagged(t, store, "docs/sub/b.pdf", map[string]string{"archive": "true"})
+	putTagged(t, store, "docs/c.pdf", map[string]string{"archive": "false"})
+	putTagged(t, store, "docs/d.pdf", nil)
+
+	body := `{"prefix": "docs/", "tags": {"archive": "true"}, "dest_prefix": "cold", "move": true, "dry_run": true}`
+	var got copyByTagResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/copy-by-tag", strings.NewReader(body))), &got)
+	if got.Scanned != 4 || got.Matched != 2 || got.Results[0].Status != "would_move" {
+		t.Fatalf("dry run = %+v", got)
+	}
+	if _, ok := store.object(testBucket, "cold/a.pdf"); ok {
+		t.Fatal("dry run copied an object")
+	}
+
+	body = strings.Replace(body, `"dry_run": true`, `"dry_run": false`, 1)
+	got = copyByTagResponse{}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/copy-by-tag", strings.NewReader(body))), &got)
+	if got.Matched != 2 || got.Failed != 0 {
+		t.Fatalf("move = %+v", got)
+	}
+	for _, res := range got.Results {
+		if res.Status != "moved" {
+			t.Errorf("result = %+v, want moved", res)
+		}
+	}
+	for key, want := range map[string]bool{"cold/a.pdf": true, "cold/sub/b.pdf": true, "docs/a.pdf": false, "docs/c.pdf": true} {
+		if _, ok := store.object(testBucket, key); ok != want {
+			t.Errorf("%s exists = %v, want %v", key, ok, want)
+		}
+	}
+	if tags, _ := store.GetObjectTagging(t.Context(), testBucket, "cold/a.pdf", minio.GetObjectTaggingOptions{}); tags.ToMap()["team"] != "x" {
+		t.Errorf("tags of the copy = %v", tags.ToMap())
+	}
+}
+
+func TestCopyByTagTruncates(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.tagScanMax = 2
+	for _, key := range []string{"a", "b", "c"} {
+		putTagged(t, store, key, map[string]string{"k": "v"})
+	}
+
+	var got copyByTagResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/copy-by-tag", strings.NewReader(`{"tags": {"k": "v"}, "dest_prefix": "out/"}`))), &got)
+	if !got.Truncated || got.Next != "b" || got.Matched != 2 {
+		t.Errorf("first run = %+v", got)
+	}
+	got = copyByTagResponse{}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/copy-by-tag", strings.NewReader(`{"tags": {"k": "v"}, "dest_prefix": "out/", "after": "b"}`))), &got)
+	if got.Truncated || got.Matched != 1 || got.Results[0].Destination != "out/c" {
+		t.Errorf("second run = %+v", got)
+	}
+}
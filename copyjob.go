@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// maxSingleCopySize is the largest object S3 copies in one CopyObject
+	// call; anything bigger needs a multipart copy.
+	maxSingleCopySize = 5 << 30
+	// copyPartSize is the part size used for multipart copies. It is raised
+	// for very large objects so the copy stays under the 10,000 part limit.
+	copyPartSize = 512 << 20
+	maxCopyParts = 10000
+)
+
+// copyProgress is the progress payload reported for copy jobs.
+type copyProgress struct {
+	Source         string `json:"source"`
+	Destination    string `json:"destination"`
+	TotalBytes     int64  `json:"total_bytes"`
+	CopiedBytes    int64  `json:"copied_bytes"`
+	TotalParts     int    `json:"total_parts"`
+	CompletedParts int    `json:"completed_parts"`
+}
+
+// copyJobHandler starts a server-side copy in the background and returns its
+// job ID immediately. Progress is available at /jobs/{id} and
+// /jobs/{id}/events.
+//
+// "if_match" (an ETag) and "if_unmodified_since" (RFC 3339) make the copy
+// conditional on the source: if it no longer matches when the request
+// arrives the answer is 412, and the conditions are also sent with the copy
+// itself, so a source changed while the job runs fails the job.
+func (h *MinioHandler) copyJobHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source            string `json:"source"`
+		Destination       string `json:"destination"`
+		IfMatch           string `json:"if_match"`
+		IfUnmodifiedSince string `json:"if_unmodified_since"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Source == "" || req.Destination == "" {
+		http.Error(w, `Request body must be JSON like {"source": "a.bin", "destination": "b.bin"}`, http.StatusBadRequest)
+		return
+	}
+	var unmodifiedSince time.Time
+	if req.IfUnmodifiedSince != "" {
+		if unmodifiedSince, err = time.Parse(time.RFC3339, req.IfUnmodifiedSince); err != nil {
+			http.Error(w, "if_unmodified_since must be an RFC 3339 timestamp such as 2024-05-01T00:00:00Z", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Source == req.Destination {
+		http.Error(w, "Source and destination must differ", http.StatusBadRequest)
+		return
+	}
+	if isTusState(req.Source) {
+		http.Error(w, tusStateReserved, http.StatusBadRequest)
+		return
+	}
+	if h.trash.holds(req.Source) {
+		http.Error(w, trashReserved(h.trash.root()), http.StatusBadRequest)
+		return
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, req.Destination); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := h.store.StatObject(r.Context(), h.bucketName, req.Source, minio.StatObjectOptions{ServerSideEncryption: key})
+	if err != nil {
+		if wrongCustomerKey(err, key) {
+			http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+			return
+		}
+		logger(r.Context()).Error("Error stating copy source", "source", req.Source, "err", err)
+		http.Error(w, "Source file not found", http.StatusNotFound)
+		return
+	}
+	if match := strings.Trim(req.IfMatch, `"`); match != "" && match != src.ETag {
+		http.Error(w, "Source has changed (ETag does not match if_match)", http.StatusPreconditionFailed)
+		return
+	}
+	if !unmodifiedSince.IsZero() && src.LastModified.After(unmodifiedSince) {
+		http.Error(w, "Source has been modified since "+req.IfUnmodifiedSince, http.StatusPreconditionFailed)
+		return
+	}
+
+	progress := copyProgress{Source: req.Source, Destination: req.Destination, TotalBytes: src.Size, TotalParts: copyParts(src.Size)}
+	j := h.jobs.start("copy", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.runCopy(context.Background(), j, src, progress, unmodifiedSince, h.sse.forCopy(key))
+		if err != nil {
+			logger(r.Context()).Error("Copy job failed", "job", id, "source", req.Source, "destination", req.Destination, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// runCopy performs the copy described by p, using a single CopyObject for
+// small objects and a part-by-part multipart copy otherwise so progress can
+// be reported as each part completes. Every request is conditional on the
+// source still having src's ETag and, unless it is zero, on it not being
+// modified after unmodifiedSince. The copy is encrypted as enc says.
+func (h *MinioHandler) runCopy(ctx context.Context, j *job, src minio.ObjectInfo, p copyProgress, unmodifiedSince time.Time, enc copyEncryption) error {
+	if p.TotalParts == 1 {
+		_, err := h.store.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: h.bucketName, Object: p.Destination, Encryption: enc.dst},
+			minio.CopySrcOptions{Bucket: h.bucketName, Object: p.Source, MatchETag: src.ETag, MatchUnmodifiedSince: unmodifiedSince, Encryption: enc.src})
+		if err != nil {
+			return copyFailed(err)
+		}
+		p.CopiedBytes, p.CompletedParts = p.TotalBytes, 1
+		j.setProgress(p)
+		return nil
+	}
+
+	_, err := h.multipartCopy(ctx, h.bucketName, src, h.bucketName, p.Destination, p.TotalParts, unmodifiedSince, enc, func(part int, length int64) {
+		p.CopiedBytes += length
+		p.CompletedParts = part
+		j.setProgress(p)
+	})
+	return err
+}
+
+// copyParts returns how many parts a copy of size bytes takes: 1 up to
+// maxSingleCopySize, which one CopyObject handles, and parts of at least
+// copyPartSize beyond it.
+func copyParts(size int64) int {
+	if size <= maxSingleCopySize {
+		return 1
+	}
+	partSize := max(int64(copyPartSize), (size+maxCopyParts-1)/maxCopyParts)
+	return int((size + partSize - 1) / partSize)
+}
+
+// multipartCopy copies src from srcBucket to dst in dstBucket in the given
+// number of parts, calling onPart after each. Every part is conditional on
+// the source still having src's ETag and, unless it is zero, on it not
+// being modified after unmodifiedSince. The copy is encrypted as enc
+// says. The multipart upload is aborted if a part fails.
+func (h *MinioHandler) multipartCopy(ctx context.Context, srcBucket string, src minio.ObjectInfo, dstBucket, dst string,
+	parts int, unmodifiedSince time.Time, enc copyEncryption, onPart func(part int, length int64)) (minio.UploadInfo, error) {
+	uploadID, err := h.store.NewMultipartUpload(ctx, dstBucket, dst, minio.PutObjectOptions{
+		ContentType:          src.ContentType,
+		UserMetadata:         src.UserMetadata,
+		ServerSideEncryption: enc.dst,
+	})
+	if err != nil {
+		return minio.UploadInfo{}, fmt.Errorf("starting multipart copy: %w", err)
+	}
+
+	conditions := map[string]string{"x-amz-copy-source-if-match": src.ETag}
+	if !unmodifiedSince.IsZero() {
+		conditions["x-amz-copy-source-if-unmodified-since"] = unmodifiedSince.UTC().Format(http.TimeFormat)
+	}
+	enc.addPartHeaders(conditions)
+	partSize := (src.Size + int64(parts) - 1) / int64(parts)
+	completed := make([]minio.CompletePart, 0, parts)
+	for part := 1; part <= parts; part++ {
+		offset := int64(part-1) * partSize
+		length := min(partSize, src.Size-offset)
+		cp, err := h.store.CopyObjectPart(ctx, srcBucket, src.Key, dstBucket, dst,
+			uploadID, part, offset, length, conditions)
+		if err != nil {
+			h.store.AbortMultipartUpload(context.WithoutCancel(ctx), dstBucket, dst, uploadID)
+			return minio.UploadInfo{}, fmt.Errorf("copying part %d: %w", part, copyFailed(err))
+		}
+		completed = append(completed, cp)
+		onPart(part, length)
+	}
+
+	info, err := h.store.CompleteMultipartUpload(ctx, dstBucket, dst, uploadID, completed, minio.PutObjectOptions{})
+	if err != nil {
+		h.store.AbortMultipartUpload(context.WithoutCancel(ctx), dstBucket, dst, uploadID)
+		return minio.UploadInfo{}, fmt.Errorf("completing multipart copy: %w", err)
+	}
+	return info, nil
+}
+
+// copyFailed explains a failed source precondition, which otherwise reads
+// as a bare "At least one of the pre-conditions you specified did not hold".
+func copyFailed(err error) error {
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		return fmt.Errorf("source changed after the copy was requested: %w", err)
+	}
+	return err
+}
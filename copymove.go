@@ -0,0 +1,204 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// transferRequest is the body of /copy and /move. The buckets default to
+// the handler's own; others can be named when MINIO_BUCKETS allows them.
+type transferRequest struct {
+	Source            string `json:"source"`
+	Destination       string `json:"destination"`
+	SourceBucket      string `json:"source_bucket"`
+	DestinationBucket string `json:"destination_bucket"`
+	// Overwrite replaces an existing destination, which is otherwise a 409.
+	Overwrite bool `json:"overwrite"`
+}
+
+type transferResult struct {
+	Source            string `json:"source"`
+	SourceBucket      string `json:"source_bucket"`
+	Destination       string `json:"destination"`
+	DestinationBucket string `json:"destination_bucket"`
+	Size              int64  `json:"size"`
+	ETag              string `json:"etag"`
+	// Moved is false for a copy, and for a move whose source could not be
+	// removed afterwards.
+	Moved bool `json:"moved"`
+}
+
+// copyHandler copies an object server side (POST /copy), so nothing passes
+// through this service. Objects over 5 GiB are copied part by part.
+func (h *MinioHandler) copyHandler(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Source == "" || req.Destination == "" {
+		http.Error(w, `Request body must be JSON like {"source": "a.pdf", "destination": "docs/a.pdf"}`, http.StatusBadRequest)
+		return
+	}
+	if res, ok := h.transfer(w, r, req); ok {
+		writeJSON(w, r, http.StatusOK, res)
+	}
+}
+
+// moveHandler copies an object server side and then removes the source
+// (POST /move). Removing the source needs files:delete as well.
+func (h *MinioHandler) moveHandler(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Source == "" || req.Destination == "" {
+		http.Error(w, `Request body must be JSON like {"source": "inbox/a.pdf", "destination": "docs/a.pdf"}`, http.StatusBadRequest)
+		return
+	}
+	h.move(w, r, req)
+}
+
+// renameHandler gives an object a new name in the same folder (POST
+// /rename, {"source": "docs/a.pdf", "name": "b.pdf"}). It is a move within
+// the bucket.
+func (h *MinioHandler) renameHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source    string `json:"source"`
+		Name      string `json:"name"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Source == "" || req.Name == "" {
+		http.Error(w, `Request body must be JSON like {"source": "docs/a.pdf", "name": "b.pdf"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.Contains(req.Name, "/") || req.Name == "." || req.Name == ".." {
+		http.Error(w, "name must be a file name without '/'; use /move to change folders", http.StatusBadRequest)
+		return
+	}
+	dst := req.Name
+	if dir := path.Dir(req.Source); dir != "." {
+		dst = dir + "/" + req.Name
+	}
+	h.move(w, r, transferRequest{Source: req.Source, Destination: dst, Overwrite: req.Overwrite})
+}
+
+func (h *MinioHandler) move(w http.ResponseWriter, r *http.Request, req transferRequest) {
+	res, ok := h.transfer(w, r, req)
+	if !ok {
+		return
+	}
+	err := h.store.RemoveObject(r.Context(), res.SourceBucket, res.Source, minio.RemoveObjectOptions{})
+	if err != nil {
+		logger(r.Context()).Error("Error removing object after copy", "bucket", res.SourceBucket, "object", res.Source, "err", err)
+		http.Error(w, fmt.Sprintf("Copied to '%s' but failed to remove '%s'; both exist now", res.Destination, res.Source), http.StatusInternalServerError)
+		return
+	}
+	if res.SourceBucket == h.bucketName {
+		h.cache.invalidate(res.Source)
+	}
+	res.Moved = true
+	writeJSON(w, r, http.StatusOK, res)
+}
+
+// transfer checks req and copies its source to its destination, answering
+// the request itself when it returns false.
+func (h *MinioHandler) transfer(w http.ResponseWriter, r *http.Request, req transferRequest) (transferResult, bool) {
+	res := transferResult{
+		Source: req.Source, SourceBucket: cmp.Or(req.SourceBucket, h.bucketName),
+		Destination: req.Destination, DestinationBucket: cmp.Or(req.DestinationBucket, h.bucketName),
+	}
+	for _, bucket := range []string{res.SourceBucket, res.DestinationBucket} {
+		if !h.reachable(bucket) {
+			http.Error(w, fmt.Sprintf("Bucket '%s' is not served here", bucket), http.StatusForbidden)
+			return res, false
+		}
+	}
+	if res.Source == res.Destination && res.SourceBucket == res.DestinationBucket {
+		http.Error(w, "Source and destination must differ", http.StatusBadRequest)
+		return res, false
+	}
+	if isTusState(res.Source) {
+		http.Error(w, tusStateReserved, http.StatusBadRequest)
+		return res, false
+	}
+	if h.trash.holds(res.Source) {
+		http.Error(w, trashReserved(h.trash.root()), http.StatusBadRequest)
+		return res, false
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return res, false
+	}
+	if status, reason := h.uploadRules.check(res.DestinationBucket, res.Destination); status != 0 {
+		http.Error(w, reason, status)
+		return res, false
+	}
+
+	src, err := h.store.StatObject(r.Context(), res.SourceBucket, res.Source, minio.StatObjectOptions{ServerSideEncryption: key})
+	if wrongCustomerKey(err, key) {
+		http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+		return res, false
+	}
+	if err != nil {
+		h.transferFailed(w, r, res, "Source file not found", err)
+		return res, false
+	}
+	if !req.Overwrite {
+		_, err := h.store.StatObject(r.Context(), res.DestinationBucket, res.Destination, minio.StatObjectOptions{})
+		if err == nil {
+			http.Error(w, fmt.Sprintf("'%s' already exists; set \"overwrite\": true to replace it", res.Destination), http.StatusConflict)
+			return res, false
+		}
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			h.transferFailed(w, r, res, "Destination not found", err)
+			return res, false
+		}
+	}
+
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	var info minio.UploadInfo
+	enc := h.sse.forCopy(key)
+	if parts := copyParts(src.Size); parts == 1 {
+		info, err = h.store.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: res.DestinationBucket, Object: res.Destination, Encryption: enc.dst},
+			minio.CopySrcOptions{Bucket: res.SourceBucket, Object: res.Source, MatchETag: src.ETag, Encryption: enc.src})
+	} else {
+		info, err = h.multipartCopy(ctx, res.SourceBucket, src, res.DestinationBucket, res.Destination, parts, time.Time{}, enc, func(int, int64) {})
+	}
+	if err != nil {
+		h.transferFailed(w, r, res, "Failed to copy file", err)
+		return res, false
+	}
+	if res.DestinationBucket == h.bucketName {
+		h.cache.invalidate(res.Destination)
+	}
+	res.Size, res.ETag = src.Size, info.ETag
+	return res, true
+}
+
+// transferFailed answers a failed stat or copy: 404 for a missing object or
+// bucket (notFound says which to expect), 412 when the source changed
+// during the copy, and storeFailed otherwise.
+func (h *MinioHandler) transferFailed(w http.ResponseWriter, r *http.Request, res transferResult, notFound string, err error) {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey":
+		http.Error(w, notFound, http.StatusNotFound)
+	case "NoSuchBucket":
+		http.Error(w, "Bucket not found", http.StatusNotFound)
+	case "PreconditionFailed":
+		http.Error(w, "Source changed during the copy; retry the request", http.StatusPreconditionFailed)
+	default:
+		logger(r.Context()).Error("Error copying object", "bucket", res.SourceBucket, "object", res.Source,
+			"destination_bucket", res.DestinationBucket, "destination", res.Destination, "err", err)
+		h.storeFailed(w, "Failed to copy file", err)
+	}
+}
+
+// reachable reports whether /copy and /move may use bucket: the handler's
+// own, or with MINIO_BUCKETS, the main bucket and the buckets it allows.
+func (h *MinioHandler) reachable(bucket string) bool {
+	return bucket == h.bucketName || h.peers != nil && (bucket == h.peers.main || h.peers.allows(bucket))
+}
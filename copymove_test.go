@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func postJSON(h *MinioHandler, target, body string) *httptest.ResponseRecorder {
+	return serve(h, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+}
+
+func TestCopyMoveRename(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "inbox/a.pdf", []byte("pdf"), "application/pdf")
+	store.put(testBucket, "docs/taken.pdf", []byte("old"), "application/pdf")
+
+	rec := postJSON(h, "/copy", `{"source": "inbox/a.pdf", "destination": "docs/a.pdf"}`)
+	var res transferResult
+	decodeJSON(t, rec, &res)
+	if res.Moved || res.Size != 3 || res.SourceBucket != testBucket || res.DestinationBucket != testBucket {
+		t.Errorf("copy = %+v", res)
+	}
+	if _, ok := store.object(testBucket, "inbox/a.pdf"); !ok {
+		t.Error("copy removed the source")
+	}
+	if info, err := store.StatObject(t.Context(), testBucket, "docs/a.pdf", minio.StatObjectOptions{}); err != nil || info.ContentType != "application/pdf" {
+		t.Errorf("copy destination = %+v, %v", info, err)
+	}
+
+	if rec := postJSON(h, "/move", `{"source": "inbox/a.pdf", "destination": "docs/taken.pdf"}`); rec.Code != http.StatusConflict {
+		t.Errorf("move onto an existing object: status %d", rec.Code)
+	}
+	rec = postJSON(h, "/move", `{"source": "inbox/a.pdf", "destination": "docs/taken.pdf", "overwrite": true}`)
+	decodeJSON(t, rec, &res)
+	if !res.Moved {
+		t.Errorf("move = %+v", res)
+	}
+	if _, ok := store.object(testBucket, "inbox/a.pdf"); ok {
+		t.Error("move kept the source")
+	}
+	if data, _ := store.object(testBucket, "docs/taken.pdf"); string(data) != "pdf" {
+		t.Errorf("overwritten destination = %q", data)
+	}
+
+	rec = postJSON(h, "/rename", `{"source": "docs/a.pdf", "name": "b.pdf"}`)
+	decodeJSON(t, rec, &res)
+	if res.Destination != "docs/b.pdf" || !res.Moved {
+		t.Errorf("rename = %+v", res)
+	}
+
+	for _, tc := range []struct {
+		target, body string
+		want         int
+	}{
+		{"/copy", `{"source": "missing.pdf", "destination": "x.pdf"}`, http.StatusNotFound},
+		{"/copy", `{"source": "docs/b.pdf", "destination": "docs/b.pdf"}`, http.StatusBadRequest},
+		{"/copy", `{"source": "docs/b.pdf"}`, http.StatusBadRequest},
+		{"/rename", `{"source": "docs/b.pdf", "name": "../c.pdf"}`, http.StatusBadRequest},
+		// Other buckets need MINIO_BUCKETS.
+		{"/copy", `{"source": "docs/b.pdf", "destination": "b.pdf", "destination_bucket": "media"}`, http.StatusForbidden},
+	} {
+		if rec := postJSON(h, tc.target, tc.body); rec.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.target, tc.body, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestCopyAcrossBuckets(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	store.MakeBucket(t.Context(), "media", minio.MakeBucketOptions{})
+	h.buckets, _ = newBucketRouter(testBucket, []string{"media", "logs-*"}, true)
+	h.peers = h.buckets
+
+	if rec := postJSON(h, "/move", `{"source": "a.txt", "destination": "a.txt", "destination_bucket": "media"}`); rec.Code != http.StatusOK {
+		t.Fatalf("move to media: status %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := store.object("media", "a.txt"); !ok {
+		t.Error("object not in media")
+	}
+	// From a bucket's own routes, the main bucket is reachable too.
+	if rec := postJSON(h, "/b/media/copy", `{"source": "a.txt", "destination": "back.txt", "destination_bucket": "`+testBucket+`"}`); rec.Code != http.StatusOK {
+		t.Errorf("copy from media to the main bucket: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := postJSON(h, "/copy", `{"source": "a.txt", "source_bucket": "media", "destination": "a.txt", "destination_bucket": "logs-web"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("copy to an allowed bucket that doesn't exist: status %d", rec.Code)
+	}
+	if rec := postJSON(h, "/copy", `{"source": "a.txt", "source_bucket": "secrets", "destination": "a.txt"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("copy from a bucket not served: status %d", rec.Code)
+	}
+}
+
+func TestMoveNeedsDeleteScope(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.auth = &authenticator{jwt: &jwtAuth{alg: "HS256", secret: []byte(testJWTSecret)}}
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	token := signToken(t, "HS256", map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": "files:read files:write"}, hs256(testJWTSecret))
+
+	for target, want := range map[string]int{"/copy": http.StatusOK, "/move": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"source": "a.txt", "destination": "b`+strings.TrimPrefix(target, "/")+`.txt"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if rec := serve(h, req); rec.Code != want {
+			t.Errorf("%s with files:write: status %d, want %d: %s", target, rec.Code, want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dataURICache remembers encoded data URIs for a fixed TTL so templating
+// services rendering the same images repeatedly don't refetch them.
+type dataURICache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]dataURIEntry
+}
+
+type dataURIEntry struct {
+	uri     string
+	expires time.Time
+}
+
+func newDataURICache(ttl time.Duration) *dataURICache {
+	return &dataURICache{ttl: ttl, entries: make(map[string]dataURIEntry)}
+}
+
+func (c *dataURICache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return "", false
+	}
+	return e.uri, true
+}
+
+func (c *dataURICache) put(key, uri string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = dataURIEntry{uri: uri, expires: now.Add(c.ttl)}
+}
+
+// dataURIHandler returns a small object inlined as a base64 data URI, as
+// plain text or, with ?format=json, as {"name": ..., "data_uri": ...}.
+// Objects larger than MINIO_DATAURI_MAX_BYTES are rejected with 413.
+func (h *MinioHandler) dataURIHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/datauri/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /datauri/logo.png)", http.StatusBadRequest)
+		return
+	}
+
+	uri, ok := h.dataURIs.get(objectName)
+	if !ok {
+		obj, info, err := h.openObject(r.Context(), objectName)
+		if err != nil {
+			logger(r.Context()).Error("Error getting object", "object", objectName, "err", err)
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		defer obj.Close()
+		if info.Size > h.dataURIMaxBytes {
+			http.Error(w, fmt.Sprintf("File is %d bytes; data URIs are limited to %d bytes", info.Size, h.dataURIMaxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(obj, h.dataURIMaxBytes))
+		if err != nil {
+			logger(r.Context()).Error("Error reading object", "object", objectName, "err", err)
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return
+		}
+		contentType := info.ContentType
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		uri = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		h.dataURIs.put(objectName, uri)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, r, http.StatusOK, map[string]string{"name": objectName, "data_uri": uri})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, uri)
+}
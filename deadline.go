@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDurationHeader is the request header in which a client says how long
+// it is willing to wait for an operation, as a duration ("30s", "1m30s")
+// or whole seconds. ?max_duration= does the same for clients that can't
+// set headers.
+const maxDurationHeader = "X-Max-Duration"
+
+// parseMaxDuration parses an X-Max-Duration value.
+func parseMaxDuration(v string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 30s or 1m30s, got %q", maxDurationHeader, v)
+	}
+	return d, nil
+}
+
+// maxDuration returns the deadline the client asked for, zero if none,
+// clamped to MINIO_MAX_DURATION. The duration applied is echoed in the
+// X-Max-Duration response header so clients can tell when it was clamped.
+// It writes a 400 and returns false when the value is malformed.
+func (h *MinioHandler) maxDuration(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(maxDurationHeader)
+	if v == "" {
+		v = r.URL.Query().Get("max_duration")
+	}
+	if v == "" {
+		return 0, true
+	}
+	d, err := parseMaxDuration(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if h.maxDurationCap > 0 && d > h.maxDurationCap {
+		d = h.maxDurationCap
+	}
+	w.Header().Set(maxDurationHeader, d.String())
+	return d, true
+}
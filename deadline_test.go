@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseMaxDuration(t *testing.T) {
+	for v, want := range map[string]time.Duration{"30": 30 * time.Second, "1m30s": 90 * time.Second, "250ms": 250 * time.Millisecond} {
+		if got, err := parseMaxDuration(v); err != nil || got != want {
+			t.Errorf("parseMaxDuration(%q) = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"0", "-5s", "soon"} {
+		if _, err := parseMaxDuration(v); err == nil {
+			t.Errorf("parseMaxDuration(%q) succeeded", v)
+		}
+	}
+}
+
+func TestListMaxDuration(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.maxDurationCap = time.Minute
+	store.put(testBucket, "a.txt", []byte("x"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("x"), "text/plain")
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	req.Header.Set(maxDurationHeader, "1ns")
+	rec := serve(h, req)
+	var got listResponse
+	decodeJSON(t, rec, &got)
+	if !got.Truncated || !got.DeadlineExceeded || len(got.Files) == 2 {
+		t.Errorf("list = %+v, want truncated by the deadline", got)
+	}
+	if rec.Header().Get(maxDurationHeader) != "1ns" {
+		t.Errorf("%s = %q, want 1ns", maxDurationHeader, rec.Header().Get(maxDurationHeader))
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/list?max_duration=1h", nil))
+	decodeJSON(t, rec, &got)
+	if got.Truncated || len(got.Files) != 2 || rec.Header().Get(maxDurationHeader) != "1m0s" {
+		t.Errorf("list = %+v, %s = %q; want a full listing clamped to 1m0s", got, maxDurationHeader, rec.Header().Get(maxDurationHeader))
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?max_duration=soon", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad max_duration status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCleanupEmptyMaxDuration(t *testing.T) {
+	h, store := newTestHandler(t)
+	for _, key := range []string{"tmp/a.bin", "tmp/b.bin", "tmp/c.bin"} {
+		store.put(testBucket, key, nil, "application/octet-stream")
+	}
+
+	var report emptyObjectsReport
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/admin/cleanup-empty?prefix=tmp/&max_duration=1ns", nil)), &report)
+	if !report.Truncated || !report.DeadlineExceeded || report.Count == 3 {
+		t.Errorf("report = %+v, want truncated by the deadline", report)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/admin/cleanup-empty?prefix=tmp/&after=tmp/a.bin", nil)), &report)
+	if report.Truncated || report.Deleted != 2 {
+		t.Errorf("resumed cleanup = %+v, want b.bin and c.bin deleted", report)
+	}
+	if _, ok := store.object(testBucket, "tmp/a.bin"); !ok {
+		t.Error("cleanup resumed after tmp/a.bin removed it")
+	}
+}
+
+func TestScrubMaxDuration(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "cold/a.txt", []byte("a"), "text/plain")
+	store.put(testBucket, "cold/b.txt", []byte("b"), "text/plain")
+
+	p := runScrubJob(t, h, "?prefix=cold/&max_duration=1ns")
+	if !p.DeadlineExceeded || p.Scrubbed == 2 {
+		t.Errorf("progress = %+v, want stopped by the deadline", p)
+	}
+
+	p = runScrubJob(t, h, "?prefix=cold/&after=cold/a.txt")
+	if p.DeadlineExceeded || p.Scrubbed != 1 {
+		t.Errorf("resumed progress = %+v, want only cold/b.txt scrubbed", p)
+	}
+}
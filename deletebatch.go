@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// maxBatchDeleteKeys caps the objects one /delete-batch call removes. A
+// prefix with more is deleted over several calls.
+const maxBatchDeleteKeys = 10000
+
+type batchDeleteResult struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchDeleteReport struct {
+	Prefix  string `json:"prefix,omitempty"`
+	DryRun  bool   `json:"dry_run"`
+	Deleted int    `json:"deleted"`
+	Failed  int    `json:"failed"`
+	// Truncated means the prefix holds more than maxBatchDeleteKeys
+	// objects; call again to delete the rest.
+	Truncated bool                `json:"truncated"`
+	Results   []batchDeleteResult `json:"results"`
+}
+
+// deleteBatchHandler removes the objects named in "keys", or every object
+// under "prefix", in RemoveObjects batches (POST /delete-batch), and
+// reports each key as "deleted" or "failed". With "dry_run" it only lists
+// what would be deleted. With the trash enabled, objects are moved there
+// one by one instead, unless "permanent" is set.
+func (h *MinioHandler) deleteBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys   []string `json:"keys"`
+		Prefix string   `json:"prefix"`
+		DryRun bool     `json:"dry_run"`
+		// Permanent skips the trash.
+		Permanent bool `json:"permanent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (len(req.Keys) == 0) == (req.Prefix == "") {
+		http.Error(w, `Request body must be JSON like {"keys": ["a.txt", "b.txt"]} or {"prefix": "tmp/"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Keys) > maxBatchDeleteKeys {
+		http.Error(w, fmt.Sprintf("At most %d keys can be deleted per request", maxBatchDeleteKeys), http.StatusBadRequest)
+		return
+	}
+	if strings.Trim(req.Prefix, "/") == "" && req.Prefix != "" {
+		http.Error(w, "prefix must name a folder; the whole bucket can't be deleted this way", http.StatusBadRequest)
+		return
+	}
+	for _, objectName := range req.Keys {
+		if isTusState(objectName) {
+			http.Error(w, tusStateReserved, http.StatusForbidden)
+			return
+		}
+		if h.trash.holds(objectName) {
+			http.Error(w, trashReserved(h.trash.root()), http.StatusForbidden)
+			return
+		}
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := batchDeleteReport{Prefix: req.Prefix, DryRun: req.DryRun, Results: []batchDeleteResult{}}
+	keys := req.Keys
+	if req.Prefix != "" {
+		keys = nil
+		for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: req.Prefix, Recursive: true}) {
+			if object.Err != nil {
+				logger(r.Context()).Error("Error listing objects for batch delete", "prefix", req.Prefix, "err", object.Err)
+				h.storeFailed(w, "Failed to list files", object.Err)
+				return
+			}
+			if h.hiddenKey(object.Key) {
+				continue
+			}
+			if len(keys) == maxBatchDeleteKeys {
+				report.Truncated = true
+				break
+			}
+			keys = append(keys, object.Key)
+		}
+	}
+	if req.DryRun {
+		for _, objectName := range keys {
+			report.Results = append(report.Results, batchDeleteResult{Key: objectName, Status: "would_delete"})
+		}
+		writeJSON(w, r, http.StatusOK, report)
+		return
+	}
+
+	var removeErrs map[string]error
+	if h.trash.retention > 0 && !req.Permanent {
+		removeErrs = h.removeObjectsToTrash(r.Context(), keys, key)
+		for objectName, err := range removeErrs {
+			logger(r.Context()).Error("Error moving object to trash in batch", "object", objectName, "err", err)
+		}
+	} else {
+		removeErrs = h.removeObjects(r.Context(), keys)
+	}
+	for _, objectName := range keys {
+		res := batchDeleteResult{Key: objectName, Status: "deleted"}
+		switch err := removeErrs[objectName]; {
+		case objectName == "":
+			res.Status, res.Error = "failed", "empty key"
+		case err != nil:
+			res.Status, res.Error = "failed", err.Error()
+		default:
+			h.cache.invalidate(objectName)
+		}
+		if res.Status == "failed" {
+			report.Failed++
+		} else {
+			report.Deleted++
+		}
+		report.Results = append(report.Results, res)
+	}
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+// removeObjects deletes keys permanently in RemoveObjects batches,
+// returning the errors by key.
+func (h *MinioHandler) removeObjects(ctx context.Context, keys []string) map[string]error {
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, key := range keys {
+			if key != "" {
+				objectsCh <- minio.ObjectInfo{Key: key}
+			}
+		}
+	}()
+	errs := map[string]error{}
+	for rErr := range h.store.RemoveObjects(ctx, h.bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		logger(ctx).Error("Error removing object in batch", "object", rErr.ObjectName, "err", rErr.Err)
+		errs[rErr.ObjectName] = rErr.Err
+	}
+	return errs
+}
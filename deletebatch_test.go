@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestDeleteBatchKeys(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("b"), "text/plain")
+	store.put(testBucket, "keep.txt", []byte("k"), "text/plain")
+
+	var report batchDeleteReport
+	decodeJSON(t, postJSON(h, "/delete-batch", `{"keys": ["a.txt", "b.txt", ""]}`), &report)
+	if report.Deleted != 2 || report.Failed != 1 || len(report.Results) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Results[0] != (batchDeleteResult{Key: "a.txt", Status: "deleted"}) || report.Results[2].Status != "failed" {
+		t.Errorf("results = %+v", report.Results)
+	}
+	for _, key := range []string{"a.txt", "b.txt"} {
+		if _, ok := store.object(testBucket, key); ok {
+			t.Errorf("%s still exists", key)
+		}
+	}
+	if _, ok := store.object(testBucket, "keep.txt"); !ok {
+		t.Error("keep.txt was deleted")
+	}
+}
+
+func TestDeleteBatchPrefix(t *testing.T) {
+	h, store := newTestHandler(t)
+	for i := range 3 {
+		store.put(testBucket, fmt.Sprintf("tmp/%d.txt", i), []byte("x"), "text/plain")
+	}
+	store.put(testBucket, "tmp.txt", []byte("x"), "text/plain")
+
+	var report batchDeleteReport
+	decodeJSON(t, postJSON(h, "/delete-batch", `{"prefix": "tmp/", "dry_run": true}`), &report)
+	if len(report.Results) != 3 || report.Results[0].Status != "would_delete" || report.Deleted != 0 {
+		t.Errorf("dry run = %+v", report)
+	}
+	if _, ok := store.object(testBucket, "tmp/0.txt"); !ok {
+		t.Error("dry run deleted objects")
+	}
+
+	decodeJSON(t, postJSON(h, "/delete-batch", `{"prefix": "tmp/"}`), &report)
+	if report.Deleted != 3 || report.Truncated {
+		t.Errorf("report = %+v", report)
+	}
+	if _, ok := store.object(testBucket, "tmp.txt"); !ok {
+		t.Error("an object outside the prefix was deleted")
+	}
+
+	for _, body := range []string{`{}`, `{"prefix": "/"}`, `{"keys": ["a"], "prefix": "tmp/"}`, `[]`} {
+		if rec := postJSON(h, "/delete-batch", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestDeleteBatchKeepsTusState(t *testing.T) {
+	h, store := newTestHandler(t)
+	state := tusStatePrefix + "x.info"
+	store.put(testBucket, state, []byte("{}"), "application/json")
+
+	if rec := postJSON(h, "/delete-batch", `{"keys": ["`+state+`"]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("explicit key: status %d, want 403", rec.Code)
+	}
+	var report batchDeleteReport
+	decodeJSON(t, postJSON(h, "/delete-batch", `{"prefix": "`+tusStatePrefix+`"}`), &report)
+	if report.Deleted != 0 || len(report.Results) != 0 {
+		t.Errorf("prefix: report = %+v", report)
+	}
+	if _, ok := store.object(testBucket, state); !ok {
+		t.Error("upload state was deleted")
+	}
+}
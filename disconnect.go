@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// statusClientClosedRequest is nginx's non-standard 499, logged for
+// requests the client abandoned before a response could be sent.
+const statusClientClosedRequest = 499
+
+// clientDisconnected reports whether err, returned while reading an upload,
+// means the client went away rather than that the server failed.
+func clientDisconnected(r *http.Request, err error) bool {
+	return r.Context().Err() != nil || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.Canceled)
+}
+
+// uploadAbandoned records an upload the client disconnected from. Nothing
+// was stored: forms are read in full before PutObject starts, and streamed
+// uploads run on a context that outlives the request so the SDK can still
+// abort their multipart upload. The status (MINIO_CLIENT_CLOSED_STATUS) is
+// never seen by the client but keeps access logs and error metrics apart
+// from real server errors.
+func (h *MinioHandler) uploadAbandoned(w http.ResponseWriter, r *http.Request, err error) {
+	logger(r.Context()).Info("Client disconnected during upload; upload aborted", "path", r.URL.Path, "err", err)
+	status := h.clientClosedStatus
+	if status == 0 {
+		status = statusClientClosedRequest
+	}
+	w.WriteHeader(status)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRawUploadClientDisconnect(t *testing.T) {
+	h, store := newTestHandler(t)
+	// The body ends early, as when the client drops the connection.
+	body := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/big.bin", body))
+	if rec.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+	if _, ok := store.object(testBucket, "big.bin"); ok {
+		t.Error("a partial object was stored")
+	}
+
+	h.clientClosedStatus = http.StatusBadRequest
+	body = io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/big.bin", body)); rec.Code != http.StatusBadRequest {
+		t.Errorf("configured status: got %d, want 400", rec.Code)
+	}
+}
+
+func TestFormUploadClientDisconnect(t *testing.T) {
+	h, store := newTestHandler(t)
+	var full bytes.Buffer
+	mw := multipart.NewWriter(&full)
+	part, _ := mw.CreateFormFile("file", "cut.txt")
+	part.Write(bytes.Repeat([]byte("x"), 4096))
+	mw.Close()
+
+	// Only half of the form arrives.
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(full.Bytes()[:full.Len()/2]))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if rec := serve(h, req); rec.Code != statusClientClosedRequest {
+		t.Errorf("truncated form: status %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+
+	// A canceled request context also counts as a disconnect.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req = httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("not a form")).WithContext(ctx)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if rec := serve(h, req); rec.Code != statusClientClosedRequest {
+		t.Errorf("canceled request: status %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+	if _, ok := store.object(testBucket, "cut.txt"); ok {
+		t.Error("a partial object was stored")
+	}
+
+	// A malformed but complete form is still the client's error.
+	req = httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("garbage"))
+	req.Header.Set("Content-Type", "multipart/form-data")
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed form: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// downloadFileHandler streams /download/{objectName} through the service,
+// for deployments where clients can't reach MinIO to follow a presigned
+// link. http.ServeContent answers Range (including If-Range),
+// If-None-Match and If-Modified-Since from the object's ETag and
+// modification time, so video players can seek and downloaders can resume;
+// each range is read from MinIO as it is served. The object is read as of
+// the version first stated, and is always sent as an attachment, as an
+// opaque download when MINIO_UNTRUSTED_* says it may not be rendered.
+// ?version_id= downloads that version instead of the current one. An
+// object stored with a customer-provided key (SSE-C) takes the same key
+// headers.
+func (h *MinioHandler) downloadFileHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/download/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /download/my-image.jpg)", http.StatusBadRequest)
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	versionID := r.URL.Query().Get("version_id")
+	info, err := h.statObject(ctx, objectName, minio.StatObjectOptions{VersionID: versionID, ServerSideEncryption: key})
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey":
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		case "NoSuchVersion", "InvalidArgument", "MethodNotAllowed":
+			if versionID != "" {
+				http.Error(w, "Version not found", http.StatusNotFound)
+				return
+			}
+		}
+		if wrongCustomerKey(err, key) {
+			http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+			return
+		}
+		logger(r.Context()).Error("Error stating object for download", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to read file", err)
+		return
+	}
+	opts := minio.GetObjectOptions{VersionID: versionID, ServerSideEncryption: key}
+	opts.SetMatchETag(info.ETag)
+	// Nothing is fetched until ServeContent seeks or reads, so a 304 or
+	// 412 costs no transfer from MinIO.
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		logger(r.Context()).Error("Error getting object for download", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to read file", err)
+		return
+	}
+	defer obj.Close()
+
+	// Ranges are of the stored bytes, so they must not be re-encoded.
+	skipCompression(w)
+	h.applyInferredType(r, objectName, &info)
+	if h.untrusted.untrustedPrefix(objectName) || h.untrusted.untrustedType(info.ContentType) {
+		info.ContentType = "application/octet-stream"
+	}
+	setObjectHeaders(w, info)
+	h.setPropagatedHeaders(w, info)
+	if coding := info.Metadata.Get("Content-Encoding"); coding != "" {
+		w.Header().Set("Content-Encoding", coding)
+	}
+	w.Header().Set("Content-Disposition", attachmentDisposition(path.Base(objectName)))
+	http.ServeContent(w, r, objectName, info.LastModified, obj)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadStreamsObject(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "videos/clip.mp4", []byte("0123456789"), "video/mp4")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/videos/clip.mp4", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("got %d %q, want 200 with the object", rec.Code, rec.Body.String())
+	}
+	for name, want := range map[string]string{
+		"Content-Type":        "video/mp4",
+		"Content-Length":      "10",
+		"Accept-Ranges":       "bytes",
+		"Content-Disposition": `attachment; filename=clip.mp4`,
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if rec.Header().Get("ETag") == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Errorf("missing validators: %v", rec.Header())
+	}
+
+	head := serve(h, httptest.NewRequest(http.MethodHead, "/download/videos/clip.mp4", nil))
+	if head.Code != http.StatusOK || head.Body.Len() != 0 || head.Header().Get("Content-Length") != "10" {
+		t.Errorf("HEAD: got %d, %d body bytes, Content-Length %q", head.Code, head.Body.Len(), head.Header().Get("Content-Length"))
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/missing.mp4", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object: got %d, want 404", rec.Code)
+	}
+}
+
+func TestDownloadHonorsRange(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "clip.mp4", []byte("0123456789"), "video/mp4")
+
+	for _, tc := range []struct {
+		rng        string
+		wantStatus int
+		wantBody   string
+		wantRange  string
+	}{
+		{"bytes=2-5", http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=-3", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=20-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/download/clip.mp4", nil)
+		req.Header.Set("Range", tc.rng)
+		rec := serve(h, req)
+		if rec.Code != tc.wantStatus || rec.Header().Get("Content-Range") != tc.wantRange {
+			t.Errorf("Range %s: got %d, Content-Range %q; want %d, %q", tc.rng, rec.Code, rec.Header().Get("Content-Range"), tc.wantStatus, tc.wantRange)
+		}
+		if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+			t.Errorf("Range %s: body %q, want %q", tc.rng, rec.Body.String(), tc.wantBody)
+		}
+	}
+
+	// A stale If-Range gets the whole, current object.
+	req := httptest.NewRequest(http.MethodGet, "/download/clip.mp4", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	req.Header.Set("If-Range", `"stale"`)
+	if rec := serve(h, req); rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("stale If-Range: got %d %q, want 200 with the whole object", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDownloadConditionalRequests(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "report.pdf", []byte("%PDF-1.7"), "application/pdf")
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/report.pdf", nil))
+	etag := rec.Header().Get("ETag")
+	modified, err := http.ParseTime(rec.Header().Get("Last-Modified"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, header, value string
+		want                int
+	}{
+		{"matching If-None-Match", "If-None-Match", etag, http.StatusNotModified},
+		{"other If-None-Match", "If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since now", "If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since earlier", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/download/report.pdf", nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := serve(h, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+		if tc.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 with a %d byte body", tc.name, rec.Body.Len())
+		}
+	}
+}
+
+func TestDownloadForcesOpaqueTypeForUntrustedContent(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.untrusted = untrustedRules{types: []string{"text/html"}}
+	store.put(testBucket, "page.html", []byte("<script>alert(1)</script>"), "text/html")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/page.html", nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want application/octet-stream", got)
+	}
+}
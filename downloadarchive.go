@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// archiveSettings configures /download-archive.
+type archiveSettings struct {
+	prefix string
+	// abortOnDisconnect drops the archive copy when the client goes away
+	// mid-download; otherwise the rest of the object is still archived.
+	abortOnDisconnect bool
+}
+
+// loadArchiveSettings reads MINIO_ARCHIVE_PREFIX and
+// MINIO_ARCHIVE_ON_DISCONNECT ("complete", the default, or "abort").
+func loadArchiveSettings() (archiveSettings, error) {
+	s := archiveSettings{prefix: "archive/"}
+	if v, ok := os.LookupEnv("MINIO_ARCHIVE_PREFIX"); ok {
+		s.prefix = v
+	}
+	switch v := os.Getenv("MINIO_ARCHIVE_ON_DISCONNECT"); v {
+	case "", "complete":
+	case "abort":
+		s.abortOnDisconnect = true
+	default:
+		return s, fmt.Errorf("MINIO_ARCHIVE_ON_DISCONNECT: unknown value %q (use complete or abort)", v)
+	}
+	return s, nil
+}
+
+// errClientGone aborts an archive write after the client disconnected.
+var errClientGone = errors.New("client disconnected during download")
+
+// clientWriter remembers whether writing to the client failed, so a
+// disconnect can be told apart from a failed read of the object.
+type clientWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// softWriter passes writes on to w until one fails and then discards the
+// rest, so a failed archive write doesn't cut off the client's download.
+type softWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *softWriter) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// downloadArchiveHandler streams /download-archive/{objectName} to the
+// client and, from the same read, writes a copy to the archive prefix: the
+// object stream is teed into a pipe that feeds PutObject. If the client
+// disconnects, the archive copy is either completed from the remaining
+// stream or aborted, per MINIO_ARCHIVE_ON_DISCONNECT. The archive key is
+// reported in X-Archive-Key.
+func (h *MinioHandler) downloadArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/download-archive/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /download-archive/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	archiveKey := h.archive.prefix + objectName
+	if status, reason := h.uploadRules.check(h.bucketName, archiveKey); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{})
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	// The object read and the archive write outlive the request unless the
+	// archive should be aborted along with it. Either way, they end with the
+	// shutdown grace period.
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	if h.archive.abortOnDisconnect {
+		ctx = r.Context()
+	}
+	getOpts := minio.GetObjectOptions{}
+	getOpts.SetMatchETag(info.ETag)
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, getOpts)
+	if err != nil {
+		logger(r.Context()).Error("Error getting object for archive download", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to read file", err)
+		return
+	}
+	defer obj.Close()
+
+	pr, pw := io.Pipe()
+	archived := make(chan error, 1)
+	go func() {
+		opts, _ := h.uploadOptions(archiveKey, info.ContentType)
+		_, err := h.store.PutObject(ctx, h.bucketName, archiveKey, pr, info.Size, opts)
+		// Fails further pipe writes if the upload gave up early.
+		pr.CloseWithError(err)
+		archived <- err
+	}()
+
+	skipCompression(w)
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Content-Disposition", attachmentDisposition(path.Base(objectName)))
+	w.Header().Set("X-Archive-Key", archiveKey)
+	client := &clientWriter{w: w}
+	_, err = io.Copy(client, io.TeeReader(obj, &softWriter{w: pw}))
+	switch {
+	case client.err != nil && !h.archive.abortOnDisconnect:
+		// The client is gone; finish the archive from the rest of the object.
+		_, err = io.Copy(pw, obj)
+		pw.CloseWithError(err)
+	case client.err != nil:
+		pw.CloseWithError(errClientGone)
+	default:
+		pw.CloseWithError(err)
+	}
+
+	if err := <-archived; err != nil {
+		logger(r.Context()).Error("Error archiving object", "object", objectName, "archive", archiveKey, "err", err)
+		return
+	}
+	h.cache.invalidate(archiveKey)
+}
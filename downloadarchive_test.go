@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// brokenClient is a ResponseWriter whose connection drops after limit bytes.
+type brokenClient struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (b *brokenClient) Write(p []byte) (int, error) {
+	if b.Body.Len()+len(p) > b.limit {
+		return 0, errors.New("connection reset by peer")
+	}
+	return b.ResponseRecorder.Write(p)
+}
+
+func TestDownloadArchive(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "reports/q1.csv", []byte("a,b\n1,2\n"), "text/csv")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-archive/reports/q1.csv", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "a,b\n1,2\n" {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Archive-Key"); got != "archive/reports/q1.csv" {
+		t.Errorf("X-Archive-Key = %q", got)
+	}
+	if data, ok := store.object(testBucket, "archive/reports/q1.csv"); !ok || string(data) != "a,b\n1,2\n" {
+		t.Errorf("archive = %q, %v", data, ok)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-archive/missing.csv", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDownloadArchiveClientDisconnects(t *testing.T) {
+	payload := strings.Repeat("x", 256<<10)
+	for _, abort := range []bool{false, true} {
+		h, store := newTestHandler(t)
+		h.archive.abortOnDisconnect = abort
+		store.put(testBucket, "big.bin", []byte(payload), "application/octet-stream")
+
+		client := &brokenClient{ResponseRecorder: httptest.NewRecorder(), limit: 1024}
+		h.routes().ServeHTTP(client, httptest.NewRequest(http.MethodGet, "/download-archive/big.bin", nil))
+
+		data, ok := store.object(testBucket, "archive/big.bin")
+		if abort && ok {
+			t.Error("abort: archive copy was stored")
+		}
+		if !abort && string(data) != payload {
+			t.Errorf("complete: archive has %d bytes, want %d", len(data), len(payload))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// zipHandler streams every object under ?prefix= as a zip archive, like
+// tarHandler: entries keep their full object names and are compressed and
+// written one at a time, so memory use doesn't depend on the size of the
+// prefix. Objects removed after they were listed are left out. Any other
+// error ends the response early, leaving an archive without its central
+// directory that unzip reports as damaged.
+func (h *MinioHandler) zipHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	objectCh := h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	// Peek at the listing so a bad prefix or bucket still gets a proper error.
+	first, ok := <-objectCh
+	for ok && first.Err == nil && h.hiddenKey(first.Key) {
+		first, ok = <-objectCh
+	}
+	if ok && first.Err != nil {
+		logger(r.Context()).Error("Error listing objects for zip", "err", first.Err)
+		h.storeFailed(w, "Failed to list files", first.Err)
+		return
+	}
+	if !ok {
+		http.Error(w, "No objects found under prefix", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", attachmentDisposition(h.prefixArchiveName(prefix)+".zip"))
+	zw := zip.NewWriter(w)
+
+	for object := first; ok; object, ok = <-objectCh {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing objects for zip", "err", object.Err)
+			return
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		if err := h.writeZipEntry(r, zw, object.Key); err != nil {
+			logger(r.Context()).Error("Error adding object to zip", "object", object.Key, "err", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logger(r.Context()).Error("Error finishing zip", "err", err)
+	}
+}
+
+// writeZipEntry copies one object into zw, as a folder entry for folder
+// markers. An object that no longer exists is skipped.
+func (h *MinioHandler) writeZipEntry(r *http.Request, zw *zip.Writer, key string) error {
+	if strings.HasSuffix(key, "/") {
+		_, err := zw.CreateHeader(&zip.FileHeader{Name: key, Method: zip.Store})
+		return err
+	}
+	obj, info, err := h.openObject(r.Context(), key)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: key, Method: zip.Deflate, Modified: info.LastModified})
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(entry, obj)
+	if err == nil && n != info.Size {
+		err = fmt.Errorf("object changed while archiving: read %d of %d bytes", n, info.Size)
+	}
+	return err
+}
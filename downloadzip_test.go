@@ -0,0 +1,49 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZipHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "photos/2024/a.jpg", []byte("alpha"), "image/jpeg")
+	store.put(testBucket, "photos/2024/trip/b.jpg", []byte("bravo!"), "image/jpeg")
+	store.put(testBucket, "photos/2023/c.jpg", []byte("x"), "image/jpeg")
+	putWithExpiry(t, store, "photos/2024/gone.jpg", time.Now().Add(-time.Minute))
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-zip?prefix=photos/2024/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "attachment; filename=2024.zip" {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got = append(got, f.Name+"="+string(data))
+	}
+	if want := "photos/2024/a.jpg=alpha,photos/2024/trip/b.jpg=bravo!"; strings.Join(got, ",") != want {
+		t.Errorf("entries = %v, want %s", got, want)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-zip?prefix=nothing/", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("empty prefix: status = %d, want 404", rec.Code)
+	}
+}
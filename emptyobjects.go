@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// emptyObjectsReport lists the zero-byte objects under a prefix.
+type emptyObjectsReport struct {
+	Prefix string `json:"prefix"`
+	DryRun bool   `json:"dry_run"`
+	Count  int    `json:"count"`
+	// Excluded counts zero-byte objects kept as folder markers or because
+	// they match MINIO_EMPTY_OBJECT_EXCLUDE.
+	Excluded int      `json:"excluded"`
+	Deleted  int      `json:"deleted"`
+	Failed   int      `json:"failed"`
+	Objects  []string `json:"objects"`
+	// Truncated is set when the client's X-Max-Duration ran out before the
+	// whole prefix was listed; Next is passed back as ?after= to continue.
+	Truncated        bool   `json:"truncated"`
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"`
+	Next             string `json:"next,omitempty"`
+}
+
+// legitimatelyEmpty reports whether a zero-byte key is meant to be empty:
+// a folder placeholder ending in "/", or a name matching one of the
+// MINIO_EMPTY_OBJECT_EXCLUDE patterns (same matching as upload rules).
+func (h *MinioHandler) legitimatelyEmpty(objectName string) bool {
+	if strings.HasSuffix(objectName, "/") {
+		return true
+	}
+	_, ok := matchKey(h.emptyExclude, objectName)
+	return ok
+}
+
+// findEmptyObjects lists the zero-byte objects under prefix, after the key
+// after, that aren't legitimately empty. A listing cut short by ctx's
+// deadline is reported as truncated rather than as an error.
+func (h *MinioHandler) findEmptyObjects(ctx context.Context, prefix, after string) (emptyObjectsReport, error) {
+	report := emptyObjectsReport{Prefix: prefix, Objects: []string{}}
+	scanned := after
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true, StartAfter: after}) {
+		if ctx.Err() != nil {
+			break
+		}
+		if object.Err != nil {
+			return report, object.Err
+		}
+		scanned = object.Key
+		if object.Size != 0 {
+			continue
+		}
+		if h.legitimatelyEmpty(object.Key) {
+			report.Excluded++
+			continue
+		}
+		report.Objects = append(report.Objects, object.Key)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		report.Truncated, report.DeadlineExceeded, report.Next = true, true, scanned
+	}
+	report.Count = len(report.Objects)
+	return report, nil
+}
+
+// listEmptyObjects runs findEmptyObjects for ?prefix= and ?after= within
+// the client's X-Max-Duration, if any. It writes the error response itself
+// and returns false on failure.
+func (h *MinioHandler) listEmptyObjects(w http.ResponseWriter, r *http.Request) (emptyObjectsReport, bool) {
+	timeout, ok := h.maxDuration(w, r)
+	if !ok {
+		return emptyObjectsReport{}, false
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), timeout)
+	}
+	defer cancel()
+	q := r.URL.Query()
+	report, err := h.findEmptyObjects(ctx, q.Get("prefix"), q.Get("after"))
+	if err != nil {
+		logger(r.Context()).Error("Error listing objects for empty-object report", "err", err)
+		h.storeFailed(w, "Failed to list files", err)
+		return report, false
+	}
+	return report, true
+}
+
+// emptyObjectsHandler reports the zero-byte objects under ?prefix=, such
+// as those left behind by failed uploads.
+func (h *MinioHandler) emptyObjectsHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.listEmptyObjects(w, r)
+	if !ok {
+		return
+	}
+	report.DryRun = true
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+// cleanupEmptyHandler removes the zero-byte objects under ?prefix= in one
+// RemoveObjects batch, or with ?dry_run=true only reports them. Each one is
+// stat'ed again first so an object written since the listing is kept.
+//
+// X-Max-Duration bounds the listing: when it runs out, the objects found
+// so far are still removed and the report is truncated, with ?after= to
+// pick up from in the next call.
+func (h *MinioHandler) cleanupEmptyHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.listEmptyObjects(w, r)
+	if !ok {
+		return
+	}
+	report.DryRun = r.URL.Query().Get("dry_run") == "true"
+	if report.DryRun || report.Count == 0 {
+		writeJSON(w, r, http.StatusOK, report)
+		return
+	}
+
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, key := range report.Objects {
+			info, err := h.store.StatObject(r.Context(), h.bucketName, key, minio.StatObjectOptions{})
+			if err != nil || info.Size != 0 {
+				continue
+			}
+			objectsCh <- minio.ObjectInfo{Key: key}
+			report.Deleted++
+		}
+	}()
+	for rErr := range h.store.RemoveObjects(r.Context(), h.bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		logger(r.Context()).Error("Error removing empty object", "object", rErr.ObjectName, "err", rErr.Err)
+		report.Failed++
+	}
+	report.Deleted -= report.Failed
+	for _, key := range report.Objects {
+		h.cache.invalidate(key)
+	}
+	writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmptyObjectsCleanup(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.emptyExclude = []string{".keep"}
+	for key, data := range map[string]string{
+		"uploads/failed.bin": "",
+		"uploads/ok.bin":     "data",
+		"uploads/folder/":    "",
+		"uploads/.keep":      "",
+		"other/failed.bin":   "",
+	} {
+		store.put(testBucket, key, []byte(data), "application/octet-stream")
+	}
+
+	var report emptyObjectsReport
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/admin/empty-objects?prefix=uploads/", nil)), &report)
+	if report.Count != 1 || report.Objects[0] != "uploads/failed.bin" || report.Excluded != 2 {
+		t.Errorf("report = %+v", report)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/admin/cleanup-empty?prefix=uploads/&dry_run=true", nil)), &report)
+	if _, ok := store.object(testBucket, "uploads/failed.bin"); !ok || report.Deleted != 0 || !report.DryRun {
+		t.Errorf("dry run removed objects: %+v", report)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/admin/cleanup-empty?prefix=uploads/", nil)), &report)
+	if report.Deleted != 1 || report.Failed != 0 {
+		t.Errorf("cleanup = %+v", report)
+	}
+	for key, want := range map[string]bool{"uploads/failed.bin": false, "uploads/folder/": true, "uploads/.keep": true, "other/failed.bin": true} {
+		if _, ok := store.object(testBucket, key); ok != want {
+			t.Errorf("%s exists = %v, want %v", key, ok, want)
+		}
+	}
+
+	h.setReadOnly(true)
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/cleanup-empty", strings.NewReader(""))); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("read-only cleanup status = %d, want 503", rec.Code)
+	}
+}
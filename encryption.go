@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/sse"
+)
+
+// Default encryption algorithms, as S3 names them.
+const (
+	sseS3Algorithm  = "AES256"
+	sseKMSAlgorithm = "aws:kms"
+)
+
+// bucketEncryption is the JSON view of a bucket's default encryption.
+type bucketEncryption struct {
+	Enabled   bool   `json:"enabled"`
+	Algorithm string `json:"algorithm,omitempty"`
+	KMSKeyID  string `json:"kms_key_id,omitempty"`
+}
+
+func encryptionOf(config *sse.Configuration) bucketEncryption {
+	if config == nil || len(config.Rules) == 0 {
+		return bucketEncryption{}
+	}
+	apply := config.Rules[0].Apply
+	return bucketEncryption{Enabled: true, Algorithm: apply.SSEAlgorithm, KMSKeyID: apply.KmsMasterKeyID}
+}
+
+// currentEncryption reads the bucket's default encryption. A bucket without
+// one reports it as disabled rather than as an error.
+func (h *MinioHandler) currentEncryption(r *http.Request) (bucketEncryption, error) {
+	config, err := h.store.GetBucketEncryption(r.Context(), h.bucketName)
+	if minio.ToErrorResponse(err).Code == "ServerSideEncryptionConfigurationNotFoundError" {
+		return bucketEncryption{}, nil
+	}
+	if err != nil {
+		return bucketEncryption{}, err
+	}
+	return encryptionOf(config), nil
+}
+
+// bucketEncryptionHandler reports (GET) or sets (PUT) the bucket's default
+// encryption, which MinIO applies to every upload that doesn't ask for
+// something else. A PUT body is {"algorithm": "AES256"} for SSE-S3 or
+// {"algorithm": "aws:kms", "kms_key_id": "my-key"} for SSE-KMS; both
+// answer with the configuration now in effect.
+func (h *MinioHandler) bucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		if h.rejectIfReadOnly(w) {
+			return
+		}
+		var req struct {
+			Algorithm string `json:"algorithm"`
+			KMSKeyID  string `json:"kms_key_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `Request body must be JSON like {"algorithm": "AES256"} or {"algorithm": "aws:kms", "kms_key_id": "my-key"}`, http.StatusBadRequest)
+			return
+		}
+		var config *sse.Configuration
+		switch {
+		case req.Algorithm == sseS3Algorithm && req.KMSKeyID == "":
+			config = sse.NewConfigurationSSES3()
+		case req.Algorithm == sseS3Algorithm:
+			http.Error(w, "kms_key_id only applies to the aws:kms algorithm", http.StatusBadRequest)
+			return
+		case req.Algorithm == sseKMSAlgorithm:
+			config = sse.NewConfigurationSSEKMS(req.KMSKeyID)
+		default:
+			http.Error(w, `algorithm must be "AES256" (SSE-S3) or "aws:kms" (SSE-KMS)`, http.StatusBadRequest)
+			return
+		}
+		if err := h.store.SetBucketEncryption(r.Context(), h.bucketName, config); err != nil {
+			logger(r.Context()).Error("Error setting encryption of bucket", "bucket", h.bucketName, "err", err)
+			if resp := minio.ToErrorResponse(err); resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				// Typically KMS isn't configured on the server or the key is unknown.
+				http.Error(w, "MinIO rejected the encryption configuration: "+resp.Message, http.StatusBadRequest)
+				return
+			}
+			h.storeFailed(w, "Failed to set bucket encryption", err)
+			return
+		}
+	}
+
+	current, err := h.currentEncryption(r)
+	if err != nil {
+		logger(r.Context()).Error("Error reading encryption of bucket", "bucket", h.bucketName, "err", err)
+		h.storeFailed(w, "Failed to read bucket encryption", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, current)
+}
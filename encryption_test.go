@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBucketEncryption(t *testing.T) {
+	h, _ := newTestHandler(t)
+	put := func(body string) *httptest.ResponseRecorder {
+		return serve(h, httptest.NewRequest(http.MethodPut, "/bucket/encryption", strings.NewReader(body)))
+	}
+
+	var got bucketEncryption
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/bucket/encryption", nil))
+	decodeJSON(t, rec, &got)
+	if rec.Code != http.StatusOK || got.Enabled {
+		t.Fatalf("unset: status %d, %+v", rec.Code, got)
+	}
+
+	rec = put(`{"algorithm": "AES256"}`)
+	got = bucketEncryption{}
+	decodeJSON(t, rec, &got)
+	if rec.Code != http.StatusOK || got != (bucketEncryption{Enabled: true, Algorithm: "AES256"}) {
+		t.Fatalf("SSE-S3: status %d, %+v", rec.Code, got)
+	}
+
+	rec = put(`{"algorithm": "aws:kms", "kms_key_id": "archive-key"}`)
+	got = bucketEncryption{}
+	decodeJSON(t, rec, &got)
+	if got != (bucketEncryption{Enabled: true, Algorithm: "aws:kms", KMSKeyID: "archive-key"}) {
+		t.Fatalf("SSE-KMS: %+v", got)
+	}
+
+	for _, bad := range []string{`{"algorithm": "DES"}`, `{"algorithm": "AES256", "kms_key_id": "k"}`, `not json`} {
+		if rec := put(bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", bad, rec.Code)
+		}
+	}
+	if rec := put(`{"algorithm": "aws:kms"}`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "No default KMS key") {
+		t.Errorf("rejected by MinIO: status %d: %s", rec.Code, rec.Body)
+	}
+
+	h.setReadOnly(true)
+	defer h.setReadOnly(false)
+	if rec := put(`{"algorithm": "AES256"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("read-only: status %d, want 503", rec.Code)
+	}
+}
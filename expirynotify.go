@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// expiryWebhookTimeout bounds one delivery to MINIO_EXPIRY_WEBHOOK_URL.
+const expiryWebhookTimeout = 10 * time.Second
+
+// expiringObject is an object due to expire within the notice lead time.
+type expiringObject struct {
+	Key       string    `json:"key"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// expiryNotice lists the objects a scan found newly due to expire.
+type expiryNotice struct {
+	Time    time.Time        `json:"time"`
+	Lead    string           `json:"lead"`
+	Objects []expiringObject `json:"objects"`
+}
+
+// expiryNotifier warns ahead of time about objects stamped with an expiry
+// (see ttl.go). Each scan sends one notice listing the objects that entered
+// the lead time since the last one, to /watch clients as an "expiring"
+// event and, when configured, as a POST to a webhook.
+type expiryNotifier struct {
+	lead    time.Duration
+	webhook string
+	client  *http.Client
+
+	mu sync.Mutex
+	// notified maps keys already announced to the expiry they were
+	// announced with; an object stamped again is announced again.
+	notified map[string]time.Time
+	subs     map[chan expiryNotice]struct{}
+}
+
+// newExpiryNotifier returns nil, disabling notices, when lead is not
+// positive.
+func newExpiryNotifier(lead time.Duration, webhook string) *expiryNotifier {
+	if lead <= 0 {
+		return nil
+	}
+	return &expiryNotifier{
+		lead:     lead,
+		webhook:  webhook,
+		client:   &http.Client{Timeout: expiryWebhookTimeout},
+		notified: make(map[string]time.Time),
+		subs:     make(map[chan expiryNotice]struct{}),
+	}
+}
+
+// subscribe returns a channel of notices and a function that ends the
+// subscription. A nil notifier returns a nil channel, which never delivers.
+func (n *expiryNotifier) subscribe() (<-chan expiryNotice, func()) {
+	if n == nil {
+		return nil, func() {}
+	}
+	ch := make(chan expiryNotice, 4)
+	n.mu.Lock()
+	n.subs[ch] = struct{}{}
+	n.mu.Unlock()
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subs, ch)
+		n.mu.Unlock()
+	}
+}
+
+// broadcast hands notice to every subscriber, skipping those whose buffer
+// is full rather than stalling the scan.
+func (n *expiryNotifier) broadcast(notice expiryNotice) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs {
+		select {
+		case ch <- notice:
+		default:
+		}
+	}
+}
+
+// post delivers notice to the webhook, if one is configured.
+func (n *expiryNotifier) post(ctx context.Context, notice expiryNotice) error {
+	if n.webhook == "" {
+		return nil
+	}
+	body, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// runExpiryNotifier scans for expiring objects every interval until ctx is
+// done.
+func (h *MinioHandler) runExpiryNotifier(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			notice, err := h.notifyExpiring(ctx)
+			if err != nil {
+				logger(ctx).Error("Error notifying about expiring objects", "err", err)
+			}
+			if len(notice.Objects) > 0 {
+				logger(ctx).Info("Announced expiring objects", "objects", len(notice.Objects), "within", h.expiryNotices.lead)
+			}
+		}
+	}
+}
+
+// notifyExpiring lists the bucket and announces the objects that expire
+// within the lead time and haven't been announced yet. Like sweepExpired,
+// it stats objects whose listing entry carries no metadata. When the
+// webhook can't be reached the objects stay unannounced, so the next scan
+// tries again.
+func (h *MinioHandler) notifyExpiring(ctx context.Context) (expiryNotice, error) {
+	n := h.expiryNotices
+	now := time.Now()
+	notice := expiryNotice{Time: now.UTC(), Lead: n.lead.String(), Objects: []expiringObject{}}
+	due := make(map[string]time.Time)
+	objectCh := h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Recursive: true, WithMetadata: true})
+	for object := range objectCh {
+		if object.Err != nil {
+			return expiryNotice{}, object.Err
+		}
+		if object.UserMetadata == nil {
+			info, err := h.store.StatObject(ctx, h.bucketName, object.Key, minio.StatObjectOptions{})
+			if err != nil {
+				continue
+			}
+			object.UserMetadata = info.UserMetadata
+		}
+		at, ok := objectExpiry(object)
+		if !ok || !at.After(now) || at.After(now.Add(n.lead)) {
+			continue
+		}
+		due[object.Key] = at
+		n.mu.Lock()
+		announced, seen := n.notified[object.Key]
+		n.mu.Unlock()
+		if !seen || !announced.Equal(at) {
+			notice.Objects = append(notice.Objects, expiringObject{Key: object.Key, Size: object.Size, ExpiresAt: at.UTC()})
+		}
+	}
+	if len(notice.Objects) > 0 {
+		n.broadcast(notice)
+		if err := n.post(ctx, notice); err != nil {
+			return notice, fmt.Errorf("posting to MINIO_EXPIRY_WEBHOOK_URL: %w", err)
+		}
+	}
+	// Objects that expired, were removed or were stamped again drop out.
+	n.mu.Lock()
+	n.notified = due
+	n.mu.Unlock()
+	return notice, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyExpiring(t *testing.T) {
+	h, store := newTestHandler(t)
+	posted := make(chan expiryNotice, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notice expiryNotice
+		json.NewDecoder(r.Body).Decode(&notice)
+		posted <- notice
+	}))
+	defer hook.Close()
+	h.expiryNotices = newExpiryNotifier(24*time.Hour, hook.URL)
+
+	soon := time.Now().Add(time.Hour).Truncate(time.Second)
+	putWithExpiry(t, store, "soon.txt", soon)
+	putWithExpiry(t, store, "later.txt", time.Now().Add(72*time.Hour))
+	putWithExpiry(t, store, "gone.txt", time.Now().Add(-time.Minute))
+	store.put(testBucket, "forever.txt", []byte("x"), "text/plain")
+
+	notice, err := h.notifyExpiring(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := expiringObject{Key: "soon.txt", Size: int64(len("soon.txt")), ExpiresAt: soon.UTC()}
+	if len(notice.Objects) != 1 || notice.Objects[0] != want {
+		t.Fatalf("objects = %+v, want [%+v]", notice.Objects, want)
+	}
+	if got := <-posted; len(got.Objects) != 1 || got.Objects[0].Key != "soon.txt" || got.Lead != "24h0m0s" {
+		t.Errorf("webhook got %+v", got)
+	}
+
+	// Announced objects aren't announced again, unless stamped anew.
+	if notice, _ := h.notifyExpiring(t.Context()); len(notice.Objects) != 0 {
+		t.Errorf("second scan announced %+v, want nothing", notice.Objects)
+	}
+	putWithExpiry(t, store, "soon.txt", soon.Add(time.Minute))
+	if notice, _ := h.notifyExpiring(t.Context()); len(notice.Objects) != 1 {
+		t.Errorf("scan after a new expiry announced %+v, want soon.txt", notice.Objects)
+	}
+}
+
+func TestNotifyExpiringRetriesFailedWebhook(t *testing.T) {
+	h, store := newTestHandler(t)
+	status := http.StatusBadGateway
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer hook.Close()
+	h.expiryNotices = newExpiryNotifier(time.Hour, hook.URL)
+	putWithExpiry(t, store, "soon.txt", time.Now().Add(time.Minute))
+
+	if _, err := h.notifyExpiring(t.Context()); err == nil {
+		t.Fatal("scan succeeded despite the failing webhook")
+	}
+	status = http.StatusOK
+	if notice, err := h.notifyExpiring(t.Context()); err != nil || len(notice.Objects) != 1 {
+		t.Errorf("retry = %+v, %v, want soon.txt announced", notice.Objects, err)
+	}
+}
+
+func TestWatchStreamsExpiryNotices(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.expiryNotices = newExpiryNotifier(time.Hour, "")
+	putWithExpiry(t, store, "soon.txt", time.Now().Add(time.Minute))
+	srv := httptest.NewServer(h.routes())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	// The handler has subscribed once the stream has started.
+	lines.Scan()
+	if _, err := h.notifyExpiring(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	event := ""
+	for lines.Scan() {
+		if name, ok := strings.CutPrefix(lines.Text(), "event: "); ok {
+			event = name
+			continue
+		}
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var notice expiryNotice
+		if err := json.Unmarshal([]byte(data), &notice); err != nil {
+			t.Fatalf("decoding %q: %v", data, err)
+		}
+		if event != "expiring" || len(notice.Objects) != 1 || notice.Objects[0].Key != "soon.txt" {
+			t.Fatalf("event %q = %+v, want an expiring event for soon.txt", event, notice)
+		}
+		return
+	}
+	t.Fatal("stream ended without an event")
+}
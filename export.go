@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// exportFlushRows is how many CSV rows are buffered before being flushed
+// to the client.
+const exportFlushRows = 500
+
+// exportHeader is the first row of /export.csv.
+var exportHeader = []string{"key", "size", "last_modified", "etag", "content_type", "storage_class"}
+
+// spreadsheetSafe keeps a cell from being read as a formula by spreadsheet
+// software (CSV injection): values starting with =, +, -, @ or a control
+// character get a leading apostrophe, which spreadsheets hide.
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportHandler streams a CSV index of every object under ?prefix=, one row
+// per object as the listing arrives, so memory stays flat however large the
+// bucket is. The listing stops when the client goes away.
+func (h *MinioHandler) exportHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	objectCh := h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true, WithMetadata: true})
+	// Peek at the listing so a bad prefix or bucket still gets a proper error.
+	first, ok := <-objectCh
+	if ok && first.Err != nil {
+		logger(r.Context()).Error("Error listing objects for CSV export", "err", first.Err)
+		h.storeFailed(w, "Failed to list files", first.Err)
+		return
+	}
+
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(prefix, "/")), ".")
+	if name == "" || name == "/" {
+		name = h.bucketName
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentDisposition(name+".csv"))
+	cw := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	flush := func() bool {
+		cw.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return cw.Error() == nil
+	}
+	cw.Write(exportHeader)
+
+	rows := 0
+	for object := first; ok; object, ok = <-objectCh {
+		if object.Err != nil {
+			// Too late for an error status; the CSV simply ends early.
+			logger(r.Context()).Error("Error listing objects for CSV export", "err", object.Err)
+			break
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		contentType := object.ContentType
+		if contentType == "" {
+			contentType = object.UserMetadata["content-type"]
+		}
+		cw.Write([]string{
+			spreadsheetSafe(object.Key),
+			strconv.FormatInt(object.Size, 10),
+			object.LastModified.UTC().Format(time.RFC3339),
+			strings.Trim(object.ETag, `"`),
+			spreadsheetSafe(contentType),
+			object.StorageClass,
+		})
+		if rows++; rows%exportFlushRows == 0 && !flush() {
+			logger(r.Context()).Warn("CSV export stopped early", "prefix", prefix, "rows", rows, "err", cw.Error())
+			return
+		}
+	}
+	flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "reports/q1.pdf", []byte("pdf"), "application/pdf")
+	store.put(testBucket, "reports/=SUM(A1).csv", []byte("x,y"), "text/csv")
+	store.put(testBucket, "other.txt", []byte("hi"), "text/plain")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/export.csv?prefix=reports/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "reports.csv") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != "key,size,last_modified,etag,content_type,storage_class" {
+		t.Fatalf("rows = %q", rows)
+	}
+	if rows[1][0] != "reports/=SUM(A1).csv" || rows[2][0] != "reports/q1.pdf" || rows[2][1] != "3" || rows[2][4] != "application/pdf" || rows[2][3] == "" {
+		t.Errorf("rows = %q", rows[1:])
+	}
+
+	if got := spreadsheetSafe("=SUM(A1)"); got != "'=SUM(A1)" {
+		t.Errorf("spreadsheetSafe = %q", got)
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/export.csv?prefix=none/", nil))
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 1 {
+		t.Errorf("empty prefix: status %d, body %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,829 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/minio/minio-go/v7/pkg/replication"
+	"github.com/minio/minio-go/v7/pkg/sse"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// fakeStore is an in-memory ObjectStore for handler tests. It mimics the
+// minio-go behaviors the handlers depend on: S3-style error responses,
+// lazy errors from GetObject, delimiter grouping in ListObjects, and ETag
+// preconditions on reads and copies.
+type fakeStore struct {
+	mu       sync.Mutex
+	buckets  map[string]map[string]*fakeObject
+	policies map[string]string
+	// encryption holds each bucket's default encryption, if set.
+	encryption map[string]*sse.Configuration
+	// replication holds each bucket's replication config, if set.
+	replication map[string]replication.Config
+	// versioning holds each bucket's versioning status, if set.
+	versioning map[string]string
+	// history holds the noncurrent versions and delete markers of objects
+	// in versioned buckets, by bucket and key, oldest first.
+	history map[string]map[string][]*fakeObject
+	uploads map[string]*fakeUpload
+	events  chan notification.Info
+	nextID  int
+}
+
+type fakeObject struct {
+	data []byte
+	info minio.ObjectInfo
+	tags map[string]string
+	// customerKey is the SSE-C key header the object was stored with,
+	// which reads must repeat.
+	customerKey string
+}
+
+type fakeUpload struct {
+	bucket, object string
+	opts           minio.PutObjectOptions
+	parts          map[int][]byte
+	initiated      time.Time
+}
+
+var _ ObjectStore = (*fakeStore)(nil)
+
+// newFakeStore returns a fake with the given buckets already created.
+func newFakeStore(buckets ...string) *fakeStore {
+	f := &fakeStore{
+		buckets:     make(map[string]map[string]*fakeObject),
+		policies:    make(map[string]string),
+		encryption:  make(map[string]*sse.Configuration),
+		replication: make(map[string]replication.Config),
+		versioning:  make(map[string]string),
+		history:     make(map[string]map[string][]*fakeObject),
+		uploads:     make(map[string]*fakeUpload),
+		events:      make(chan notification.Info, 16),
+	}
+	for _, b := range buckets {
+		f.buckets[b] = make(map[string]*fakeObject)
+	}
+	return f
+}
+
+func noSuchKey(bucket, object string) error {
+	return minio.ErrorResponse{
+		Code:       "NoSuchKey",
+		Message:    "The specified key does not exist.",
+		BucketName: bucket,
+		Key:        object,
+		StatusCode: http.StatusNotFound,
+	}
+}
+
+func noSuchBucket(bucket string) error {
+	return minio.ErrorResponse{
+		Code:       "NoSuchBucket",
+		Message:    "The specified bucket does not exist",
+		BucketName: bucket,
+		StatusCode: http.StatusNotFound,
+	}
+}
+
+func preconditionFailed(bucket, object string) error {
+	return minio.ErrorResponse{
+		Code:       "PreconditionFailed",
+		Message:    "At least one of the pre-conditions you specified did not hold",
+		BucketName: bucket,
+		Key:        object,
+		StatusCode: http.StatusPreconditionFailed,
+	}
+}
+
+// put stores data directly, bypassing PutObject. Tests use it for fixtures.
+func (f *fakeStore) put(bucket, object string, data []byte, contentType string) {
+	f.PutObject(context.Background(), bucket, object, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+}
+
+// object returns a stored object's bytes, for assertions.
+func (f *fakeStore) object(bucket, object string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.buckets[bucket][object]
+	if !ok {
+		return nil, false
+	}
+	return o.data, true
+}
+
+// store makes o the current version of object. In a bucket with versioning
+// enabled it gets a version ID and the version it replaces is kept.
+func (f *fakeStore) store(bucket, object string, o *fakeObject) {
+	if f.versioning[bucket] == minio.Enabled {
+		f.nextID++
+		o.info.VersionID = fmt.Sprintf("v%d", f.nextID)
+		f.archive(bucket, object)
+	}
+	f.buckets[bucket][object] = o
+}
+
+// archive moves the current version of object, if any, to its history.
+func (f *fakeStore) archive(bucket, object string) {
+	o, ok := f.buckets[bucket][object]
+	if !ok {
+		return
+	}
+	if f.history[bucket] == nil {
+		f.history[bucket] = make(map[string][]*fakeObject)
+	}
+	f.history[bucket][object] = append(f.history[bucket][object], o)
+}
+
+// lookupVersion is lookup for a version of object; an empty versionID is
+// the current version. Objects stored while the bucket was unversioned
+// have the version ID "null", as in S3.
+func (f *fakeStore) lookupVersion(bucket, object, versionID string) (*fakeObject, error) {
+	if versionID == "" {
+		return f.lookup(bucket, object)
+	}
+	if versionID == "null" {
+		versionID = ""
+	}
+	versions := f.history[bucket][object]
+	if o, ok := f.buckets[bucket][object]; ok {
+		versions = append(versions, o)
+	}
+	for _, o := range versions {
+		if o.info.VersionID != versionID {
+			continue
+		}
+		if o.info.IsDeleteMarker {
+			return nil, minio.ErrorResponse{Code: "MethodNotAllowed", StatusCode: http.StatusMethodNotAllowed, BucketName: bucket, Key: object}
+		}
+		return o, nil
+	}
+	return nil, minio.ErrorResponse{Code: "NoSuchVersion", Message: "The specified version does not exist.", BucketName: bucket, Key: object, StatusCode: http.StatusNotFound}
+}
+
+func (f *fakeStore) lookup(bucket, object string) (*fakeObject, error) {
+	objects, ok := f.buckets[bucket]
+	if !ok {
+		return nil, noSuchBucket(bucket)
+	}
+	o, ok := objects[object]
+	if !ok {
+		return nil, noSuchKey(bucket, object)
+	}
+	return o, nil
+}
+
+func (f *fakeStore) MakeBucket(_ context.Context, bucketName string, _ minio.MakeBucketOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.buckets[bucketName]; ok {
+		return minio.ErrorResponse{Code: "BucketAlreadyOwnedByYou", BucketName: bucketName, StatusCode: http.StatusConflict}
+	}
+	f.buckets[bucketName] = make(map[string]*fakeObject)
+	return nil
+}
+
+func (f *fakeStore) BucketExists(_ context.Context, bucketName string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.buckets[bucketName]
+	return ok, nil
+}
+
+func (f *fakeStore) GetBucketPolicy(_ context.Context, bucketName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.policies[bucketName], nil
+}
+
+func (f *fakeStore) SetBucketPolicy(_ context.Context, bucketName, policy string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policies[bucketName] = policy
+	return nil
+}
+
+func (f *fakeStore) GetBucketEncryption(_ context.Context, bucketName string) (*sse.Configuration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	config, ok := f.encryption[bucketName]
+	if !ok {
+		return nil, minio.ErrorResponse{
+			Code:       "ServerSideEncryptionConfigurationNotFoundError",
+			Message:    "The server side encryption configuration was not found",
+			BucketName: bucketName,
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return config, nil
+}
+
+// SetBucketEncryption rejects SSE-KMS without a key, as MinIO does when it
+// has no default KMS key configured.
+func (f *fakeStore) SetBucketEncryption(_ context.Context, bucketName string, config *sse.Configuration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if apply := config.Rules[0].Apply; apply.SSEAlgorithm == "aws:kms" && apply.KmsMasterKeyID == "" {
+		return minio.ErrorResponse{Code: "InvalidArgument", Message: "No default KMS key is configured", BucketName: bucketName, StatusCode: http.StatusBadRequest}
+	}
+	f.encryption[bucketName] = config
+	return nil
+}
+
+// GetBucketReplication returns an empty config for a bucket without one, as
+// minio-go does.
+func (f *fakeStore) GetBucketReplication(_ context.Context, bucketName string) (replication.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.replication[bucketName], nil
+}
+
+// GetBucketVersioning reports an empty status for a bucket that never had
+// versioning enabled, as MinIO does.
+func (f *fakeStore) GetBucketVersioning(_ context.Context, bucketName string) (minio.BucketVersioningConfiguration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return minio.BucketVersioningConfiguration{Status: f.versioning[bucketName]}, nil
+}
+
+func (f *fakeStore) SetBucketVersioning(_ context.Context, bucketName string, config minio.BucketVersioningConfiguration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.buckets[bucketName]; !ok {
+		return noSuchBucket(bucketName)
+	}
+	f.versioning[bucketName] = config.Status
+	return nil
+}
+
+func (f *fakeStore) SetBucketReplication(_ context.Context, bucketName string, cfg replication.Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replication[bucketName] = cfg
+	return nil
+}
+
+func (f *fakeStore) PutObject(_ context.Context, bucketName, objectName string, reader io.Reader, _ int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objects, ok := f.buckets[bucketName]
+	if !ok {
+		return minio.UploadInfo{}, noSuchBucket(bucketName)
+	}
+	// Conditional writes, as MinIO supports with SetMatchETag(Except).
+	existing, exists := objects[objectName]
+	if match := strings.Trim(opts.Header().Get("If-Match"), `"`); match != "" && (!exists || (match != "*" && match != existing.info.ETag)) {
+		return minio.UploadInfo{}, preconditionFailed(bucketName, objectName)
+	}
+	if none := strings.Trim(opts.Header().Get("If-None-Match"), `"`); none != "" && exists && (none == "*" || none == existing.info.ETag) {
+		return minio.UploadInfo{}, preconditionFailed(bucketName, objectName)
+	}
+	o := newFakeObject(objectName, data, opts)
+	f.store(bucketName, objectName, o)
+	return minio.UploadInfo{
+		Bucket: bucketName, Key: objectName, ETag: o.info.ETag, Size: o.info.Size, LastModified: o.info.LastModified, VersionID: o.info.VersionID,
+		ChecksumCRC32: o.info.ChecksumCRC32, ChecksumCRC32C: o.info.ChecksumCRC32C, ChecksumSHA1: o.info.ChecksumSHA1,
+		ChecksumSHA256: o.info.ChecksumSHA256, ChecksumCRC64NVME: o.info.ChecksumCRC64NVME,
+	}, nil
+}
+
+// newFakeObject builds the stored form of an upload, splitting user metadata
+// the way StatObject reports it.
+func newFakeObject(objectName string, data []byte, opts minio.PutObjectOptions) *fakeObject {
+	sum := md5.Sum(data)
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	info := minio.ObjectInfo{
+		Key:          objectName,
+		Size:         int64(len(data)),
+		ETag:         hex.EncodeToString(sum[:]),
+		ContentType:  contentType,
+		LastModified: time.Now().UTC().Truncate(time.Second),
+		Metadata:     http.Header{},
+		UserMetadata: minio.StringMap{},
+	}
+	info.Metadata.Set("Content-Type", contentType)
+	for name, v := range map[string]string{
+		"Cache-Control":       opts.CacheControl,
+		"Content-Disposition": opts.ContentDisposition,
+		"Content-Encoding":    opts.ContentEncoding,
+		"Content-Language":    opts.ContentLanguage,
+	} {
+		if v != "" {
+			info.Metadata.Set(name, v)
+		}
+	}
+	if opts.Checksum.IsSet() {
+		sum := opts.Checksum.ChecksumBytes(data).Encoded()
+		switch opts.Checksum.Base() {
+		case minio.ChecksumCRC32:
+			info.ChecksumCRC32 = sum
+		case minio.ChecksumCRC32C:
+			info.ChecksumCRC32C = sum
+		case minio.ChecksumSHA1:
+			info.ChecksumSHA1 = sum
+		case minio.ChecksumSHA256:
+			info.ChecksumSHA256 = sum
+		case minio.ChecksumCRC64NVME:
+			info.ChecksumCRC64NVME = sum
+		}
+	}
+	for k, v := range opts.UserMetadata {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") && !strings.HasPrefix(lk, "x-amz-meta-") {
+			info.Metadata.Set(k, v)
+			continue
+		}
+		name := http.CanonicalHeaderKey(strings.TrimPrefix(lk, "x-amz-meta-"))
+		info.UserMetadata[name] = v
+		info.Metadata.Set("X-Amz-Meta-"+name, v)
+	}
+	o := &fakeObject{data: data, info: info, tags: maps.Clone(opts.UserTags)}
+	if opts.ServerSideEncryption != nil {
+		h := http.Header{}
+		opts.ServerSideEncryption.Marshal(h)
+		// MinIO reports the encryption, but never the customer key.
+		o.customerKey = h.Get(sseCKeyHeader)
+		h.Del(sseCKeyHeader)
+		h.Del(sseCKeyMD5Header)
+		for name := range h {
+			info.Metadata.Set(name, h.Get(name))
+		}
+	}
+	return o
+}
+
+// checkCustomerKey fails a read of an SSE-C object without its key, as
+// S3 does.
+func (o *fakeObject) checkCustomerKey(bucket, object string, sse encrypt.ServerSide) error {
+	if o.customerKey == "" {
+		return nil
+	}
+	h := http.Header{}
+	if sse != nil {
+		sse.Marshal(h)
+	}
+	switch h.Get(sseCKeyHeader) {
+	case o.customerKey:
+		return nil
+	case "":
+		return minio.ErrorResponse{Code: "InvalidRequest", Message: "The object was stored using a form of Server Side Encryption.", BucketName: bucket, Key: object, StatusCode: http.StatusBadRequest}
+	}
+	return minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied.", BucketName: bucket, Key: object, StatusCode: http.StatusForbidden}
+}
+
+func (f *fakeStore) GetObject(_ context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, err := f.lookupVersion(bucketName, objectName, opts.VersionID)
+	if err == nil {
+		err = o.checkCustomerKey(bucketName, objectName, opts.ServerSideEncryption)
+	}
+	if err != nil {
+		return &fakeReader{err: err}, nil
+	}
+	if match := strings.Trim(opts.Header().Get("If-Match"), `"`); match != "" && match != o.info.ETag {
+		return &fakeReader{err: preconditionFailed(bucketName, objectName)}, nil
+	}
+	return &fakeReader{Reader: bytes.NewReader(o.data), info: o.info}, nil
+}
+
+func (f *fakeStore) StatObject(_ context.Context, bucketName, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, err := f.lookupVersion(bucketName, objectName, opts.VersionID)
+	if err == nil {
+		err = o.checkCustomerKey(bucketName, objectName, opts.ServerSideEncryption)
+	}
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	return o.info, nil
+}
+
+func (f *fakeStore) GetObjectTagging(_ context.Context, bucketName, objectName string, _ minio.GetObjectTaggingOptions) (*tags.Tags, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, err := f.lookup(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return tags.NewTags(o.tags, true)
+}
+
+func (f *fakeStore) PutObjectTagging(_ context.Context, bucketName, objectName string, otags *tags.Tags, _ minio.PutObjectTaggingOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, err := f.lookup(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	o.tags = otags.ToMap()
+	return nil
+}
+
+func (f *fakeStore) CopyObject(_ context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, err := f.lookupVersion(src.Bucket, src.Object, src.VersionID)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if src.MatchETag != "" && strings.Trim(src.MatchETag, `"`) != o.info.ETag {
+		return minio.UploadInfo{}, preconditionFailed(src.Bucket, src.Object)
+	}
+	if !src.MatchUnmodifiedSince.IsZero() && o.info.LastModified.After(src.MatchUnmodifiedSince) {
+		return minio.UploadInfo{}, preconditionFailed(src.Bucket, src.Object)
+	}
+	if err := o.checkCustomerKey(src.Bucket, src.Object, encrypt.SSE(src.Encryption)); err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if _, ok := f.buckets[dst.Bucket]; !ok {
+		return minio.UploadInfo{}, noSuchBucket(dst.Bucket)
+	}
+	opts := minio.PutObjectOptions{
+		ContentType:        o.info.ContentType,
+		CacheControl:       o.info.Metadata.Get("Cache-Control"),
+		ContentDisposition: o.info.Metadata.Get("Content-Disposition"),
+		ContentEncoding:    o.info.Metadata.Get("Content-Encoding"),
+		ContentLanguage:    o.info.Metadata.Get("Content-Language"),
+		UserMetadata:       o.info.UserMetadata,
+		UserTags:           o.tags,
+	}
+	if dst.ReplaceMetadata {
+		opts = minio.PutObjectOptions{
+			ContentType:        dst.ContentType,
+			CacheControl:       dst.CacheControl,
+			ContentDisposition: dst.ContentDisposition,
+			ContentEncoding:    dst.ContentEncoding,
+			ContentLanguage:    dst.ContentLanguage,
+			UserMetadata:       dst.UserMetadata,
+			UserTags:           o.tags,
+		}
+	}
+	if dst.ReplaceTags {
+		opts.UserTags = dst.UserTags
+	}
+	opts.ServerSideEncryption = dst.Encryption
+	c := newFakeObject(dst.Object, o.data, opts)
+	f.store(dst.Bucket, dst.Object, c)
+	return minio.UploadInfo{Bucket: dst.Bucket, Key: dst.Object, ETag: c.info.ETag, Size: c.info.Size, VersionID: c.info.VersionID}, nil
+}
+
+func (f *fakeStore) RemoveObject(_ context.Context, bucketName, objectName string, _ minio.RemoveObjectOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objects, ok := f.buckets[bucketName]
+	if !ok {
+		return noSuchBucket(bucketName)
+	}
+	if _, exists := objects[objectName]; exists && f.versioning[bucketName] == minio.Enabled {
+		// The object is kept as a noncurrent version behind a delete marker.
+		f.archive(bucketName, objectName)
+		f.nextID++
+		marker := &fakeObject{info: minio.ObjectInfo{Key: objectName, VersionID: fmt.Sprintf("v%d", f.nextID), IsDeleteMarker: true, LastModified: time.Now().UTC().Truncate(time.Second)}}
+		f.history[bucketName][objectName] = append(f.history[bucketName][objectName], marker)
+	}
+	delete(objects, objectName)
+	return nil
+}
+
+func (f *fakeStore) RemoveObjects(ctx context.Context, bucketName string, objectsCh <-chan minio.ObjectInfo, opts minio.RemoveObjectsOptions) <-chan minio.RemoveObjectError {
+	errCh := make(chan minio.RemoveObjectError)
+	go func() {
+		defer close(errCh)
+		for obj := range objectsCh {
+			if err := f.RemoveObject(ctx, bucketName, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+				errCh <- minio.RemoveObjectError{ObjectName: obj.Key, Err: err}
+			}
+		}
+	}()
+	return errCh
+}
+
+func (f *fakeStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	f.mu.Lock()
+	_, ok := f.buckets[bucketName]
+	var infos []minio.ObjectInfo
+	seen := map[string]bool{}
+	for key, o := range f.listed(bucketName, opts) {
+		if !strings.HasPrefix(key, opts.Prefix) || (opts.StartAfter != "" && key <= opts.StartAfter) {
+			continue
+		}
+		if !opts.Recursive {
+			if i := strings.Index(key[len(opts.Prefix):], "/"); i >= 0 {
+				dir := key[:len(opts.Prefix)+i+1]
+				if !seen[dir] {
+					seen[dir] = true
+					infos = append(infos, minio.ObjectInfo{Key: dir})
+				}
+				continue
+			}
+		}
+		infos = append(infos, o...)
+	}
+	f.mu.Unlock()
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	if opts.MaxKeys > 0 && len(infos) > opts.MaxKeys {
+		infos = infos[:opts.MaxKeys]
+	}
+
+	ch := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(ch)
+		if !ok {
+			ch <- minio.ObjectInfo{Err: noSuchBucket(bucketName)}
+			return
+		}
+		for _, info := range infos {
+			select {
+			case ch <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// listed returns what ListObjects reports for each key: the current
+// version, or with opts.WithVersions every version, newest first.
+func (f *fakeStore) listed(bucketName string, opts minio.ListObjectsOptions) map[string][]minio.ObjectInfo {
+	listed := map[string][]minio.ObjectInfo{}
+	for key, o := range f.buckets[bucketName] {
+		listed[key] = []minio.ObjectInfo{o.info}
+	}
+	if !opts.WithVersions {
+		return listed
+	}
+	for key, versions := range f.history[bucketName] {
+		for i := len(versions) - 1; i >= 0; i-- {
+			listed[key] = append(listed[key], versions[i].info)
+		}
+	}
+	for _, infos := range listed {
+		infos[0].IsLatest = true
+		for i := range infos {
+			if infos[i].VersionID == "" {
+				infos[i].VersionID = "null"
+			}
+		}
+	}
+	return listed
+}
+
+func (f *fakeStore) PresignedGetObject(_ context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error) {
+	q := url.Values{}
+	for k, v := range reqParams {
+		q[k] = v
+	}
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expires.Seconds())))
+	q.Set("X-Amz-Signature", "fake")
+	u := f.EndpointURL()
+	u.Path = "/" + bucketName + "/" + objectName
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// PresignHeader signs like PresignedGetObject, adding the method and the
+// names of the signed headers to the query so tests can inspect them.
+func (f *fakeStore) PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error) {
+	u, err := f.PresignedGetObject(ctx, bucketName, objectName, expires, reqParams)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("X-Fake-Method", method)
+	signed := []string{"host"}
+	for name := range extraHeaders {
+		signed = append(signed, strings.ToLower(name))
+	}
+	sort.Strings(signed)
+	q.Set("X-Amz-SignedHeaders", strings.Join(signed, ";"))
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// PresignedPostPolicy returns the policy document unencoded in the "policy"
+// field so tests can inspect its conditions.
+func (f *fakeStore) PresignedPostPolicy(_ context.Context, policy *minio.PostPolicy) (*url.URL, map[string]string, error) {
+	u := f.EndpointURL()
+	u.Path = "/" + testBucket + "/"
+	return u, map[string]string{"policy": policy.String(), "x-amz-signature": "fake"}, nil
+}
+
+// ListenBucketNotification relays whatever the test sends on f.events until
+// ctx is done.
+func (f *fakeStore) ListenBucketNotification(ctx context.Context, _, _, _ string, _ []string) <-chan notification.Info {
+	ch := make(chan notification.Info)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case info := <-f.events:
+				select {
+				case ch <- info:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (f *fakeStore) EndpointURL() *url.URL {
+	return &url.URL{Scheme: "http", Host: "fake.local"}
+}
+
+func (f *fakeStore) NewMultipartUpload(_ context.Context, bucket, object string, opts minio.PutObjectOptions) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.buckets[bucket]; !ok {
+		return "", noSuchBucket(bucket)
+	}
+	f.nextID++
+	id := fmt.Sprintf("upload-%d", f.nextID)
+	f.uploads[id] = &fakeUpload{bucket: bucket, object: object, opts: opts, parts: make(map[int][]byte), initiated: time.Now().UTC()}
+	return id, nil
+}
+
+func (f *fakeStore) CopyObjectPart(_ context.Context, srcBucket, srcObject, _, _, uploadID string, partID int, startOffset, length int64, headers map[string]string) (minio.CompletePart, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up, ok := f.uploads[uploadID]
+	if !ok {
+		return minio.CompletePart{}, minio.ErrorResponse{Code: "NoSuchUpload", StatusCode: http.StatusNotFound}
+	}
+	o, err := f.lookup(srcBucket, srcObject)
+	if err != nil {
+		return minio.CompletePart{}, err
+	}
+	if key := headers["X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"]; key != o.customerKey {
+		return minio.CompletePart{}, minio.ErrorResponse{Code: "InvalidRequest", Message: "The object was stored using a form of Server Side Encryption.", StatusCode: http.StatusBadRequest}
+	}
+	if startOffset < 0 || startOffset+length > int64(len(o.data)) {
+		return minio.CompletePart{}, minio.ErrorResponse{Code: "InvalidRange", StatusCode: http.StatusRequestedRangeNotSatisfiable}
+	}
+	part := append([]byte(nil), o.data[startOffset:startOffset+length]...)
+	up.parts[partID] = part
+	sum := md5.Sum(part)
+	return minio.CompletePart{PartNumber: partID, ETag: hex.EncodeToString(sum[:])}, nil
+}
+
+func (f *fakeStore) PutObjectPart(_ context.Context, _, _, uploadID string, partID int, data io.Reader, size int64, _ minio.PutObjectPartOptions) (minio.ObjectPart, error) {
+	part, err := io.ReadAll(data)
+	if err != nil {
+		return minio.ObjectPart{}, err
+	}
+	if size >= 0 && int64(len(part)) != size {
+		return minio.ObjectPart{}, minio.ErrorResponse{Code: "IncompleteBody", StatusCode: http.StatusBadRequest}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up, ok := f.uploads[uploadID]
+	if !ok {
+		return minio.ObjectPart{}, minio.ErrorResponse{Code: "NoSuchUpload", StatusCode: http.StatusNotFound}
+	}
+	up.parts[partID] = part
+	sum := md5.Sum(part)
+	return minio.ObjectPart{PartNumber: partID, ETag: hex.EncodeToString(sum[:]), Size: int64(len(part))}, nil
+}
+
+func (f *fakeStore) CompleteMultipartUpload(_ context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up, ok := f.uploads[uploadID]
+	if !ok || up.bucket != bucket || up.object != object {
+		return minio.UploadInfo{}, minio.ErrorResponse{Code: "NoSuchUpload", StatusCode: http.StatusNotFound}
+	}
+	if match := strings.Trim(opts.Header().Get("If-Match"), `"`); match != "" {
+		if existing, exists := f.buckets[bucket][object]; !exists || (match != "*" && match != existing.info.ETag) {
+			return minio.UploadInfo{}, preconditionFailed(bucket, object)
+		}
+	}
+	var data []byte
+	for _, p := range parts {
+		part, ok := up.parts[p.PartNumber]
+		sum := md5.Sum(part)
+		if !ok || strings.Trim(p.ETag, `"`) != hex.EncodeToString(sum[:]) {
+			return minio.UploadInfo{}, minio.ErrorResponse{Code: "InvalidPart", Message: "One or more of the specified parts could not be found.", StatusCode: http.StatusBadRequest}
+		}
+		data = append(data, part...)
+	}
+	delete(f.uploads, uploadID)
+	o := newFakeObject(object, data, up.opts)
+	o.info.ETag = fmt.Sprintf("%s-%d", o.info.ETag, len(parts))
+	f.buckets[bucket][object] = o
+	return minio.UploadInfo{Bucket: bucket, Key: object, ETag: o.info.ETag, Size: o.info.Size}, nil
+}
+
+func (f *fakeStore) AbortMultipartUpload(_ context.Context, _, _, uploadID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.uploads, uploadID)
+	return nil
+}
+
+func (f *fakeStore) ListObjectParts(_ context.Context, bucket, object, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up, ok := f.uploads[uploadID]
+	if !ok || up.bucket != bucket || up.object != object {
+		return minio.ListObjectPartsResult{}, minio.ErrorResponse{Code: "NoSuchUpload", StatusCode: http.StatusNotFound}
+	}
+	result := minio.ListObjectPartsResult{Bucket: bucket, Key: object, UploadID: uploadID, PartNumberMarker: partNumberMarker, MaxParts: maxParts}
+	for _, n := range slices.Sorted(maps.Keys(up.parts)) {
+		if n <= partNumberMarker {
+			continue
+		}
+		if len(result.ObjectParts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		sum := md5.Sum(up.parts[n])
+		result.ObjectParts = append(result.ObjectParts, minio.ObjectPart{PartNumber: n, ETag: hex.EncodeToString(sum[:]), Size: int64(len(up.parts[n]))})
+		result.NextPartNumberMarker = n
+	}
+	return result, nil
+}
+
+func (f *fakeStore) ListIncompleteUploads(_ context.Context, bucketName, objectPrefix string, _ bool) <-chan minio.ObjectMultipartInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var uploads []minio.ObjectMultipartInfo
+	for id, up := range f.uploads {
+		if up.bucket != bucketName || !strings.HasPrefix(up.object, objectPrefix) {
+			continue
+		}
+		info := minio.ObjectMultipartInfo{Key: up.object, UploadID: id, Initiated: up.initiated}
+		for _, part := range up.parts {
+			info.Size += int64(len(part))
+		}
+		uploads = append(uploads, info)
+	}
+	ch := make(chan minio.ObjectMultipartInfo, len(uploads))
+	for _, info := range uploads {
+		ch <- info
+	}
+	close(ch)
+	return ch
+}
+
+// fakeReader is the ObjectReader returned by fakeStore.GetObject. A reader
+// for a missing object reports the error on every call, like *minio.Object.
+type fakeReader struct {
+	*bytes.Reader
+	info minio.ObjectInfo
+	err  error
+}
+
+func (r *fakeReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.Reader.Read(p)
+}
+
+func (r *fakeReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *fakeReader) Seek(offset int64, whence int) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.Reader.Seek(offset, whence)
+}
+
+func (r *fakeReader) Stat() (minio.ObjectInfo, error) {
+	return r.info, r.err
+}
+
+func (r *fakeReader) Close() error { return nil }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// decoders undo the Content-Encodings an object may be stored with, for
+// clients that don't accept them. There is no brotli decoder in the
+// standard library, so "br" objects are only served to clients accepting br.
+var decoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	// HTTP's "deflate" is zlib-wrapped deflate (RFC 9110, section 8.4.1.2).
+	"deflate": zlib.NewReader,
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header value allows
+// the given content coding, honoring q=0 exclusions and the "*" wildcard.
+func acceptsEncoding(header, coding string) bool {
+	wildcard := false
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+		rejected := false
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				q, err := strconv.ParseFloat(v, 64)
+				rejected = err == nil && q == 0
+			}
+		}
+		switch name {
+		case coding:
+			return !rejected
+		case "*":
+			wildcard = !rejected
+		}
+	}
+	return wildcard
+}
+
+// fetchHandler streams /fetch/{objectName} to the client, negotiating the
+// Content-Encoding the object was stored with: compressed bytes pass through
+// when the client's Accept-Encoding allows them, and are otherwise
+// decompressed on the fly. ?infer_type=true serves objects stored with a
+// generic type as the type their extension implies.
+func (h *MinioHandler) fetchHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/fetch/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /fetch/data.json)", http.StatusBadRequest)
+		return
+	}
+	body, info, err := h.openObject(r.Context(), objectName)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			logger(r.Context()).Error("Error opening object for fetch", "object", objectName, "err", err)
+		}
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+	// The body is negotiated here, from the stored Content-Encoding.
+	skipCompression(w)
+
+	h.applyInferredType(r, objectName, &info)
+	setObjectHeaders(w, info)
+	h.setPropagatedHeaders(w, info)
+	// The handler doesn't serve ranges, least of all of decoded bodies.
+	w.Header().Del("Accept-Ranges")
+	coding := strings.ToLower(strings.TrimSpace(info.Metadata.Get("Content-Encoding")))
+	if coding == "" || coding == "identity" {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		io.Copy(w, body)
+		return
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	if acceptsEncoding(r.Header.Get("Accept-Encoding"), coding) {
+		w.Header().Set("Content-Encoding", coding)
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		io.Copy(w, body)
+		return
+	}
+
+	decode, ok := decoders[coding]
+	if !ok {
+		http.Error(w, fmt.Sprintf("'%s' is stored with Content-Encoding %s, which the client doesn't accept", objectName, coding), http.StatusNotAcceptable)
+		return
+	}
+	decoded, err := decode(body)
+	if err != nil {
+		logger(r.Context()).Error("Error decoding object", "object", objectName, "encoding", coding, "err", err)
+		http.Error(w, "Failed to decompress file", http.StatusInternalServerError)
+		return
+	}
+	defer decoded.Close()
+	// The decoded body is a different representation of the same object.
+	w.Header().Set("ETag", `W/"`+info.ETag+`"`)
+	if _, err := io.Copy(w, decoded); err != nil {
+		logger(r.Context()).Error("Error decompressing object for fetch", "object", objectName, "encoding", coding, "err", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		header, coding string
+		want           bool
+	}{
+		{"gzip, deflate, br", "gzip", true},
+		{"deflate", "gzip", false},
+		{"", "gzip", false},
+		{"x-gzip", "gzip", true},
+		{"gzip;q=0, *", "gzip", false},
+		{"*", "br", true},
+		{"*;q=0", "gzip", false},
+		{"GZIP; q=0.5", "gzip", true},
+	} {
+		if got := acceptsEncoding(tc.header, tc.coding); got != tc.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tc.header, tc.coding, got, tc.want)
+		}
+	}
+}
+
+func TestFetchNegotiatesEncoding(t *testing.T) {
+	h, store := newTestHandler(t)
+	plain := []byte(`{"hello":"world"}`)
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(plain)
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write(plain)
+	zw.Close()
+	for _, o := range []struct {
+		name, coding string
+		data         []byte
+	}{
+		{"a.json.gz", "gzip", gz.Bytes()},
+		{"a.json.z", "deflate", zl.Bytes()},
+		{"a.json.br", "br", []byte("x")},
+	} {
+		store.PutObject(context.Background(), testBucket, o.name, bytes.NewReader(o.data), int64(len(o.data)),
+			minio.PutObjectOptions{ContentType: "application/json", ContentEncoding: o.coding})
+	}
+	store.put(testBucket, "plain.txt", []byte("hi"), "text/plain")
+
+	fetch := func(name, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/fetch/"+name, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		return serve(h, req)
+	}
+
+	rec := fetch("a.json.gz", "gzip")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(rec.Body.Bytes(), gz.Bytes()) {
+		t.Fatalf("gzip client: status %d, encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+	}
+
+	for _, name := range []string{"a.json.gz", "a.json.z"} {
+		rec = fetch(name, "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != string(plain) {
+			t.Fatalf("%s, no Accept-Encoding: status %d, encoding %q, body %q", name, rec.Code, rec.Header().Get("Content-Encoding"), rec.Body)
+		}
+		if etag := rec.Header().Get("ETag"); len(etag) < 2 || etag[:2] != "W/" {
+			t.Errorf("%s: decoded ETag = %q, want a weak ETag", name, etag)
+		}
+	}
+
+	if rec = fetch("a.json.br", "gzip"); rec.Code != http.StatusNotAcceptable {
+		t.Errorf("br to a gzip-only client: status %d", rec.Code)
+	}
+	if rec = fetch("plain.txt", ""); rec.Code != http.StatusOK || rec.Body.String() != "hi" || rec.Header().Get("Vary") != "" {
+		t.Errorf("plain object: status %d, body %q, Vary %q", rec.Code, rec.Body, rec.Header().Get("Vary"))
+	}
+	if rec = fetch("missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object: status %d", rec.Code)
+	}
+}
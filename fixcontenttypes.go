@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// sniffLimit is how much of an object is read to sniff its type; it is
+	// all http.DetectContentType looks at.
+	sniffLimit = 512
+	// defaultMinConfidence is the confidence a sniffed type needs before
+	// fix-content-types writes it, unless ?min_confidence= says otherwise.
+	defaultMinConfidence = 0.7
+	// maxContentTypeFixes caps the fixes and failed keys a job reports.
+	maxContentTypeFixes = 100
+)
+
+// guessContentType returns the type of objectName going by head, the start
+// of its content, and its extension, with a confidence from 0 to 1:
+//
+//	1.0  the content and the extension agree
+//	0.9  the content has a known signature, such as a PNG or PDF header
+//	0.7  the content is text and the extension names a text type
+//	0.5  the content is text and the extension says nothing more
+//	0.3  the content is unrecognised; the type is the extension's
+//
+// An empty type means neither says anything.
+func (h *MinioHandler) guessContentType(objectName string, head []byte) (string, float64) {
+	sniffed := http.DetectContentType(head)
+	sniffedBase, _, _ := mime.ParseMediaType(sniffed)
+	var byExt string
+	if ext := strings.ToLower(path.Ext(objectName)); ext != "" {
+		if byExt = h.contentTypes[ext]; byExt == "" {
+			byExt = mime.TypeByExtension(ext)
+		}
+	}
+	extBase, _, _ := mime.ParseMediaType(byExt)
+
+	switch {
+	case sniffedBase == "application/octet-stream":
+		if byExt == "" {
+			return "", 0
+		}
+		return byExt, 0.3
+	case extBase == sniffedBase:
+		return byExt, 1
+	case sniffedBase == "text/plain":
+		if isTextType(extBase) {
+			return byExt, 0.7
+		}
+		return sniffed, 0.5
+	default:
+		return sniffed, 0.9
+	}
+}
+
+// isTextType reports whether the media type t is held as text.
+func isTextType(t string) bool {
+	switch {
+	case strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/json", "application/xml", "application/javascript", "application/yaml", "application/x-yaml", "application/toml":
+		return true
+	}
+	return false
+}
+
+// contentTypeFix is a stored type replaced (or, in a dry run, to be
+// replaced) by a sniffed one.
+type contentTypeFix struct {
+	Key        string  `json:"key"`
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Confidence float64 `json:"confidence"`
+}
+
+// fixTypesProgress is the progress payload of a fix-content-types job.
+type fixTypesProgress struct {
+	Prefix        string  `json:"prefix"`
+	DryRun        bool    `json:"dry_run"`
+	MinConfidence float64 `json:"min_confidence"`
+	// Checked counts the objects with a missing or generic type that were
+	// sniffed; Fixed those whose type was (or would be) replaced.
+	Checked       int `json:"checked"`
+	Fixed         int `json:"fixed"`
+	LowConfidence int `json:"low_confidence"`
+	// Skipped counts objects under MINIO_UNTRUSTED_PREFIXES and ones
+	// sniffed as an untrusted type, which are never given a served type.
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	// Fixes and FailedKeys list up to maxContentTypeFixes objects each.
+	Fixes      []contentTypeFix `json:"fixes,omitempty"`
+	FailedKeys []string         `json:"failed_keys,omitempty"`
+}
+
+// fixOutcome is what fixContentType did with one object.
+type fixOutcome int
+
+const (
+	fixApplied fixOutcome = iota
+	fixLowConfidence
+	fixSkipped
+)
+
+// fixContentTypesHandler starts a background job that sniffs the content
+// of every object under ?prefix= stored with a missing or generic type and
+// replaces the type with a metadata-only copy when the guess reaches
+// ?min_confidence= (default 0.7). With ?dry_run=true nothing is changed.
+func (h *MinioHandler) fixContentTypesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	progress := fixTypesProgress{Prefix: q.Get("prefix"), DryRun: q.Get("dry_run") == "true", MinConfidence: defaultMinConfidence}
+	if v := q.Get("min_confidence"); v != "" {
+		c, err := strconv.ParseFloat(v, 64)
+		if err != nil || c < 0 || c > 1 {
+			http.Error(w, "min_confidence must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		progress.MinConfidence = c
+	}
+	if !progress.DryRun && h.rejectIfReadOnly(w) {
+		return
+	}
+
+	j := h.jobs.start("fix-content-types", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.fixContentTypes(context.Background(), j, progress)
+		if err != nil {
+			logger(r.Context()).Error("Content type job failed", "job", id, "prefix", progress.Prefix, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// fixContentTypes lists p.Prefix and fixes the generic types found, up to
+// bulkConcurrency objects at a time. Failures of single objects are
+// counted, not returned.
+func (h *MinioHandler) fixContentTypes(ctx context.Context, j *job, p fixTypesProgress) error {
+	var mu sync.Mutex
+	record := func(update func(*fixTypesProgress)) {
+		mu.Lock()
+		defer mu.Unlock()
+		update(&p)
+		snapshot := p
+		snapshot.Fixes = slices.Clone(p.Fixes)
+		snapshot.FailedKeys = slices.Clone(p.FailedKeys)
+		j.setProgress(snapshot)
+	}
+
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	var listErr error
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: p.Prefix, Recursive: true}) {
+		if object.Err != nil {
+			listErr = object.Err
+			break
+		}
+		// Listings don't carry the type; objects are stat'ed below only
+		// when they have content to sniff.
+		if object.Size == 0 || strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			fix, outcome, err := h.fixContentType(ctx, object, p.MinConfidence, p.DryRun)
+			if err == nil && fix.Key == "" {
+				return // stored type isn't generic
+			}
+			record(func(p *fixTypesProgress) {
+				p.Checked++
+				switch {
+				case err != nil:
+					logger(ctx).Error("Error fixing content type", "object", object.Key, "err", err)
+					p.Failed++
+					if len(p.FailedKeys) < maxContentTypeFixes {
+						p.FailedKeys = append(p.FailedKeys, object.Key)
+					}
+				case outcome == fixSkipped:
+					p.Skipped++
+				case outcome == fixLowConfidence:
+					p.LowConfidence++
+				default:
+					p.Fixed++
+					if len(p.Fixes) < maxContentTypeFixes {
+						p.Fixes = append(p.Fixes, fix)
+					}
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	record(func(*fixTypesProgress) {})
+	if listErr != nil {
+		return fmt.Errorf("listing '%s': %w", p.Prefix, listErr)
+	}
+	return nil
+}
+
+// fixContentType sniffs object if its stored type is missing or generic
+// and, unless dryRun, replaces the type when the guess is confident
+// enough. fix.Key is empty for objects that didn't need sniffing.
+func (h *MinioHandler) fixContentType(ctx context.Context, object minio.ObjectInfo, minConfidence float64, dryRun bool) (contentTypeFix, fixOutcome, error) {
+	info, err := h.store.StatObject(ctx, h.bucketName, object.Key, minio.StatObjectOptions{})
+	if err != nil {
+		return contentTypeFix{}, 0, err
+	}
+	if !genericContentTypes[strings.ToLower(strings.TrimSpace(info.ContentType))] {
+		return contentTypeFix{}, 0, nil
+	}
+	fix := contentTypeFix{Key: object.Key, From: info.ContentType}
+	if h.untrusted.untrustedPrefix(object.Key) {
+		return fix, fixSkipped, nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	opts.SetMatchETag(info.ETag)
+	if err := opts.SetRange(0, sniffLimit-1); err != nil {
+		return fix, 0, err
+	}
+	obj, err := h.store.GetObject(ctx, h.bucketName, object.Key, opts)
+	if err != nil {
+		return fix, 0, err
+	}
+	head, err := io.ReadAll(io.LimitReader(obj, sniffLimit))
+	obj.Close()
+	if err != nil {
+		return fix, 0, err
+	}
+
+	fix.To, fix.Confidence = h.guessContentType(object.Key, head)
+	switch {
+	case fix.To == "" || fix.Confidence < minConfidence || genericContentTypes[fix.To]:
+		return fix, fixLowConfidence, nil
+	case h.untrusted.untrustedType(fix.To):
+		return fix, fixSkipped, nil
+	case dryRun:
+		return fix, fixApplied, nil
+	}
+
+	putOpts, err := h.preservingOptions(ctx, object.Key, info)
+	if err != nil {
+		return fix, 0, err
+	}
+	dst := minio.CopyDestOptions{
+		Bucket:             h.bucketName,
+		Object:             object.Key,
+		ReplaceMetadata:    true,
+		UserMetadata:       putOpts.UserMetadata,
+		ContentType:        fix.To,
+		ContentEncoding:    putOpts.ContentEncoding,
+		ContentDisposition: putOpts.ContentDisposition,
+		CacheControl:       putOpts.CacheControl,
+		ContentLanguage:    putOpts.ContentLanguage,
+		Encryption:         h.sse.fallback(),
+	}
+	src := minio.CopySrcOptions{Bucket: h.bucketName, Object: object.Key, MatchETag: info.ETag}
+	if _, err := h.store.CopyObject(ctx, dst, src); err != nil {
+		return fix, 0, err
+	}
+	h.cache.invalidate(object.Key)
+	return fix, fixApplied, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestGuessContentType(t *testing.T) {
+	h, _ := newTestHandler(t)
+	for _, tc := range []struct {
+		name       string
+		head       []byte
+		want       string
+		confidence float64
+	}{
+		{"a.png", pngHeader, "image/png", 1},
+		{"a.bin", pngHeader, "image/png", 0.9},
+		{"a.json", []byte(`{"a": 1}`), "application/json", 0.7},
+		{"notes", []byte("hello"), "text/plain; charset=utf-8", 0.5},
+		{"a.wasm2", []byte{0, 1, 2, 3}, "", 0},
+	} {
+		got, confidence := h.guessContentType(tc.name, tc.head)
+		if got != tc.want || confidence != tc.confidence {
+			t.Errorf("guessContentType(%q) = %q, %v; want %q, %v", tc.name, got, confidence, tc.want, tc.confidence)
+		}
+	}
+}
+
+func runFixContentTypes(t *testing.T, h *MinioHandler, query string) fixTypesProgress {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/fix-content-types?"+query, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+	var status struct {
+		jobStatus
+		Progress fixTypesProgress `json:"progress"`
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil)), &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+	return status.Progress
+}
+
+func TestFixContentTypes(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "img/photo", pngHeader, "application/octet-stream")
+	store.put(testBucket, "img/notes", []byte("hello"), "")
+	store.put(testBucket, "img/ok.txt", []byte("hello"), "text/plain")
+
+	p := runFixContentTypes(t, h, "prefix=img/&dry_run=true")
+	if p.Checked != 2 || p.Fixed != 1 || p.LowConfidence != 1 {
+		t.Errorf("dry run progress = %+v", p)
+	}
+	if info, _ := store.StatObject(t.Context(), testBucket, "img/photo", minio.StatObjectOptions{}); info.ContentType != "application/octet-stream" {
+		t.Errorf("dry run changed type to %q", info.ContentType)
+	}
+
+	p = runFixContentTypes(t, h, "prefix=img/&min_confidence=0.5")
+	if p.Checked != 2 || p.Fixed != 2 || len(p.Fixes) != 2 {
+		t.Errorf("progress = %+v", p)
+	}
+	for key, want := range map[string]string{"img/photo": "image/png", "img/notes": "text/plain; charset=utf-8", "img/ok.txt": "text/plain"} {
+		if info, _ := store.StatObject(t.Context(), testBucket, key, minio.StatObjectOptions{}); info.ContentType != want {
+			t.Errorf("type of %s = %q, want %q", key, info.ContentType, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// serveObjectHead answers a HEAD request for objectName with the headers a
+// download of the object would carry, and no body. Monitors and download
+// tools use this to probe for existence and size before fetching.
+func (h *MinioHandler) serveObjectHead(w http.ResponseWriter, r *http.Request, objectName string) {
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			logger(r.Context()).Error("Error stating object", "object", objectName, "err", err)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	h.applyInferredType(r, objectName, &info)
+	setObjectHeaders(w, info)
+	h.setPropagatedHeaders(w, info)
+	for name, sum := range objectChecksums(info) {
+		w.Header().Set(name, sum)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// setObjectHeaders sets the entity headers describing info on w.
+func setObjectHeaders(w http.ResponseWriter, info minio.ObjectInfo) {
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+info.ETag+`"`)
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	for _, name := range []string{"Cache-Control", "Content-Language"} {
+		if v := info.Metadata.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+}
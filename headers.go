@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// objectHeaders are the stored response headers of an object that can be
+// changed without re-uploading it.
+type objectHeaders struct {
+	ContentType     string `json:"content_type"`
+	CacheControl    string `json:"cache_control"`
+	ContentLanguage string `json:"content_language"`
+}
+
+func headersOf(info minio.ObjectInfo) objectHeaders {
+	return objectHeaders{
+		ContentType:     info.ContentType,
+		CacheControl:    info.Metadata.Get("Cache-Control"),
+		ContentLanguage: info.Metadata.Get("Content-Language"),
+	}
+}
+
+// objectHeadersHandler reads (GET) or changes (PUT) the Cache-Control and
+// Content-Language stored with /headers/{objectName}. A PUT body such as
+// {"cache_control": "max-age=3600"} sets only the fields it contains; an
+// empty string clears one. The change is a server-side copy of the object
+// onto itself with replaced metadata, so the data is not re-uploaded.
+func (h *MinioHandler) objectHeadersHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/headers/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /headers/index.html)", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && h.rejectIfReadOnly(w) {
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{ServerSideEncryption: key})
+	if wrongCustomerKey(err, key) {
+		http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, r, http.StatusOK, headersOf(info))
+		return
+	}
+
+	var req struct {
+		CacheControl    *string `json:"cache_control"`
+		ContentLanguage *string `json:"content_language"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `Request body must be JSON like {"cache_control": "max-age=3600", "content_language": "fr"}`, http.StatusBadRequest)
+		return
+	}
+	opts, err := h.preservingOptions(r.Context(), objectName, info)
+	if err != nil {
+		logger(r.Context()).Error("Error reading metadata", "object", objectName, "err", err)
+		http.Error(w, "Failed to update headers", http.StatusInternalServerError)
+		return
+	}
+	if req.CacheControl != nil {
+		opts.CacheControl = *req.CacheControl
+	}
+	if req.ContentLanguage != nil {
+		opts.ContentLanguage = *req.ContentLanguage
+	}
+
+	enc := h.sse.forCopy(key)
+	dst := minio.CopyDestOptions{
+		Bucket:             h.bucketName,
+		Object:             objectName,
+		ReplaceMetadata:    true,
+		UserMetadata:       opts.UserMetadata,
+		ContentType:        opts.ContentType,
+		ContentEncoding:    opts.ContentEncoding,
+		ContentDisposition: opts.ContentDisposition,
+		CacheControl:       opts.CacheControl,
+		ContentLanguage:    opts.ContentLanguage,
+		Encryption:         enc.dst,
+	}
+	src := minio.CopySrcOptions{Bucket: h.bucketName, Object: objectName, MatchETag: info.ETag, Encryption: enc.src}
+	if _, err := h.store.CopyObject(r.Context(), dst, src); err != nil {
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			http.Error(w, "Object changed while its headers were being updated; retry the request", http.StatusPreconditionFailed)
+			return
+		}
+		logger(r.Context()).Error("Error updating headers", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to update headers", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	writeJSON(w, r, http.StatusOK, objectHeaders{
+		ContentType:     opts.ContentType,
+		CacheControl:    opts.CacheControl,
+		ContentLanguage: opts.ContentLanguage,
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestUploadStoresCacheControlAndLanguage(t *testing.T) {
+	h, _ := newTestHandler(t)
+	rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload?cache_control=max-age%3D60&content_language=fr", "page.html", "<p>bonjour</p>"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	head := serve(h, httptest.NewRequest(http.MethodHead, "/get-download-link/page.html", nil))
+	if got := head.Header().Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Cache-Control = %q, want max-age=60", got)
+	}
+	if got := head.Header().Get("Content-Language"); got != "fr" {
+		t.Errorf("Content-Language = %q, want fr", got)
+	}
+}
+
+func TestObjectHeadersHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	opts := minio.PutObjectOptions{ContentType: "text/html", CacheControl: "no-cache", ContentLanguage: "en", UserMetadata: map[string]string{"Owner": "web"}}
+	store.PutObject(t.Context(), testBucket, "index.html", strings.NewReader("<p>hi</p>"), 9, opts)
+
+	var got objectHeaders
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/headers/index.html", nil)), &got)
+	if got != (objectHeaders{ContentType: "text/html", CacheControl: "no-cache", ContentLanguage: "en"}) {
+		t.Errorf("GET = %+v", got)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/headers/index.html", strings.NewReader(`{"cache_control": "max-age=3600"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "index.html", minio.StatObjectOptions{})
+	if headersOf(info) != (objectHeaders{ContentType: "text/html", CacheControl: "max-age=3600", ContentLanguage: "en"}) {
+		t.Errorf("stored headers = %+v", headersOf(info))
+	}
+	if info.UserMetadata["Owner"] != "web" {
+		t.Errorf("user metadata lost: %v", info.UserMetadata)
+	}
+	if data, _ := store.object(testBucket, "index.html"); string(data) != "<p>hi</p>" {
+		t.Errorf("content = %q", data)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/headers/missing.html", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states reported in jobStatus.State.
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// jobRetention is how long a finished job stays pollable before it is pruned.
+const jobRetention = time.Hour
+
+// jobStatus is the JSON view of a background job. Progress holds a
+// kind-specific value (e.g. copyProgress) and must be replaced, not mutated,
+// by the job's goroutine.
+type jobStatus struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	State      string     `json:"state"`
+	Progress   any        `json:"progress,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// job is a single background operation whose progress can be polled or
+// streamed to subscribers.
+type job struct {
+	mu       sync.Mutex
+	status   jobStatus
+	watchers map[chan jobStatus]struct{}
+}
+
+// jobRegistry keeps track of background jobs by ID.
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*job)}
+}
+
+// start registers a new running job of the given kind and returns it. The
+// caller is responsible for running the work and calling finish.
+func (reg *jobRegistry) start(kind string, progress any) *job {
+	j := &job{
+		status: jobStatus{
+			ID:        newID(),
+			Kind:      kind,
+			State:     jobRunning,
+			Progress:  progress,
+			StartedAt: time.Now().UTC(),
+		},
+		watchers: make(map[chan jobStatus]struct{}),
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for id, old := range reg.jobs {
+		if s := old.snapshot(); s.FinishedAt != nil && time.Since(*s.FinishedAt) > jobRetention {
+			delete(reg.jobs, id)
+		}
+	}
+	reg.jobs[j.status.ID] = j
+	return j
+}
+
+func (reg *jobRegistry) get(id string) (*job, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	j, ok := reg.jobs[id]
+	return j, ok
+}
+
+// snapshot returns a copy of the job's current status.
+func (j *job) snapshot() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// setProgress replaces the job's progress value and notifies subscribers.
+func (j *job) setProgress(progress any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Progress = progress
+	j.notifyLocked()
+}
+
+// finish marks the job done, or failed if err is non-nil, and closes all
+// subscriber channels after delivering the final status.
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	j.status.FinishedAt = &now
+	j.status.State = jobDone
+	if err != nil {
+		j.status.State = jobFailed
+		j.status.Error = err.Error()
+	}
+	j.notifyLocked()
+	for ch := range j.watchers {
+		close(ch)
+	}
+	j.watchers = nil
+}
+
+// subscribe returns a channel that receives the latest status whenever it
+// changes. Slow readers only see the most recent update. The channel is
+// closed once the job finishes; call the returned func to stop early.
+func (j *job) subscribe() (<-chan jobStatus, func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ch := make(chan jobStatus, 1)
+	ch <- j.status
+	if j.watchers == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	j.watchers[ch] = struct{}{}
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.watchers[ch]; ok {
+			delete(j.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (j *job) notifyLocked() {
+	for ch := range j.watchers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.status
+	}
+}
+
+// jobsHandler serves GET /jobs/{id} (current status as JSON) and
+// GET /jobs/{id}/events (status updates as Server-Sent Events).
+func (h *MinioHandler) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	id, events := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/events")
+	j, ok := h.jobs.get(id)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !events {
+		writeJSON(w, r, http.StatusOK, j.snapshot())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	sseSubscribers.add(1, "job")
+	defer sseSubscribers.add(-1, "job")
+	updates, cancel := j.subscribe()
+	defer cancel()
+	for {
+		select {
+		case status, open := <-updates:
+			if !open {
+				return
+			}
+			data, err := json.Marshal(status)
+			if err != nil {
+				logger(r.Context()).Error("Error marshaling job status", "err", err)
+				return
+			}
+			event := "progress"
+			if status.State != jobRunning {
+				event = status.State
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-h.lifecycle.drained():
+			fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// keyFilter is a pair of glob lists matched against object keys on upload.
+// Patterns without a '/' are matched against the key's base name, so "*.exe"
+// blocks executables in every folder; patterns with a '/' match the full key.
+type keyFilter struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// keyRules holds the default upload filter plus per-bucket overrides, and
+// limits on the shape of keys so they can be mirrored onto filesystems.
+type keyRules struct {
+	defaults keyFilter
+	buckets  map[string]keyFilter
+
+	// maxLength is the longest key in bytes and maxSegments the most
+	// '/'-separated segments it may have; zero means no limit.
+	maxLength   int
+	maxSegments int
+
+	// trashRoot is the trash folder while the trash is enabled; keys
+	// under it are written only by deletes.
+	trashRoot string
+}
+
+// loadKeyRules builds the upload rules from MINIO_UPLOAD_ALLOW and
+// MINIO_UPLOAD_DENY, and optionally per-bucket overrides from the JSON file
+// named by MINIO_UPLOAD_RULES_FILE, e.g. {"media": {"allow": ["*.png"]}}.
+// MINIO_MAX_KEY_LENGTH (default 1024, S3's own limit) and
+// MINIO_MAX_KEY_SEGMENTS (default 0, unlimited) bound the key itself.
+func loadKeyRules() (keyRules, error) {
+	rules := keyRules{
+		defaults:    keyFilter{Allow: envList("MINIO_UPLOAD_ALLOW"), Deny: envList("MINIO_UPLOAD_DENY")},
+		maxLength:   int(max(envInt64("MINIO_MAX_KEY_LENGTH", 1024), 0)),
+		maxSegments: int(max(envInt64("MINIO_MAX_KEY_SEGMENTS", 0), 0)),
+	}
+	if err := rules.defaults.validate(); err != nil {
+		return rules, err
+	}
+	file := os.Getenv("MINIO_UPLOAD_RULES_FILE")
+	if file == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return rules, err
+	}
+	if err := json.Unmarshal(data, &rules.buckets); err != nil {
+		return rules, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	for bucket, f := range rules.buckets {
+		if err := f.validate(); err != nil {
+			return rules, fmt.Errorf("bucket %q: %w", bucket, err)
+		}
+	}
+	return rules, nil
+}
+
+// validate rejects malformed glob patterns up front so a typo in the config
+// fails at startup instead of silently never matching.
+func (f keyFilter) validate() error {
+	for _, p := range append(append([]string{}, f.Allow...), f.Deny...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// filterFor returns the filter for bucket. A bucket override replaces the
+// default lists it sets and inherits the ones it leaves empty.
+func (k keyRules) filterFor(bucket string) keyFilter {
+	f := k.defaults
+	if o, ok := k.buckets[bucket]; ok {
+		if o.Allow != nil {
+			f.Allow = o.Allow
+		}
+		if o.Deny != nil {
+			f.Deny = o.Deny
+		}
+	}
+	return f
+}
+
+// check reports whether objectName may be uploaded to bucket. When it may
+// not, it returns the HTTP status to use (400 for a key that is too long,
+// too deeply nested or reserved, 403 for an explicit deny, 415 for a key
+// outside the allow list) and a human-readable reason.
+func (k keyRules) check(bucket, objectName string) (int, string) {
+	if isTusState(objectName) {
+		return http.StatusBadRequest, tusStateReserved
+	}
+	if k.trashRoot != "" && strings.HasPrefix(objectName, k.trashRoot) {
+		return http.StatusBadRequest, trashReserved(k.trashRoot)
+	}
+	if k.maxLength > 0 && len(objectName) > k.maxLength {
+		return http.StatusBadRequest, fmt.Sprintf("Object name is %d bytes long; the limit is %d", len(objectName), k.maxLength)
+	}
+	if n := strings.Count(objectName, "/") + 1; k.maxSegments > 0 && n > k.maxSegments {
+		return http.StatusBadRequest, fmt.Sprintf("Object name '%s' has %d path segments; the limit is %d", objectName, n, k.maxSegments)
+	}
+	f := k.filterFor(bucket)
+	if p, ok := matchKey(f.Deny, objectName); ok {
+		return http.StatusForbidden, fmt.Sprintf("Object name '%s' is blocked by upload policy (matches '%s')", objectName, p)
+	}
+	if len(f.Allow) > 0 {
+		if _, ok := matchKey(f.Allow, objectName); !ok {
+			return http.StatusUnsupportedMediaType, fmt.Sprintf("Object name '%s' is not an allowed file type for this bucket", objectName)
+		}
+	}
+	return 0, ""
+}
+
+// matchKey returns the first pattern that matches key, case-insensitively.
+func matchKey(patterns []string, key string) (string, bool) {
+	key = strings.ToLower(key)
+	base := path.Base(key)
+	for _, p := range patterns {
+		target := base
+		if strings.Contains(p, "/") {
+			target = key
+		}
+		if ok, _ := path.Match(strings.ToLower(p), target); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
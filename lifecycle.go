@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// lifecycle coordinates a graceful shutdown. Draining starts when the server
+// stops accepting requests: event streams end so their clients reconnect
+// elsewhere, and in-flight requests get MINIO_SHUTDOWN_GRACE to finish.
+// Whatever is still running after that is cancelled. A nil lifecycle never
+// drains or cancels, as in tests.
+type lifecycle struct {
+	// ctx is the base of every request's context, and of uploads detached
+	// from one; it is cancelled when the grace period ends.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	draining chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel, draining: make(chan struct{})}
+}
+
+// baseContext is the http.Server BaseContext.
+func (l *lifecycle) baseContext(net.Listener) context.Context {
+	return l.ctx
+}
+
+// drained is closed once shutdown starts; long-lived handlers such as event
+// streams select on it to finish.
+func (l *lifecycle) drained() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	return l.draining
+}
+
+// detach returns a context with ctx's values that isn't cancelled along with
+// ctx, so a client disconnecting doesn't cut an upload short halfway, but is
+// cancelled when the shutdown grace period ends.
+func (l *lifecycle) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if l == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(l.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// shutdown stops srv accepting requests, ends event streams and waits up to
+// grace for in-flight requests. Past the grace period, it cancels them and
+// closes their connections, and returns context.DeadlineExceeded.
+func (l *lifecycle) shutdown(srv *http.Server, grace time.Duration) error {
+	close(l.draining)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	l.cancel()
+	if err != nil {
+		srv.Close()
+	}
+	return err
+}
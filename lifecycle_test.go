@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handler on a local port under lc, returning its URL.
+func startServer(t *testing.T, lc *lifecycle, handler http.Handler) (*http.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler, BaseContext: lc.baseContext}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return srv, "http://" + ln.Addr().String()
+}
+
+func TestShutdownEndsWatchStreams(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.lifecycle = newLifecycle()
+	srv, url := startServer(t, h.lifecycle, h.routes())
+
+	resp, err := http.Get(url + "/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	lines.Scan() // ": connection established"
+
+	done := make(chan error, 1)
+	go func() { done <- h.lifecycle.shutdown(srv, 5*time.Second) }()
+	var shutdownEvent bool
+	for lines.Scan() {
+		shutdownEvent = shutdownEvent || strings.HasPrefix(lines.Text(), "event: shutdown")
+	}
+	if !shutdownEvent {
+		t.Error("stream ended without a shutdown event")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	lc := newLifecycle()
+	started := make(chan struct{}, 2)
+	uploadErr := make(chan error, 1)
+	srv, url := startServer(t, lc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		if r.URL.Path == "/quick" {
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("done"))
+			return
+		}
+		// An upload detached from the request ends with the grace period.
+		ctx, cancel := lc.detach(r.Context())
+		defer cancel()
+		<-ctx.Done()
+		uploadErr <- ctx.Err()
+	}))
+
+	quick := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url + "/quick")
+		if err != nil {
+			quick <- 0
+			return
+		}
+		resp.Body.Close()
+		quick <- resp.StatusCode
+	}()
+	go http.Get(url + "/upload")
+	<-started
+	<-started
+
+	if err := lc.shutdown(srv, 300*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown = %v, want the grace period to run out", err)
+	}
+	if code := <-quick; code != http.StatusOK {
+		t.Errorf("in-flight request: status %d, want it to finish", code)
+	}
+	select {
+	case err := <-uploadErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("upload context: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("upload still running after the grace period")
+	}
+}
+
+func TestDetachWithoutLifecycle(t *testing.T) {
+	var lc *lifecycle
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := lc.detach(parent)
+	defer cancel()
+	cancelParent()
+	if ctx.Err() != nil {
+		t.Error("detached context cancelled along with its parent")
+	}
+	if lc.drained() != nil {
+		t.Error("nil lifecycle drains")
+	}
+}
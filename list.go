@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// listLimits bounds the work a single /list request may do. Zero values mean
+// no limit.
+type listLimits struct {
+	maxKeys  int
+	timeout  time.Duration
+	maxDepth int
+	// partial returns what was listed before a listing error instead of
+	// failing the request; ?partial= overrides it per request.
+	partial bool
+}
+
+// loadListLimits reads MINIO_LIST_MAX_KEYS, MINIO_LIST_TIMEOUT,
+// MINIO_LIST_MAX_DEPTH and MINIO_LIST_PARTIAL_ON_ERROR.
+func loadListLimits() listLimits {
+	return listLimits{
+		maxKeys:  int(max(envInt64("MINIO_LIST_MAX_KEYS", 1000), 0)),
+		timeout:  envDuration("MINIO_LIST_TIMEOUT", 30*time.Second),
+		maxDepth: int(max(envInt64("MINIO_LIST_MAX_DEPTH", 0), 0)),
+		partial:  os.Getenv("MINIO_LIST_PARTIAL_ON_ERROR") == "true",
+	}
+}
+
+type listResponse struct {
+	Files     []string `json:"files"`
+	Truncated bool     `json:"truncated"`
+	// DeadlineExceeded is set when the listing was cut short by
+	// MINIO_LIST_TIMEOUT or the client's X-Max-Duration rather than by
+	// MINIO_LIST_MAX_KEYS.
+	DeadlineExceeded bool `json:"deadline_exceeded,omitempty"`
+	// Error describes the listing error that cut a partial listing short.
+	Error string `json:"error,omitempty"`
+	// NextToken is passed back as ?continuation-token= (or ?after=) to
+	// continue a truncated listing. Next repeats it for older clients.
+	NextToken string `json:"nextToken,omitempty"`
+	Next      string `json:"next,omitempty"`
+	// Objects describes each entry of Files.
+	Objects []listEntry `json:"objects"`
+}
+
+// listEntry describes an entry of a listing. Folders have no size, ETag or
+// modification time of their own.
+type listEntry struct {
+	Key          string     `json:"key"`
+	Folder       bool       `json:"folder,omitempty"`
+	Size         int64      `json:"size"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+// collapseDepth shortens key to its first depth path segments, returning
+// the folder prefix and true when the key is nested deeper than that.
+func collapseDepth(key string, depth int) (string, bool) {
+	if depth <= 0 {
+		return key, false
+	}
+	end := 0
+	for range depth {
+		i := strings.Index(key[end:], "/")
+		if i < 0 || end+i == len(key)-1 {
+			return key, false
+		}
+		end += i + 1
+	}
+	return key[:end], true
+}
+
+// groupAt shortens key to the prefix plus everything up to and including
+// the first delimiter after it, as S3 groups common prefixes, returning
+// true when key was grouped.
+func groupAt(key, prefix, delimiter string) (string, bool) {
+	i := strings.Index(key[len(prefix):], delimiter)
+	if i < 0 {
+		return key, false
+	}
+	return key[:len(prefix)+i+len(delimiter)], true
+}
+
+// cursorAfter returns the ?after= value that continues a listing past entry.
+// A folder entry stands for every key beneath it, so the cursor skips them.
+func cursorAfter(entry string, folder bool) string {
+	if folder {
+		return entry + string(utf8.MaxRune)
+	}
+	return entry
+}
+
+// timeWindow selects objects by LastModified; zero bounds are open.
+type timeWindow struct {
+	since, before time.Time
+}
+
+// parseTimeWindow reads ?modified_since= (inclusive) and ?modified_before=
+// (exclusive) as RFC 3339 timestamps.
+func parseTimeWindow(q url.Values) (timeWindow, error) {
+	var tw timeWindow
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"modified_since", &tw.since}, {"modified_before", &tw.before}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return tw, fmt.Errorf("%s must be an RFC 3339 timestamp such as 2024-05-01T00:00:00Z", p.name)
+			}
+			*p.t = t
+		}
+	}
+	if !tw.since.IsZero() && !tw.before.IsZero() && !tw.since.Before(tw.before) {
+		return tw, errors.New("modified_since must be before modified_before")
+	}
+	return tw, nil
+}
+
+func (tw timeWindow) active() bool {
+	return !tw.since.IsZero() || !tw.before.IsZero()
+}
+
+func (tw timeWindow) contains(t time.Time) bool {
+	return (tw.since.IsZero() || !t.Before(tw.since)) && (tw.before.IsZero() || t.Before(tw.before))
+}
+
+// listFilesHandler lists object names at the top level of the bucket, or
+// every name with ?recursive=true, optionally under ?prefix=. Listings stop
+// at MINIO_LIST_MAX_KEYS entries or after MINIO_LIST_TIMEOUT and report
+// truncated with a cursor to resume from; recursive keys deeper than
+// MINIO_LIST_MAX_DEPTH are reported as their folder at that depth. A
+// client's X-Max-Duration shortens the timeout for its request.
+//
+// ?delimiter= groups a non-recursive listing on a character other than "/",
+// for keys such as "reports:2024:q1.csv". minio-go only lists with "/",
+// so other delimiters are grouped here from a recursive listing of the
+// prefix, which costs as much as listing it in full.
+//
+// A listing error fails the request unless ?partial=true (or
+// MINIO_LIST_PARTIAL_ON_ERROR) is set, in which case the entries listed so
+// far are returned as a truncated listing with the error, so a transient
+// failure deep into a large listing can be resumed from next.
+//
+// ?max-keys= returns fewer entries per page than MINIO_LIST_MAX_KEYS, but
+// never more. ?continuation-token= is accepted for ?after=, as S3 clients
+// call it. Each entry's size, ETag and modification time are returned under
+// objects; ?details=true, which used to ask for them, is still accepted.
+//
+// ?modified_since= and ?modified_before= keep only objects last modified in
+// that window. S3 can't filter listings by time, so every key under the
+// prefix is still listed and filtered here: narrow windows over large
+// prefixes cost as much as listing them in full, and are mostly bounded by
+// MINIO_LIST_TIMEOUT. Folder entries of a non-recursive listing carry no
+// modification time and are left out of a filtered listing.
+func (h *MinioHandler) listFilesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	recursive := q.Get("recursive") == "true"
+	after := q.Get("after")
+	if after == "" {
+		after = q.Get("continuation-token")
+	}
+	maxKeys := h.listLimits.maxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "max-keys must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if maxKeys == 0 || n < maxKeys {
+			maxKeys = n
+		}
+	}
+	window, err := parseTimeWindow(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	if delimiter == "" {
+		delimiter = "/"
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		http.Error(w, "delimiter must be a single character, such as : or |", http.StatusBadRequest)
+		return
+	}
+	grouped := !recursive && delimiter != "/"
+	partial := h.listLimits.partial
+	if v := q.Get("partial"); v != "" {
+		partial = v == "true"
+	}
+
+	timeout, ok := h.maxDuration(w, r)
+	if !ok {
+		return
+	}
+	if timeout == 0 || (h.listLimits.timeout > 0 && h.listLimits.timeout < timeout) {
+		timeout = h.listLimits.timeout
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), timeout)
+	}
+	// Cancelling also stops the listing goroutine when we return early.
+	defer cancel()
+
+	resp := listResponse{Files: []string{}, Objects: []listEntry{}}
+	lastFolder := false
+	// scanned is the last key listed, matching or not; a filtered listing
+	// that times out or fails resumes from there rather than rescanning.
+	scanned := ""
+	objectCh := h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{
+		Prefix:     prefix,
+		Recursive:  recursive || grouped,
+		StartAfter: after,
+	})
+	for object := range objectCh {
+		if ctx.Err() != nil {
+			break
+		}
+		if object.Err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger(r.Context()).Error("Error listing object", "err", object.Err)
+			if partial {
+				resp.Truncated, resp.Error = true, object.Err.Error()
+				break
+			}
+			h.storeFailed(w, "Failed to list files", object.Err)
+			return
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		entry, folder := object.Key, !recursive && strings.HasSuffix(object.Key, "/")
+		switch {
+		case grouped:
+			entry, folder = groupAt(object.Key, prefix, delimiter)
+		case recursive:
+			entry, folder = collapseDepth(object.Key, h.listLimits.maxDepth)
+		}
+		if window.active() {
+			scanned = object.Key
+			if !window.contains(object.LastModified) || (!recursive && folder) {
+				continue
+			}
+		}
+		if n := len(resp.Files); n > 0 && resp.Files[n-1] == entry {
+			continue
+		}
+		if maxKeys > 0 && len(resp.Files) == maxKeys {
+			resp.Truncated = true
+			break
+		}
+		resp.Files = append(resp.Files, entry)
+		e := listEntry{Key: entry, Folder: folder}
+		if !folder {
+			e.Size, e.ETag, e.LastModified = object.Size, object.ETag, &object.LastModified
+		}
+		resp.Objects = append(resp.Objects, e)
+		lastFolder = folder
+	}
+	timedOut := ctx.Err() != nil && r.Context().Err() == nil
+	if timedOut {
+		logger(r.Context()).Warn("Listing stopped early", "timeout", timeout, "entries", len(resp.Files))
+		resp.Truncated = true
+		resp.DeadlineExceeded = true
+	}
+	if resp.Truncated {
+		resp.Next = after
+		if n := len(resp.Files); n > 0 {
+			resp.Next = cursorAfter(resp.Files[n-1], lastFolder)
+		}
+		if (timedOut || resp.Error != "") && scanned > resp.Next {
+			resp.Next = scanned
+		}
+		resp.NextToken = resp.Next
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestListTruncatesAndResumes(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.listLimits.maxKeys = 2
+	for _, key := range []string{"a.txt", "b/one.txt", "b/two.txt", "c.txt", "d.txt"} {
+		store.put(testBucket, key, []byte("x"), "text/plain")
+	}
+
+	var pages []string
+	after := ""
+	for range 5 {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?after="+url.QueryEscape(after), nil))
+		var got listResponse
+		decodeJSON(t, rec, &got)
+		pages = append(pages, strings.Join(got.Files, ","))
+		if !got.Truncated {
+			break
+		}
+		after = got.Next
+	}
+	if want := "a.txt,b/|c.txt,d.txt"; strings.Join(pages, "|") != want {
+		t.Errorf("pages = %q, want %q", strings.Join(pages, "|"), want)
+	}
+}
+
+func TestListExactlyMaxKeysIsNotTruncated(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.listLimits.maxKeys = 2
+	store.put(testBucket, "a.txt", []byte("x"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("x"), "text/plain")
+
+	var got listResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)), &got)
+	if got.Truncated || got.Next != "" {
+		t.Errorf("list = %+v, want not truncated", got)
+	}
+}
+
+func TestListRecursiveMaxDepth(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.listLimits.maxDepth = 2
+	for _, key := range []string{"a/b/c/d.txt", "a/b/c/e.txt", "a/b/f.txt", "a/g.txt", "h.txt"} {
+		store.put(testBucket, key, []byte("x"), "text/plain")
+	}
+
+	var got listResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?recursive=true", nil)), &got)
+	if want := "a/b/,a/g.txt,h.txt"; strings.Join(got.Files, ",") != want {
+		t.Errorf("files = %v, want %s", got.Files, want)
+	}
+}
+
+func TestListModifiedWindow(t *testing.T) {
+	h, store := newTestHandler(t)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, key := range []string{"sync/old.txt", "sync/edge.txt", "sync/new.txt", "sync/future.txt", "other/new.txt"} {
+		store.put(testBucket, key, []byte("x"), "text/plain")
+		o, _ := store.lookup(testBucket, key)
+		o.info.LastModified = day.Add(time.Duration(i%4-1) * 24 * time.Hour)
+	}
+
+	target := "/list?recursive=true&prefix=sync/&modified_since=2024-05-01T00:00:00Z&modified_before=2024-05-03T00:00:00Z"
+	rec := serve(h, httptest.NewRequest(http.MethodGet, target, nil))
+	var got listResponse
+	decodeJSON(t, rec, &got)
+	if want := "sync/edge.txt,sync/new.txt"; strings.Join(got.Files, ",") != want {
+		t.Errorf("files = %v, want %s", got.Files, want)
+	}
+
+	for _, bad := range []string{"modified_since=yesterday", "modified_since=2024-05-02T00:00:00Z&modified_before=2024-05-01T00:00:00Z"} {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?"+bad, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+func TestListCustomDelimiter(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.listLimits.maxKeys = 2
+	for _, key := range []string{"logs|a:1.txt", "logs|a:2.txt", "logs|b:1.txt", "logs|c.txt", "logs/d.txt"} {
+		store.put(testBucket, key, []byte("x"), "text/plain")
+	}
+
+	var pages []string
+	after := ""
+	for range 5 {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?prefix=logs|&delimiter=:&after="+url.QueryEscape(after), nil))
+		var got listResponse
+		decodeJSON(t, rec, &got)
+		pages = append(pages, strings.Join(got.Files, ","))
+		if !got.Truncated {
+			break
+		}
+		after = got.Next
+	}
+	if want := "logs|a:,logs|b: ; logs|c.txt"; strings.Join(pages, " ; ") != want {
+		t.Errorf("pages = %q, want %q", strings.Join(pages, " ; "), want)
+	}
+
+	for _, bad := range []string{"::", "%7C%7C"} {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?delimiter="+bad, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("delimiter=%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+// failingListStore lists its objects and then fails, as a listing can
+// partway through a large bucket.
+type failingListStore struct {
+	*fakeStore
+}
+
+func (s failingListStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	ch := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(ch)
+		for object := range s.fakeStore.ListObjects(ctx, bucketName, opts) {
+			ch <- object
+		}
+		ch <- minio.ObjectInfo{Err: errors.New("connection reset by peer")}
+	}()
+	return ch
+}
+
+func TestListPartialOnError(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.store = failingListStore{store}
+	store.put(testBucket, "a.txt", []byte("x"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("x"), "text/plain")
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)); rec.Code != http.StatusInternalServerError {
+		t.Errorf("strict status = %d, want 500", rec.Code)
+	}
+
+	var got listResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?partial=true", nil)), &got)
+	if strings.Join(got.Files, ",") != "a.txt,b.txt" || !got.Truncated || got.Error == "" || got.Next != "b.txt" {
+		t.Errorf("partial list = %+v", got)
+	}
+
+	h.listLimits.partial = true
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?partial=false", nil)); rec.Code != http.StatusInternalServerError {
+		t.Errorf("partial=false status = %d, want 500", rec.Code)
+	}
+}
+
+func TestListMaxKeysAndDetails(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.listLimits.maxKeys = 3
+	for _, key := range []string{"a.txt", "b/one.txt", "c.txt", "d.txt"} {
+		store.put(testBucket, key, []byte("hello"), "text/plain")
+	}
+
+	var got listResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?max-keys=2", nil)), &got)
+	if strings.Join(got.Files, ",") != "a.txt,b/" || !got.Truncated || got.NextToken == "" || got.Next != got.NextToken {
+		t.Fatalf("first page = %+v, want a.txt,b/ truncated", got)
+	}
+	if len(got.Objects) != 2 {
+		t.Fatalf("objects = %+v, want 2", got.Objects)
+	}
+	if o := got.Objects[0]; o.Key != "a.txt" || o.Folder || o.Size != 5 || o.ETag == "" || o.LastModified == nil {
+		t.Errorf("object entry = %+v", o)
+	}
+	if o := got.Objects[1]; o.Key != "b/" || !o.Folder || o.ETag != "" || o.LastModified != nil {
+		t.Errorf("folder entry = %+v", o)
+	}
+
+	// continuation-token is another name for after.
+	var next listResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?max-keys=2&continuation-token="+url.QueryEscape(got.NextToken), nil)), &next)
+	if strings.Join(next.Files, ",") != "c.txt,d.txt" || len(next.Objects) != 2 || next.Objects[1].Size != 5 {
+		t.Errorf("second page = %+v, want c.txt,d.txt with details", next)
+	}
+
+	// max-keys can't raise MINIO_LIST_MAX_KEYS.
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?max-keys=100", nil)), &got)
+	if len(got.Files) != 3 || !got.Truncated {
+		t.Errorf("max-keys above the limit = %+v, want 3 entries", got)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list?max-keys=0", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("max-keys=0: status %d, want 400", rec.Code)
+	}
+}
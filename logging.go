@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// requestIDHeader carries the ID that ties a request's log lines together.
+// A client may send its own; otherwise one is generated. Either way it is
+// echoed on the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds the IDs accepted from clients.
+const maxRequestIDLen = 128
+
+// loadLogger builds the logger from MINIO_LOG_FORMAT (text, the default, or
+// json) and MINIO_LOG_LEVEL (debug, info, the default, warn or error).
+func loadLogger(out io.Writer) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{}
+	if v := os.Getenv("MINIO_LOG_LEVEL"); v != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("MINIO_LOG_LEVEL: unknown level %q (use debug, info, warn or error)", v)
+		}
+		opts.Level = level
+	}
+	switch v := os.Getenv("MINIO_LOG_FORMAT"); v {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(out, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(out, opts)), nil
+	default:
+		return nil, fmt.Errorf("MINIO_LOG_FORMAT: unknown format %q (use text or json)", v)
+	}
+}
+
+// fatal logs msg at error level and exits, for startup failures.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// logger returns the logger for ctx: the one withRequestID attached, which
+// adds the request ID to every line, or the default logger.
+func logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+type requestIDKey struct{}
+
+// requestID returns the ID of the request ctx belongs to, or "".
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so a client
+// can't forge log lines or headers with its own.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID gives every request an ID, from the client's X-Request-ID
+// when it sends a valid one, sets it on the response, and attaches a logger
+// that adds it to every line logged for the request (see logger).
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, loggerKey{}, slog.Default().With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logBackendErrors wraps a MinIO transport to log failed requests with the
+// ID of the request that caused them and MinIO's own request ID, so an
+// error can be followed from our logs into MinIO's. Client errors such as a
+// missing object are routine and logged at debug level only.
+func logBackendErrors(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		log := logger(req.Context())
+		switch {
+		case err != nil && !errors.Is(err, context.Canceled):
+			log.Warn("MinIO request failed", "method", req.Method, "path", req.URL.Path, "err", err)
+		case err != nil:
+		case resp.StatusCode >= 500:
+			log.Warn("MinIO returned an error", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode,
+				"minio_request_id", resp.Header.Get("X-Amz-Request-Id"))
+		case resp.StatusCode >= 400:
+			log.Debug("MinIO returned an error", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode,
+				"minio_request_id", resp.Header.Get("X-Amz-Request-Id"))
+		}
+		return resp, err
+	})
+}
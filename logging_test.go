@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadLogger(t *testing.T) {
+	var out bytes.Buffer
+	t.Setenv("MINIO_LOG_FORMAT", "json")
+	t.Setenv("MINIO_LOG_LEVEL", "warn")
+	l, err := loadLogger(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("hidden")
+	l.Warn("shown", "object", "a.txt")
+	var rec map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil || rec["msg"] != "shown" || rec["object"] != "a.txt" {
+		t.Errorf("log output %q: %v", out.String(), err)
+	}
+
+	t.Setenv("MINIO_LOG_FORMAT", "xml")
+	if _, err := loadLogger(&out); err == nil {
+		t.Error("unknown format accepted")
+	}
+	t.Setenv("MINIO_LOG_FORMAT", "")
+	t.Setenv("MINIO_LOG_LEVEL", "loud")
+	if _, err := loadLogger(&out); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &out
+}
+
+func TestWithRequestID(t *testing.T) {
+	out := captureLogs(t)
+	var seen string
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r.Context())
+		logger(r.Context()).Info("handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	req.Header.Set(requestIDHeader, "gw-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if seen != "gw-123" || rec.Header().Get(requestIDHeader) != "gw-123" {
+		t.Errorf("client ID: saw %q, header %q", seen, rec.Header().Get(requestIDHeader))
+	}
+	if !strings.Contains(out.String(), `"request_id":"gw-123"`) {
+		t.Errorf("log line lacks the request ID: %s", out)
+	}
+
+	for _, bad := range []string{"", "has space", "line\nbreak", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/list", nil)
+		req.Header.Set(requestIDHeader, bad)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get(requestIDHeader); got == bad || len(got) != 32 {
+			t.Errorf("ID %q: got %q, want a generated one", bad, got)
+		}
+	}
+}
+
+func TestLogBackendErrors(t *testing.T) {
+	out := captureLogs(t)
+	rt := logBackendErrors(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: http.NoBody}
+		resp.Header.Set("X-Amz-Request-Id", "17A2B3C4D5E6F708")
+		return resp, nil
+	}))
+	var req *http.Request
+	withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = httptest.NewRequestWithContext(r.Context(), http.MethodGet, "http://minio/bucket/a.txt", nil)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/download/a.txt", nil))
+	rt.RoundTrip(req)
+
+	var rec map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatalf("log output %q: %v", out, err)
+	}
+	if rec["level"] != "WARN" || rec["minio_request_id"] != "17A2B3C4D5E6F708" || rec["request_id"] != requestID(req.Context()) || rec["path"] != "/bucket/a.txt" {
+		t.Errorf("log record = %v", rec)
+	}
+}
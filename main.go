@@ -3,22 +3,156 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time" // <-- IMPORTED FOR URL EXPIRATION
 
 	"github.com/joho/godotenv"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"golang.org/x/sync/singleflight"
 )
 
-// MinioHandler holds the MinIO client and bucket name.
+// MinioHandler holds the object store and bucket name.
 type MinioHandler struct {
-	minioClient *minio.Client
+	store       ObjectStore
 	bucketName  string
+	uploadRules keyRules
+	visibility  visibilityRules
+	untrusted   untrustedRules
+	jobs        *jobRegistry
+	cache       *diskCache
+	presigned   *presignCache
+	// presignMissing signs download links without checking that the
+	// object exists (MINIO_PRESIGN_CHECK_EXISTS=false).
+	presignMissing bool
+	// publicLinks signs client-facing links for MINIO_PUBLIC_ENDPOINT;
+	// nil uses store.
+	publicLinks presigner
+	// stsLinks signs ?scoped=true links when MINIO_STS_LINKS is set.
+	stsLinks *stsLinks
+
+	// contentTypes maps extensions to the type ?infer_type=true serves
+	// them as, ahead of the system MIME table.
+	contentTypes map[string]string
+
+	// propagateHeaders are request headers uploads store as object
+	// metadata and downloads send back, such as X-Correlation-Id.
+	propagateHeaders []string
+
+	// sharding is the store when MINIO_KEY_SHARD_WIDTH is set, kept so
+	// /admin/reshard can reach the keys beneath it; nil otherwise.
+	sharding *shardedStore
+
+	// Multipart tuning for PutObject; zero means minio-go's default.
+	partSize      uint64
+	uploadThreads uint
+	rawMaxBytes   int64
+	multipart     multipartLimits
+	// clientClosedStatus is logged for uploads the client abandoned.
+	clientClosedStatus int
+	// checksum is the x-amz-checksum algorithm uploads send by default;
+	// unset leaves the choice to the SDK.
+	checksum minio.ChecksumType
+
+	dataURIs        *dataURICache
+	dataURIMaxBytes int64
+
+	prefetchConcurrency int
+	prefetchMaxBytes    int64
+
+	sidecars         sidecarLimits
+	concatMaxObjects int
+	// tagScanMax caps the objects /copy-by-tag checks per request.
+	tagScanMax int
+	// transformMaxBytes caps the data a /transform gunzip may produce.
+	transformMaxBytes int64
+	// convertQuality is the default JPEG quality of /convert.
+	convertQuality int
+
+	// readOnly blocks every write endpoint with a 503 while set. It is
+	// shared with the tenant handlers.
+	readOnly *atomic.Bool
+
+	watchBuffer       int
+	watchStallTimeout time.Duration
+
+	listLimits   listLimits
+	uploadPolicy uploadPolicyLimits
+
+	// maxDurationCap bounds the X-Max-Duration a client may ask for; zero
+	// leaves it unbounded.
+	maxDurationCap time.Duration
+
+	// Soft per-prefix quotas, checked against the usage cache on upload.
+	usage            *usageCache
+	quotas           quotaRules
+	quotaWarnPercent float64
+
+	// Cached /usage-tree rollups; nil disables caching.
+	usageTrees *usageTreeCache
+
+	// Tracks MinIO throttling so failures can carry a Retry-After.
+	backpressure *backpressure
+	// Turns requests away while MinIO is down; nil when disabled.
+	breaker *breaker
+
+	shares *shareLinks
+
+	// Latest bucket events for /recent-events; nil when disabled.
+	recent *eventRing
+
+	// Warns about objects nearing their expiry; nil when disabled.
+	expiryNotices *expiryNotifier
+
+	thumbnails thumbnailLimits
+	archive    archiveSettings
+
+	// variantBuilds lets concurrent requests for the same derived variant,
+	// a thumbnail or a converted image, share one decode and encode. Each
+	// view gets its own, since views on other stores may serve a bucket
+	// of the same name.
+	variantBuilds *singleflight.Group
+
+	// emptyExclude are glob patterns of zero-byte objects that are meant to
+	// be empty, such as ".keep".
+	emptyExclude []string
+
+	// protectVersions makes /modify on a versioned bucket ask for
+	// ?new_version=true or If-Match before it adds a version.
+	protectVersions bool
+
+	// tenants routes requests for other MinIO tenants to their own handler;
+	// nil when MINIO_TENANTS_FILE is unset. basePath is the path prefix of
+	// a tenant handler's routes, used in links it hands out.
+	tenants  *tenantRouter
+	basePath string
+
+	// buckets routes /b/{bucket}/ requests to a handler on that bucket;
+	// nil when MINIO_BUCKETS is unset.
+	buckets *bucketRouter
+	// peers are the buckets /copy and /move may reach besides bucketName.
+	// Unlike buckets, bucket handlers keep it; nil without MINIO_BUCKETS.
+	peers *bucketRouter
+
+	// auth checks tokens and API keys ahead of every route; nil when
+	// MINIO_AUTH is unset or "none".
+	auth *authenticator
+
+	// lifecycle ends event streams and uploads on shutdown; nil in tests.
+	lifecycle *lifecycle
+
+	tus    tusSettings
+	unpack unpackLimits
+	trash  trashSettings
+	sse    sseSettings
 }
 
 func main() {
@@ -26,8 +160,15 @@ func main() {
 	// -- Minio Environment Variables --
 	// Load environment variables from .env file if it exists
 	err := godotenv.Load()
+	// Logs are structured, as text or JSON (see logging.go); the standard
+	// log package writes through the same handler.
+	baseLogger, logErr := loadLogger(os.Stderr)
+	if logErr != nil {
+		fatal("Error loading log settings", "err", logErr)
+	}
+	slog.SetDefault(baseLogger)
 	if err != nil {
-		log.Println("Warning: Could not load .env file. Falling back to system environment variables.")
+		slog.Warn("Could not load .env file; falling back to system environment variables")
 	}
 
 	// --- MinIO Configuration ---
@@ -38,104 +179,691 @@ func main() {
 	useSSL := true // Should be true for production
 
 	if endpoint == "" || accessKeyID == "" || secretAccessKey == "" || bucketName == "" {
-		log.Fatal("Error: MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, and MINIO_BUCKET environment variables must be set.")
+		fatal("MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, and MINIO_BUCKET environment variables must be set")
+	}
+
+	// Throttled (SlowDown/503) responses are waited out per their Retry-After
+	// before minio-go retries them (see backpressure.go).
+	backpressure := newBackpressure(
+		envDuration("MINIO_RETRY_AFTER_BASE", time.Second),
+		envDuration("MINIO_RETRY_AFTER_MAX", 30*time.Second),
+	)
+	// After MINIO_BREAKER_THRESHOLD failures in a row, requests get 503
+	// until MinIO answers again (see breaker.go).
+	breaker := newBreaker(
+		int(envInt64("MINIO_BREAKER_THRESHOLD", 0)),
+		envDuration("MINIO_BREAKER_COOLDOWN", 30*time.Second),
+	)
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		fatal("Error initializing MinIO transport", "err", err)
 	}
 
-	// 1. Initialize MinIO client object.
-	minioClient, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: useSSL,
-		// Using BucketLookupPath is important for Nginx proxy compatibility.
-		BucketLookup: minio.BucketLookupPath,
+	lookups, err := loadBucketLookupRules()
+	if err != nil {
+		fatal("Error loading bucket addressing settings", "err", err)
+	}
+
+	// 1. Initialize MinIO client object. The store re-creates the client
+	// if the bucket turns out to live in a different region (see region.go).
+	store, err := newRegionStore(os.Getenv("MINIO_REGION"), func(region string) (ObjectStore, error) {
+		minioClient, err := minio.New(endpoint, &minio.Options{
+			Creds:      credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+			Secure:     useSSL,
+			Region:     region,
+			Transport:  breaker.wrap(backpressure.wrap(countBackendRequests(logBackendErrors(transport)))),
+			MaxRetries: int(envInt64("MINIO_MAX_RETRIES", 0)),
+			// Required for the x-amz-checksum trailers (see checksum.go).
+			TrailingHeaders: true,
+			// Path style, the default, is important for Nginx proxy
+			// compatibility; some gateways need DNS style for some buckets.
+			BucketLookupViaURL: lookups.forBucket,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newMinioStore(minioClient), nil
 	})
 	if err != nil {
-		log.Fatalf("Error initializing MinIO client: %s\n", err)
+		fatal("Error initializing MinIO client", "err", err)
+	}
+	if breaker != nil {
+		breaker.probe = func(ctx context.Context) error {
+			_, err := store.BucketExists(ctx, bucketName)
+			return err
+		}
 	}
 
-	log.Printf("Successfully connected to MinIO at %s\n", endpoint)
+	slog.Info("Successfully connected to MinIO", "endpoint", endpoint)
 
 	// 2. Ensure the bucket exists.
 	ctx := context.Background()
-	err = minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+	err = store.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
 	if err != nil {
-		exists, errBucketExists := minioClient.BucketExists(ctx, bucketName)
+		exists, errBucketExists := store.BucketExists(ctx, bucketName)
 		if errBucketExists == nil && exists {
-			log.Printf("Bucket '%s' already exists.\n", bucketName)
+			slog.Info("Bucket already exists", "bucket", bucketName)
 		} else {
-			log.Fatalf("Error creating/checking bucket: %s\n", err)
+			hintErr := errBucketExists
+			if hintErr == nil {
+				hintErr = err
+			}
+			if hint := addressingHint(hintErr, bucketName, lookups.forBucket(url.URL{}, bucketName)); hint != "" {
+				slog.Info("Hint: " + hint)
+			}
+			fatal("Error creating/checking bucket", "err", err)
 		}
 	} else {
-		log.Printf("Successfully created bucket '%s'.\n", bucketName)
+		slog.Info("Successfully created bucket", "bucket", bucketName)
+	}
+
+	if os.Getenv("MINIO_PREWARM") == "true" {
+		conns := int(envInt64("MINIO_PREWARM_CONNECTIONS", 4))
+		prewarmCtx, cancel := context.WithTimeout(ctx, envDuration("MINIO_PREWARM_TIMEOUT", 10*time.Second))
+		err := prewarm(prewarmCtx, store, bucketName, conns)
+		cancel()
+		if err != nil {
+			fatal("Startup self-test against MinIO failed", "err", err)
+		}
+	}
+
+	uploadRules, err := loadKeyRules()
+	if err != nil {
+		fatal("Error loading upload rules", "err", err)
+	}
+
+	visibility, err := loadVisibilityRules()
+	if err != nil {
+		fatal("Error loading visibility rules", "err", err)
+	}
+	if len(visibility) > 0 {
+		if err := syncVisibilityPolicy(ctx, store, bucketName, visibility); err != nil {
+			slog.Warn("Could not apply prefix visibility to bucket policy", "err", err)
+		}
+	}
+
+	partSize, uploadThreads, err := loadUploadTuning()
+	if err != nil {
+		fatal("Error loading upload settings", "err", err)
+	}
+	quotas, err := loadQuotaRules()
+	if err != nil {
+		fatal("Error loading quotas", "err", err)
+	}
+
+	emptyExclude := envList("MINIO_EMPTY_OBJECT_EXCLUDE")
+	if err := (keyFilter{Deny: emptyExclude}).validate(); err != nil {
+		fatal("Error loading MINIO_EMPTY_OBJECT_EXCLUDE", "err", err)
+	}
+
+	multipart, err := loadMultipartLimits()
+	if err != nil {
+		fatal("Error loading upload settings", "err", err)
+	}
+
+	tus, err := loadTusSettings()
+	if err != nil {
+		fatal("Error loading tus settings", "err", err)
+	}
+
+	unpack, err := loadUnpackLimits()
+	if err != nil {
+		fatal("Error loading archive upload settings", "err", err)
+	}
+
+	trash, err := loadTrashSettings()
+	if err != nil {
+		fatal("Error loading trash settings", "err", err)
+	}
+	if trash.retention > 0 {
+		uploadRules.trashRoot = trash.root()
+	}
+
+	sse, err := loadSSESettings()
+	if err != nil {
+		fatal("Error loading encryption settings", "err", err)
+	}
+
+	archive, err := loadArchiveSettings()
+	if err != nil {
+		fatal("Error loading archive settings", "err", err)
+	}
+
+	checksum, err := parseChecksum(os.Getenv("MINIO_UPLOAD_CHECKSUM"))
+	if err != nil {
+		fatal("Error loading MINIO_UPLOAD_CHECKSUM", "err", err)
+	}
+
+	contentTypes, err := loadContentTypeOverrides()
+	if err != nil {
+		fatal("Error loading MINIO_CONTENT_TYPES", "err", err)
+	}
+	propagateHeaders, err := loadPropagatedHeaders()
+	if err != nil {
+		fatal("Error loading MINIO_PROPAGATE_HEADERS", "err", err)
+	}
+
+	var cache *diskCache
+	if dir := os.Getenv("MINIO_CACHE_DIR"); dir != "" {
+		cache, err = newDiskCache(dir, envInt64("MINIO_CACHE_MAX_BYTES", 1<<30), envInt64("MINIO_CACHE_MAX_OBJECT_BYTES", 8<<20))
+		if err != nil {
+			fatal("Error initializing download cache", "err", err)
+		}
+		expvar.Publish("cache_bytes", expvar.Func(cache.bytes))
+		slog.Info("Download cache enabled", "dir", dir)
 	}
 
 	// Instantiate our handler
 	handler := &MinioHandler{
-		minioClient: minioClient,
-		bucketName:  bucketName,
+		lifecycle:      newLifecycle(),
+		store:          store,
+		bucketName:     bucketName,
+		uploadRules:    uploadRules,
+		visibility:     visibility,
+		untrusted:      loadUntrustedRules(),
+		jobs:           newJobRegistry(),
+		cache:          cache,
+		presigned:      newPresignCache(int(envInt64("MINIO_PRESIGN_CACHE_SIZE", 1024)), envDuration("MINIO_PRESIGN_CACHE_MARGIN", time.Minute)),
+		presignMissing: os.Getenv("MINIO_PRESIGN_CHECK_EXISTS") == "false",
+
+		contentTypes:     contentTypes,
+		propagateHeaders: propagateHeaders,
+
+		partSize:      partSize,
+		uploadThreads: uploadThreads,
+		rawMaxBytes:   envInt64("MINIO_RAW_UPLOAD_MAX_BYTES", 1<<30),
+		multipart:     multipart,
+		checksum:      checksum,
+
+		clientClosedStatus: int(envInt64("MINIO_CLIENT_CLOSED_STATUS", statusClientClosedRequest)),
+
+		dataURIs:        newDataURICache(envDuration("MINIO_DATAURI_TTL", 10*time.Minute)),
+		dataURIMaxBytes: envInt64("MINIO_DATAURI_MAX_BYTES", 256<<10),
+
+		prefetchConcurrency: int(max(envInt64("MINIO_PREFETCH_CONCURRENCY", 4), 1)),
+		prefetchMaxBytes:    envInt64("MINIO_PREFETCH_MAX_BYTES", 1<<30),
+
+		sidecars:         loadSidecarLimits(),
+		concatMaxObjects: int(max(envInt64("MINIO_CONCAT_MAX_OBJECTS", 1000), 0)),
+		tagScanMax:       int(max(envInt64("MINIO_TAG_SCAN_MAX", 10000), 1)),
+
+		transformMaxBytes: envInt64("MINIO_TRANSFORM_MAX_BYTES", 1<<30),
+		convertQuality:    int(min(max(envInt64("MINIO_CONVERT_QUALITY", 85), 1), 100)),
+
+		watchBuffer:       int(max(envInt64("MINIO_WATCH_BUFFER", 64), 1)),
+		watchStallTimeout: envDuration("MINIO_WATCH_STALL_TIMEOUT", 30*time.Second),
+
+		listLimits:   loadListLimits(),
+		uploadPolicy: loadUploadPolicyLimits(),
+
+		maxDurationCap: envDuration("MINIO_MAX_DURATION", 10*time.Minute),
+
+		usage:            newUsageCache(envDuration("MINIO_USAGE_CACHE_TTL", 5*time.Minute)),
+		quotas:           quotas,
+		quotaWarnPercent: float64(envInt64("MINIO_QUOTA_WARN_PERCENT", 90)),
+
+		usageTrees: newUsageTreeCache(envDuration("MINIO_USAGE_TREE_TTL", 5*time.Minute)),
+
+		backpressure:    backpressure,
+		breaker:         breaker,
+		shares:          newShareLinks(),
+		recent:          newEventRing(int(envInt64("MINIO_RECENT_EVENTS", 1000))),
+		expiryNotices:   newExpiryNotifier(envDuration("MINIO_EXPIRY_NOTIFY_LEAD", 0), os.Getenv("MINIO_EXPIRY_WEBHOOK_URL")),
+		thumbnails:      loadThumbnailLimits(),
+		variantBuilds:   new(singleflight.Group),
+		archive:         archive,
+		tus:             tus,
+		unpack:          unpack,
+		trash:           trash,
+		sse:             sse,
+		emptyExclude:    emptyExclude,
+		protectVersions: os.Getenv("MINIO_PROTECT_VERSIONS") == "true",
+		readOnly:        new(atomic.Bool),
 	}
 
-	// --- HTTP Server Setup ---
-	http.HandleFunc("/upload", handler.uploadFileHandler)
-	http.HandleFunc("/modify/", handler.modifyFileHandler)
-	http.HandleFunc("/delete/", handler.deleteFileHandler)
-	http.HandleFunc("/list", handler.listFilesHandler)
-	http.HandleFunc("/watch", handler.watchBucketHandler)
+	// Signed after the bucket checks above, so a detected region is used.
+	if public := os.Getenv("MINIO_PUBLIC_ENDPOINT"); public != "" {
+		handler.publicLinks, err = newPublicPresigner(public, credentials.NewStaticV4(accessKeyID, secretAccessKey, ""), store.currentRegion(), useSSL)
+		if err != nil {
+			fatal("Error initializing MINIO_PUBLIC_ENDPOINT client", "err", err)
+		}
+		slog.Info("Download and upload links use the public endpoint", "endpoint", public)
+	}
+
+	if os.Getenv("MINIO_STS_LINKS") == "true" {
+		duration := envDuration("MINIO_STS_DURATION", minSTSDuration)
+		if duration < minSTSDuration || duration > maxSTSDuration {
+			fatal("Error loading MINIO_STS_DURATION: out of range", "min", minSTSDuration, "max", maxSTSDuration)
+		}
+		scheme := "http://"
+		if useSSL {
+			scheme = "https://"
+		}
+		region := store.currentRegion()
+		handler.stsLinks = &stsLinks{
+			endpoint:        scheme + endpoint,
+			accessKeyID:     accessKeyID,
+			secretAccessKey: secretAccessKey,
+			duration:        duration,
+			client:          &http.Client{Transport: transport, Timeout: 10 * time.Second},
+			signer: func(creds *credentials.Credentials) (presigner, error) {
+				if public := os.Getenv("MINIO_PUBLIC_ENDPOINT"); public != "" {
+					return newPublicPresigner(public, creds, region, useSSL)
+				}
+				client, err := minio.New(endpoint, &minio.Options{
+					Creds:              creds,
+					Secure:             useSSL,
+					Region:             region,
+					BucketLookupViaURL: lookups.forBucket,
+				})
+				if err != nil {
+					return nil, err
+				}
+				return newMinioStore(client), nil
+			},
+		}
+		slog.Info("Scoped download links use STS credentials", "valid_for", duration)
+	}
+
+	if width := int(envInt64("MINIO_KEY_SHARD_WIDTH", 0)); width != 0 {
+		handler.sharding, err = newShardedStore(store, width)
+		if err != nil {
+			fatal("Error loading key sharding", "err", err)
+		}
+		handler.store = handler.sharding
+		if handler.publicLinks != nil {
+			handler.publicLinks = shardedPresigner{presigner: handler.publicLinks, width: width}
+		}
+		slog.Info("Object keys are sharded by the leading hex digits of their SHA-1", "digits", width)
+	}
+
+	if file := os.Getenv("MINIO_TENANTS_FILE"); file != "" {
+		configs, err := loadTenantConfigs(file)
+		if err != nil {
+			fatal("Error loading MINIO_TENANTS_FILE", "err", err)
+		}
+		header := os.Getenv("MINIO_TENANT_HEADER")
+		if header == "" {
+			header = "X-Tenant"
+		}
+		handler.tenants = newTenantRouter(header, configs, func(cfg tenantConfig) (ObjectStore, error) {
+			transport, err := minio.DefaultTransport(cfg.secure())
+			if err != nil {
+				return nil, err
+			}
+			return newRegionStore(cfg.Region, func(region string) (ObjectStore, error) {
+				client, err := minio.New(cfg.Endpoint, &minio.Options{
+					Creds:           credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+					Secure:          cfg.secure(),
+					Region:          region,
+					Transport:       backpressure.wrap(countBackendRequests(logBackendErrors(transport))),
+					MaxRetries:      int(envInt64("MINIO_MAX_RETRIES", 0)),
+					TrailingHeaders: true,
+					BucketLookup:    cfg.lookup(),
+				})
+				if err != nil {
+					return nil, err
+				}
+				return newMinioStore(client), nil
+			})
+		})
+		slog.Info("Serving tenants", "tenants", len(configs), "header", header, "path", tenantPathPrefix+"{tenant}/")
+	}
+
+	if allowed := envList("MINIO_BUCKETS"); len(allowed) > 0 {
+		handler.buckets, err = newBucketRouter(bucketName, allowed, os.Getenv("MINIO_BUCKETS_AUTO_CREATE") != "false")
+		if err != nil {
+			fatal("Error loading MINIO_BUCKETS", "err", err)
+		}
+		handler.peers = handler.buckets
+		slog.Info("Serving further buckets", "patterns", allowed, "path", bucketPathPrefix+"{bucket}/")
+	}
+
+	handler.auth, err = loadAuth()
+	if err != nil {
+		fatal("Error loading MINIO_AUTH", "err", err)
+	}
+	if handler.auth != nil {
+		slog.Info("Requests need credentials", "schemes", handler.auth.challenge())
+	} else {
+		slog.Warn("MINIO_AUTH is not set; every endpoint is open to anyone who can reach the service")
+	}
+
+	prettyJSONDefault = os.Getenv("MINIO_JSON_PRETTY") == "true"
 
-	// --- REPLACED THE DOWNLOAD HANDLER ---
-	// http.HandleFunc("/download/", handler.downloadFileHandler) // <-- OLD WAY
-	http.HandleFunc("/get-download-link/", handler.getPresignedURLHandler) // <-- NEW, RECOMMENDED WAY
+	if os.Getenv("MINIO_READ_ONLY") == "true" {
+		handler.setReadOnly(true)
+		slog.Info("Starting in read-only mode")
+	}
+
+	// running ends on SIGINT or SIGTERM, which starts a graceful shutdown.
+	// Background workers stop with it.
+	running, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	if interval := envDuration("MINIO_TTL_SWEEP_INTERVAL", time.Hour); interval > 0 {
+		go handler.runTTLSweeper(running, interval)
+	}
+	if interval := envDuration("MINIO_EXPIRY_SCAN_INTERVAL", time.Hour); handler.expiryNotices != nil && interval > 0 {
+		go handler.runExpiryNotifier(running, interval)
+	}
+	if handler.recent != nil {
+		go handler.recordEvents(running)
+	}
+	// Buckets under /b/ and tenants start their own tus sweeper and trash
+	// purger when first served; see bucketRouter.handler and
+	// tenantRouter.handler.
+	handler.startTusSweeper(running)
+	handler.startTrashPurger(running)
+
+	// --- HTTP Server Setup ---
+	logFormat, err := parseAccessLogFormat(os.Getenv("MINIO_ACCESS_LOG"))
+	if err != nil {
+		fatal("Error loading MINIO_ACCESS_LOG", "err", err)
+	}
+	mux := handler.routes()
+	// JSON, CSV and text responses are gzipped for clients that accept it
+	// (see compress.go).
+	if os.Getenv("MINIO_GZIP_RESPONSES") != "false" {
+		mux = compressResponses(int(max(envInt64("MINIO_GZIP_MIN_BYTES", 1024), 0)), mux)
+	}
 
 	port := "8080"
-	log.Printf("Starting server on port %s...\n", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Failed to start server: %s\n", err)
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     withRequestID(accessLog(logFormat, os.Stdout, mux)),
+		BaseContext: handler.lifecycle.baseContext,
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+	slog.Info("Starting server", "port", port)
+
+	select {
+	case err := <-served:
+		fatal("Failed to start server", "err", err)
+	case <-running.Done():
+	}
+	// A second signal kills the process without waiting.
+	stopSignals()
+	grace := envDuration("MINIO_SHUTDOWN_GRACE", 30*time.Second)
+	slog.Info("Shutting down; waiting for in-flight requests", "grace", grace)
+	if err := handler.lifecycle.shutdown(srv, grace); err != nil {
+		slog.Warn("Grace period over; cancelled the requests still running", "err", err)
 	}
+	slog.Info("Server stopped")
+}
+
+// routes registers every endpoint along with the methods it accepts (see
+// methods.go), so handlers don't check r.Method themselves.
+func (h *MinioHandler) routes() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler http.HandlerFunc, methods ...string) {
+		mux.Handle(pattern, instrument(pattern, allowMethods(handler, methods...)))
+	}
+	// object registers an endpoint naming an existing object after
+	// pattern; objects in the trash are only reached through /trash.
+	object := func(pattern string, handler http.HandlerFunc, methods ...string) {
+		handle(pattern, h.outsideTrash(pattern, handler), methods...)
+	}
+	handle("/upload", h.writes(h.uploadFileHandler), http.MethodPost)
+	handle("/upload/", h.writes(h.uploadBodyHandler), http.MethodPut)
+	handle("/upload-archive", h.writes(h.uploadArchiveHandler), http.MethodPost)
+	object("/modify/", h.writes(h.modifyFileHandler), http.MethodPut)
+	handle("/raw/", h.writes(h.rawUploadHandler), http.MethodPut)
+	object("/content/", h.writes(h.patchContentHandler), http.MethodPatch)
+	object("/append/", h.writes(h.appendHandler), http.MethodPost)
+	handle("/multipart/", h.writes(h.multipartUploadHandler), http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	handle("/multipart-uploads", h.incompleteUploadsHandler, http.MethodGet)
+	handle("/tus/", h.tusHandler, http.MethodOptions, http.MethodPost, http.MethodHead, http.MethodPatch, http.MethodDelete)
+	object("/headers/", h.objectHeadersHandler, http.MethodGet, http.MethodPut)
+	object("/stat/", h.statHandler, http.MethodGet)
+	object("/meta/", h.objectMetaHandler, http.MethodGet, http.MethodPut)
+	object("/tags/", h.objectTagsHandler, http.MethodGet, http.MethodPut)
+	object("/delete/", h.writes(h.deleteFileHandler), http.MethodDelete)
+	object("/versions/", h.versionsHandler, http.MethodGet, http.MethodPost)
+	handle("/trash/list", h.trashListHandler, http.MethodGet)
+	handle("/trash/restore/", h.writes(h.trashRestoreHandler), http.MethodPost)
+	handle("/list", h.listFilesHandler, http.MethodGet)
+	handle("/browse", h.browseHandler, http.MethodGet)
+	handle("/watch", h.watchBucketHandler, http.MethodGet)
+	handle("/recent-events", h.recentEventsHandler, http.MethodGet)
+	handle("/copy-jobs", h.writes(h.copyJobHandler), http.MethodPost)
+	handle("/jobs/", h.jobsHandler, http.MethodGet)
+	object("/datauri/", h.dataURIHandler, http.MethodGet)
+	handle("/prefetch", h.prefetchHandler, http.MethodPost)
+	handle("/sidecars", h.sidecarsHandler, http.MethodGet)
+	handle("/concat", h.concatHandler, http.MethodPost)
+	handle("/verify", h.verifyHandler, http.MethodPost)
+	handle("/organize", h.writes(h.organizeHandler), http.MethodPost)
+	handle("/copy-by-tag", h.writes(h.copyByTagHandler), http.MethodPost)
+	handle("/swap", h.writes(h.swapHandler), http.MethodPost)
+	handle("/copy", h.writes(h.copyHandler), http.MethodPost)
+	handle("/move", h.writes(h.moveHandler), http.MethodPost)
+	handle("/rename", h.writes(h.renameHandler), http.MethodPost)
+	handle("/delete-batch", h.writes(h.deleteBatchHandler), http.MethodPost)
+	handle("/manifest", h.manifestHandler, http.MethodPost)
+	handle("/download-tar", h.tarHandler, http.MethodGet)
+	handle("/download-zip", h.zipHandler, http.MethodGet)
+	handle("/export.csv", h.exportHandler, http.MethodGet)
+	handle("/usage-tree", h.usageTreeHandler, http.MethodGet)
+	object("/download-archive/", h.writes(h.downloadArchiveHandler), http.MethodGet)
+	object("/fetch/", h.fetchHandler, http.MethodGet)
+	object("/transform/", h.transformHandler, http.MethodGet)
+	object("/convert/", h.convertHandler, http.MethodGet)
+	handle("/healthz", h.healthzHandler, http.MethodGet)
+	handle("/admin/read-only", h.readOnlyHandler, http.MethodGet, http.MethodPut)
+	handle("/bucket/encryption", h.bucketEncryptionHandler, http.MethodGet, http.MethodPut)
+	handle("/bucket/versioning", h.bucketVersioningHandler, http.MethodGet, http.MethodPut)
+	handle("/bucket/replication", h.bucketReplicationHandler, http.MethodGet, http.MethodPut)
+	object("/object-replication-status/", h.objectReplicationStatusHandler, http.MethodGet)
+	handle("/admin/empty-objects", h.emptyObjectsHandler, http.MethodGet)
+	handle("/admin/cleanup-empty", h.writes(h.cleanupEmptyHandler), http.MethodPost)
+	handle("/admin/generate-thumbnails", h.writes(h.generateThumbnailsHandler), http.MethodPost)
+	handle("/admin/scrub", h.scrubHandler, http.MethodPost)
+	handle("/admin/recompute-usage", h.recomputeUsageHandler, http.MethodPost)
+	handle("/admin/reshard", h.reshardHandler, http.MethodPost)
+	handle("/admin/fix-content-types", h.fixContentTypesHandler, http.MethodPost)
+	handle("/admin/selftest", h.writes(h.selftestHandler), http.MethodGet)
+	handle("/admin/keys", h.apiKeysHandler, http.MethodGet, http.MethodPost)
+	handle("/admin/keys/", h.apiKeyHandler, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	handle("/debug/vars", expvar.Handler().ServeHTTP, http.MethodGet)
+	handle("/metrics", metricsHandler, http.MethodGet)
+
+	// Presigned links are the recommended way to download; /download/
+	// streams through the service for clients that can't reach MinIO.
+	object("/download/", h.downloadFileHandler, http.MethodGet, http.MethodHead)
+	object("/get-download-link/", h.getPresignedURLHandler, http.MethodGet, http.MethodHead)
+	handle("/get-upload-policy/", h.writes(h.uploadPolicyHandler), http.MethodGet)
+	handle("/get-upload-link/", h.writes(h.uploadLinkHandler), http.MethodGet)
+	handle("/share", h.shareHandler, http.MethodPost)
+	handle("/s/", h.shareRedirectHandler, http.MethodGet)
+	return h.auth.wrap(h.tenants.wrap(h, h.buckets.wrap(h, h.breaker.gate(mux))))
 }
 
 // =================================================================================
 // NEW HANDLER: getPresignedURLHandler
 // This handler generates a temporary, secure URL for a private object.
+// A HEAD request returns the headers of the object the link would download
+// (size, type, ETag, Last-Modified) instead of minting a link. With
+// ?version_id= the link downloads that version of the object. For an
+// object stored with a customer-provided key (SSE-C), the request sends the
+// key headers, which are signed into the link and must be sent with it.
 // =================================================================================
 func (h *MinioHandler) getPresignedURLHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 
-	objectName := strings.TrimPrefix(r.URL.Path, "/get-download-link/")
+	objectName := objectNameFromPath(r, "/get-download-link/")
 	if objectName == "" {
 		http.Error(w, "Object name is required in the URL path (e.g., /get-download-link/my-image.jpg)", http.StatusBadRequest)
 		return
 	}
+	if r.Method == http.MethodHead {
+		h.serveObjectHead(w, r, objectName)
+		return
+	}
+
+	sseKey, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Links to versions that don't exist are refused up front, since the
+	// link itself would only fail when it is followed.
+	versionID := r.URL.Query().Get("version_id")
+	info, statErr := h.store.StatObject(r.Context(), h.bucketName, objectName, minio.StatObjectOptions{VersionID: versionID, ServerSideEncryption: sseKey})
+	if statErr != nil && wrongCustomerKey(statErr, sseKey) {
+		http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+		return
+	}
+	if versionID != "" && statErr != nil {
+		switch minio.ToErrorResponse(statErr).Code {
+		case "NoSuchKey", "NoSuchVersion", "InvalidArgument":
+			http.Error(w, "Version not found", http.StatusNotFound)
+		default:
+			logger(r.Context()).Error("Error checking version", "object", objectName, "version", versionID, "err", statErr)
+			h.storeFailed(w, "Failed to generate download link", statErr)
+		}
+		return
+	}
+
+	// Links to expired objects are refused even before the sweeper runs.
+	if statErr == nil && expired(info, time.Now()) {
+		http.Error(w, "File not found or access denied", http.StatusNotFound)
+		return
+	}
+	// So are links to missing objects, unless MINIO_PRESIGN_CHECK_EXISTS
+	// is false.
+	if statErr != nil && !h.presignMissing {
+		if minio.ToErrorResponse(statErr).Code == "NoSuchKey" {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		logger(r.Context()).Error("Error checking object before signing a link", "object", objectName, "err", statErr)
+		h.storeFailed(w, "Failed to generate download link", statErr)
+		return
+	}
 
 	// 1. Set the expiration time for the URL.
 	// Here, we set it to 5 minutes.
 	expiry := 5 * time.Minute
 
-	// 2. Generate the presigned URL.
-	presignedURL, err := h.minioClient.PresignedGetObject(context.Background(), h.bucketName, objectName, expiry, nil)
+	// 2. Generate the presigned URL, forcing a plain download for content a
+	// browser could execute (see untrusted.go).
+	reqParams := h.downloadOnlyParams(r.Context(), objectName)
+	if reqParams == nil && statErr == nil {
+		// ?infer_type=true fixes links to objects stored with a generic type.
+		typed := info
+		h.applyInferredType(r, objectName, &typed)
+		if typed.ContentType != info.ContentType {
+			reqParams = url.Values{"response-content-type": {typed.ContentType}}
+		}
+	}
+	if versionID != "" {
+		// An older version may have an untrusted type the latest doesn't.
+		if reqParams == nil && h.untrusted.untrustedType(info.ContentType) {
+			reqParams = attachmentParams(objectName)
+		}
+		if reqParams == nil {
+			reqParams = url.Values{}
+		}
+		reqParams.Set("versionId", versionID)
+	}
+	if sseKey != nil {
+		if r.URL.Query().Get("scoped") == "true" {
+			http.Error(w, "Scoped links can't be signed for objects with a customer-provided key", http.StatusBadRequest)
+			return
+		}
+		h.serveSSECLink(w, r, objectName, expiry, reqParams, sseKey)
+		return
+	}
+	if r.URL.Query().Get("scoped") == "true" {
+		h.serveScopedLink(w, r, objectName, expiry, reqParams)
+		return
+	}
+	key := presignKey(http.MethodGet, objectName, expiry, reqParams)
+	presignedURL, err := h.presigned.get(key, expiry, func() (*url.URL, error) {
+		return h.links().PresignedGetObject(context.Background(), h.bucketName, objectName, expiry, reqParams)
+	})
 	if err != nil {
-		log.Printf("Error generating presigned URL for '%s': %v", objectName, err)
+		logger(r.Context()).Error("Error generating presigned URL", "object", objectName, "err", err)
 		// This error often means the object doesn't exist, so 404 is appropriate.
 		http.Error(w, "File not found or access denied", http.StatusNotFound)
 		return
 	}
 
-	// 3. Create a JSON response containing the URL.
-	response := map[string]string{
-		"url": presignedURL.String(),
+	// 3. Create a JSON response containing the URL and, with
+	// ?with_info=true, the size and ETag of what it links to.
+	response := map[string]any{
+		"url": presignedURL,
+	}
+	if r.URL.Query().Get("with_info") == "true" && statErr == nil {
+		response["size"] = info.Size
+		response["etag"] = info.ETag
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, r, http.StatusOK, response)
 }
 
 // (The rest of your handlers: uploadFileHandler, modifyFileHandler, deleteFileHandler, etc. remain exactly the same)
 
-func (h *MinioHandler) processAndUploadFile(w http.ResponseWriter, r *http.Request, objectName string) {
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "Could not parse multipart form", http.StatusBadRequest)
+// uploadOptions returns the PutObject options for an upload of objectName,
+// and whether the object lands under a public-read prefix.
+func (h *MinioHandler) uploadOptions(objectName, contentType string) (minio.PutObjectOptions, bool) {
+	opts := minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    h.partSize,
+		NumThreads:  h.uploadThreads,
+	}
+	opts.ServerSideEncryption = h.sse.fallback()
+	public := h.visibility.forKey(objectName) == visibilityPublic
+	if public {
+		// Honored by S3; MinIO ignores object ACLs and relies on the
+		// prefix statements synced into the bucket policy at startup.
+		opts.UserMetadata = map[string]string{"x-amz-acl": visibilityPublic}
+	}
+	return opts, public
+}
+
+// applyUploadParams applies the optional upload query parameters to opts:
+// ?checksum=, ?ttl=, ?cache_control= and ?content_language=, along with
+// user metadata, the encryption headers (see sse.go) and the request
+// headers listed in MINIO_PROPAGATE_HEADERS.
+func (h *MinioHandler) applyUploadParams(r *http.Request, opts *minio.PutObjectOptions) error {
+	checksum, err := h.uploadChecksum(r)
+	if err != nil {
+		return err
+	}
+	opts.Checksum = checksum
+	if opts.ServerSideEncryption, err = h.sse.forUpload(r); err != nil {
+		return err
+	}
+	if err := applyTTL(r, opts); err != nil {
+		return err
+	}
+	q := r.URL.Query()
+	opts.CacheControl = q.Get("cache_control")
+	opts.ContentLanguage = q.Get("content_language")
+	if err := applyUploadMetadata(r, opts); err != nil {
+		return err
+	}
+	return h.applyPropagatedHeaders(r, opts)
+}
+
+// processAndUploadFile stores the "file" form field as objectName, or under
+// its own file name when objectName is empty. A non-empty matchETag makes
+// the upload conditional on the object still having that ETag. A form with
+// several "file" fields or a "prefix" field, which only /upload accepts, is
+// handed to uploadFormFiles.
+func (h *MinioHandler) processAndUploadFile(w http.ResponseWriter, r *http.Request, objectName, matchETag string) {
+	if !h.parseUploadForm(w, r) {
+		return
+	}
+	files := r.MultipartForm.File["file"]
+	prefix := r.PostFormValue("prefix")
+	switch {
+	case objectName == "" && len(files) > 0 && (len(files) > 1 || prefix != ""):
+		h.uploadFormFiles(w, r, files, prefix)
+		return
+	case len(files) > 1:
+		http.Error(w, "Only one file can replace an object", http.StatusBadRequest)
 		return
 	}
 	file, header, err := r.FormFile("file")
@@ -147,11 +875,37 @@ func (h *MinioHandler) processAndUploadFile(w http.ResponseWriter, r *http.Reque
 	if objectName == "" {
 		objectName = header.Filename
 	}
-	contentType := header.Header.Get("Content-Type")
-	_, err = h.minioClient.PutObject(context.Background(), h.bucketName, objectName, file, header.Size, minio.PutObjectOptions{ContentType: contentType})
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	opts, public := h.uploadOptions(objectName, header.Header.Get("Content-Type"))
+	if err := h.applyUploadParams(r, &opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if matchETag != "" {
+		opts.SetMatchETag(matchETag)
+	}
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	_, err = h.store.PutObject(ctx, h.bucketName, objectName, file, header.Size, opts)
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		http.Error(w, "Object has changed (ETag does not match If-Match)", http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
-		log.Printf("Error uploading file to MinIO: %s", err)
-		http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+		logger(r.Context()).Error("Error uploading file to MinIO", "err", err)
+		h.storeFailed(w, "Failed to upload file", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	h.quotaAfterUpload(w, objectName, header.Size)
+	if public {
+		publicURL := publicObjectURL(h.links().EndpointURL(), h.bucketName, objectName)
+		w.Header().Set("X-Public-URL", publicURL)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "Successfully processed '%s' in bucket '%s'.\nPublic URL: %s\n", objectName, h.bucketName, publicURL)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -159,64 +913,59 @@ func (h *MinioHandler) processAndUploadFile(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *MinioHandler) uploadFileHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	h.processAndUploadFile(w, r, "")
+	h.processAndUploadFile(w, r, "", "")
 }
 
 func (h *MinioHandler) modifyFileHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	objectName := strings.TrimPrefix(r.URL.Path, "/modify/")
+	objectName := objectNameFromPath(r, "/modify/")
 	if objectName == "" {
 		http.Error(w, "Object name is required in the URL path (e.g., /modify/myfile.png)", http.StatusBadRequest)
 		return
 	}
-	h.processAndUploadFile(w, r, objectName)
+	matchETag, ok := h.guardOverwrite(w, r, objectName)
+	if !ok {
+		return
+	}
+	h.processAndUploadFile(w, r, objectName, matchETag)
 }
 
+// deleteFileHandler removes /delete/{objectName}, to the trash when
+// MINIO_TRASH_RETENTION is set and ?permanent=true isn't.
 func (h *MinioHandler) deleteFileHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	objectName := strings.TrimPrefix(r.URL.Path, "/delete/")
+	objectName := objectNameFromPath(r, "/delete/")
 	if objectName == "" {
 		http.Error(w, "Object name is required", http.StatusBadRequest)
 		return
 	}
-	err := h.minioClient.RemoveObject(context.Background(), h.bucketName, objectName, minio.RemoveObjectOptions{})
+	if isTusState(objectName) {
+		http.Error(w, tusStateReserved, http.StatusBadRequest)
+		return
+	}
+	key, err := customerKey(r)
 	if err != nil {
-		log.Printf("Error removing object: %v", err)
-		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	fmt.Fprintf(w, "Successfully deleted '%s' from bucket '%s'.\n", objectName, h.bucketName)
-}
-
-func (h *MinioHandler) listFilesHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	err = h.removeObject(context.Background(), objectName, r.URL.Query().Get("permanent") == "true", key)
+	if wrongCustomerKey(err, key) {
+		http.Error(w, sseCKeyRequired, http.StatusBadRequest)
 		return
 	}
-	var fileList []string
-	objectCh := h.minioClient.ListObjects(context.Background(), h.bucketName, minio.ListObjectsOptions{})
-	for object := range objectCh {
-		if object.Err != nil {
-			log.Printf("Error listing object: %v", object.Err)
-			http.Error(w, "Failed to list files", http.StatusInternalServerError)
-			return
-		}
-		fileList = append(fileList, object.Key)
+	if err != nil {
+		logger(r.Context()).Error("Error removing object", "err", err)
+		h.storeFailed(w, "Failed to delete file", err)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(fileList)
+	h.cache.invalidate(objectName)
+	fmt.Fprintf(w, "Successfully deleted '%s' from bucket '%s'.\n", objectName, h.bucketName)
 }
 
+// watchBucketHandler streams bucket events as Server-Sent Events. Events pass
+// through a bounded buffer (MINIO_WATCH_BUFFER); when the client can't keep
+// up, excess events are dropped and reported with an "overflow" event, and a
+// client that stays behind or blocks a write for MINIO_WATCH_STALL_TIMEOUT is
+// disconnected. With MINIO_EXPIRY_NOTIFY_LEAD set, objects about to expire
+// are announced with "expiring" events.
 func (h *MinioHandler) watchBucketHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -226,31 +975,73 @@ func (h *MinioHandler) watchBucketHandler(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
 		return
 	}
-	notificationChan := h.minioClient.ListenBucketNotification(r.Context(), h.bucketName, "", "", []string{
+	sseSubscribers.add(1, "watch")
+	defer sseSubscribers.add(-1, "watch")
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	notificationChan := h.store.ListenBucketNotification(ctx, h.bucketName, "", "", []string{
 		"s3:ObjectCreated:*",
 		"s3:ObjectRemoved:*",
 	})
-	log.Println("SSE connection established. Watching for bucket events...")
-	fmt.Fprintf(w, ": connection established\n\n")
-	flusher.Flush()
+	relay := relayNotifications(notificationChan, h.watchBuffer, h.watchStallTimeout, cancel)
+	expiring, unsubscribe := h.expiryNotices.subscribe()
+	defer unsubscribe()
+	rc := http.NewResponseController(w)
+	send := func(format string, args ...any) bool {
+		// Not every ResponseWriter supports deadlines; streaming still works without.
+		rc.SetWriteDeadline(time.Now().Add(h.watchStallTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	logger(r.Context()).Info("SSE connection established; watching for bucket events")
+	if !send(": connection established\n\n") {
+		return
+	}
 	for {
 		select {
-		case notification := <-notificationChan:
+		case notification, open := <-relay.events:
+			if !open {
+				logger(r.Context()).Info("SSE watch closed")
+				return
+			}
+			if dropped := relay.takeDropped(); dropped > 0 {
+				if !send("event: overflow\ndata: {\"dropped\": %d}\n\n", dropped) {
+					return
+				}
+			}
 			if notification.Err != nil {
-				log.Printf("Error in bucket notification: %v", notification.Err)
-				fmt.Fprintf(w, "event: error\ndata: %v\n\n", notification.Err)
-				flusher.Flush()
+				logger(r.Context()).Error("Error in bucket notification", "err", notification.Err)
+				send("event: error\ndata: %v\n\n", notification.Err)
 				return
 			}
 			jsonData, err := json.Marshal(notification.Records)
 			if err != nil {
-				log.Printf("Error marshaling notification: %v", err)
+				logger(r.Context()).Error("Error marshaling notification", "err", err)
+				continue
+			}
+			if !send("data: %s\n\n", jsonData) {
+				logger(r.Context()).Info("SSE client stopped reading; closing watch")
+				return
+			}
+		case notice := <-expiring:
+			jsonData, err := json.Marshal(notice)
+			if err != nil {
+				logger(r.Context()).Error("Error marshaling expiry notice", "err", err)
 				continue
 			}
-			fmt.Fprintf(w, "data: %s\n\n", jsonData)
-			flusher.Flush()
+			if !send("event: expiring\ndata: %s\n\n", jsonData) {
+				logger(r.Context()).Info("SSE client stopped reading; closing watch")
+				return
+			}
 		case <-r.Context().Done():
-			log.Println("SSE client disconnected.")
+			logger(r.Context()).Info("SSE client disconnected")
+			return
+		case <-h.lifecycle.drained():
+			send("event: shutdown\ndata: {}\n\n")
 			return
 		}
 	}
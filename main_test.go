@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"golang.org/x/sync/singleflight"
+)
+
+const testBucket = "testbucket"
+
+// newTestHandler returns a handler backed by a fresh fakeStore with the
+// test bucket created, configured with the same defaults as main.
+func newTestHandler(t *testing.T) (*MinioHandler, *fakeStore) {
+	t.Helper()
+	store := newFakeStore(testBucket)
+	h := &MinioHandler{
+		store:               store,
+		bucketName:          testBucket,
+		jobs:                newJobRegistry(),
+		rawMaxBytes:         1 << 20,
+		dataURIs:            newDataURICache(time.Minute),
+		dataURIMaxBytes:     256 << 10,
+		prefetchConcurrency: 4,
+		prefetchMaxBytes:    1 << 30,
+		watchBuffer:         64,
+		watchStallTimeout:   time.Second,
+		uploadPolicy:        uploadPolicyLimits{maxBytes: 10 << 20, expiry: time.Minute},
+		thumbnails:          thumbnailLimits{size: 16, maxBytes: 1 << 20, maxPixels: 1 << 20},
+		archive:             archiveSettings{prefix: "archive/"},
+		variantBuilds:       new(singleflight.Group),
+		shares:              &shareLinks{defaultExpiry: time.Hour, maxExpiry: 24 * time.Hour, links: map[string]shareLink{}},
+		readOnly:            new(atomic.Bool),
+	}
+	return h, store
+}
+
+// serve runs req through the handler's routes and returns the recorded response.
+func serve(h *MinioHandler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+// newUploadRequest builds a multipart/form-data request with a single
+// "file" part holding content.
+func newUploadRequest(t *testing.T, method, target, filename, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if filename != "" {
+		part, err := mw.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	mw.Close()
+	req := httptest.NewRequest(method, target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// decodeJSON unmarshals the recorded body into v, failing the test on error.
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+}
+
+func TestUploadHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		filename   string
+		wantStatus int
+		wantStored string
+	}{
+		{"stores file under its name", http.MethodPost, "hello.txt", http.StatusCreated, "hello.txt"},
+		{"rejects GET", http.MethodGet, "hello.txt", http.StatusMethodNotAllowed, ""},
+		{"rejects missing file part", http.MethodPost, "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			rec := serve(h, newUploadRequest(t, tt.method, "/upload", tt.filename, "hi there"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStored == "" {
+				return
+			}
+			data, ok := store.object(testBucket, tt.wantStored)
+			if !ok || string(data) != "hi there" {
+				t.Errorf("stored %q (exists=%v), want %q", data, ok, "hi there")
+			}
+		})
+	}
+}
+
+func TestUploadHandlerRejectsMalformedBody(t *testing.T) {
+	h, _ := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("not multipart"))
+	req.Header.Set("Content-Type", "text/plain")
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUploadHandlerKeyRules(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.uploadRules = keyRules{defaults: keyFilter{Allow: []string{"*.png", "*.txt"}, Deny: []string{"*.exe"}}}
+	tests := []struct {
+		filename   string
+		wantStatus int
+	}{
+		{"ok.PNG", http.StatusCreated},
+		{"virus.exe", http.StatusForbidden},
+		{"script.sh", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload", tt.filename, "x"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if _, ok := store.object(testBucket, tt.filename); ok != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("object stored = %v, want %v", ok, !ok)
+			}
+		})
+	}
+}
+
+func TestUploadKeyShapeLimits(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.uploadRules = keyRules{maxLength: 16, maxSegments: 3}
+	tests := []struct {
+		key        string
+		wantStatus int
+	}{
+		{strings.Repeat("k", 16), http.StatusCreated},
+		{strings.Repeat("k", 17), http.StatusBadRequest},
+		{"a/b/c.txt", http.StatusCreated},
+		{"a/b/c/d.txt", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			rec := serve(h, newUploadRequest(t, http.MethodPut, "/modify/"+tt.key, "f.txt", "x"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if _, ok := store.object(testBucket, tt.key); ok != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("object stored = %v", ok)
+			}
+		})
+	}
+}
+
+func TestModifyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"overwrites named object", http.MethodPut, "/modify/doc.txt", http.StatusCreated},
+		{"rejects POST", http.MethodPost, "/modify/doc.txt", http.StatusMethodNotAllowed},
+		{"requires object name", http.MethodPut, "/modify/", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			store.put(testBucket, "doc.txt", []byte("old"), "text/plain")
+			rec := serve(h, newUploadRequest(t, tt.method, tt.target, "whatever.txt", "new"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			want := "old"
+			if tt.wantStatus == http.StatusCreated {
+				want = "new"
+			}
+			if data, _ := store.object(testBucket, "doc.txt"); string(data) != want {
+				t.Errorf("doc.txt = %q, want %q", data, want)
+			}
+			if _, ok := store.object(testBucket, "whatever.txt"); ok {
+				t.Error("modify stored the upload under its file name")
+			}
+		})
+	}
+}
+
+func TestDeleteHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantGone   bool
+	}{
+		{"deletes object", http.MethodDelete, "/delete/doc.txt", http.StatusOK, true},
+		{"rejects GET", http.MethodGet, "/delete/doc.txt", http.StatusMethodNotAllowed, false},
+		{"requires object name", http.MethodDelete, "/delete/", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			store.put(testBucket, "doc.txt", []byte("x"), "text/plain")
+			rec := serve(h, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if _, ok := store.object(testBucket, "doc.txt"); ok == tt.wantGone {
+				t.Errorf("doc.txt exists = %v, want %v", ok, !tt.wantGone)
+			}
+		})
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "b.txt", []byte("b"), "text/plain")
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var got listResponse
+	decodeJSON(t, rec, &got)
+	if strings.Join(got.Files, ",") != "a.txt,b.txt" || got.Truncated {
+		t.Errorf("list = %+v, want [a.txt b.txt], not truncated", got)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/list", nil)); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestListHandlerStoreError(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.bucketName = "missing-bucket"
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestPresignHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "pic.jpg", []byte("jpeg"), "image/jpeg")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"returns link", http.MethodGet, "/get-download-link/pic.jpg", http.StatusOK},
+		{"rejects POST", http.MethodPost, "/get-download-link/pic.jpg", http.StatusMethodNotAllowed},
+		{"requires object name", http.MethodGet, "/get-download-link/", http.StatusBadRequest},
+		{"HEAD describes object", http.MethodHead, "/get-download-link/pic.jpg", http.StatusOK},
+		{"HEAD on missing object", http.MethodHead, "/get-download-link/nope.jpg", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/pic.jpg", nil))
+	var resp map[string]string
+	decodeJSON(t, rec, &resp)
+	u, err := url.Parse(resp["url"])
+	if err != nil || u.Path != "/"+testBucket+"/pic.jpg" || u.Query().Get("X-Amz-Expires") != "300" {
+		t.Errorf("url = %q, want a 5 minute link to pic.jpg", resp["url"])
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodHead, "/get-download-link/pic.jpg", nil))
+	if rec.Header().Get("Content-Length") != "4" || rec.Header().Get("Content-Type") != "image/jpeg" || rec.Body.Len() != 0 {
+		t.Errorf("HEAD headers = %v, body %q", rec.Header(), rec.Body)
+	}
+}
+
+// versionedStore keeps a single old version, "v1", of every object.
+type versionedStore struct {
+	*fakeStore
+}
+
+func (s versionedStore) StatObject(ctx context.Context, bucketName, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	if opts.VersionID != "" && opts.VersionID != "v1" {
+		return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchVersion", StatusCode: http.StatusNotFound}
+	}
+	// "v1" reads as the current version.
+	opts.VersionID = ""
+	return s.fakeStore.StatObject(ctx, bucketName, objectName, opts)
+}
+
+func TestPresignHandlerVersion(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.store = versionedStore{store}
+	store.put(testBucket, "pic.jpg", []byte("jpeg"), "image/jpeg")
+
+	var resp map[string]string
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/pic.jpg?version_id=v1", nil)), &resp)
+	u, err := url.Parse(resp["url"])
+	if err != nil || u.Query().Get("versionId") != "v1" {
+		t.Errorf("url = %q, want a link to version v1", resp["url"])
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/pic.jpg?version_id=v2", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown version: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestPresignHandlerChecksExistence(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "pic.jpg", []byte("jpeg"), "image/jpeg")
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/missing.jpg", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object: status = %d, want 404", rec.Code)
+	}
+
+	var resp struct {
+		URL  string `json:"url"`
+		Size int64  `json:"size"`
+		ETag string `json:"etag"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/pic.jpg?with_info=true", nil)), &resp)
+	info, _ := store.StatObject(t.Context(), testBucket, "pic.jpg", minio.StatObjectOptions{})
+	if resp.URL == "" || resp.Size != 4 || resp.ETag != info.ETag {
+		t.Errorf("response = %+v, want the link with size 4 and ETag %s", resp, info.ETag)
+	}
+
+	h.presignMissing = true
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/missing.jpg", nil)); rec.Code != http.StatusOK {
+		t.Errorf("missing object with the check off: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestWatchHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	srv := httptest.NewServer(h.routes())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	var event notification.Event
+	event.EventName = "s3:ObjectCreated:Put"
+	event.S3.Object.Key = "new.txt"
+	store.events <- notification.Info{Records: []notification.Event{event}}
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var records []notification.Event
+		if err := json.Unmarshal([]byte(data), &records); err != nil {
+			t.Fatalf("decoding event %q: %v", data, err)
+		}
+		if len(records) != 1 || records[0].S3.Object.Key != "new.txt" {
+			t.Fatalf("records = %+v, want one event for new.txt", records)
+		}
+		return
+	}
+	t.Fatal("stream ended without an event")
+}
+
+func TestDataURIHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.dataURIMaxBytes = 8
+	store.put(testBucket, "dot.gif", []byte("GIF89a"), "image/gif")
+	store.put(testBucket, "big.bin", bytes.Repeat([]byte("x"), 9), "application/octet-stream")
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/datauri/dot.gif", http.StatusOK, "data:image/gif;base64,R0lGODlh"},
+		{"/datauri/big.bin", http.StatusRequestEntityTooLarge, ""},
+		{"/datauri/missing.gif", http.StatusNotFound, ""},
+		{"/datauri/", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/datauri/dot.gif?format=json", nil))
+	var resp map[string]string
+	decodeJSON(t, rec, &resp)
+	if resp["name"] != "dot.gif" || resp["data_uri"] != "data:image/gif;base64,R0lGODlh" {
+		t.Errorf("json = %v", resp)
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("hello"), "text/plain")
+	etag := "5d41402abc4b2a76b9719d911017c592" // md5("hello")
+
+	body := `[
+		{"object": "a.txt", "expected_size": 5, "expected_etag": "` + etag + `"},
+		{"object": "a.txt", "expected_size": 6},
+		{"object": "a.txt", "expected_etag": "0000"},
+		{"object": "gone.txt"}
+	]`
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Checked    int            `json:"checked"`
+		Mismatches int            `json:"mismatches"`
+		Results    []verifyResult `json:"results"`
+	}
+	decodeJSON(t, rec, &resp)
+	want := []string{verifyOK, verifySizeMismatch, verifyETagMismatch, verifyMissing}
+	if resp.Checked != 4 || resp.Mismatches != 3 || len(resp.Results) != 4 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	for i, res := range resp.Results {
+		if res.Status != want[i] {
+			t.Errorf("result %d status = %q, want %q", i, res.Status, want[i])
+		}
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader("{"))); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body status = %d, want 400", rec.Code)
+	}
+}
+
+func TestOrganizeHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "inbox/a.txt", []byte("a"), "text/plain")
+
+	body := `{"objects": ["inbox/a.txt", "missing.txt"], "dest_prefix": "archive"}`
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/organize", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Failed  int              `json:"failed"`
+		Results []organizeResult `json:"results"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Failed != 1 || resp.Results[0].Status != "moved" || resp.Results[1].Status != "failed" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if _, ok := store.object(testBucket, "archive/a.txt"); !ok {
+		t.Error("archive/a.txt was not created")
+	}
+	if _, ok := store.object(testBucket, "inbox/a.txt"); ok {
+		t.Error("inbox/a.txt was not removed")
+	}
+}
+
+func TestOrganizeCollisions(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "x/a.txt", []byte("x"), "text/plain")
+	store.put(testBucket, "y/a.txt", []byte("y"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("b"), "text/plain")
+	store.put(testBucket, "in/b.txt", []byte("in"), "text/plain")
+	store.put(testBucket, "c.txt", []byte("c"), "text/plain")
+
+	body := `{"objects": ["x/a.txt", "y/a.txt", "b.txt", "in/b.txt", "c.txt"], "dest_prefix": "in/"}`
+	var resp struct {
+		Failed  int              `json:"failed"`
+		Results []organizeResult `json:"results"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/organize", strings.NewReader(body))), &resp)
+	want := []string{"failed", "failed", "failed", "unchanged", "moved"}
+	for i, res := range resp.Results {
+		if res.Status != want[i] {
+			t.Errorf("%s: status = %q (%s), want %q", res.Object, res.Status, res.Error, want[i])
+		}
+	}
+	if resp.Failed != 3 {
+		t.Errorf("failed = %d, want 3", resp.Failed)
+	}
+	if data, _ := store.object(testBucket, "in/b.txt"); string(data) != "in" {
+		t.Errorf("in/b.txt = %q, want it untouched", data)
+	}
+	for _, key := range []string{"x/a.txt", "y/a.txt", "b.txt"} {
+		if _, ok := store.object(testBucket, key); !ok {
+			t.Errorf("%s was removed", key)
+		}
+	}
+}
+
+func TestManifestHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "d/1.csv", []byte("12"), "text/csv")
+	store.put(testBucket, "d/sub/2.csv", []byte("345"), "text/csv")
+	store.put(testBucket, "other.csv", []byte("x"), "text/csv")
+
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/manifest?prefix=d", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var resp map[string]any
+	decodeJSON(t, rec, &resp)
+	if resp["manifest"] != "d/_manifest.json" || resp["count"] != float64(2) {
+		t.Fatalf("resp = %v", resp)
+	}
+
+	// Regenerating must not list the previous manifest.
+	rec = serve(h, httptest.NewRequest(http.MethodPost, "/manifest?prefix=d/&inline=true", nil))
+	var m manifest
+	decodeJSON(t, rec, &m)
+	if m.Count != 2 || m.TotalBytes != 5 || m.Objects[0].Key != "d/1.csv" {
+		t.Errorf("manifest = %+v", m)
+	}
+}
+
+func TestCopyJob(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "src.bin", []byte("payload"), "application/octet-stream")
+
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/copy-jobs", strings.NewReader(`{"source": "src.bin", "destination": "dst.bin"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+
+	var status jobStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec = serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil))
+		decodeJSON(t, rec, &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+	if data, _ := store.object(testBucket, "dst.bin"); string(data) != "payload" {
+		t.Errorf("dst.bin = %q", data)
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodPost, "/copy-jobs", strings.NewReader(`{"source": "nope", "destination": "x"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing source status = %d, want 404", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCopyJobSourceConditions(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "src.bin", []byte("payload"), "application/octet-stream")
+	o, _ := store.lookup(testBucket, "src.bin")
+	o.info.LastModified = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		conditions string
+		wantStatus int
+	}{
+		{"matching etag", `"if_match": "` + o.info.ETag + `"`, http.StatusAccepted},
+		{"stale etag", `"if_match": "0123"`, http.StatusPreconditionFailed},
+		{"unmodified", `"if_unmodified_since": "2024-05-01T12:00:00Z"`, http.StatusAccepted},
+		{"modified since", `"if_unmodified_since": "2024-05-01T11:59:59Z"`, http.StatusPreconditionFailed},
+		{"bad timestamp", `"if_unmodified_since": "yesterday"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"source": "src.bin", "destination": "dst.bin", ` + tt.conditions + `}`
+			rec := serve(h, httptest.NewRequest(http.MethodPost, "/copy-jobs", strings.NewReader(body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	// A source modified after the request fails the copy itself.
+	info, _ := store.StatObject(context.Background(), testBucket, "src.bin", minio.StatObjectOptions{})
+	j := h.jobs.start("copy", nil)
+	p := copyProgress{Source: "src.bin", Destination: "late.bin", TotalBytes: info.Size, TotalParts: 1}
+	err := h.runCopy(context.Background(), j, info, p, info.LastModified.Add(-time.Second), copyEncryption{})
+	if err == nil || !strings.Contains(err.Error(), "source changed") {
+		t.Errorf("runCopy err = %v, want a source-changed error", err)
+	}
+	if _, ok := store.object(testBucket, "late.bin"); ok {
+		t.Error("late.bin was copied")
+	}
+}
+
+func TestRunCopyMultipartReportsParts(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "src.bin", []byte("0123456789"), "application/octet-stream")
+	info, _ := store.StatObject(context.Background(), testBucket, "src.bin", minio.StatObjectOptions{})
+
+	p := copyProgress{Source: "src.bin", Destination: "dst.bin", TotalBytes: 10, TotalParts: 3}
+	j := h.jobs.start("copy", p)
+	if err := h.runCopy(context.Background(), j, info, p, time.Time{}, copyEncryption{}); err != nil {
+		t.Fatal(err)
+	}
+	got := j.snapshot().Progress.(copyProgress)
+	if got.CompletedParts != 3 || got.CopiedBytes != 10 {
+		t.Errorf("progress = %+v, want 3 parts and 10 bytes", got)
+	}
+	if data, _ := store.object(testBucket, "dst.bin"); string(data) != "0123456789" {
+		t.Errorf("dst.bin = %q", data)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// manifestName is the object name a manifest is stored under, relative to
+// the prefix it describes.
+const manifestName = "_manifest.json"
+
+type manifestEntry struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type manifest struct {
+	Prefix      string          `json:"prefix"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Count       int             `json:"count"`
+	TotalBytes  int64           `json:"total_bytes"`
+	Objects     []manifestEntry `json:"objects"`
+}
+
+// manifestHandler lists every object under ?prefix= and writes a JSON
+// manifest of them to <prefix>/_manifest.json, replacing any previous one.
+// With ?inline=true the manifest is returned instead of stored.
+func (h *MinioHandler) manifestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	manifestKey := prefix + manifestName
+	inline := r.URL.Query().Get("inline") == "true"
+	if !inline && h.rejectIfReadOnly(w) {
+		return
+	}
+
+	m := manifest{Prefix: prefix, GeneratedAt: time.Now().UTC(), Objects: []manifestEntry{}}
+	objectCh := h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for object := range objectCh {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing objects for manifest", "err", object.Err)
+			h.storeFailed(w, "Failed to list files", object.Err)
+			return
+		}
+		// Skip the manifest itself so regenerating doesn't list the old one,
+		// and upload state and trash entries.
+		if object.Key == manifestKey || h.hiddenKey(object.Key) {
+			continue
+		}
+		m.Objects = append(m.Objects, manifestEntry{
+			Key:          object.Key,
+			Size:         object.Size,
+			ETag:         strings.Trim(object.ETag, `"`),
+			LastModified: object.LastModified,
+		})
+		m.TotalBytes += object.Size
+	}
+	m.Count = len(m.Objects)
+
+	if inline {
+		writeJSON(w, r, http.StatusOK, m)
+		return
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		logger(r.Context()).Error("Error marshaling manifest", "err", err)
+		http.Error(w, "Failed to build manifest", http.StatusInternalServerError)
+		return
+	}
+	_, err = h.store.PutObject(r.Context(), h.bucketName, manifestKey, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json", ServerSideEncryption: h.sse.fallback()})
+	if err != nil {
+		logger(r.Context()).Error("Error storing manifest", "manifest", manifestKey, "err", err)
+		h.storeFailed(w, "Failed to store manifest", err)
+		return
+	}
+	h.cache.invalidate(manifestKey)
+	writeJSON(w, r, http.StatusCreated, map[string]any{
+		"manifest": manifestKey,
+		"count":    m.Count,
+	})
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// maxUserMetadata is S3's limit on an object's x-amz-meta-* metadata,
+// counted over its keys and values.
+const maxUserMetadata = 2048
+
+// serviceMeta are the metadata keys the service maintains itself. /meta
+// shows them but keeps them across a PUT, and clients can't set them.
+var serviceMeta = []string{expiresAtMeta, sourceETagMeta, sha256Meta}
+
+func isServiceMeta(key string) bool {
+	return slices.ContainsFunc(serviceMeta, func(name string) bool { return strings.EqualFold(key, name) })
+}
+
+// checkUserMetadata validates client-supplied metadata: keys of letters,
+// digits and '-', none of them a service key, printable ASCII values, and
+// at most maxUserMetadata bytes in all.
+func checkUserMetadata(meta map[string]string) error {
+	size := 0
+	for k, v := range meta {
+		if k == "" || strings.IndexFunc(k, func(c rune) bool {
+			return !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-')
+		}) >= 0 {
+			return fmt.Errorf("metadata key %q must consist of letters, digits and '-'", k)
+		}
+		if isServiceMeta(k) {
+			return fmt.Errorf("metadata key %q is reserved", k)
+		}
+		if strings.IndexFunc(v, func(c rune) bool { return c < ' ' || c > '~' }) >= 0 {
+			return fmt.Errorf("metadata value of %q must be printable ASCII", k)
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxUserMetadata {
+		return fmt.Errorf("metadata must not exceed %d bytes", maxUserMetadata)
+	}
+	return nil
+}
+
+// canonicalMetadata returns meta with its keys in the canonical form HTTP
+// headers take, as S3 stores them, rejecting keys that differ only in case
+// and so would overwrite each other.
+func canonicalMetadata(meta map[string]string) (map[string]string, error) {
+	canonical := make(map[string]string, len(meta))
+	for k, v := range meta {
+		name := http.CanonicalHeaderKey(k)
+		if _, dup := canonical[name]; dup {
+			return nil, fmt.Errorf("metadata key %q is given more than once, in different cases", name)
+		}
+		canonical[name] = v
+	}
+	return canonical, nil
+}
+
+// applyUploadMetadata adds the metadata an upload carries to opts: a
+// "metadata" form field holding a JSON object, and X-Amz-Meta-* request
+// headers, which win over the form field.
+func applyUploadMetadata(r *http.Request, opts *minio.PutObjectOptions) error {
+	// Keys are canonicalized up front so that a header replaces the form
+	// field's "owner" as well as its "Owner".
+	meta := map[string]string{}
+	if r.MultipartForm != nil {
+		if v := r.MultipartForm.Value["metadata"]; len(v) > 0 && v[0] != "" {
+			var form map[string]string
+			if err := json.Unmarshal([]byte(v[0]), &form); err != nil {
+				return errors.New(`metadata must be a JSON object like {"owner": "ana"}`)
+			}
+			var err error
+			if meta, err = canonicalMetadata(form); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range r.Header {
+		if name, ok := strings.CutPrefix(http.CanonicalHeaderKey(k), "X-Amz-Meta-"); ok {
+			meta[name] = v[0]
+		}
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	if err := checkUserMetadata(meta); err != nil {
+		return err
+	}
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = map[string]string{}
+	}
+	for k, v := range meta {
+		opts.UserMetadata["X-Amz-Meta-"+k] = v
+	}
+	return nil
+}
+
+type objectMeta struct {
+	Key      string            `json:"key"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// objectMetaHandler reads (GET) or replaces (PUT) the user metadata of
+// /meta/{objectName}. A PUT body such as {"metadata": {"owner": "ana"}}
+// becomes the object's whole metadata, apart from the service's own keys.
+// Like /headers, the change is a server-side copy of the object onto itself.
+func (h *MinioHandler) objectMetaHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/meta/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /meta/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && h.rejectIfReadOnly(w) {
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{ServerSideEncryption: key})
+	if wrongCustomerKey(err, key) {
+		http.Error(w, sseCKeyRequired, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, r, http.StatusOK, objectMeta{Key: objectName, Metadata: statOf(info).Metadata})
+		return
+	}
+
+	var req struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Metadata == nil {
+		http.Error(w, `Request body must be JSON like {"metadata": {"owner": "ana"}}`, http.StatusBadRequest)
+		return
+	}
+	meta, err := canonicalMetadata(req.Metadata)
+	if err == nil {
+		err = checkUserMetadata(meta)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := h.preservingOptions(r.Context(), objectName, info)
+	if err != nil {
+		logger(r.Context()).Error("Error reading metadata", "object", objectName, "err", err)
+		http.Error(w, "Failed to update metadata", http.StatusInternalServerError)
+		return
+	}
+	// Drop the old client metadata; x-amz-* settings such as the ACL and
+	// the service keys stay.
+	maps.DeleteFunc(opts.UserMetadata, func(k, _ string) bool {
+		return !strings.HasPrefix(strings.ToLower(k), "x-amz-") && !isServiceMeta(k)
+	})
+	updated := map[string]string{}
+	for k, v := range info.UserMetadata {
+		if isServiceMeta(k) {
+			updated[k] = v
+		}
+	}
+	for k, v := range meta {
+		opts.UserMetadata["X-Amz-Meta-"+k] = v
+		updated[k] = v
+	}
+
+	enc := h.sse.forCopy(key)
+	dst := minio.CopyDestOptions{
+		Bucket:             h.bucketName,
+		Object:             objectName,
+		ReplaceMetadata:    true,
+		UserMetadata:       opts.UserMetadata,
+		ContentType:        opts.ContentType,
+		ContentEncoding:    opts.ContentEncoding,
+		ContentDisposition: opts.ContentDisposition,
+		CacheControl:       opts.CacheControl,
+		ContentLanguage:    opts.ContentLanguage,
+		Encryption:         enc.dst,
+	}
+	src := minio.CopySrcOptions{Bucket: h.bucketName, Object: objectName, MatchETag: info.ETag, Encryption: enc.src}
+	if _, err := h.store.CopyObject(r.Context(), dst, src); err != nil {
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			http.Error(w, "Object changed while its metadata was being updated; retry the request", http.StatusPreconditionFailed)
+			return
+		}
+		logger(r.Context()).Error("Error updating metadata", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to update metadata", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	writeJSON(w, r, http.StatusOK, objectMeta{Key: objectName, Metadata: updated})
+}
+
+type objectTags struct {
+	Key  string            `json:"key"`
+	Tags map[string]string `json:"tags"`
+}
+
+// objectTagsHandler reads (GET) or replaces (PUT, body {"tags": {"k": "v"}})
+// the tags of /tags/{objectName}. S3 allows up to 10 tags per object, with
+// keys of up to 128 and values of up to 256 characters.
+func (h *MinioHandler) objectTagsHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/tags/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /tags/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && h.rejectIfReadOnly(w) {
+		return
+	}
+	if _, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{}); err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		t, err := h.store.GetObjectTagging(r.Context(), h.bucketName, objectName, minio.GetObjectTaggingOptions{})
+		if err != nil {
+			logger(r.Context()).Error("Error reading tags", "object", objectName, "err", err)
+			h.storeFailed(w, "Failed to read tags", err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, objectTags{Key: objectName, Tags: t.ToMap()})
+		return
+	}
+
+	var req struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Tags == nil {
+		http.Error(w, `Request body must be JSON like {"tags": {"project": "apollo"}}`, http.StatusBadRequest)
+		return
+	}
+	t, err := tags.NewTags(req.Tags, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.store.PutObjectTagging(r.Context(), h.bucketName, objectName, t, minio.PutObjectTaggingOptions{}); err != nil {
+		logger(r.Context()).Error("Error updating tags", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to update tags", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, objectTags{Key: objectName, Tags: t.ToMap()})
+}
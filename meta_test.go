@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestUploadStoresMetadata(t *testing.T) {
+	h, store := newTestHandler(t)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("metadata", `{"owner": "ana", "project": "apollo"}`)
+	part, _ := mw.CreateFormFile("file", "report.pdf")
+	part.Write([]byte("%PDF"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Amz-Meta-Project", "gemini")
+	if rec := serve(h, req); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "report.pdf", minio.StatObjectOptions{})
+	if info.UserMetadata["Owner"] != "ana" || info.UserMetadata["Project"] != "gemini" {
+		t.Errorf("metadata = %v, want owner ana and the header's project", info.UserMetadata)
+	}
+
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	mw.WriteField("metadata", `{"owner": "ana", "OWNER": "bo"}`)
+	part, _ = mw.CreateFormFile("file", "twice.pdf")
+	part.Write([]byte("%PDF"))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("keys differing in case: status = %d, want 400", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/raw/raw.txt", strings.NewReader("hi"))
+	req.Header.Set("X-Amz-Meta-Expires-At", "2000-01-01T00:00:00Z")
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("reserved key: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestObjectMetaHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	opts := minio.PutObjectOptions{ContentType: "application/pdf", CacheControl: "no-cache",
+		UserMetadata: map[string]string{"Owner": "ana", "X-Amz-Meta-Sha256": "abc"}, UserTags: map[string]string{"keep": "yes"}}
+	store.PutObject(t.Context(), testBucket, "report.pdf", strings.NewReader("%PDF"), 4, opts)
+
+	var got objectMeta
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/meta/report.pdf", nil)), &got)
+	if got.Metadata["Owner"] != "ana" {
+		t.Errorf("GET = %+v", got)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/meta/report.pdf", strings.NewReader(`{"metadata": {"reviewer": "bo"}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "report.pdf", minio.StatObjectOptions{})
+	if _, ok := info.UserMetadata["Owner"]; ok || info.UserMetadata["Reviewer"] != "bo" || info.UserMetadata["Sha256"] != "abc" {
+		t.Errorf("stored metadata = %v, want only reviewer and the service key", info.UserMetadata)
+	}
+	if info.Metadata.Get("Cache-Control") != "no-cache" || info.ContentType != "application/pdf" {
+		t.Errorf("headers lost: %v", info.Metadata)
+	}
+	if tg, _ := store.GetObjectTagging(t.Context(), testBucket, "report.pdf", minio.GetObjectTaggingOptions{}); tg.ToMap()["keep"] != "yes" {
+		t.Errorf("tags lost: %v", tg)
+	}
+
+	for _, body := range []string{`{"metadata": {"bad key": "x"}}`, `{"metadata": {"sha256": "forged"}}`, `{"metadata": {"owner": "a", "Owner": "b"}}`, `{"metadata": {"k": "` + strings.Repeat("v", maxUserMetadata) + `"}}`, `{}`} {
+		if rec := serve(h, httptest.NewRequest(http.MethodPut, "/meta/report.pdf", strings.NewReader(body))); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %.40s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/meta/missing.pdf", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object status = %d, want 404", rec.Code)
+	}
+}
+
+func TestObjectTagsHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "report.pdf", []byte("%PDF"), "application/pdf")
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/tags/report.pdf", strings.NewReader(`{"tags": {"project": "apollo"}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	var got objectTags
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/tags/report.pdf", nil)), &got)
+	if len(got.Tags) != 1 || got.Tags["project"] != "apollo" {
+		t.Errorf("GET = %+v", got)
+	}
+
+	tooMany := map[string]string{}
+	for _, k := range strings.Split("a b c d e f g h i j k", " ") {
+		tooMany[k] = "v"
+	}
+	body, _ := json.Marshal(map[string]any{"tags": tooMany})
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/tags/report.pdf", bytes.NewReader(body))); rec.Code != http.StatusBadRequest {
+		t.Errorf("11 tags: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/tags/missing.pdf", strings.NewReader(`{"tags": {}}`))); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object status = %d, want 404", rec.Code)
+	}
+}
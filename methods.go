@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// allowMethods restricts next to the given methods. OPTIONS is answered
+// with 204 and an Allow header listing them, unless next handles OPTIONS
+// itself; any other method gets 405 with the same header, as RFC 9110
+// requires.
+func allowMethods(next http.Handler, methods ...string) http.Handler {
+	allowed := slices.Clone(methods)
+	if !slices.Contains(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	allow := strings.Join(allowed, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(methods, r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethodNotAllowedSetsAllow(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tests := []struct {
+		method, target, wantAllow string
+	}{
+		{http.MethodPost, "/list", "GET, OPTIONS"},
+		{http.MethodDelete, "/headers/a.txt", "GET, PUT, OPTIONS"},
+		{http.MethodGet, "/delete/a.txt", "DELETE, OPTIONS"},
+		{http.MethodPut, "/get-download-link/a.txt", "GET, HEAD, OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("status = %d, want 405", rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestOptionsListsAllowedMethods(t *testing.T) {
+	h, _ := newTestHandler(t)
+	// OPTIONS is answered even for write routes while read-only.
+	h.setReadOnly(true)
+	rec := serve(h, httptest.NewRequest(http.MethodOptions, "/upload", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "POST, OPTIONS" {
+		t.Errorf("status = %d, Allow = %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
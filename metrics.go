@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The metrics /metrics exposes, in the Prometheus text format. HTTP metrics
+// are labelled by route pattern rather than path, so object names don't
+// each get a series of their own.
+var (
+	httpRequests = newMetricVec("go_minio_http_requests_total", "counter",
+		"HTTP requests served, by route, method and status code.", nil, "route", "method", "code")
+	httpDuration = newMetricVec("go_minio_http_request_duration_seconds", "histogram",
+		"Time to serve HTTP requests, by route and method.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "route", "method")
+	httpBytesIn = newMetricVec("go_minio_http_request_bytes_total", "counter",
+		"Request body bytes received, by route.", nil, "route")
+	httpBytesOut = newMetricVec("go_minio_http_response_bytes_total", "counter",
+		"Response body bytes sent, by route.", nil, "route")
+	operations = newMetricVec("go_minio_operations_total", "counter",
+		"Uploads, downloads, deletes and presigned links, by outcome (success or error).", nil, "operation", "outcome")
+	backendRequests = newMetricVec("go_minio_backend_requests_total", "counter",
+		"Requests sent to MinIO, by method and status code (error when no response came back).", nil, "method", "code")
+	sseSubscribers = newMetricVec("go_minio_sse_subscribers", "gauge",
+		"Clients connected to a Server-Sent Events stream, by stream (watch or job).", nil, "stream")
+)
+
+// routeOperations classify routes for go_minio_operations_total.
+var routeOperations = map[string]string{
+	"/upload":             "upload",
+	"/upload/":            "upload",
+	"/upload-archive":     "upload",
+	"/modify/":            "upload",
+	"/raw/":               "upload",
+	"/content/":           "upload",
+	"/append/":            "upload",
+	"/tus/":               "upload",
+	"/multipart/":         "upload",
+	"/download/":          "download",
+	"/fetch/":             "download",
+	"/download-archive/":  "download",
+	"/download-tar":       "download",
+	"/download-zip":       "download",
+	"/delete/":            "delete",
+	"/delete-batch":       "delete",
+	"/get-download-link/": "presign",
+	"/get-upload-link/":   "presign",
+	"/get-upload-policy/": "presign",
+	"/s/":                 "presign",
+}
+
+// metricVec is a family of counters, gauges or histograms that share a name
+// and label names.
+type metricVec struct {
+	name, kind, help string
+	labels           []string
+	// buckets are the upper bounds of a histogram's buckets.
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64
+	// counts[i] holds the observations in buckets[i]; not cumulative.
+	counts []uint64
+	n      uint64
+}
+
+// metricFamilies are written by /metrics in this order.
+var metricFamilies []*metricVec
+
+func newMetricVec(name, kind, help string, buckets []float64, labels ...string) *metricVec {
+	m := &metricVec{name: name, kind: kind, help: help, labels: labels, buckets: buckets, series: make(map[string]*metricSeries)}
+	metricFamilies = append(metricFamilies, m)
+	return m
+}
+
+// with returns the series for labelValues, creating it. m.mu must be held.
+func (m *metricVec) with(labelValues []string) *metricSeries {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: labelValues}
+		if m.buckets != nil {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// add adds v to a counter or gauge.
+func (m *metricVec) add(v float64, labelValues ...string) {
+	m.mu.Lock()
+	m.with(labelValues).value += v
+	m.mu.Unlock()
+}
+
+// observe records v in a histogram.
+func (m *metricVec) observe(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.with(labelValues)
+	s.value += v
+	s.n++
+	if i := sort.SearchFloat64s(m.buckets, v); i < len(m.buckets) {
+		s.counts[i]++
+	}
+}
+
+// writeTo writes the family in the Prometheus text format, series sorted
+// by label values.
+func (m *metricVec) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	withLE := slices.Concat(m.labels, []string{"le"})
+	for _, k := range keys {
+		s := m.series[k]
+		labels := formatLabels(m.labels, s.labelValues)
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, labels, formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(withLE, slices.Concat(s.labelValues, []string{formatValue(le)})), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(withLE, slices.Concat(s.labelValues, []string{"+Inf"})), s.n)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labels, formatValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, labels, s.n)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricsHandler serves every metric family in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, m := range metricFamilies {
+		m.writeTo(bw)
+	}
+	bw.Flush()
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// instrument records the HTTP metrics of every request to the route
+// registered as pattern, and the outcome of those that are uploads,
+// downloads, deletes or presigns.
+func instrument(pattern string, next http.Handler) http.Handler {
+	op := routeOperations[pattern]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.add(1, pattern, r.Method, strconv.Itoa(status))
+		httpDuration.observe(time.Since(start).Seconds(), pattern, r.Method)
+		httpBytesIn.add(float64(body.n.Load()), pattern)
+		httpBytesOut.add(float64(rec.bytes), pattern)
+		if op != "" && r.Method != http.MethodOptions {
+			outcome := "success"
+			if status >= 400 {
+				outcome = "error"
+			}
+			operations.add(1, op, outcome)
+		}
+	})
+}
+
+// countBackendRequests wraps a MinIO transport to count its requests by
+// status code, from which MinIO's error rate follows.
+func countBackendRequests(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		backendRequests.add(1, req.Method, code)
+		return resp, err
+	})
+}
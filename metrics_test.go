@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricVecText(t *testing.T) {
+	hist := &metricVec{name: "h", kind: "histogram", help: "A histogram.", labels: []string{"route"}, buckets: []float64{1, 5}, series: make(map[string]*metricSeries)}
+	hist.observe(0.5, "/a")
+	hist.observe(3, "/a")
+	hist.observe(10, "/a")
+	counter := &metricVec{name: "c", kind: "counter", help: "A counter.", labels: []string{"path"}, series: make(map[string]*metricSeries)}
+	counter.add(2, `say "hi"`)
+
+	var b bytes.Buffer
+	hist.writeTo(&b)
+	counter.writeTo(&b)
+	want := `# HELP h A histogram.
+# TYPE h histogram
+h_bucket{route="/a",le="1"} 1
+h_bucket{route="/a",le="5"} 2
+h_bucket{route="/a",le="+Inf"} 3
+h_sum{route="/a"} 13.5
+h_count{route="/a"} 3
+# HELP c A counter.
+# TYPE c counter
+c{path="say \"hi\""} 2
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+// metricValue returns the value of the series with labels in /metrics, or
+// "" without one.
+func metricValue(t *testing.T, h *MinioHandler, name, labels string) string {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, name+labels+" "); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func TestMetricsInstrumentRoutes(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("hello"), "text/plain")
+	before := func(name, labels string) string { return metricValue(t, h, name, labels) }
+	downloads, failed := before("go_minio_operations_total", `{operation="download",outcome="success"}`), before("go_minio_operations_total", `{operation="download",outcome="error"}`)
+
+	serve(h, httptest.NewRequest(http.MethodGet, "/download/a.txt", nil))
+	serve(h, httptest.NewRequest(http.MethodGet, "/download/missing.txt", nil))
+
+	if got := metricValue(t, h, "go_minio_operations_total", `{operation="download",outcome="success"}`); got != incremented(downloads) {
+		t.Errorf("successful downloads = %q, was %q", got, downloads)
+	}
+	if got := metricValue(t, h, "go_minio_operations_total", `{operation="download",outcome="error"}`); got != incremented(failed) {
+		t.Errorf("failed downloads = %q, was %q", got, failed)
+	}
+	// Series are per route pattern, not per object.
+	if got := metricValue(t, h, "go_minio_http_requests_total", `{route="/download/",method="GET",code="404"}`); got == "" {
+		t.Error("no 404 series for /download/")
+	}
+	if got := metricValue(t, h, "go_minio_http_response_bytes_total", `{route="/download/"}`); got == "" || got == "0" {
+		t.Errorf("response bytes = %q", got)
+	}
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "a.txt") || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q, body mentions object names: %v", rec.Header().Get("Content-Type"), strings.Contains(rec.Body.String(), "a.txt"))
+	}
+}
+
+func TestCountBackendRequests(t *testing.T) {
+	rt := countBackendRequests(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	h, _ := newTestHandler(t)
+	was := metricValue(t, h, "go_minio_backend_requests_total", `{method="PUT",code="503"}`)
+	rt.RoundTrip(httptest.NewRequest(http.MethodPut, "http://minio/bucket/a", nil))
+	if got := metricValue(t, h, "go_minio_backend_requests_total", `{method="PUT",code="503"}`); got != incremented(was) {
+		t.Errorf("backend 503s = %q, was %q", got, was)
+	}
+}
+
+// incremented returns the counter value after v, written as /metrics writes it.
+func incremented(v string) string {
+	if v == "" {
+		v = "0"
+	}
+	n, _ := strconv.ParseFloat(v, 64)
+	return formatValue(n + 1)
+}
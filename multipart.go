@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+)
+
+// multipartLimits bounds how multipart upload forms are parsed.
+type multipartLimits struct {
+	// memory is how much of the form is held in memory; file parts beyond
+	// it are spooled to temporary files.
+	memory int64
+	// maxParts caps the parts (files and fields) of one form; zero disables.
+	maxParts int
+}
+
+// loadMultipartLimits reads MINIO_MULTIPART_MEMORY (a size such as 32MiB)
+// and MINIO_MULTIPART_MAX_PARTS.
+func loadMultipartLimits() (multipartLimits, error) {
+	limits := multipartLimits{memory: 10 << 20, maxParts: 100}
+	if v := os.Getenv("MINIO_MULTIPART_MEMORY"); v != "" {
+		n, err := parseByteSize(v)
+		if err != nil || n == 0 {
+			return limits, fmt.Errorf("MINIO_MULTIPART_MEMORY must be a positive size such as 32MiB, got %q", v)
+		}
+		limits.memory = n
+	}
+	n := envInt64("MINIO_MULTIPART_MAX_PARTS", int64(limits.maxParts))
+	if n < 0 {
+		return limits, fmt.Errorf("MINIO_MULTIPART_MAX_PARTS must not be negative, got %d", n)
+	}
+	limits.maxParts = int(n)
+	return limits, nil
+}
+
+// errTooManyParts is returned while reading a form with more parts than
+// MINIO_MULTIPART_MAX_PARTS allows.
+var errTooManyParts = errors.New("too many parts in multipart form")
+
+// partLimitReader counts the boundary delimiters in a multipart body as it
+// is read and fails once it has seen more parts than max, so a crafted form
+// is rejected before its remaining parts are spooled to disk.
+type partLimitReader struct {
+	r     io.Reader
+	delim []byte
+	max   int
+	seen  int
+	// tail holds the end of the previous read, in case a delimiter is split
+	// across two reads. It is shorter than delim, so nothing is counted twice.
+	tail []byte
+}
+
+func (p *partLimitReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	buf := append(p.tail, b[:n]...)
+	p.seen += bytes.Count(buf, p.delim)
+	// The closing delimiter doesn't start a part.
+	if p.seen-1 > p.max {
+		return 0, errTooManyParts
+	}
+	p.tail = append(p.tail[:0], buf[max(len(buf)-len(p.delim)+1, 0):]...)
+	return n, err
+}
+
+// parseUploadForm is r.ParseMultipartForm with the configured memory
+// threshold and part limit. It writes a 400 and returns false when the form
+// is malformed or has too many parts, or records an abandoned upload when
+// the client disconnected while sending it.
+func (h *MinioHandler) parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	limits := h.multipart
+	if limits.memory == 0 {
+		limits.memory = 10 << 20
+	}
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && params["boundary"] != "" && limits.maxParts > 0 {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{&partLimitReader{r: r.Body, delim: []byte("--" + params["boundary"]), max: limits.maxParts}, r.Body}
+	}
+	if err := r.ParseMultipartForm(limits.memory); err != nil {
+		if errors.Is(err, errTooManyParts) {
+			http.Error(w, fmt.Sprintf("Multipart form has more than %d parts", limits.maxParts), http.StatusBadRequest)
+			return false
+		}
+		if clientDisconnected(r, err) {
+			h.uploadAbandoned(w, r, err)
+			return false
+		}
+		http.Error(w, "Could not parse multipart form", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
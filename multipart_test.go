@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadFormPartLimit(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.multipart = multipartLimits{memory: 1 << 10, maxParts: 3}
+
+	form := func(parts int) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for i := range parts {
+			part, _ := mw.CreateFormFile("file", fmt.Sprintf("f%d.txt", i))
+			part.Write([]byte(strings.Repeat("x", 2048)))
+		}
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	rec := serve(h, form(3))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("3 parts: status %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := store.object(testBucket, "f0.txt"); !ok {
+		t.Error("f0.txt was not uploaded")
+	}
+
+	rec = serve(h, form(4))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "more than 3 parts") {
+		t.Fatalf("4 parts: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPartLimitReaderSplitDelimiter(t *testing.T) {
+	body := "--b\r\nx\r\n--b\r\ny\r\n--b--"
+	p := &partLimitReader{r: strings.NewReader(body), delim: []byte("--b"), max: 1}
+	buf := make([]byte, 1)
+	var err error
+	for err == nil {
+		_, err = p.Read(buf)
+	}
+	if err != errTooManyParts {
+		t.Fatalf("err = %v, want errTooManyParts", err)
+	}
+}
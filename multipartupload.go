@@ -0,0 +1,233 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// maxListedUploads caps the incomplete uploads /multipart-uploads returns.
+const maxListedUploads = 1000
+
+type multipartPart struct {
+	PartNumber   int       `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size,omitempty"`
+	LastModified time.Time `json:"last_modified,omitzero"`
+}
+
+type multipartParts struct {
+	Key      string          `json:"key"`
+	UploadID string          `json:"upload_id"`
+	Parts    []multipartPart `json:"parts"`
+	// NextPartNumberMarker continues a truncated listing as
+	// ?part_number_marker=.
+	NextPartNumberMarker int  `json:"next_part_number_marker,omitempty"`
+	Truncated            bool `json:"truncated"`
+}
+
+// multipartUploadHandler drives an S3 multipart upload of
+// /multipart/{objectName} step by step, for clients that send the parts of
+// a large file in parallel themselves:
+//
+//   - POST starts an upload and returns its upload_id;
+//   - PUT ?upload_id=&part_number= uploads the body as one part;
+//   - GET ?upload_id= lists the parts uploaded so far;
+//   - POST ?upload_id= with {"parts": [...]} assembles the object;
+//   - DELETE ?upload_id= aborts the upload and discards its parts.
+func (h *MinioHandler) multipartUploadHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/multipart/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /multipart/backup.tar)", http.StatusBadRequest)
+		return
+	}
+	uploadID := r.URL.Query().Get("upload_id")
+	switch {
+	case r.Method == http.MethodPost && uploadID == "":
+		h.initiateMultipart(w, r, objectName)
+	case uploadID == "":
+		http.Error(w, "upload_id is required", http.StatusBadRequest)
+	case r.Method == http.MethodPut:
+		h.uploadPart(w, r, objectName, uploadID)
+	case r.Method == http.MethodGet:
+		h.listParts(w, r, objectName, uploadID)
+	case r.Method == http.MethodPost:
+		h.completeMultipart(w, r, objectName, uploadID)
+	case r.Method == http.MethodDelete:
+		h.abortMultipart(w, r, objectName, uploadID)
+	}
+}
+
+// initiateMultipart starts an upload with the ?content_type= given and the
+// metadata and encryption headers an upload may carry (see
+// applyUploadMetadata and sse.go).
+func (h *MinioHandler) initiateMultipart(w http.ResponseWriter, r *http.Request, objectName string) {
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	opts, _ := h.uploadOptions(objectName, r.URL.Query().Get("content_type"))
+	if err := applyUploadMetadata(r, &opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var err error
+	if opts.ServerSideEncryption, err = h.sse.forUpload(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	uploadID, err := h.store.NewMultipartUpload(r.Context(), h.bucketName, objectName, opts)
+	if err != nil {
+		logger(r.Context()).Error("Error starting multipart upload", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to start upload", err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, map[string]string{"key": objectName, "upload_id": uploadID})
+}
+
+// uploadPart stores the request body as part ?part_number= (1 to 10,000).
+// Every part but the last must be at least 5 MiB, which S3 checks when the
+// upload is completed. Parts of an upload started with an SSE-C key must
+// carry the same key.
+func (h *MinioHandler) uploadPart(w http.ResponseWriter, r *http.Request, objectName, uploadID string) {
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("part_number"))
+	if err != nil || partNumber < 1 || partNumber > maxCopyParts {
+		http.Error(w, fmt.Sprintf("part_number must be between 1 and %d", maxCopyParts), http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength < 0 {
+		http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength > maxPartSize {
+		http.Error(w, fmt.Sprintf("A part can't exceed %d bytes (5 GiB)", maxPartSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	part, err := h.store.PutObjectPart(r.Context(), h.bucketName, objectName, uploadID, partNumber, r.Body, r.ContentLength, minio.PutObjectPartOptions{SSE: key})
+	if err != nil {
+		if clientDisconnected(r, err) {
+			h.uploadAbandoned(w, r, err)
+			return
+		}
+		h.multipartFailed(w, r, objectName, "Failed to upload part", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, multipartPart{PartNumber: part.PartNumber, ETag: part.ETag, Size: part.Size})
+}
+
+// listParts lists the uploaded parts, 1,000 at a time from
+// ?part_number_marker=.
+func (h *MinioHandler) listParts(w http.ResponseWriter, r *http.Request, objectName, uploadID string) {
+	marker, _ := strconv.Atoi(r.URL.Query().Get("part_number_marker"))
+	result, err := h.store.ListObjectParts(r.Context(), h.bucketName, objectName, uploadID, marker, 1000)
+	if err != nil {
+		h.multipartFailed(w, r, objectName, "Failed to list parts", err)
+		return
+	}
+	resp := multipartParts{Key: objectName, UploadID: uploadID, Parts: []multipartPart{}, Truncated: result.IsTruncated}
+	for _, p := range result.ObjectParts {
+		resp.Parts = append(resp.Parts, multipartPart{PartNumber: p.PartNumber, ETag: p.ETag, Size: p.Size, LastModified: p.LastModified})
+	}
+	if result.IsTruncated {
+		resp.NextPartNumberMarker = result.NextPartNumberMarker
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// completeMultipart assembles the parts listed in the body, in part number
+// order, into the object.
+func (h *MinioHandler) completeMultipart(w http.ResponseWriter, r *http.Request, objectName, uploadID string) {
+	var req struct {
+		Parts []multipartPart `json:"parts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		http.Error(w, `Request body must be JSON like {"parts": [{"part_number": 1, "etag": "..."}]}`, http.StatusBadRequest)
+		return
+	}
+	parts := make([]minio.CompletePart, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		parts = append(parts, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	slices.SortFunc(parts, func(a, b minio.CompletePart) int { return cmp.Compare(a.PartNumber, b.PartNumber) })
+
+	// Assembling a large object takes a while; a client that gives up
+	// waiting shouldn't leave it half done.
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	info, err := h.store.CompleteMultipartUpload(ctx, h.bucketName, objectName, uploadID, parts, minio.PutObjectOptions{})
+	if err != nil {
+		h.multipartFailed(w, r, objectName, "Failed to complete upload", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	writeJSON(w, r, http.StatusOK, map[string]string{"key": objectName, "etag": info.ETag})
+}
+
+func (h *MinioHandler) abortMultipart(w http.ResponseWriter, r *http.Request, objectName, uploadID string) {
+	if err := h.store.AbortMultipartUpload(r.Context(), h.bucketName, objectName, uploadID); err != nil {
+		h.multipartFailed(w, r, objectName, "Failed to abort upload", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// multipartFailed answers a failed multipart call: 404 for an unknown
+// upload, 400 with S3's reason for parts it rejected, and storeFailed
+// otherwise.
+func (h *MinioHandler) multipartFailed(w http.ResponseWriter, r *http.Request, objectName, msg string, err error) {
+	switch resp := minio.ToErrorResponse(err); resp.Code {
+	case "NoSuchUpload":
+		http.Error(w, "Upload not found", http.StatusNotFound)
+	case "InvalidPart", "InvalidPartOrder", "EntityTooSmall", "MalformedXML":
+		http.Error(w, fmt.Sprintf("%s: %s", msg, resp.Message), http.StatusBadRequest)
+	default:
+		logger(r.Context()).Error("Error in multipart upload", "object", objectName, "err", err)
+		h.storeFailed(w, msg, err)
+	}
+}
+
+type incompleteUpload struct {
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+	Size      int64     `json:"size"`
+}
+
+// incompleteUploadsHandler lists the multipart uploads under ?prefix= that
+// were started but neither completed nor aborted, oldest first (GET
+// /multipart-uploads). Their parts take up space until they are.
+func (h *MinioHandler) incompleteUploadsHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	resp := struct {
+		Prefix    string             `json:"prefix,omitempty"`
+		Uploads   []incompleteUpload `json:"uploads"`
+		Truncated bool               `json:"truncated"`
+	}{Prefix: prefix, Uploads: []incompleteUpload{}}
+	for upload := range h.store.ListIncompleteUploads(r.Context(), h.bucketName, prefix, true) {
+		if upload.Err != nil {
+			logger(r.Context()).Error("Error listing incomplete uploads", "prefix", prefix, "err", upload.Err)
+			h.storeFailed(w, "Failed to list uploads", upload.Err)
+			return
+		}
+		if len(resp.Uploads) == maxListedUploads {
+			resp.Truncated = true
+			break
+		}
+		resp.Uploads = append(resp.Uploads, incompleteUpload{Key: upload.Key, UploadID: upload.UploadID, Initiated: upload.Initiated, Size: upload.Size})
+	}
+	slices.SortFunc(resp.Uploads, func(a, b incompleteUpload) int {
+		return cmp.Or(a.Initiated.Compare(b.Initiated), cmp.Compare(a.Key, b.Key))
+	})
+	writeJSON(w, r, http.StatusOK, resp)
+}
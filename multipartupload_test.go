@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipartUploadLifecycle(t *testing.T) {
+	h, store := newTestHandler(t)
+	var started struct {
+		UploadID string `json:"upload_id"`
+	}
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/multipart/backup.tar?content_type=application/x-tar", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("initiate status = %d: %s", rec.Code, rec.Body)
+	}
+	decodeJSON(t, rec, &started)
+	base := "/multipart/backup.tar?upload_id=" + started.UploadID
+
+	// Parts may arrive in any order.
+	var parts []multipartPart
+	for _, n := range []int{2, 1} {
+		rec := serve(h, httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s&part_number=%d", base, n), strings.NewReader(fmt.Sprintf("part%d;", n))))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("part %d status = %d: %s", n, rec.Code, rec.Body)
+		}
+		var part multipartPart
+		decodeJSON(t, rec, &part)
+		parts = append(parts, part)
+	}
+
+	var listed multipartParts
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, base, nil)), &listed)
+	if len(listed.Parts) != 2 || listed.Parts[0].PartNumber != 1 || listed.Parts[1].Size != 6 {
+		t.Errorf("parts = %+v", listed.Parts)
+	}
+	var pending struct {
+		Uploads []incompleteUpload `json:"uploads"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/multipart-uploads?prefix=back", nil)), &pending)
+	if len(pending.Uploads) != 1 || pending.Uploads[0].UploadID != started.UploadID || pending.Uploads[0].Size != 12 {
+		t.Errorf("incomplete uploads = %+v", pending.Uploads)
+	}
+
+	bad, _ := json.Marshal(map[string]any{"parts": []multipartPart{{PartNumber: 1, ETag: "wrong"}}})
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, base, bytes.NewReader(bad))); rec.Code != http.StatusBadRequest {
+		t.Errorf("wrong ETag: status = %d, want 400", rec.Code)
+	}
+	body, _ := json.Marshal(map[string]any{"parts": parts})
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, base, bytes.NewReader(body))); rec.Code != http.StatusOK {
+		t.Fatalf("complete status = %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := store.object(testBucket, "backup.tar"); string(data) != "part1;part2;" {
+		t.Errorf("object = %q, want the parts in order", data)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, base, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("completed upload: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestMultipartUploadAbortAndErrors(t *testing.T) {
+	h, store := newTestHandler(t)
+	var started struct {
+		UploadID string `json:"upload_id"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPost, "/multipart/a.bin", nil)), &started)
+	base := "/multipart/a.bin?upload_id=" + started.UploadID
+
+	for target, want := range map[string]int{
+		base + "&part_number=0":                         http.StatusBadRequest,
+		base + "&part_number=10001":                     http.StatusBadRequest,
+		"/multipart/a.bin?part_number=1":                http.StatusBadRequest,
+		"/multipart/a.bin?upload_id=nope&part_number=1": http.StatusNotFound,
+	} {
+		if rec := serve(h, httptest.NewRequest(http.MethodPut, target, strings.NewReader("x"))); rec.Code != want {
+			t.Errorf("PUT %s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodDelete, base, nil)); rec.Code != http.StatusNoContent {
+		t.Fatalf("abort status = %d", rec.Code)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("uploads left after abort: %d", len(store.uploads))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type fileUploadResult struct {
+	File   string `json:"file"`
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+	ETag   string `json:"etag,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type multiUploadReport struct {
+	Bucket   string             `json:"bucket"`
+	Prefix   string             `json:"prefix,omitempty"`
+	Uploaded int                `json:"uploaded"`
+	Failed   int                `json:"failed"`
+	Results  []fileUploadResult `json:"results"`
+}
+
+// uploadFormFiles stores every "file" part of a parsed /upload form under
+// prefix, bulkConcurrency at a time, and reports each file as "uploaded" or
+// "failed" in form order. A file failing doesn't stop the others. The
+// response is 201 when all were stored and 207 otherwise.
+func (h *MinioHandler) uploadFormFiles(w http.ResponseWriter, r *http.Request, files []*multipart.FileHeader, prefix string) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	report := multiUploadReport{Bucket: h.bucketName, Prefix: prefix, Results: make([]fileUploadResult, len(files))}
+
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	seen := map[string]bool{}
+	for i, header := range files {
+		objectName := prefix + header.Filename
+		res := &report.Results[i]
+		*res = fileUploadResult{File: header.Filename, Key: objectName, Status: "failed", Size: header.Size}
+		// Parts with the same name would overwrite each other in no
+		// particular order; only the first is stored.
+		if seen[objectName] {
+			res.Error = "duplicate file name"
+			continue
+		}
+		seen[objectName] = true
+		if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+			res.Error = reason
+			continue
+		}
+		opts, _ := h.uploadOptions(objectName, header.Header.Get("Content-Type"))
+		if err := h.applyUploadParams(r, &opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			file, err := header.Open()
+			if err != nil {
+				res.Error = err.Error()
+				return
+			}
+			defer file.Close()
+			info, err := h.store.PutObject(ctx, h.bucketName, objectName, file, header.Size, opts)
+			if err != nil {
+				logger(r.Context()).Error("Error uploading file to MinIO", "object", objectName, "err", err)
+				res.Error = err.Error()
+				return
+			}
+			res.Status, res.ETag = "uploaded", info.ETag
+		}()
+	}
+	wg.Wait()
+
+	for _, res := range report.Results {
+		if res.Status != "uploaded" {
+			report.Failed++
+			continue
+		}
+		report.Uploaded++
+		h.cache.invalidate(res.Key)
+		h.quotaAfterUpload(w, res.Key, res.Size)
+	}
+	status := http.StatusCreated
+	if report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, r, status, report)
+}
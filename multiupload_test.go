@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// multiFileRequest builds a form with a "file" part per name, in order, and
+// a "prefix" field when prefix is non-empty.
+func multiFileRequest(target, prefix string, names ...string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if prefix != "" {
+		mw.WriteField("prefix", prefix)
+	}
+	for _, name := range names {
+		part, _ := mw.CreateFormFile("file", name)
+		part.Write([]byte("content of " + name))
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadMultipleFiles(t *testing.T) {
+	h, store := newTestHandler(t)
+	rec := serve(h, multiFileRequest("/upload", "photos/2024", "a.jpg", "b.jpg", "c.jpg"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var report multiUploadReport
+	decodeJSON(t, rec, &report)
+	if report.Uploaded != 3 || report.Failed != 0 || report.Prefix != "photos/2024/" {
+		t.Errorf("report = %+v", report)
+	}
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		if res := report.Results[i]; res.File != name || res.Key != "photos/2024/"+name || res.Status != "uploaded" || res.ETag == "" {
+			t.Errorf("result %d = %+v", i, res)
+		}
+		if data, _ := store.object(testBucket, "photos/2024/"+name); string(data) != "content of "+name {
+			t.Errorf("%s stored as %q", name, data)
+		}
+	}
+
+	// A single file with a prefix is reported the same way.
+	rec = serve(h, multiFileRequest("/upload", "docs", "notes.txt"))
+	decodeJSON(t, rec, &report)
+	if rec.Code != http.StatusCreated || len(report.Results) != 1 || report.Results[0].Key != "docs/notes.txt" {
+		t.Errorf("status %d, report %+v", rec.Code, report)
+	}
+}
+
+func TestUploadMultipleFilesPartialFailure(t *testing.T) {
+	h, store := newTestHandler(t)
+	rec := serve(h, multiFileRequest("/upload", "", "a.txt", "a.txt", "b.txt"))
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207: %s", rec.Code, rec.Body)
+	}
+	var report multiUploadReport
+	decodeJSON(t, rec, &report)
+	if report.Uploaded != 2 || report.Failed != 1 || report.Results[1].Status != "failed" || report.Results[1].Error == "" {
+		t.Errorf("report = %+v", report)
+	}
+	if _, ok := store.object(testBucket, "b.txt"); !ok {
+		t.Error("b.txt was not uploaded")
+	}
+
+	// /modify replaces one object, so it takes one file.
+	store.put(testBucket, "a.txt", []byte("old"), "text/plain")
+	req := multiFileRequest("/modify/a.txt", "", "x.txt", "y.txt")
+	req.Method = http.MethodPut
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("/modify with two files: status = %d, want 400", rec.Code)
+	}
+}
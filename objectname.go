@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// objectNameFromPath returns the object key that follows prefix in the
+// request path.
+//
+// Clients must percent-encode the key as a URL path, e.g. "my file#1.pdf" is
+// sent as "my%20file%231.pdf" and "é" as "%C3%A9". Slashes separating
+// folders may be sent as-is. The key is decoded exactly once, from the
+// escaped path, so a literal '%' in a key must be sent as "%25" and "+" is
+// never treated as a space.
+func objectNameFromPath(r *http.Request, prefix string) string {
+	escaped, ok := strings.CutPrefix(r.URL.EscapedPath(), prefix)
+	if !ok {
+		return ""
+	}
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// specialKeys are real-world names that used to break when passed through
+// the URL path.
+var specialKeys = []string{
+	"my file#1 (2024).pdf",
+	"reports/Q1 summary?.xlsx",
+	"100% done+final.txt",
+	"résumé/naïve café.docx",
+	"日本語/ファイル.png",
+}
+
+func TestObjectNameFromPath(t *testing.T) {
+	for _, key := range specialKeys {
+		t.Run(key, func(t *testing.T) {
+			target := (&url.URL{Path: "/delete/" + key}).EscapedPath()
+			req := httptest.NewRequest(http.MethodDelete, target, nil)
+			if got := objectNameFromPath(req, "/delete/"); got != key {
+				t.Errorf("objectNameFromPath(%q) = %q, want %q", target, got, key)
+			}
+		})
+	}
+}
+
+func TestSpecialKeysRoundTrip(t *testing.T) {
+	for _, key := range specialKeys {
+		t.Run(key, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			escaped := (&url.URL{Path: key}).EscapedPath()
+
+			rec := serve(h, newUploadRequest(t, http.MethodPut, "/modify/"+escaped, "upload.bin", "data"))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("modify status = %d (%s)", rec.Code, rec.Body)
+			}
+			if _, ok := store.object(testBucket, key); !ok {
+				t.Fatalf("object stored under wrong key; want %q", key)
+			}
+
+			rec = serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/"+escaped, nil))
+			var resp map[string]string
+			decodeJSON(t, rec, &resp)
+			u, err := url.Parse(resp["url"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "/" + testBucket + "/" + key; u.Path != want {
+				t.Errorf("presigned path decodes to %q, want %q", u.Path, want)
+			}
+
+			rec = serve(h, httptest.NewRequest(http.MethodGet, "/datauri/"+escaped, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("datauri status = %d", rec.Code)
+			}
+
+			rec = serve(h, httptest.NewRequest(http.MethodDelete, "/delete/"+escaped, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("delete status = %d", rec.Code)
+			}
+			if _, ok := store.object(testBucket, key); ok {
+				t.Error("object still exists after delete")
+			}
+		})
+	}
+}
+
+func TestPublicObjectURLEncodesKey(t *testing.T) {
+	got := publicObjectURL(&url.URL{Scheme: "https", Host: "s3.example.com"}, "media", "public/my file#1.png")
+	if want := "https://s3.example.com/media/public/my%20file%231.png"; got != want {
+		t.Errorf("publicObjectURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type organizeResult struct {
+	Object      string `json:"object"`
+	Destination string `json:"destination"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+// organizeHandler moves objects into a folder-like prefix, keeping each
+// object's base file name. Sources are copied server side first and only
+// removed, in one RemoveObjects batch, once their copy has succeeded.
+func (h *MinioHandler) organizeHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Objects    []string `json:"objects"`
+		DestPrefix string   `json:"dest_prefix"`
+		DryRun     bool     `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Objects) == 0 || req.DestPrefix == "" {
+		http.Error(w, `Request body must be JSON like {"objects": ["a.pdf"], "dest_prefix": "archive/2024/"}`, http.StatusBadRequest)
+		return
+	}
+	prefix := strings.TrimPrefix(req.DestPrefix, "/")
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Objects with the same base name would overwrite each other's copy,
+	// and a destination that is another source in the batch would be
+	// removed with it, so neither is moved.
+	sources, targets := map[string]bool{}, map[string]int{}
+	for _, src := range req.Objects {
+		sources[src] = true
+		targets[prefix+path.Base(src)]++
+	}
+
+	results := make([]organizeResult, len(req.Objects))
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for i, src := range req.Objects {
+		dst := prefix + path.Base(src)
+		results[i] = organizeResult{Object: src, Destination: dst}
+		switch {
+		case src == "" || strings.HasSuffix(src, "/"):
+			results[i].Status, results[i].Error = "failed", "not an object name"
+			continue
+		case isTusState(src):
+			results[i].Status, results[i].Error = "failed", tusStateReserved
+			continue
+		case h.trash.holds(src):
+			results[i].Status, results[i].Error = "failed", trashReserved(h.trash.root())
+			continue
+		case src == dst:
+			results[i].Status = "unchanged"
+			continue
+		case targets[dst] > 1:
+			results[i].Status, results[i].Error = "failed", "another object in the batch has the same destination"
+			continue
+		case sources[dst]:
+			results[i].Status, results[i].Error = "failed", "destination is another object in the batch"
+			continue
+		}
+		if status, reason := h.uploadRules.check(h.bucketName, dst); status != 0 {
+			results[i].Status, results[i].Error = "failed", reason
+			continue
+		}
+		if req.DryRun {
+			results[i].Status = "would_move"
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			info, err := h.store.StatObject(r.Context(), h.bucketName, src, minio.StatObjectOptions{ServerSideEncryption: key})
+			if err == nil {
+				_, err = h.copyObject(r.Context(), info, dst, h.sse.forCopy(key))
+			}
+			if err != nil {
+				logger(r.Context()).Error("Error copying object", "object", src, "destination", dst, "err", err)
+				results[i].Status, results[i].Error = "failed", err.Error()
+				return
+			}
+			results[i].Status = "copied"
+		}()
+	}
+	wg.Wait()
+
+	if !req.DryRun {
+		h.removeCopiedSources(r.Context(), results)
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Status == "failed" || res.Status == "copied" {
+			failed++
+		}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"dest_prefix": prefix,
+		"dry_run":     req.DryRun,
+		"failed":      failed,
+		"results":     results,
+	})
+}
+
+// removeCopiedSources deletes the source of every "copied" result in one
+// batch, marking each as "moved" or, if its removal failed, leaving it as
+// "copied" with the error so the caller knows both copies exist.
+func (h *MinioHandler) removeCopiedSources(ctx context.Context, results []organizeResult) {
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, res := range results {
+			if res.Status == "copied" {
+				objectsCh <- minio.ObjectInfo{Key: res.Object}
+			}
+		}
+	}()
+	removeErrs := map[string]error{}
+	for rErr := range h.store.RemoveObjects(ctx, h.bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		logger(ctx).Error("Error removing object after copy", "object", rErr.ObjectName, "err", rErr.Err)
+		removeErrs[rErr.ObjectName] = rErr.Err
+	}
+	for i := range results {
+		if results[i].Status != "copied" {
+			continue
+		}
+		if err := removeErrs[results[i].Object]; err != nil {
+			results[i].Error = "copied but failed to remove source: " + err.Error()
+			continue
+		}
+		results[i].Status = "moved"
+		h.cache.invalidate(results[i].Object)
+	}
+}
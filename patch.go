@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// patchMemoryLimit is the largest object patched in memory; bigger ones are
+// staged in a temp file.
+const patchMemoryLimit = 8 << 20
+
+type patchResponse struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag"`
+	PreviousETag string `json:"previous_etag"`
+}
+
+// patchContentHandler overwrites part of /content/{objectName} with the
+// request body, starting at byte ?offset=. S3 can't modify objects in
+// place, so this is a read-modify-write: the object is read, patched into a
+// staging copy and uploaded again with its metadata and tags. The upload is
+// conditional on the ETag read, so a concurrent change fails with 412
+// instead of being lost; clients can also send If-Match themselves.
+func (h *MinioHandler) patchContentHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/content/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /content/header.bin?offset=0)", http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must be a non-negative byte position", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	info, err := h.statObject(ctx, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if match := strings.Trim(r.Header.Get("If-Match"), `"`); match != "" && match != "*" && match != info.ETag {
+		http.Error(w, "Object has changed (ETag does not match If-Match)", http.StatusPreconditionFailed)
+		return
+	}
+	if offset > info.Size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		http.Error(w, fmt.Sprintf("offset %d is past the end of the object (%d bytes)", offset, info.Size), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	var staged io.ReadWriter = &bytes.Buffer{}
+	if info.Size > patchMemoryLimit {
+		f, err := os.CreateTemp("", "minio-patch-*")
+		if err != nil {
+			logger(r.Context()).Error("Error creating patch staging file", "err", err)
+			http.Error(w, "Failed to patch file", http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		staged = f
+	}
+
+	getOpts := minio.GetObjectOptions{}
+	getOpts.SetMatchETag(info.ETag)
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, getOpts)
+	if err != nil {
+		logger(r.Context()).Error("Error reading object for patch", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to patch file", err)
+		return
+	}
+	defer obj.Close()
+
+	// Head of the object, then the patch, then whatever the patch didn't cover.
+	if _, err := io.CopyN(staged, obj, offset); err != nil {
+		h.patchFailed(w, r, objectName, err)
+		return
+	}
+	n, err := io.Copy(staged, http.MaxBytesReader(w, r.Body, h.rawMaxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Patch exceeds the limit of "+strconv.FormatInt(h.rawMaxBytes, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Request body must contain the replacement bytes", http.StatusBadRequest)
+		return
+	}
+	if end := offset + n; end < info.Size {
+		if _, err := obj.Seek(end, io.SeekStart); err != nil {
+			h.patchFailed(w, r, objectName, err)
+			return
+		}
+		if _, err := io.Copy(staged, obj); err != nil {
+			h.patchFailed(w, r, objectName, err)
+			return
+		}
+	}
+	if f, ok := staged.(*os.File); ok {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			h.patchFailed(w, r, objectName, err)
+			return
+		}
+	}
+
+	opts, err := h.preservingOptions(r.Context(), objectName, info)
+	if err != nil {
+		h.patchFailed(w, r, objectName, err)
+		return
+	}
+	opts.SetMatchETag(info.ETag)
+	size := max(info.Size, offset+n)
+	uploaded, err := h.store.PutObject(ctx, h.bucketName, objectName, staged, size, opts)
+	if err != nil {
+		h.patchFailed(w, r, objectName, err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	writeJSON(w, r, http.StatusOK, patchResponse{Key: objectName, Size: size, ETag: uploaded.ETag, PreviousETag: info.ETag})
+}
+
+// preservingOptions returns PutObject options that re-create info's content
+// headers, user metadata and tags on a rewrite of the object.
+func (h *MinioHandler) preservingOptions(ctx context.Context, objectName string, info minio.ObjectInfo) (minio.PutObjectOptions, error) {
+	opts, _ := h.uploadOptions(objectName, info.ContentType)
+	opts.CacheControl = info.Metadata.Get("Cache-Control")
+	opts.ContentDisposition = info.Metadata.Get("Content-Disposition")
+	opts.ContentEncoding = info.Metadata.Get("Content-Encoding")
+	opts.ContentLanguage = info.Metadata.Get("Content-Language")
+	opts.StorageClass = info.StorageClass
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = map[string]string{}
+	}
+	for k, v := range info.UserMetadata {
+		opts.UserMetadata[k] = v
+	}
+	t, err := h.store.GetObjectTagging(ctx, h.bucketName, objectName, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return opts, fmt.Errorf("reading tags: %w", err)
+	}
+	opts.UserTags = t.ToMap()
+	return opts, nil
+}
+
+// patchFailed reports an error from the read-modify-write, mapping a failed
+// ETag condition to 412.
+func (h *MinioHandler) patchFailed(w http.ResponseWriter, r *http.Request, objectName string, err error) {
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		http.Error(w, "Object changed while it was being patched; retry the request", http.StatusPreconditionFailed)
+		return
+	}
+	logger(r.Context()).Error("Error patching object", "object", objectName, "err", err)
+	h.storeFailed(w, "Failed to patch file", err)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestPatchContentHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		ifMatch    string
+		wantStatus int
+		want       string
+	}{
+		{name: "middle", target: "/content/doc.bin?offset=2", body: "XY", wantStatus: http.StatusOK, want: "abXYefgh"},
+		{name: "extends past end", target: "/content/doc.bin?offset=6", body: "XYZ", wantStatus: http.StatusOK, want: "abcdefXYZ"},
+		{name: "append", target: "/content/doc.bin?offset=8", body: "!", wantStatus: http.StatusOK, want: "abcdefgh!"},
+		{name: "offset past end", target: "/content/doc.bin?offset=9", body: "X", wantStatus: http.StatusRequestedRangeNotSatisfiable, want: "abcdefgh"},
+		{name: "stale If-Match", target: "/content/doc.bin?offset=0", body: "X", ifMatch: `"stale"`, wantStatus: http.StatusPreconditionFailed, want: "abcdefgh"},
+		{name: "empty body", target: "/content/doc.bin?offset=0", wantStatus: http.StatusBadRequest, want: "abcdefgh"},
+		{name: "missing offset", target: "/content/doc.bin", body: "X", wantStatus: http.StatusBadRequest, want: "abcdefgh"},
+		{name: "missing object", target: "/content/nope.bin?offset=0", body: "X", wantStatus: http.StatusNotFound, want: "abcdefgh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			opts := minio.PutObjectOptions{
+				ContentType:  "application/x-doc",
+				CacheControl: "max-age=60",
+				UserMetadata: map[string]string{"Owner": "ops"},
+				UserTags:     map[string]string{"team": "ops"},
+			}
+			store.PutObject(t.Context(), testBucket, "doc.bin", strings.NewReader("abcdefgh"), 8, opts)
+
+			req := httptest.NewRequest(http.MethodPatch, tt.target, strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if data, _ := store.object(testBucket, "doc.bin"); string(data) != tt.want {
+				t.Errorf("content = %q, want %q", data, tt.want)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			info, _ := store.StatObject(t.Context(), testBucket, "doc.bin", minio.StatObjectOptions{})
+			if info.ContentType != "application/x-doc" || info.UserMetadata["Owner"] != "ops" || info.Metadata.Get("Cache-Control") != "max-age=60" {
+				t.Errorf("metadata not preserved: %+v", info)
+			}
+			tags, _ := store.GetObjectTagging(t.Context(), testBucket, "doc.bin", minio.GetObjectTaggingOptions{})
+			if tags.ToMap()["team"] != "ops" {
+				t.Errorf("tags = %v, want team=ops", tags.ToMap())
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// errPrefetchBudget is reported for the object that would exceed the
+// request's byte budget and for every object not yet started when it did.
+var errPrefetchBudget = errors.New("prefetch byte budget exhausted")
+
+// prefetchResult is the per-object outcome reported by /prefetch.
+type prefetchResult struct {
+	Object string `json:"object"`
+	OK     bool   `json:"ok"`
+	Bytes  int64  `json:"bytes"`
+	// Cached means the disk cache already held the object, so nothing was
+	// read or charged against the budget.
+	Cached bool   `json:"cached,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// prefetchHandler reads each requested object end to end so that caches in
+// front of or inside MinIO are warm for the next request. It also doubles as
+// an existence check. Work is bounded by MINIO_PREFETCH_CONCURRENCY parallel
+// fetches and MINIO_PREFETCH_MAX_BYTES total bytes per request; once the
+// budget runs out, the objects not yet started are skipped.
+func (h *MinioHandler) prefetchHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Objects []string `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Objects) == 0 {
+		http.Error(w, `Request body must be JSON like {"objects": ["a.png", "b.png"]}`, http.StatusBadRequest)
+		return
+	}
+
+	var used atomic.Int64
+	var exhausted atomic.Bool
+	results := make([]prefetchResult, len(req.Objects))
+	sem := make(chan struct{}, h.prefetchConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range req.Objects {
+		sem <- struct{}{}
+		if exhausted.Load() {
+			<-sem
+			results[i] = prefetchResult{Object: name, Error: errPrefetchBudget.Error()}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			n, cached, err := h.prefetchObject(r.Context(), name, &used)
+			results[i] = prefetchResult{Object: name, OK: err == nil, Bytes: n, Cached: cached}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			if errors.Is(err, errPrefetchBudget) {
+				exhausted.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, res := range results {
+		if res.OK {
+			succeeded++
+		}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"bytes":     used.Load(),
+	})
+}
+
+// prefetchObject reads objectName fully through the disk cache, charging its
+// size against used, and reports whether the cache already held it, in
+// which case nothing is read.
+func (h *MinioHandler) prefetchObject(ctx context.Context, objectName string, used *atomic.Int64) (int64, bool, error) {
+	info, err := h.statObject(ctx, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return 0, false, err
+	}
+	if f, ok := h.cache.open(objectName, strings.Trim(info.ETag, `"`)); ok {
+		f.Close()
+		return 0, true, nil
+	}
+	if used.Add(info.Size) > h.prefetchMaxBytes {
+		used.Add(-info.Size)
+		return 0, false, errPrefetchBudget
+	}
+	obj, _, err := h.openObject(ctx, objectName)
+	if err != nil {
+		used.Add(-info.Size)
+		return 0, false, err
+	}
+	defer obj.Close()
+	n, err := io.Copy(io.Discard, obj)
+	if err != nil {
+		used.Add(-info.Size)
+		return 0, false, err
+	}
+	return n, false, nil
+}
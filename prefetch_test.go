@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type prefetchReport struct {
+	Results   []prefetchResult `json:"results"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Bytes     int64            `json:"bytes"`
+}
+
+// peakStore records the most GetObject calls it has had in flight at once.
+type peakStore struct {
+	*fakeStore
+	inFlight, peak atomic.Int32
+}
+
+func (s *peakStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for p := s.peak.Load(); n > p && !s.peak.CompareAndSwap(p, n); p = s.peak.Load() {
+	}
+	time.Sleep(10 * time.Millisecond)
+	return s.fakeStore.GetObject(ctx, bucketName, objectName, opts)
+}
+
+func TestPrefetch(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("aaaa"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("bb"), "text/plain")
+
+	var report prefetchReport
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": ["a.txt", "missing.txt", "b.txt"]}`), &report)
+	if report.Succeeded != 2 || report.Failed != 1 || report.Bytes != 6 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Results[0] != (prefetchResult{Object: "a.txt", OK: true, Bytes: 4}) || report.Results[1].OK {
+		t.Errorf("results = %+v", report.Results)
+	}
+}
+
+func TestPrefetchBoundsConcurrency(t *testing.T) {
+	h, fake := newTestHandler(t)
+	store := &peakStore{fakeStore: fake}
+	h.store = store
+	h.prefetchConcurrency = 2
+	var objects []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		fake.put(testBucket, name, []byte(name), "text/plain")
+		objects = append(objects, `"`+name+`"`)
+	}
+
+	var report prefetchReport
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": [`+strings.Join(objects, ",")+`]}`), &report)
+	if report.Succeeded != 6 {
+		t.Errorf("report = %+v", report)
+	}
+	if peak := store.peak.Load(); peak > 2 {
+		t.Errorf("%d fetches at once, want at most 2", peak)
+	}
+}
+
+func TestPrefetchByteBudget(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.prefetchConcurrency = 1
+	h.prefetchMaxBytes = 5
+	store.put(testBucket, "a.txt", []byte("aaa"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("bbb"), "text/plain")
+	store.put(testBucket, "c.txt", []byte("c"), "text/plain")
+
+	var report prefetchReport
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": ["a.txt", "b.txt", "c.txt"]}`), &report)
+	if report.Succeeded != 1 || report.Failed != 2 || report.Bytes != 3 {
+		t.Errorf("report = %+v", report)
+	}
+	// c.txt would still fit, but the run stops at the first object over
+	// the budget.
+	for _, res := range report.Results[1:] {
+		if res.OK || res.Error != errPrefetchBudget.Error() {
+			t.Errorf("%s = %+v, want skipped for the budget", res.Object, res)
+		}
+	}
+}
+
+func TestPrefetchSkipsCachedObjects(t *testing.T) {
+	h, store := newTestHandler(t)
+	cache, err := newDiskCache(t.TempDir(), 1<<10, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.cache = cache
+	h.prefetchMaxBytes = 4
+	store.put(testBucket, "a.txt", []byte("aaaa"), "text/plain")
+
+	var report prefetchReport
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": ["a.txt"]}`), &report)
+	if report.Results[0].Cached || report.Bytes != 4 {
+		t.Fatalf("first prefetch = %+v", report)
+	}
+	// The budget only covers one read; a cached object isn't charged.
+	report = prefetchReport{}
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": ["a.txt", "a.txt"]}`), &report)
+	if report.Succeeded != 2 || report.Bytes != 0 || !report.Results[0].Cached || !report.Results[1].Cached {
+		t.Errorf("prefetch of a cached object = %+v", report)
+	}
+
+	store.put(testBucket, "a.txt", []byte("AAAA"), "text/plain")
+	report = prefetchReport{}
+	decodeJSON(t, postJSON(h, "/prefetch", `{"objects": ["a.txt"]}`), &report)
+	if report.Results[0].Cached || report.Bytes != 4 {
+		t.Errorf("prefetch of a changed object = %+v", report)
+	}
+}
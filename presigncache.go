@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// presignCache reuses presigned URLs while they still have at least margin
+// left before they expire, so pages that request the same links on every
+// render don't pay for re-signing. Concurrent requests for the same link
+// wait for a single signing call. A nil *presignCache signs every time.
+type presignCache struct {
+	maxEntries int
+	margin     time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]presignEntry
+	inflight map[string]*presignCall
+}
+
+type presignEntry struct {
+	url string
+	// reuseUntil is the link's expiry minus the safety margin.
+	reuseUntil time.Time
+}
+
+type presignCall struct {
+	done chan struct{}
+	url  string
+	err  error
+}
+
+// newPresignCache returns a cache of up to maxEntries links, or nil when
+// maxEntries is not positive.
+func newPresignCache(maxEntries int, margin time.Duration) *presignCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &presignCache{
+		maxEntries: maxEntries,
+		margin:     margin,
+		entries:    make(map[string]presignEntry),
+		inflight:   make(map[string]*presignCall),
+	}
+}
+
+// presignKey identifies a link by everything that goes into its signature
+// apart from the signing time.
+func presignKey(method, objectName string, expiry time.Duration, params url.Values) string {
+	return strings.Join([]string{method, objectName, expiry.String(), params.Encode()}, "\x00")
+}
+
+// get returns the cached link for key, or calls sign to create one valid for
+// expiry and caches it.
+func (c *presignCache) get(key string, expiry time.Duration, sign func() (*url.URL, error)) (string, error) {
+	if c == nil || expiry <= c.margin {
+		u, err := sign()
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.reuseUntil) {
+		c.mu.Unlock()
+		return e.url, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.url, call.err
+	}
+	call := &presignCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	signedAt := time.Now()
+	u, err := sign()
+	if err == nil {
+		call.url = u.String()
+	}
+	call.err = err
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if err == nil {
+		c.store(key, presignEntry{url: call.url, reuseUntil: signedAt.Add(expiry - c.margin)})
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.url, call.err
+}
+
+// store adds e, first dropping links past their reuse time and then, if the
+// cache is still full, the one closest to it. Callers hold c.mu.
+func (c *presignCache) store(key string, e presignEntry) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		var oldest string
+		for k, v := range c.entries {
+			if now.After(v.reuseUntil) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldest == "" || v.reuseUntil.Before(c.entries[oldest].reuseUntil) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = e
+}
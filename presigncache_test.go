@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPresignCacheReusesAndCoalesces(t *testing.T) {
+	c := newPresignCache(10, time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	sign := func() (*url.URL, error) {
+		<-release
+		n := calls.Add(1)
+		return url.Parse(fmt.Sprintf("https://minio.example/b/a.jpg?sig=%d", n))
+	}
+
+	var wg sync.WaitGroup
+	got := make([]string, 5)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], _ = c.get("k", 5*time.Minute, sign)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if again, _ := c.get("k", 5*time.Minute, sign); again != got[0] {
+		t.Errorf("cached url = %q, want %q", again, got[0])
+	}
+	for _, u := range got {
+		if u != got[0] {
+			t.Errorf("concurrent callers got %v, want one url", got)
+			break
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("signed %d times, want 1", calls.Load())
+	}
+}
+
+func TestPresignCacheRespectsMarginAndSize(t *testing.T) {
+	c := newPresignCache(2, time.Minute)
+	var calls int
+	sign := func() (*url.URL, error) {
+		calls++
+		return url.Parse(fmt.Sprintf("https://minio.example/?sig=%d", calls))
+	}
+
+	// Links that would expire within the margin are never reused.
+	c.get("short", 30*time.Second, sign)
+	c.get("short", 30*time.Second, sign)
+	if calls != 2 {
+		t.Errorf("short-lived link signed %d times, want 2", calls)
+	}
+
+	calls = 0
+	c.get("a", time.Hour, sign)
+	c.get("b", 2*time.Hour, sign)
+	c.get("c", 3*time.Hour, sign) // evicts "a", the soonest to expire
+	c.get("b", 2*time.Hour, sign)
+	c.get("a", time.Hour, sign)
+	if calls != 4 {
+		t.Errorf("signed %d times, want 4", calls)
+	}
+	if len(c.entries) != 2 {
+		t.Errorf("cache holds %d entries, want 2", len(c.entries))
+	}
+}
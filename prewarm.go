@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// prewarm opens up to conns connections to MinIO at startup by running that
+// many BucketExists calls at once; the transport keeps them idle for the
+// first requests. It doubles as a self-test of DNS, TLS and credentials,
+// returning the first failure.
+func prewarm(ctx context.Context, store ObjectStore, bucketName string, conns int) error {
+	start := time.Now()
+	errs := make([]error, max(conns, 1))
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exists, err := store.BucketExists(ctx, bucketName)
+			if err == nil && !exists {
+				err = fmt.Errorf("bucket '%s' does not exist", bucketName)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	logger(ctx).Info("Pre-warmed MinIO connections", "connections", len(errs), "duration", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+type countingStore struct {
+	ObjectStore
+	calls atomic.Int32
+}
+
+func (c *countingStore) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	c.calls.Add(1)
+	return c.ObjectStore.BucketExists(ctx, bucketName)
+}
+
+func TestPrewarm(t *testing.T) {
+	store := &countingStore{ObjectStore: newFakeStore(testBucket)}
+	if err := prewarm(context.Background(), store, testBucket, 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.calls.Load(); n != 3 {
+		t.Errorf("BucketExists called %d times, want 3", n)
+	}
+	if err := prewarm(context.Background(), store, "missing", 2); err == nil {
+		t.Error("prewarm of a missing bucket succeeded")
+	}
+}
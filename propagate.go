@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// maxPropagatedValue caps a propagated header value; S3 allows only 2 KiB
+// of user metadata per object in total.
+const maxPropagatedValue = 256
+
+// loadPropagatedHeaders reads MINIO_PROPAGATE_HEADERS, the request headers
+// (such as X-Correlation-ID) uploads copy into the stored object's metadata.
+func loadPropagatedHeaders() ([]string, error) {
+	var names []string
+	for _, name := range envList("MINIO_PROPAGATE_HEADERS") {
+		if strings.ContainsAny(name, " :\t") {
+			return nil, fmt.Errorf("%q is not a header name", name)
+		}
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	return names, nil
+}
+
+// applyPropagatedHeaders copies the MINIO_PROPAGATE_HEADERS the request
+// carries into opts as x-amz-meta-<header> metadata. Values must be short
+// printable ASCII, as S3 metadata requires.
+func (h *MinioHandler) applyPropagatedHeaders(r *http.Request, opts *minio.PutObjectOptions) error {
+	for _, name := range h.propagateHeaders {
+		v := r.Header.Get(name)
+		if v == "" {
+			continue
+		}
+		if len(v) > maxPropagatedValue || strings.IndexFunc(v, func(c rune) bool { return c < ' ' || c > '~' }) >= 0 {
+			return fmt.Errorf("%s must be printable ASCII of at most %d bytes", name, maxPropagatedValue)
+		}
+		if opts.UserMetadata == nil {
+			opts.UserMetadata = map[string]string{}
+		}
+		opts.UserMetadata["X-Amz-Meta-"+name] = v
+	}
+	return nil
+}
+
+// setPropagatedHeaders sets the MINIO_PROPAGATE_HEADERS stored with info
+// back on w under their original names. Stat results name metadata without
+// the x-amz-meta- prefix; MinIO listings keep it.
+func (h *MinioHandler) setPropagatedHeaders(w http.ResponseWriter, info minio.ObjectInfo) {
+	if len(h.propagateHeaders) == 0 {
+		return
+	}
+	for k, v := range info.UserMetadata {
+		k = strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-")
+		for _, name := range h.propagateHeaders {
+			if strings.EqualFold(k, name) {
+				w.Header().Set(name, v)
+			}
+		}
+	}
+}
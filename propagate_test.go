@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPropagatedHeaders(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.propagateHeaders = []string{"X-Correlation-Id", "X-Source-System"}
+
+	req := httptest.NewRequest(http.MethodPut, "/raw/traced.txt", strings.NewReader("hello"))
+	req.Header.Set("X-Correlation-ID", "req-123")
+	req.Header.Set("X-Unlisted", "ignored")
+	if rec := serve(h, req); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d (%s)", rec.Code, rec.Body)
+	}
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/stat/traced.txt", nil),
+		httptest.NewRequest(http.MethodGet, "/fetch/traced.txt", nil),
+		httptest.NewRequest(http.MethodHead, "/get-download-link/traced.txt", nil),
+	} {
+		rec := serve(h, r)
+		if got := rec.Header().Get("X-Correlation-Id"); got != "req-123" {
+			t.Errorf("%s %s: X-Correlation-Id = %q, want req-123", r.Method, r.URL.Path, got)
+		}
+		if rec.Header().Get("X-Source-System") != "" || rec.Header().Get("X-Unlisted") != "" {
+			t.Errorf("%s %s: unexpected headers %v", r.Method, r.URL.Path, rec.Header())
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/raw/bad.txt", strings.NewReader("hello"))
+	req.Header.Set("X-Source-System", "café")
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("non-ASCII value: status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// presigner is the part of ObjectStore that produces links handed to
+// clients.
+type presigner interface {
+	PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error)
+	PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error)
+	PresignedPostPolicy(ctx context.Context, policy *minio.PostPolicy) (*url.URL, map[string]string, error)
+	EndpointURL() *url.URL
+}
+
+// links returns the presigner for client-facing URLs: the public endpoint
+// client when MINIO_PUBLIC_ENDPOINT is set, otherwise the store itself.
+func (h *MinioHandler) links() presigner {
+	if h.publicLinks != nil {
+		return h.publicLinks
+	}
+	return h.store
+}
+
+// newPublicPresigner returns a client for the public endpoint, used only to
+// sign links. SigV4 signs the Host header, so rewriting the host of a URL
+// signed for the internal endpoint would invalidate it; signing against the
+// public host instead keeps the links valid. The region is fixed so that
+// signing never makes a network call to the public endpoint.
+//
+// endpoint is a host[:port], or a URL whose scheme picks TLS.
+func newPublicPresigner(endpoint string, creds *credentials.Credentials, region string, useSSL bool) (presigner, error) {
+	if scheme, host, ok := strings.Cut(endpoint, "://"); ok {
+		switch scheme {
+		case "https":
+			useSSL = true
+		case "http":
+			useSSL = false
+		default:
+			return nil, fmt.Errorf("unsupported scheme %q", scheme)
+		}
+		endpoint = strings.TrimSuffix(host, "/")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       useSSL,
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newMinioStore(client), nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func TestPublicEndpointLinks(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "pic.jpg", []byte("jpeg"), "image/jpeg")
+	// An unresolvable host: signing must not need the network.
+	public, err := newPublicPresigner("https://files.example.invalid", credentials.NewStaticV4("AKIDEXAMPLE", "secret", ""), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.publicLinks = public
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/pic.jpg", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]string
+	decodeJSON(t, rec, &got)
+	u, err := url.Parse(got["url"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "https" || u.Host != "files.example.invalid" || u.Path != "/"+testBucket+"/pic.jpg" {
+		t.Errorf("url = %s, want https://files.example.invalid/%s/pic.jpg", u, testBucket)
+	}
+	q := u.Query()
+	if !strings.Contains(q.Get("X-Amz-Credential"), "/us-east-1/s3/") || q.Get("X-Amz-Signature") == "" {
+		t.Errorf("url is not SigV4-signed for us-east-1: %s", u)
+	}
+}
+
+func TestNewPublicPresignerRejectsBadScheme(t *testing.T) {
+	if _, err := newPublicPresigner("ftp://files.example.com", credentials.NewStaticV4("a", "b", ""), "", true); err == nil {
+		t.Error("ftp scheme accepted")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prefixQuota is a soft storage limit for the objects under a prefix.
+type prefixQuota struct {
+	Prefix string
+	Limit  int64
+}
+
+// quotaRules is ordered longest prefix first so the most specific quota
+// applies.
+type quotaRules []prefixQuota
+
+// loadQuotaRules parses MINIO_PREFIX_QUOTAS, a comma-separated list of
+// prefix=size pairs such as "tenants/acme/=10GiB,tenants/beta/=500MiB".
+func loadQuotaRules() (quotaRules, error) {
+	var rules quotaRules
+	for _, item := range envList("MINIO_PREFIX_QUOTAS") {
+		prefix, size, ok := strings.Cut(item, "=")
+		limit, err := parseByteSize(size)
+		if !ok || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("MINIO_PREFIX_QUOTAS: %q must be prefix=size, e.g. tenants/acme/=10GiB", item)
+		}
+		rules = append(rules, prefixQuota{Prefix: strings.TrimSpace(prefix), Limit: limit})
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	return rules, nil
+}
+
+// forKey returns the quota that applies to objectName, if any.
+func (q quotaRules) forKey(objectName string) (prefixQuota, bool) {
+	for _, rule := range q {
+		if strings.HasPrefix(objectName, rule.Prefix) {
+			return rule, true
+		}
+	}
+	return prefixQuota{}, false
+}
+
+// quotaStatus is the quota section reported with an upload.
+type quotaStatus struct {
+	Prefix  string  `json:"prefix"`
+	Used    int64   `json:"used"`
+	Limit   int64   `json:"limit"`
+	Percent float64 `json:"percent"`
+	Warning bool    `json:"warning"`
+}
+
+// quotaAfterUpload accounts for a stored object of size bytes and reports
+// the usage of its quota prefix from the usage cache, setting X-Quota-*
+// headers and, past MINIO_QUOTA_WARN_PERCENT, a Warning header. Quotas are
+// soft: nothing is blocked. It returns nil when no quota applies or the
+// prefix's usage hasn't been computed yet.
+func (h *MinioHandler) quotaAfterUpload(w http.ResponseWriter, objectName string, size int64) *quotaStatus {
+	if h.usage == nil {
+		return nil
+	}
+	h.usage.added(objectName, size)
+	quota, ok := h.quotas.forKey(objectName)
+	if !ok {
+		return nil
+	}
+	usage, ok := h.cachedUsage(quota.Prefix)
+	if !ok {
+		return nil
+	}
+	s := &quotaStatus{
+		Prefix:  quota.Prefix,
+		Used:    usage.Bytes,
+		Limit:   quota.Limit,
+		Percent: math.Round(float64(usage.Bytes)/float64(quota.Limit)*1000) / 10,
+	}
+	s.Warning = s.Percent >= h.quotaWarnPercent
+	w.Header().Set("X-Quota-Used", strconv.FormatInt(s.Used, 10))
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(s.Limit, 10))
+	w.Header().Set("X-Quota-Percent", strconv.FormatFloat(s.Percent, 'f', 1, 64))
+	if s.Warning {
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "Storage quota for '%s' is %.1f%% used"`, s.Prefix, s.Percent))
+	}
+	return s
+}
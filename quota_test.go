@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{"1024": 1024, "10GiB": 10 << 30, "5 MB": 5e6, "2kib": 2048} {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "ten", "-1", "1.5GiB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded", in)
+		}
+	}
+}
+
+func TestLoadQuotaRules(t *testing.T) {
+	t.Setenv("MINIO_PREFIX_QUOTAS", "tenants/=1GiB, tenants/acme/=100")
+	rules, err := loadQuotaRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, ok := rules.forKey("tenants/acme/a.txt"); !ok || q.Limit != 100 {
+		t.Errorf("acme quota = %+v, %v", q, ok)
+	}
+	if q, ok := rules.forKey("tenants/beta/a.txt"); !ok || q.Limit != 1<<30 {
+		t.Errorf("beta quota = %+v, %v", q, ok)
+	}
+	if _, ok := rules.forKey("other/a.txt"); ok {
+		t.Error("unexpected quota for other/")
+	}
+
+	t.Setenv("MINIO_PREFIX_QUOTAS", "tenants/")
+	if _, err := loadQuotaRules(); err == nil {
+		t.Error("missing size accepted")
+	}
+}
+
+func TestRawUploadQuotaWarning(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.usage = newUsageCache(time.Hour)
+	h.quotas = quotaRules{{Prefix: "tenants/acme/", Limit: 100}}
+	h.quotaWarnPercent = 90
+	store.put(testBucket, "tenants/acme/old.bin", make([]byte, 80), "application/octet-stream")
+	if _, err := h.computeUsage(context.Background(), "tenants/acme/"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/tenants/acme/new.bin", strings.NewReader("0123456789")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got rawUploadResponse
+	decodeJSON(t, rec, &got)
+	if got.Quota == nil || got.Quota.Used != 90 || got.Quota.Percent != 90 || !got.Quota.Warning {
+		t.Errorf("quota = %+v", got.Quota)
+	}
+	if rec.Header().Get("X-Quota-Used") != "90" || !strings.HasPrefix(rec.Header().Get("Warning"), "299 ") {
+		t.Errorf("headers = %v", rec.Header())
+	}
+
+	// Objects outside any quota prefix get no quota section.
+	rec = serve(h, httptest.NewRequest(http.MethodPut, "/raw/public/x.bin", strings.NewReader("x")))
+	var other rawUploadResponse
+	decodeJSON(t, rec, &other)
+	if other.Quota != nil || rec.Header().Get("X-Quota-Used") != "" {
+		t.Errorf("unexpected quota %+v", other.Quota)
+	}
+}
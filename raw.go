@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type rawUploadResponse struct {
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ETag        string `json:"etag"`
+	ContentType string `json:"content_type"`
+	VersionID   string `json:"version_id,omitempty"`
+	// Checksums are keyed by x-amz-checksum-* header name.
+	Checksums map[string]string `json:"checksums,omitempty"`
+	PublicURL string            `json:"public_url,omitempty"`
+	Quota     *quotaStatus      `json:"quota,omitempty"`
+}
+
+// rawUploadHandler stores the request body as-is under /raw/{objectName},
+// for clients that can't build multipart forms.
+func (h *MinioHandler) rawUploadHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/raw/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /raw/sensor-42.bin)", http.StatusBadRequest)
+		return
+	}
+	h.storeRawBody(w, r, objectName)
+}
+
+// uploadBodyHandler is PUT /upload/{objectName}, the raw-body counterpart of
+// the multipart POST /upload, so that `curl -T file` works as is.
+func (h *MinioHandler) uploadBodyHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/upload/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /upload/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	h.storeRawBody(w, r, objectName)
+}
+
+// storeRawBody streams the request body straight into PutObject with the
+// request's Content-Type; without a Content-Length (a chunked body) the SDK
+// uploads it in parts as it arrives. Bodies over MINIO_RAW_UPLOAD_MAX_BYTES
+// are refused with 413.
+func (h *MinioHandler) storeRawBody(w http.ResponseWriter, r *http.Request, objectName string) {
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	tooLarge := "Upload exceeds the limit of " + strconv.FormatInt(h.rawMaxBytes, 10) + " bytes"
+	if r.ContentLength > h.rawMaxBytes {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	opts, public := h.uploadOptions(objectName, contentType)
+	if err := h.applyUploadParams(r, &opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, h.rawMaxBytes)
+	// A disconnect surfaces as a failed body read, which ends the upload;
+	// the context stays live so the SDK can abort a multipart upload
+	// instead of leaving its parts behind. It ends with the shutdown grace
+	// period (see lifecycle.go).
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	info, err := h.store.PutObject(ctx, h.bucketName, objectName, body, r.ContentLength, opts)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if clientDisconnected(r, err) {
+			h.uploadAbandoned(w, r, err)
+			return
+		}
+		logger(r.Context()).Error("Error uploading raw body to MinIO", "err", err)
+		h.storeFailed(w, "Failed to upload file", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+
+	resp := rawUploadResponse{
+		Bucket:      h.bucketName,
+		Key:         objectName,
+		Size:        info.Size,
+		ETag:        info.ETag,
+		ContentType: contentType,
+		VersionID:   info.VersionID,
+		Checksums:   uploadChecksums(info),
+		Quota:       h.quotaAfterUpload(w, objectName, info.Size),
+	}
+	if public {
+		resp.PublicURL = publicObjectURL(h.links().EndpointURL(), h.bucketName, objectName)
+		w.Header().Set("X-Public-URL", resp.PublicURL)
+	}
+	writeJSON(w, r, http.StatusCreated, resp)
+}
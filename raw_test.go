@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRawUploadHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPut, "/raw/sensors/42.bin", strings.NewReader("reading"))
+	req.Header.Set("Content-Type", "application/x-sensor")
+	rec := serve(h, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var got rawUploadResponse
+	decodeJSON(t, rec, &got)
+	if got.Key != "sensors/42.bin" || got.Size != 7 || got.ContentType != "application/x-sensor" || got.ETag == "" {
+		t.Errorf("response = %+v", got)
+	}
+	if data, _ := store.object(testBucket, "sensors/42.bin"); string(data) != "reading" {
+		t.Errorf("stored %q, want %q", data, "reading")
+	}
+}
+
+func TestUploadBodyHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+
+	// curl -T sends no Content-Type; a chunked body has no length either.
+	req := httptest.NewRequest(http.MethodPut, "/upload/docs/notes.txt", strings.NewReader("chunked body"))
+	req.ContentLength = -1
+	rec := serve(h, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var got rawUploadResponse
+	decodeJSON(t, rec, &got)
+	if got.Key != "docs/notes.txt" || got.ContentType != "application/octet-stream" {
+		t.Errorf("response = %+v", got)
+	}
+	if data, _ := store.object(testBucket, "docs/notes.txt"); string(data) != "chunked body" {
+		t.Errorf("stored %q, want %q", data, "chunked body")
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/upload/", strings.NewReader("x"))); rec.Code != http.StatusBadRequest {
+		t.Errorf("no object name: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRawUploadHandlerTooLarge(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.rawMaxBytes = 4
+
+	// Declared length over the limit is refused before reading.
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/big.bin", strings.NewReader("too large")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", rec.Code)
+	}
+
+	// So is a streamed body without a length.
+	req := httptest.NewRequest(http.MethodPut, "/raw/big.bin", strings.NewReader("too large"))
+	req.ContentLength = -1
+	if rec := serve(h, req); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("streamed status = %d, want 413", rec.Code)
+	}
+	if _, ok := store.object(testBucket, "big.bin"); ok {
+		t.Error("oversized body was stored")
+	}
+}
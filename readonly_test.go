@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyModeBlocksWrites(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "doc.txt", []byte("x"), "text/plain")
+	h.setReadOnly(true)
+	defer h.setReadOnly(false)
+
+	writes := []*http.Request{
+		newUploadRequest(t, http.MethodPost, "/upload", "new.txt", "x"),
+		newUploadRequest(t, http.MethodPut, "/modify/doc.txt", "doc.txt", "y"),
+		httptest.NewRequest(http.MethodDelete, "/delete/doc.txt", nil),
+		httptest.NewRequest(http.MethodPost, "/organize", strings.NewReader(`{"objects": ["doc.txt"], "dest_prefix": "a/"}`)),
+		httptest.NewRequest(http.MethodPost, "/copy-jobs", strings.NewReader(`{"source": "doc.txt", "destination": "b.txt"}`)),
+		httptest.NewRequest(http.MethodPost, "/manifest", nil),
+	}
+	for _, req := range writes {
+		if rec := serve(h, req); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s status = %d, want 503", req.Method, req.URL, rec.Code)
+		}
+	}
+	if data, _ := store.object(testBucket, "doc.txt"); string(data) != "x" {
+		t.Errorf("doc.txt changed to %q in read-only mode", data)
+	}
+
+	reads := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/list", nil),
+		httptest.NewRequest(http.MethodGet, "/get-download-link/doc.txt", nil),
+		httptest.NewRequest(http.MethodGet, "/datauri/doc.txt", nil),
+		httptest.NewRequest(http.MethodPost, "/manifest?inline=true", nil),
+	}
+	for _, req := range reads {
+		if rec := serve(h, req); rec.Code != http.StatusOK {
+			t.Errorf("%s %s status = %d, want 200", req.Method, req.URL, rec.Code)
+		}
+	}
+}
+
+func TestReadOnlyToggleAndHealthz(t *testing.T) {
+	h, _ := newTestHandler(t)
+	defer h.setReadOnly(false)
+
+	rec := serve(h, httptest.NewRequest(http.MethodPut, "/admin/read-only", strings.NewReader(`{"enabled": true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("toggle status = %d (%s)", rec.Code, rec.Body)
+	}
+	var health map[string]any
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/healthz", nil)), &health)
+	if health["read_only"] != true {
+		t.Errorf("healthz = %v, want read_only true", health)
+	}
+	if readOnlyGauge.Value() != 1 {
+		t.Errorf("read_only gauge = %d, want 1", readOnlyGauge.Value())
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/admin/read-only", strings.NewReader(`{}`))); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty body status = %d, want 400", rec.Code)
+	}
+}
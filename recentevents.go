@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+// recentEventsRetry is how long the recorder waits before resubscribing
+// after the notification stream fails.
+const recentEventsRetry = 5 * time.Second
+
+type recentEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Key   string    `json:"key"`
+	Size  int64     `json:"size,omitempty"`
+	ETag  string    `json:"etag,omitempty"`
+}
+
+// eventRing keeps the last len(buf) events, overwriting the oldest.
+type eventRing struct {
+	mu   sync.Mutex
+	buf  []recentEvent
+	next int
+	full bool
+}
+
+func newEventRing(size int) *eventRing {
+	if size <= 0 {
+		return nil
+	}
+	return &eventRing{buf: make([]recentEvent, size)}
+}
+
+func (r *eventRing) add(e recentEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// recent returns up to limit events, newest first, whose name matches the
+// eventType pattern (e.g. "s3:ObjectRemoved:*") and whose key starts with
+// prefix. Empty filters match everything.
+func (r *eventRing) recent(limit int, eventType, prefix string) []recentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.buf)
+	}
+	events := []recentEvent{}
+	for i := 1; i <= n && len(events) < limit; i++ {
+		e := r.buf[(r.next-i+len(r.buf))%len(r.buf)]
+		if eventType != "" {
+			if ok, _ := path.Match(eventType, e.Event); !ok {
+				continue
+			}
+		}
+		if strings.HasPrefix(e.Key, prefix) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// recordEvents subscribes to the bucket's object events and keeps them in
+// h.recent until ctx is done, resubscribing whenever the stream fails so
+// the history survives MinIO restarts.
+func (h *MinioHandler) recordEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		for info := range h.store.ListenBucketNotification(ctx, h.bucketName, "", "", []string{
+			"s3:ObjectCreated:*",
+			"s3:ObjectRemoved:*",
+		}) {
+			if info.Err != nil {
+				logger(ctx).Error("Error in recent events subscription", "err", info.Err)
+				break
+			}
+			for _, rec := range info.Records {
+				h.recent.add(recentEventOf(rec))
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(recentEventsRetry):
+		}
+	}
+}
+
+func recentEventOf(rec notification.Event) recentEvent {
+	e := recentEvent{
+		Event: rec.EventName,
+		Key:   rec.S3.Object.Key,
+		Size:  rec.S3.Object.Size,
+		ETag:  rec.S3.Object.ETag,
+	}
+	// Keys arrive URL-encoded, as in S3 event notifications.
+	if key, err := url.QueryUnescape(e.Key); err == nil {
+		e.Key = key
+	}
+	e.Time, _ = time.Parse(time.RFC3339Nano, rec.EventTime)
+	return e
+}
+
+// recentEventsHandler returns the latest bucket events, newest first, even
+// if no /watch client was connected when they happened. ?limit= caps the
+// count (default 100), ?type= filters by event name pattern and ?prefix= by
+// key.
+func (h *MinioHandler) recentEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.recent == nil {
+		http.Error(w, "Recent events are disabled (MINIO_RECENT_EVENTS=0)", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	eventType := q.Get("type")
+	if _, err := path.Match(eventType, ""); err != nil {
+		http.Error(w, "type must be an event name or pattern such as s3:ObjectRemoved:*", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string][]recentEvent{"events": h.recent.recent(limit, eventType, q.Get("prefix"))})
+}
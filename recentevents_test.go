@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+func objectEvent(name, key string) notification.Event {
+	e := notification.Event{EventName: name, EventTime: "2024-05-01T12:00:00.000Z"}
+	e.S3.Object.Key = key
+	return e
+}
+
+func TestEventRingKeepsNewest(t *testing.T) {
+	r := newEventRing(3)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		r.add(recentEvent{Event: "s3:ObjectCreated:Put", Key: key})
+	}
+	got := r.recent(10, "", "")
+	if len(got) != 3 || got[0].Key != "d" || got[2].Key != "b" {
+		t.Errorf("recent = %+v, want d, c, b", got)
+	}
+	if got := r.recent(1, "", ""); len(got) != 1 || got[0].Key != "d" {
+		t.Errorf("limit 1 = %+v", got)
+	}
+}
+
+func TestRecentEventsHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.recent = newEventRing(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.recordEvents(ctx)
+
+	store.events <- notification.Info{Records: []notification.Event{
+		objectEvent("s3:ObjectCreated:Put", "logs/a%20b.txt"),
+		objectEvent("s3:ObjectRemoved:Delete", "logs/old.txt"),
+		objectEvent("s3:ObjectCreated:Put", "img/x.png"),
+	}}
+	var got struct {
+		Events []recentEvent `json:"events"`
+	}
+	for deadline := time.Now().Add(2 * time.Second); len(got.Events) < 3 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/recent-events", nil)), &got)
+	}
+	if len(got.Events) != 3 || got.Events[0].Key != "img/x.png" || got.Events[2].Key != "logs/a b.txt" {
+		t.Fatalf("events = %+v", got.Events)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/recent-events?type=s3:ObjectCreated:*&prefix=logs/", nil)), &got)
+	if len(got.Events) != 1 || got.Events[0].Key != "logs/a b.txt" {
+		t.Errorf("filtered = %+v", got.Events)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/recent-events?limit=0", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d, want 400", rec.Code)
+	}
+}
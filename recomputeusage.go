@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// recomputeProgress is the progress payload of a recompute-usage job.
+// Prefixes lists the usage totals being recomputed: the requested prefix,
+// and every quota prefix and cached prefix under it.
+type recomputeProgress struct {
+	Prefix   string   `json:"prefix"`
+	Prefixes []string `json:"prefixes"`
+	Listed   int64    `json:"listed"`
+	Bytes    int64    `json:"bytes"`
+}
+
+// recomputeUsageHandler starts a background job that lists everything under
+// ?prefix= (the whole bucket when empty) and replaces the cached usage
+// totals that quotas and /usage-tree read, fixing drift from uploads and
+// deletes made around the service. Cached totals of prefixes containing
+// ?prefix= can't be fixed from that listing and are marked stale instead,
+// so they are recomputed in the background on their next read.
+func (h *MinioHandler) recomputeUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if h.usage == nil {
+		http.Error(w, "Usage tracking is disabled", http.StatusNotFound)
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	progress := recomputeProgress{Prefix: prefix, Prefixes: []string{prefix}}
+	for _, p := range h.usage.prefixes() {
+		switch {
+		case strings.HasPrefix(p, prefix):
+			progress.Prefixes = append(progress.Prefixes, p)
+		case strings.HasPrefix(prefix, p):
+			h.usage.expire(p)
+		}
+	}
+	for _, quota := range h.quotas {
+		if strings.HasPrefix(quota.Prefix, prefix) {
+			progress.Prefixes = append(progress.Prefixes, quota.Prefix)
+		}
+	}
+	slices.Sort(progress.Prefixes)
+	progress.Prefixes = slices.Compact(progress.Prefixes)
+
+	j := h.jobs.start("recompute-usage", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.recomputeUsage(context.Background(), j, progress)
+		if err != nil {
+			logger(r.Context()).Error("Usage recompute job failed", "job", id, "prefix", prefix, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// recomputeUsage lists p.Prefix once, totalling every prefix in p.Prefixes
+// and rolling up p.Prefix for /usage-tree, then stores the results. Nothing
+// is stored when the listing fails.
+func (h *MinioHandler) recomputeUsage(ctx context.Context, j *job, p recomputeProgress) error {
+	totals := make([]prefixUsage, len(p.Prefixes))
+	tree := newUsageTreeBuilder(usageTreePrefix(p.Prefix))
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: p.Prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("listing '%s': %w", p.Prefix, object.Err)
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		for i, prefix := range p.Prefixes {
+			if strings.HasPrefix(object.Key, prefix) {
+				totals[i].Objects++
+				totals[i].Bytes += object.Size
+			}
+		}
+		tree.add(object.Key, object.Size)
+		p.Listed++
+		p.Bytes += object.Size
+		if p.Listed%1000 == 0 {
+			j.setProgress(p)
+		}
+	}
+	j.setProgress(p)
+
+	result := tree.result()
+	for i, prefix := range p.Prefixes {
+		totals[i].ComputedAt = result.ComputedAt
+		h.usage.set(prefix, totals[i])
+	}
+	h.usageTrees.invalidate(p.Prefix)
+	h.usageTrees.set(result)
+	return nil
+}
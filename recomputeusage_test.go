@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecomputeUsage(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.usage = newUsageCache(time.Hour)
+	h.usageTrees = newUsageTreeCache(time.Hour)
+	h.quotas = quotaRules{{Prefix: "tenants/beta/", Limit: 1 << 20}}
+	store.put(testBucket, "tenants/acme/a.bin", make([]byte, 10), "application/octet-stream")
+	store.put(testBucket, "tenants/acme/docs/b.bin", make([]byte, 5), "application/octet-stream")
+	store.put(testBucket, "tenants/beta/c.bin", make([]byte, 7), "application/octet-stream")
+	store.put(testBucket, "other/d.bin", make([]byte, 1), "application/octet-stream")
+
+	// Drifted totals, as left by deletes made straight against MinIO.
+	now := time.Now()
+	h.usage.set("tenants/acme/", prefixUsage{Objects: 40, Bytes: 4000, ComputedAt: now})
+	h.usage.set("", prefixUsage{Objects: 50, Bytes: 5000, ComputedAt: now})
+	h.usage.set("other/", prefixUsage{Objects: 9, Bytes: 900, ComputedAt: now})
+	h.usageTrees.set(usageTree{Prefix: "tenants/acme/", Objects: 40, ComputedAt: now})
+
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/recompute-usage?prefix=tenants/", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+	var status jobStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil)), &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+
+	for prefix, want := range map[string]prefixUsage{
+		"tenants/":      {Objects: 3, Bytes: 22},
+		"tenants/acme/": {Objects: 2, Bytes: 15},
+		"tenants/beta/": {Objects: 1, Bytes: 7},
+		"other/":        {Objects: 9, Bytes: 900},
+	} {
+		got, _ := h.usage.get(prefix)
+		if got.Objects != want.Objects || got.Bytes != want.Bytes {
+			t.Errorf("usage of %q = %d objects, %d bytes; want %d, %d", prefix, got.Objects, got.Bytes, want.Objects, want.Bytes)
+		}
+	}
+	if whole, _ := h.usage.get(""); !whole.ComputedAt.IsZero() {
+		t.Error("usage of the whole bucket was not marked stale")
+	}
+	if _, ok := h.usageTrees.get("tenants/acme/"); ok {
+		t.Error("stale usage tree of tenants/acme/ was kept")
+	}
+	if tree, ok := h.usageTrees.get("tenants/"); !ok || tree.Objects != 3 || len(tree.Children) != 2 {
+		t.Errorf("usage tree of tenants/ = %+v, %v", tree, ok)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/minio/minio-go/v7/pkg/replication"
+	"github.com/minio/minio-go/v7/pkg/sse"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// regionStore wraps the ObjectStore for a single endpoint and follows the
+// bucket to its real region. When a call fails because it was signed for the
+// wrong region, the correct one is read from the error, a store for it is
+// created with newStore, and the call is retried once. Later calls use the
+// new store directly.
+//
+// Errors that surface lazily (reads from GetObject, bucket notifications,
+// bulk removals) are not retried; in practice the bucket checks at startup
+// detect the region before any of those run.
+type regionStore struct {
+	newStore func(region string) (ObjectStore, error)
+
+	mu     sync.RWMutex
+	region string
+	store  ObjectStore
+}
+
+var _ ObjectStore = (*regionStore)(nil)
+
+// newRegionStore creates the store for region, which may be empty to let the
+// SDK guess it from the endpoint.
+func newRegionStore(region string, newStore func(region string) (ObjectStore, error)) (*regionStore, error) {
+	store, err := newStore(region)
+	if err != nil {
+		return nil, err
+	}
+	return &regionStore{newStore: newStore, region: region, store: store}, nil
+}
+
+// currentRegion returns the region in use, "" if it was never set or
+// detected.
+func (s *regionStore) currentRegion() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.region
+}
+
+func (s *regionStore) current() ObjectStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// correctRegion returns the region named by a wrong-region error, or "" if
+// err is not one.
+func correctRegion(err error) string {
+	if err == nil {
+		return ""
+	}
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "PermanentRedirect", "AuthorizationHeaderMalformed", "InvalidRegion", "IllegalLocationConstraintException":
+		return resp.Region
+	}
+	return ""
+}
+
+// follow switches to region after a wrong-region error and reports whether
+// the failed call should be retried.
+func (s *regionStore) follow(region string) bool {
+	if region == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if region == s.region {
+		// Another call already switched.
+		return true
+	}
+	store, err := s.newStore(region)
+	if err != nil {
+		slog.Error("Error creating client for region", "region", region, "err", err)
+		return false
+	}
+	slog.Info("Bucket moved region; using it from now on", "region", region, "was", s.region)
+	s.region, s.store = region, store
+	return true
+}
+
+// withRegion runs call, retrying it once against the correct region if it
+// failed with a wrong-region error.
+func withRegion[T any](s *regionStore, call func(ObjectStore) (T, error)) (T, error) {
+	v, err := call(s.current())
+	if s.follow(correctRegion(err)) {
+		return call(s.current())
+	}
+	return v, err
+}
+
+func (s *regionStore) MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		opts := opts
+		if opts.Region == "" {
+			s.mu.RLock()
+			opts.Region = s.region
+			s.mu.RUnlock()
+		}
+		return struct{}{}, o.MakeBucket(ctx, bucketName, opts)
+	})
+	return err
+}
+
+func (s *regionStore) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	return withRegion(s, func(o ObjectStore) (bool, error) { return o.BucketExists(ctx, bucketName) })
+}
+
+func (s *regionStore) GetBucketPolicy(ctx context.Context, bucketName string) (string, error) {
+	return withRegion(s, func(o ObjectStore) (string, error) { return o.GetBucketPolicy(ctx, bucketName) })
+}
+
+func (s *regionStore) SetBucketPolicy(ctx context.Context, bucketName, policy string) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.SetBucketPolicy(ctx, bucketName, policy)
+	})
+	return err
+}
+
+func (s *regionStore) GetBucketEncryption(ctx context.Context, bucketName string) (*sse.Configuration, error) {
+	return withRegion(s, func(o ObjectStore) (*sse.Configuration, error) { return o.GetBucketEncryption(ctx, bucketName) })
+}
+
+func (s *regionStore) SetBucketEncryption(ctx context.Context, bucketName string, config *sse.Configuration) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.SetBucketEncryption(ctx, bucketName, config)
+	})
+	return err
+}
+
+func (s *regionStore) GetBucketReplication(ctx context.Context, bucketName string) (replication.Config, error) {
+	return withRegion(s, func(o ObjectStore) (replication.Config, error) { return o.GetBucketReplication(ctx, bucketName) })
+}
+
+func (s *regionStore) GetBucketVersioning(ctx context.Context, bucketName string) (minio.BucketVersioningConfiguration, error) {
+	return withRegion(s, func(o ObjectStore) (minio.BucketVersioningConfiguration, error) {
+		return o.GetBucketVersioning(ctx, bucketName)
+	})
+}
+
+func (s *regionStore) SetBucketVersioning(ctx context.Context, bucketName string, config minio.BucketVersioningConfiguration) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.SetBucketVersioning(ctx, bucketName, config)
+	})
+	return err
+}
+
+func (s *regionStore) SetBucketReplication(ctx context.Context, bucketName string, cfg replication.Config) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.SetBucketReplication(ctx, bucketName, cfg)
+	})
+	return err
+}
+
+// PutObject only retries when the body can be rewound.
+func (s *regionStore) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	info, err := s.current().PutObject(ctx, bucketName, objectName, reader, objectSize, opts)
+	seeker, ok := reader.(io.Seeker)
+	if !ok || !s.follow(correctRegion(err)) {
+		return info, err
+	}
+	if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+		return info, err
+	}
+	return s.current().PutObject(ctx, bucketName, objectName, reader, objectSize, opts)
+}
+
+func (s *regionStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	return s.current().GetObject(ctx, bucketName, objectName, opts)
+}
+
+func (s *regionStore) StatObject(ctx context.Context, bucketName, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	return withRegion(s, func(o ObjectStore) (minio.ObjectInfo, error) {
+		return o.StatObject(ctx, bucketName, objectName, opts)
+	})
+}
+
+func (s *regionStore) GetObjectTagging(ctx context.Context, bucketName, objectName string, opts minio.GetObjectTaggingOptions) (*tags.Tags, error) {
+	return withRegion(s, func(o ObjectStore) (*tags.Tags, error) {
+		return o.GetObjectTagging(ctx, bucketName, objectName, opts)
+	})
+}
+
+func (s *regionStore) PutObjectTagging(ctx context.Context, bucketName, objectName string, otags *tags.Tags, opts minio.PutObjectTaggingOptions) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.PutObjectTagging(ctx, bucketName, objectName, otags, opts)
+	})
+	return err
+}
+
+func (s *regionStore) CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	return withRegion(s, func(o ObjectStore) (minio.UploadInfo, error) { return o.CopyObject(ctx, dst, src) })
+}
+
+func (s *regionStore) RemoveObject(ctx context.Context, bucketName, objectName string, opts minio.RemoveObjectOptions) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.RemoveObject(ctx, bucketName, objectName, opts)
+	})
+	return err
+}
+
+func (s *regionStore) RemoveObjects(ctx context.Context, bucketName string, objectsCh <-chan minio.ObjectInfo, opts minio.RemoveObjectsOptions) <-chan minio.RemoveObjectError {
+	return s.current().RemoveObjects(ctx, bucketName, objectsCh, opts)
+}
+
+// ListObjects retries when the first result is a wrong-region error, and
+// otherwise relays the listing unchanged.
+func (s *regionStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	in := s.current().ListObjects(ctx, bucketName, opts)
+	first, ok := <-in
+	if ok && s.follow(correctRegion(first.Err)) {
+		return s.current().ListObjects(ctx, bucketName, opts)
+	}
+	out := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(out)
+		for ; ok; first, ok = <-in {
+			select {
+			case out <- first:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *regionStore) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error) {
+	return withRegion(s, func(o ObjectStore) (*url.URL, error) {
+		return o.PresignedGetObject(ctx, bucketName, objectName, expires, reqParams)
+	})
+}
+
+func (s *regionStore) PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error) {
+	return withRegion(s, func(o ObjectStore) (*url.URL, error) {
+		return o.PresignHeader(ctx, method, bucketName, objectName, expires, reqParams, extraHeaders)
+	})
+}
+
+func (s *regionStore) PresignedPostPolicy(ctx context.Context, policy *minio.PostPolicy) (*url.URL, map[string]string, error) {
+	return s.current().PresignedPostPolicy(ctx, policy)
+}
+
+func (s *regionStore) ListenBucketNotification(ctx context.Context, bucketName, prefix, suffix string, events []string) <-chan notification.Info {
+	return s.current().ListenBucketNotification(ctx, bucketName, prefix, suffix, events)
+}
+
+func (s *regionStore) EndpointURL() *url.URL {
+	return s.current().EndpointURL()
+}
+
+func (s *regionStore) NewMultipartUpload(ctx context.Context, bucket, object string, opts minio.PutObjectOptions) (string, error) {
+	return withRegion(s, func(o ObjectStore) (string, error) { return o.NewMultipartUpload(ctx, bucket, object, opts) })
+}
+
+func (s *regionStore) CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string, partID int, startOffset, length int64, metadata map[string]string) (minio.CompletePart, error) {
+	return withRegion(s, func(o ObjectStore) (minio.CompletePart, error) {
+		return o.CopyObjectPart(ctx, srcBucket, srcObject, destBucket, destObject, uploadID, partID, startOffset, length, metadata)
+	})
+}
+
+// PutObjectPart retries in the correct region like PutObject, when data
+// can be rewound.
+func (s *regionStore) PutObjectPart(ctx context.Context, bucket, object, uploadID string, partID int, data io.Reader, size int64, opts minio.PutObjectPartOptions) (minio.ObjectPart, error) {
+	part, err := s.current().PutObjectPart(ctx, bucket, object, uploadID, partID, data, size, opts)
+	seeker, ok := data.(io.Seeker)
+	if !ok || !s.follow(correctRegion(err)) {
+		return part, err
+	}
+	if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+		return part, err
+	}
+	return s.current().PutObjectPart(ctx, bucket, object, uploadID, partID, data, size, opts)
+}
+
+func (s *regionStore) CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return withRegion(s, func(o ObjectStore) (minio.UploadInfo, error) {
+		return o.CompleteMultipartUpload(ctx, bucket, object, uploadID, parts, opts)
+	})
+}
+
+func (s *regionStore) AbortMultipartUpload(ctx context.Context, bucket, object, uploadID string) error {
+	_, err := withRegion(s, func(o ObjectStore) (struct{}, error) {
+		return struct{}{}, o.AbortMultipartUpload(ctx, bucket, object, uploadID)
+	})
+	return err
+}
+
+func (s *regionStore) ListObjectParts(ctx context.Context, bucket, object, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	return withRegion(s, func(o ObjectStore) (minio.ListObjectPartsResult, error) {
+		return o.ListObjectParts(ctx, bucket, object, uploadID, partNumberMarker, maxParts)
+	})
+}
+
+func (s *regionStore) ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo {
+	return s.current().ListIncompleteUploads(ctx, bucketName, objectPrefix, recursive)
+}
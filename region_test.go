@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// wrongRegionStore fails every stat and listing as if signed for the wrong
+// region, pointing at want.
+type wrongRegionStore struct {
+	*fakeStore
+	want string
+}
+
+func (s wrongRegionStore) err() error {
+	return minio.ErrorResponse{Code: "AuthorizationHeaderMalformed", Region: s.want, StatusCode: http.StatusBadRequest}
+}
+
+func (s wrongRegionStore) StatObject(context.Context, string, string, minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	return minio.ObjectInfo{}, s.err()
+}
+
+func (s wrongRegionStore) ListObjects(context.Context, string, minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	ch := make(chan minio.ObjectInfo, 1)
+	ch <- minio.ObjectInfo{Err: s.err()}
+	close(ch)
+	return ch
+}
+
+func TestRegionStoreFollowsBucketRegion(t *testing.T) {
+	real := newFakeStore(testBucket)
+	real.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	var created []string
+	store, err := newRegionStore("", func(region string) (ObjectStore, error) {
+		created = append(created, region)
+		if region != "eu-west-1" {
+			return wrongRegionStore{fakeStore: newFakeStore(testBucket), want: "eu-west-1"}, nil
+		}
+		return real, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := store.StatObject(ctx, testBucket, "a.txt", minio.StatObjectOptions{}); err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	var keys []string
+	for object := range store.ListObjects(ctx, testBucket, minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			t.Fatalf("ListObjects: %v", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	if len(keys) != 1 || keys[0] != "a.txt" {
+		t.Errorf("keys = %v, want [a.txt]", keys)
+	}
+	if len(created) != 2 || created[1] != "eu-west-1" {
+		t.Errorf("clients created for regions %q, want [\"\" eu-west-1]", created)
+	}
+}
+
+func TestRegionStoreListsWithoutRetry(t *testing.T) {
+	real := newFakeStore(testBucket)
+	for _, key := range []string{"a", "b", "c"} {
+		real.put(testBucket, key, []byte(key), "text/plain")
+	}
+	store, err := newRegionStore("us-east-1", func(string) (ObjectStore, error) { return real, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for object := range store.ListObjects(context.Background(), testBucket, minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			t.Fatal(object.Err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("listed %d objects, want 3", n)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/replication"
+)
+
+// replicationRule is the JSON form of one bucket replication rule.
+type replicationRule struct {
+	ID string `json:"id"`
+	// DestinationARN is the replication target, as registered with
+	// `mc admin bucket remote add` (arn:minio:replication::...:bucket).
+	DestinationARN string `json:"destination_arn"`
+	Prefix         string `json:"prefix"`
+	// Priority orders overlapping rules; higher wins. Must be unique.
+	Priority               int    `json:"priority"`
+	Status                 string `json:"status"`
+	StorageClass           string `json:"storage_class,omitempty"`
+	ReplicateDeletes       bool   `json:"replicate_deletes"`
+	ReplicateDeleteMarkers bool   `json:"replicate_delete_markers"`
+	ExistingObjects        bool   `json:"existing_objects"`
+}
+
+// replicationSettings is the JSON form of a bucket replication config.
+type replicationSettings struct {
+	Role  string            `json:"role,omitempty"`
+	Rules []replicationRule `json:"rules"`
+}
+
+// isARN reports whether s looks like an ARN: six colon-separated fields.
+func isARN(s string) bool {
+	return strings.HasPrefix(s, "arn:") && strings.Count(s, ":") == 5
+}
+
+// enabledStatus maps a flag onto replication's Enabled/Disabled status.
+func enabledStatus(on bool) replication.Status {
+	if on {
+		return replication.Enabled
+	}
+	return replication.Disabled
+}
+
+// config validates s and builds the replication config for it. Rule IDs
+// default to "rule-<priority>" and status to Enabled.
+func (s replicationSettings) config() (replication.Config, error) {
+	cfg := replication.Config{Role: s.Role}
+	if s.Role != "" && !isARN(s.Role) {
+		return cfg, fmt.Errorf("role %q is not an ARN", s.Role)
+	}
+	if len(s.Rules) == 0 {
+		return cfg, fmt.Errorf("at least one rule is required")
+	}
+	ids, priorities := map[string]bool{}, map[int]bool{}
+	for i, r := range s.Rules {
+		if !isARN(r.DestinationARN) {
+			return cfg, fmt.Errorf("rule %d: destination_arn %q is not an ARN such as arn:minio:replication::<id>:<bucket>", i+1, r.DestinationARN)
+		}
+		if r.Priority < 1 {
+			return cfg, fmt.Errorf("rule %d: priority must be at least 1", i+1)
+		}
+		if priorities[r.Priority] {
+			return cfg, fmt.Errorf("rule %d: priority %d is used by another rule", i+1, r.Priority)
+		}
+		priorities[r.Priority] = true
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule-%d", r.Priority)
+		}
+		if ids[r.ID] {
+			return cfg, fmt.Errorf("rule %d: id %q is used by another rule", i+1, r.ID)
+		}
+		ids[r.ID] = true
+		status := replication.Enabled
+		switch r.Status {
+		case "", string(replication.Enabled):
+		case string(replication.Disabled):
+			status = replication.Disabled
+		default:
+			return cfg, fmt.Errorf("rule %d: status must be Enabled or Disabled", i+1)
+		}
+
+		rule := replication.Rule{
+			ID:                      r.ID,
+			Status:                  status,
+			Priority:                r.Priority,
+			DeleteMarkerReplication: replication.DeleteMarkerReplication{Status: enabledStatus(r.ReplicateDeleteMarkers)},
+			DeleteReplication:       replication.DeleteReplication{Status: enabledStatus(r.ReplicateDeletes)},
+			Destination:             replication.Destination{Bucket: r.DestinationARN, StorageClass: r.StorageClass},
+			Filter:                  replication.Filter{Prefix: r.Prefix},
+			SourceSelectionCriteria: replication.SourceSelectionCriteria{
+				ReplicaModifications: replication.ReplicaModifications{Status: replication.Enabled},
+			},
+			ExistingObjectReplication: replication.ExistingObjectReplication{Status: enabledStatus(r.ExistingObjects)},
+		}
+		if err := rule.Validate(); err != nil {
+			return cfg, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	return cfg, nil
+}
+
+func replicationSettingsOf(cfg replication.Config) replicationSettings {
+	s := replicationSettings{Role: cfg.Role, Rules: []replicationRule{}}
+	for _, r := range cfg.Rules {
+		s.Rules = append(s.Rules, replicationRule{
+			ID:                     r.ID,
+			DestinationARN:         r.Destination.Bucket,
+			Prefix:                 r.Prefix(),
+			Priority:               r.Priority,
+			Status:                 string(r.Status),
+			StorageClass:           r.Destination.StorageClass,
+			ReplicateDeletes:       r.DeleteReplication.Status == replication.Enabled,
+			ReplicateDeleteMarkers: r.DeleteMarkerReplication.Status == replication.Enabled,
+			ExistingObjects:        r.ExistingObjectReplication.Status == replication.Enabled,
+		})
+	}
+	return s
+}
+
+// bucketReplicationHandler reports (GET) or replaces (PUT) the bucket's
+// replication rules. A PUT body is {"rules": [{"destination_arn": "...",
+// "prefix": "docs/", "priority": 1}]}; both answer with the rules now in
+// effect. MinIO requires versioning on both buckets and the destination
+// registered as a remote target first.
+func (h *MinioHandler) bucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		if h.rejectIfReadOnly(w) {
+			return
+		}
+		var req replicationSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `Request body must be JSON like {"rules": [{"destination_arn": "arn:minio:replication::id:backup", "prefix": "docs/", "priority": 1}]}`, http.StatusBadRequest)
+			return
+		}
+		cfg, err := req.config()
+		if err != nil {
+			http.Error(w, "Invalid replication config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.store.SetBucketReplication(r.Context(), h.bucketName, cfg); err != nil {
+			logger(r.Context()).Error("Error setting replication of bucket", "bucket", h.bucketName, "err", err)
+			if resp := minio.ToErrorResponse(err); resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				// Typically versioning is off or the target ARN is unknown.
+				http.Error(w, "MinIO rejected the replication config: "+resp.Message, http.StatusBadRequest)
+				return
+			}
+			h.storeFailed(w, "Failed to set bucket replication", err)
+			return
+		}
+	}
+
+	cfg, err := h.store.GetBucketReplication(r.Context(), h.bucketName)
+	if err != nil {
+		logger(r.Context()).Error("Error reading replication of bucket", "bucket", h.bucketName, "err", err)
+		h.storeFailed(w, "Failed to read bucket replication", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, replicationSettingsOf(cfg))
+}
+
+// objectReplicationStatusHandler reports the replication status MinIO keeps
+// in the metadata of /object-replication-status/{objectName}: PENDING,
+// COMPLETED, FAILED, or REPLICA on a destination; null when the object
+// isn't covered by any replication rule.
+func (h *MinioHandler) objectReplicationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/object-replication-status/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /object-replication-status/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{})
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	status := info.ReplicationStatus
+	if status == "" {
+		status = info.Metadata.Get("X-Amz-Replication-Status")
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"object":     objectName,
+		"version_id": nullable(info.VersionID),
+		"status":     nullable(status),
+	})
+}
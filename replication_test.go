@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestBucketReplication(t *testing.T) {
+	h, store := newTestHandler(t)
+	put := func(body string) *httptest.ResponseRecorder {
+		return serve(h, httptest.NewRequest(http.MethodPut, "/bucket/replication", strings.NewReader(body)))
+	}
+
+	var got replicationSettings
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/bucket/replication", nil))
+	decodeJSON(t, rec, &got)
+	if rec.Code != http.StatusOK || len(got.Rules) != 0 {
+		t.Fatalf("unset: status %d, %+v", rec.Code, got)
+	}
+
+	rec = put(`{"rules": [
+		{"destination_arn": "arn:minio:replication::abc:backup", "prefix": "docs/", "priority": 2, "replicate_deletes": true},
+		{"id": "media", "destination_arn": "arn:minio:replication::abc:media", "prefix": "media/", "priority": 1, "status": "Disabled"}
+	]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put: status %d: %s", rec.Code, rec.Body)
+	}
+	got = replicationSettings{}
+	decodeJSON(t, rec, &got)
+	if len(got.Rules) != 2 {
+		t.Fatalf("rules = %+v", got.Rules)
+	}
+	first, second := got.Rules[0], got.Rules[1]
+	if first.ID != "rule-2" || first.Status != "Enabled" || first.Prefix != "docs/" || !first.ReplicateDeletes || first.ReplicateDeleteMarkers {
+		t.Errorf("first rule = %+v", first)
+	}
+	if second.ID != "media" || second.Status != "Disabled" || second.DestinationARN != "arn:minio:replication::abc:media" {
+		t.Errorf("second rule = %+v", second)
+	}
+	if cfg := store.replication[testBucket]; len(cfg.Rules) != 2 || cfg.Rules[0].Destination.Bucket != "arn:minio:replication::abc:backup" {
+		t.Errorf("stored config = %+v", cfg)
+	}
+
+	for _, bad := range []string{
+		`{"rules": []}`,
+		`{"rules": [{"destination_arn": "backup", "priority": 1}]}`,
+		`{"rules": [{"destination_arn": "arn:minio:replication::abc:b", "priority": 0}]}`,
+		`{"rules": [{"destination_arn": "arn:minio:replication::abc:b", "priority": 1}, {"destination_arn": "arn:minio:replication::abc:c", "priority": 1}]}`,
+		`{"rules": [{"destination_arn": "arn:minio:replication::abc:b", "priority": 1, "status": "On"}]}`,
+		`{"role": "admin", "rules": [{"destination_arn": "arn:minio:replication::abc:b", "priority": 1}]}`,
+	} {
+		if rec := put(bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+func TestObjectReplicationStatus(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.PutObject(context.Background(), testBucket, "docs/a.pdf", bytes.NewReader([]byte("a")), 1,
+		minio.PutObjectOptions{UserMetadata: map[string]string{"X-Amz-Replication-Status": "COMPLETED"}})
+	store.put(testBucket, "local.txt", []byte("b"), "text/plain")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/object-replication-status/docs/a.pdf", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"COMPLETED"`) {
+		t.Errorf("replicated object: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/object-replication-status/local.txt", nil))
+	if !strings.Contains(rec.Body.String(), `"status":null`) {
+		t.Errorf("unreplicated object: %s", rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/object-replication-status/missing", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// maxReshardErrors caps the per-object errors a reshard job reports, and
+// the SSE-C objects it lists.
+const maxReshardErrors = 100
+
+// errCustomerKeyObject is returned by moveObject for an object stored with
+// an SSE-C key, which can't be copied without that key.
+var errCustomerKeyObject = errors.New("object is encrypted with a customer-provided key")
+
+// reshardProgress is the progress payload of a reshard job. Moved counts
+// the objects moved under their shard, or with dry_run the ones that would
+// be. Objects stored with an SSE-C key are left where they are and counted
+// in SkippedCustomerKey instead of Failed.
+type reshardProgress struct {
+	Width          int      `json:"width"`
+	FromWidth      int      `json:"from_width"`
+	DryRun         bool     `json:"dry_run"`
+	Listed         int64    `json:"listed"`
+	AlreadySharded int64    `json:"already_sharded"`
+	Moved          int64    `json:"moved"`
+	Failed         int64    `json:"failed"`
+	Errors         []string `json:"errors,omitempty"`
+
+	SkippedCustomerKey int64    `json:"skipped_customer_key"`
+	CustomerKeyObjects []string `json:"customer_key_objects,omitempty"`
+}
+
+// reshardHandler starts a background job that moves every object in the
+// bucket under the shard MINIO_KEY_SHARD_WIDTH puts it in, so objects
+// uploaded before sharding was turned on (or while ?from_width= was
+// configured) become visible again. Objects already under their shard are
+// left alone, so an interrupted job can simply be started again. With
+// ?dry_run=true nothing is moved.
+func (h *MinioHandler) reshardHandler(w http.ResponseWriter, r *http.Request) {
+	if h.sharding == nil {
+		http.Error(w, "Key sharding is disabled", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	progress := reshardProgress{Width: h.sharding.width, DryRun: q.Get("dry_run") == "true"}
+	if v := q.Get("from_width"); v != "" {
+		from, err := strconv.Atoi(v)
+		if err != nil || from < 0 || from > maxShardWidth {
+			http.Error(w, fmt.Sprintf("from_width must be between 0 and %d", maxShardWidth), http.StatusBadRequest)
+			return
+		}
+		progress.FromWidth = from
+	}
+	if !progress.DryRun && h.rejectIfReadOnly(w) {
+		return
+	}
+
+	j := h.jobs.start("reshard", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.reshard(context.Background(), j, progress)
+		if err != nil {
+			logger(r.Context()).Error("Reshard job failed", "job", id, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// reshard lists the raw keys of the bucket and moves each one that isn't
+// under its shard, bulkConcurrency at a time. Keys under their shard at
+// p.FromWidth are moved by the name stored under them; any other key is
+// taken to be an unsharded name.
+func (h *MinioHandler) reshard(ctx context.Context, j *job, p reshardProgress) error {
+	raw := h.sharding.ObjectStore
+	var mu sync.Mutex
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	report := func(update func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		update()
+		if p.Listed%1000 == 0 {
+			j.setProgress(p)
+		}
+	}
+
+	var listErr error
+	for object := range raw.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			listErr = fmt.Errorf("listing bucket: %w", object.Err)
+			break
+		}
+		if _, ok := unshardKey(object.Key, p.Width); ok {
+			report(func() { p.Listed++; p.AlreadySharded++ })
+			continue
+		}
+		name := object.Key
+		if p.FromWidth > 0 {
+			if n, ok := unshardKey(object.Key, p.FromWidth); ok {
+				name = n
+			}
+		}
+		if p.DryRun {
+			report(func() { p.Listed++; p.Moved++ })
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			err := moveObject(ctx, raw, h.bucketName, object, shardOf(name, p.Width)+name, h.sse.fallback())
+			report(func() {
+				p.Listed++
+				if errors.Is(err, errCustomerKeyObject) {
+					p.SkippedCustomerKey++
+					if len(p.CustomerKeyObjects) < maxReshardErrors {
+						p.CustomerKeyObjects = append(p.CustomerKeyObjects, object.Key)
+					}
+					return
+				}
+				if err != nil {
+					p.Failed++
+					if len(p.Errors) < maxReshardErrors {
+						p.Errors = append(p.Errors, fmt.Sprintf("%s: %v", object.Key, err))
+					}
+					return
+				}
+				p.Moved++
+			})
+		}()
+	}
+	wg.Wait()
+	j.setProgress(p)
+	if listErr != nil {
+		return listErr
+	}
+	if p.Failed > 0 {
+		return fmt.Errorf("%d of %d objects could not be moved", p.Failed, p.Listed)
+	}
+	return nil
+}
+
+// moveObject copies object to key, encrypted with sse, provided it hasn't
+// changed since it was listed, and then removes it. An object stored with
+// an SSE-C key fails with errCustomerKeyObject and is left alone.
+func moveObject(ctx context.Context, store ObjectStore, bucket string, object minio.ObjectInfo, key string, sse encrypt.ServerSide) error {
+	_, err := store.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: bucket, Object: key, Encryption: sse},
+		minio.CopySrcOptions{Bucket: bucket, Object: object.Key, MatchETag: object.ETag})
+	if wrongCustomerKey(err, nil) {
+		return errCustomerKeyObject
+	}
+	if err != nil {
+		return fmt.Errorf("copying to '%s': %w", key, err)
+	}
+	if err := store.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("removing after copy to '%s': %w", key, err)
+	}
+	return nil
+}
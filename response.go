@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// prettyJSONDefault makes JSON responses indented unless a request asks for
+// ?pretty=false. It is set from MINIO_JSON_PRETTY at startup.
+var prettyJSONDefault bool
+
+// wantsPrettyJSON reports whether r's JSON response should be indented:
+// ?pretty=true or ?pretty=false, falling back to prettyJSONDefault.
+func wantsPrettyJSON(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return pretty
+	}
+	return prettyJSONDefault
+}
+
+// writeJSON encodes v as the JSON response body with the given status code,
+// compact or indented per wantsPrettyJSON.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	if wantsPrettyJSON(r) {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		logger(r.Context()).Error("Error encoding JSON response", "err", err)
+	}
+}
+
+// newID returns a random 128-bit identifier in hex, used for job IDs.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
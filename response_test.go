@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONPretty(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	compact := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)).Body.String()
+	if strings.Contains(compact, "\n  ") {
+		t.Errorf("default response is indented: %q", compact)
+	}
+	pretty := serve(h, httptest.NewRequest(http.MethodGet, "/list?pretty=true", nil)).Body.String()
+	if !strings.Contains(pretty, "\n  \"files\": [\n    \"a.txt\"\n  ]") {
+		t.Errorf("pretty response = %q", pretty)
+	}
+
+	prettyJSONDefault = true
+	defer func() { prettyJSONDefault = false }()
+	if body := serve(h, httptest.NewRequest(http.MethodGet, "/list", nil)).Body.String(); !strings.Contains(body, "\n  ") {
+		t.Errorf("MINIO_JSON_PRETTY default not applied: %q", body)
+	}
+	if body := serve(h, httptest.NewRequest(http.MethodGet, "/list?pretty=false", nil)).Body.String(); body != compact {
+		t.Errorf("pretty=false = %q, want %q", body, compact)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// sha256Meta is the user metadata key (x-amz-meta-sha256) some uploaders
+// store a hex SHA-256 of the content under.
+const sha256Meta = "Sha256"
+
+// maxScrubProblems caps the problems a scrub job reports individually.
+const maxScrubProblems = 100
+
+// Scrub outcomes reported per problem object.
+const (
+	scrubMismatch  = "mismatch"
+	scrubReadError = "read_error"
+)
+
+// scrubProblem is an object that failed a scrub.
+type scrubProblem struct {
+	Object    string `json:"object"`
+	Status    string `json:"status"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// scrubProgress is the progress payload of a scrub job. Unverified counts
+// objects that were read in full but have no stored hash to compare with.
+// DeadlineExceeded is set when the job's X-Max-Duration ran out before the
+// whole prefix was listed; Next is passed back as ?after= to continue.
+type scrubProgress struct {
+	Prefix     string         `json:"prefix"`
+	After      string         `json:"after,omitempty"`
+	Sample     float64        `json:"sample"`
+	Listed     int            `json:"listed"`
+	Scrubbed   int            `json:"scrubbed"`
+	Verified   int            `json:"verified"`
+	Unverified int            `json:"unverified"`
+	Mismatches int            `json:"mismatches"`
+	ReadErrors int            `json:"read_errors"`
+	Problems   []scrubProblem `json:"problems,omitempty"`
+
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"`
+	Next             string `json:"next,omitempty"`
+}
+
+// expectedHash is the stored hash an object's content is checked against.
+type expectedHash struct {
+	algorithm string
+	value     string
+	hasher    hash.Hash
+	// encode renders a digest the way value is written.
+	encode func([]byte) string
+}
+
+// expectedHashOf picks the hash to verify info against: x-amz-meta-sha256
+// if present, else a full-object x-amz-checksum-* value. Composite
+// checksums of multipart uploads can't be recomputed from the content and
+// are ignored.
+func expectedHashOf(info minio.ObjectInfo) (expectedHash, bool) {
+	for k, v := range info.UserMetadata {
+		if strings.EqualFold(strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-"), sha256Meta) {
+			return expectedHash{algorithm: "sha256", value: strings.ToLower(v), hasher: sha256.New(), encode: hex.EncodeToString}, true
+		}
+	}
+	if info.ChecksumMode == "COMPOSITE" {
+		return expectedHash{}, false
+	}
+	for _, c := range []struct {
+		t     minio.ChecksumType
+		value string
+	}{
+		{minio.ChecksumSHA256, info.ChecksumSHA256},
+		{minio.ChecksumSHA1, info.ChecksumSHA1},
+		{minio.ChecksumCRC64NVME, info.ChecksumCRC64NVME},
+		{minio.ChecksumCRC32C, info.ChecksumCRC32C},
+		{minio.ChecksumCRC32, info.ChecksumCRC32},
+	} {
+		if c.value == "" || strings.Contains(c.value, "-") {
+			continue
+		}
+		return expectedHash{
+			algorithm: strings.ToLower(c.t.String()),
+			value:     c.value,
+			hasher:    c.t.Hasher(),
+			encode:    base64.StdEncoding.EncodeToString,
+		}, true
+	}
+	return expectedHash{}, false
+}
+
+// scrubHandler starts a background job that reads every object under
+// ?prefix= in full and compares its content with the hash stored alongside
+// it, reporting mismatches and objects that can't be read. ?sample=0.1
+// scrubs a random tenth of the objects instead of all of them.
+//
+// X-Max-Duration bounds the job from when it starts: no object is started
+// after it runs out, those already being read are finished, and the job
+// completes with deadline_exceeded and the ?after= to resume from.
+func (h *MinioHandler) scrubHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	timeout, ok := h.maxDuration(w, r)
+	if !ok {
+		return
+	}
+	progress := scrubProgress{Prefix: q.Get("prefix"), After: q.Get("after"), Sample: 1}
+	if v := q.Get("sample"); v != "" {
+		sample, err := strconv.ParseFloat(v, 64)
+		if err != nil || sample <= 0 || sample > 1 {
+			http.Error(w, "sample must be a fraction greater than 0 and at most 1, such as 0.1", http.StatusBadRequest)
+			return
+		}
+		progress.Sample = sample
+	}
+	j := h.jobs.start("scrub", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.scrubPrefix(context.Background(), timeout, j, progress)
+		if err != nil {
+			logger(r.Context()).Error("Scrub job failed", "job", id, "prefix", progress.Prefix, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// scrubPrefix lists the objects under p.Prefix, samples them, and scrubs
+// up to bulkConcurrency at a time, reporting progress to j after each one.
+// A non-zero timeout stops the listing, but not the reads already started.
+func (h *MinioHandler) scrubPrefix(ctx context.Context, timeout time.Duration, j *job, p scrubProgress) error {
+	var mu sync.Mutex
+	record := func(update func(*scrubProgress)) {
+		mu.Lock()
+		defer mu.Unlock()
+		update(&p)
+		snapshot := p
+		snapshot.Problems = slices.Clone(p.Problems)
+		j.setProgress(snapshot)
+	}
+
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	listCtx, cancel := context.WithCancel(ctx)
+	if timeout > 0 {
+		listCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	var listErr error
+	// scanned is the last key listed; every key up to it has been scrubbed
+	// or skipped once the reads in flight finish.
+	scanned := p.After
+	for object := range h.store.ListObjects(listCtx, h.bucketName, minio.ListObjectsOptions{Prefix: p.Prefix, Recursive: true, StartAfter: p.After}) {
+		if listCtx.Err() != nil {
+			break
+		}
+		if object.Err != nil {
+			listErr = object.Err
+			break
+		}
+		scanned = object.Key
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		record(func(p *scrubProgress) { p.Listed++ })
+		if p.Sample < 1 && rand.Float64() >= p.Sample {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+			problem, verified := h.scrubObject(ctx, key)
+			record(func(p *scrubProgress) {
+				p.Scrubbed++
+				switch {
+				case problem != nil:
+					if problem.Status == scrubMismatch {
+						p.Mismatches++
+					} else {
+						p.ReadErrors++
+					}
+					if len(p.Problems) < maxScrubProblems {
+						p.Problems = append(p.Problems, *problem)
+					}
+				case verified:
+					p.Verified++
+				default:
+					p.Unverified++
+				}
+			})
+		}(object.Key)
+	}
+	wg.Wait()
+	if listErr == nil && errors.Is(listCtx.Err(), context.DeadlineExceeded) {
+		logger(ctx).Warn("Scrub stopped early", "prefix", p.Prefix, "timeout", timeout, "at", scanned)
+		record(func(p *scrubProgress) { p.DeadlineExceeded, p.Next = true, scanned })
+	}
+	if listErr != nil {
+		return fmt.Errorf("listing '%s': %w", p.Prefix, listErr)
+	}
+	return nil
+}
+
+// scrubObject reads objectName in full and checks it against its stored
+// hash. It returns the problem found, if any, and whether there was a hash
+// to check.
+func (h *MinioHandler) scrubObject(ctx context.Context, objectName string) (*scrubProblem, bool) {
+	readError := func(err error) (*scrubProblem, bool) {
+		logger(ctx).Error("Scrub: error reading object", "object", objectName, "err", err)
+		return &scrubProblem{Object: objectName, Status: scrubReadError, Error: err.Error()}, false
+	}
+	info, err := h.store.StatObject(ctx, h.bucketName, objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return readError(err)
+	}
+	opts := minio.GetObjectOptions{}
+	opts.SetMatchETag(info.ETag)
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		return readError(err)
+	}
+	defer obj.Close()
+
+	expected, ok := expectedHashOf(info)
+	sink := io.Discard
+	if ok {
+		sink = expected.hasher
+	}
+	n, err := io.Copy(sink, obj)
+	if err != nil {
+		return readError(err)
+	}
+	if n != info.Size {
+		return readError(fmt.Errorf("read %d bytes, expected %d", n, info.Size))
+	}
+	if !ok {
+		return nil, false
+	}
+	actual := expected.encode(expected.hasher.Sum(nil))
+	if actual != expected.value {
+		logger(ctx).Warn("Scrub: checksum mismatch", "object", objectName, "algorithm", expected.algorithm, "stored", expected.value, "computed", actual)
+		return &scrubProblem{Object: objectName, Status: scrubMismatch, Algorithm: expected.algorithm, Expected: expected.value, Actual: actual}, true
+	}
+	return nil, true
+}
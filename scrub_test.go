@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// runScrubJob starts a scrub and waits for it to finish.
+func runScrubJob(t *testing.T, h *MinioHandler, query string) scrubProgress {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/scrub"+query, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+	var status jobStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil)), &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+	var p scrubProgress
+	raw, _ := json.Marshal(status.Progress)
+	json.Unmarshal(raw, &p)
+	return p
+}
+
+func TestScrub(t *testing.T) {
+	h, store := newTestHandler(t)
+	put := func(name, content string, opts minio.PutObjectOptions) {
+		store.PutObject(context.Background(), testBucket, name, bytes.NewReader([]byte(content)), int64(len(content)), opts)
+	}
+	sum := sha256.Sum256([]byte("good"))
+	put("cold/meta-ok.txt", "good", minio.PutObjectOptions{UserMetadata: map[string]string{"x-amz-meta-sha256": hex.EncodeToString(sum[:])}})
+	put("cold/meta-bad.txt", "changed", minio.PutObjectOptions{UserMetadata: map[string]string{"x-amz-meta-sha256": hex.EncodeToString(sum[:])}})
+	put("cold/crc.txt", "hello", minio.PutObjectOptions{Checksum: minio.ChecksumCRC32C})
+	put("cold/rotted.txt", "hello", minio.PutObjectOptions{Checksum: minio.ChecksumSHA256})
+	put("cold/plain.txt", "no hash", minio.PutObjectOptions{})
+	put("hot/other.txt", "skip", minio.PutObjectOptions{})
+	// Simulate bit rot behind the stored checksum.
+	o, _ := store.lookup(testBucket, "cold/rotted.txt")
+	o.data = []byte("jello")
+
+	p := runScrubJob(t, h, "?prefix=cold/")
+	if p.Listed != 5 || p.Scrubbed != 5 || p.Verified != 2 || p.Unverified != 1 || p.Mismatches != 2 || p.ReadErrors != 0 {
+		t.Errorf("progress = %+v", p)
+	}
+	bad := map[string]string{}
+	for _, problem := range p.Problems {
+		bad[problem.Object] = problem.Algorithm
+	}
+	if bad["cold/meta-bad.txt"] != "sha256" || bad["cold/rotted.txt"] != "sha256" || len(bad) != 2 {
+		t.Errorf("problems = %+v", p.Problems)
+	}
+}
+
+func TestScrubSample(t *testing.T) {
+	h, store := newTestHandler(t)
+	for i := range 200 {
+		store.put(testBucket, "s/"+string(rune('a'+i%26))+string(rune('a'+i/26)), []byte("x"), "text/plain")
+	}
+	p := runScrubJob(t, h, "?sample=0.25")
+	if p.Listed != 200 || p.Scrubbed == 0 || p.Scrubbed >= 100 {
+		t.Errorf("sampled %d of %d objects, want about 50", p.Scrubbed, p.Listed)
+	}
+
+	for _, bad := range []string{"0", "1.5", "half"} {
+		if rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/scrub?sample="+bad, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("sample=%s: status %d, want 400", bad, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// selftestPrefix holds the probe objects of /admin/selftest.
+	selftestPrefix = "_selftest/"
+	// selftestSize is the size of a probe object.
+	selftestSize = 4 << 10
+	// selftestPhaseTimeout bounds each phase of a self-test.
+	selftestPhaseTimeout = 10 * time.Second
+)
+
+type selftestPhase struct {
+	Phase     string  `json:"phase"`
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type selftestResult struct {
+	OK      bool            `json:"ok"`
+	Key     string          `json:"key"`
+	Size    int             `json:"size"`
+	Phases  []selftestPhase `json:"phases"`
+	TotalMS float64         `json:"total_ms"`
+}
+
+// selftestHandler is an end-to-end probe of the store: it uploads a small
+// random object under _selftest/, reads it back and compares the bytes,
+// stats it and removes it, timing each phase. Phases after a failed upload
+// are skipped, but the removal always runs so no probe object is left
+// behind, even when the client goes away mid-test. The response is 200
+// when every phase passed and 503 otherwise, so monitors can alert on the
+// status alone.
+func (h *MinioHandler) selftestHandler(w http.ResponseWriter, r *http.Request) {
+	payload := make([]byte, selftestSize)
+	rand.Read(payload)
+	result := selftestResult{OK: true, Key: selftestPrefix + newID(), Size: selftestSize}
+	start := time.Now()
+
+	run := func(ctx context.Context, phase string, fn func(context.Context) error) bool {
+		ctx, cancel := context.WithTimeout(ctx, selftestPhaseTimeout)
+		defer cancel()
+		began := time.Now()
+		err := fn(ctx)
+		p := selftestPhase{Phase: phase, OK: err == nil, LatencyMS: float64(time.Since(began).Microseconds()) / 1000}
+		if err != nil {
+			logger(r.Context()).Error("Self-test failed", "phase", phase, "object", result.Key, "err", err)
+			p.Error = err.Error()
+			result.OK = false
+		}
+		result.Phases = append(result.Phases, p)
+		return err == nil
+	}
+
+	uploaded := run(r.Context(), "put", func(ctx context.Context) error {
+		_, err := h.store.PutObject(ctx, h.bucketName, result.Key, bytes.NewReader(payload), selftestSize, minio.PutObjectOptions{ContentType: "application/octet-stream", ServerSideEncryption: h.sse.fallback()})
+		return err
+	})
+	if uploaded {
+		run(r.Context(), "get", func(ctx context.Context) error {
+			obj, err := h.store.GetObject(ctx, h.bucketName, result.Key, minio.GetObjectOptions{})
+			if err != nil {
+				return err
+			}
+			defer obj.Close()
+			data, err := io.ReadAll(io.LimitReader(obj, selftestSize+1))
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(data, payload) {
+				return errors.New("downloaded bytes differ from the uploaded ones")
+			}
+			return nil
+		})
+		run(r.Context(), "stat", func(ctx context.Context) error {
+			info, err := h.store.StatObject(ctx, h.bucketName, result.Key, minio.StatObjectOptions{})
+			if err == nil && info.Size != selftestSize {
+				err = fmt.Errorf("stat reports %d bytes, want %d", info.Size, selftestSize)
+			}
+			return err
+		})
+	}
+	// A failed upload may still have stored the object, so it is removed
+	// regardless, and even if the client has gone.
+	run(context.WithoutCancel(r.Context()), "remove", func(ctx context.Context) error {
+		return h.store.RemoveObject(ctx, h.bucketName, result.Key, minio.RemoveObjectOptions{})
+	})
+	result.TotalMS = float64(time.Since(start).Microseconds()) / 1000
+
+	status := http.StatusOK
+	if !result.OK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, r, status, result)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestSelftest(t *testing.T) {
+	h, store := newTestHandler(t)
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/admin/selftest", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var result selftestResult
+	decodeJSON(t, rec, &result)
+	if !result.OK || !strings.HasPrefix(result.Key, selftestPrefix) {
+		t.Errorf("result = %+v, want success under %s", result, selftestPrefix)
+	}
+	var phases []string
+	for _, p := range result.Phases {
+		phases = append(phases, p.Phase)
+		if !p.OK {
+			t.Errorf("phase %s failed: %s", p.Phase, p.Error)
+		}
+	}
+	if got := strings.Join(phases, ","); got != "put,get,stat,remove" {
+		t.Errorf("phases = %s, want put,get,stat,remove", got)
+	}
+	if _, ok := store.object(testBucket, result.Key); ok {
+		t.Error("probe object was left behind")
+	}
+}
+
+// corruptingStore serves every object with different bytes than stored.
+type corruptingStore struct {
+	*fakeStore
+}
+
+func (s corruptingStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	return s.fakeStore.GetObject(ctx, bucketName, "other.bin", opts)
+}
+
+func TestSelftestFailureStillCleansUp(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "other.bin", []byte("not the probe"), "application/octet-stream")
+	h.store = corruptingStore{store}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/admin/selftest", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var result selftestResult
+	decodeJSON(t, rec, &result)
+	if result.OK || len(result.Phases) != 4 || result.Phases[1].OK || !result.Phases[3].OK {
+		t.Errorf("phases = %+v, want a failed get and a successful remove", result.Phases)
+	}
+	if _, ok := store.object(testBucket, result.Key); ok {
+		t.Error("probe object was left behind")
+	}
+}
+
+// failingPutStore refuses every upload.
+type failingPutStore struct {
+	*fakeStore
+}
+
+func (s failingPutStore) PutObject(context.Context, string, string, io.Reader, int64, minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return minio.UploadInfo{}, errors.New("disk full")
+}
+
+func TestSelftestSkipsReadsAfterFailedPut(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.store = failingPutStore{store}
+
+	var result selftestResult
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/admin/selftest", nil)), &result)
+	if result.OK || len(result.Phases) != 2 || result.Phases[0].Error != "disk full" || result.Phases[1].Phase != "remove" {
+		t.Errorf("phases = %+v, want a failed put followed by the remove", result.Phases)
+	}
+}
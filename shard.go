@@ -0,0 +1,370 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// maxShardWidth bounds MINIO_KEY_SHARD_WIDTH: every listing reads all
+// 16^width shards at once.
+const maxShardWidth = 2
+
+// errShardedPostPolicy is returned for browser upload policies, whose key
+// is signed inside the policy and can't be mapped to its shard.
+var errShardedPostPolicy = errors.New("upload policies are not available while MINIO_KEY_SHARD_WIDTH is set")
+
+// shardOf returns the shard prefix of objectName: the first width hex
+// digits of its SHA-1, and a slash.
+func shardOf(objectName string, width int) string {
+	sum := sha1.Sum([]byte(objectName))
+	return hex.EncodeToString(sum[:])[:width] + "/"
+}
+
+// unshardKey returns the name stored under key, if key is objectName
+// stored under its own shard at the given width.
+func unshardKey(key string, width int) (string, bool) {
+	if width <= 0 || len(key) <= width || key[width] != '/' {
+		return "", false
+	}
+	name := key[width+1:]
+	return name, shardOf(name, width) == key[:width+1]
+}
+
+// shardedStore stores every object under a prefix derived from a hash of
+// its name ("docs/a.pdf" as "3f/docs/a.pdf"), spreading hot prefixes over
+// the cluster. Callers keep using the original names: they are mapped on
+// the way in and back on the way out, and listings merge the listings of
+// every shard. Bucket-level calls pass through.
+//
+// Objects stored before sharding was turned on aren't found until
+// /admin/reshard has moved them under their shard.
+type shardedStore struct {
+	ObjectStore
+	width int
+}
+
+var _ ObjectStore = (*shardedStore)(nil)
+
+func newShardedStore(store ObjectStore, width int) (*shardedStore, error) {
+	if width < 1 || width > maxShardWidth {
+		return nil, fmt.Errorf("MINIO_KEY_SHARD_WIDTH must be between 1 and %d, got %d", maxShardWidth, width)
+	}
+	return &shardedStore{ObjectStore: store, width: width}, nil
+}
+
+func (s *shardedStore) key(objectName string) string {
+	return shardOf(objectName, s.width) + objectName
+}
+
+// name maps a stored key back to the name callers use. Keys outside the
+// shard scheme are returned unchanged.
+func (s *shardedStore) name(key string) string {
+	if name, ok := unshardKey(key, s.width); ok {
+		return name
+	}
+	return key
+}
+
+func (s *shardedStore) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	info, err := s.ObjectStore.PutObject(ctx, bucketName, s.key(objectName), reader, objectSize, opts)
+	info.Key = objectName
+	return info, err
+}
+
+// shardedReader reports the caller's name for the object it reads.
+type shardedReader struct {
+	ObjectReader
+	objectName string
+}
+
+func (r shardedReader) Stat() (minio.ObjectInfo, error) {
+	info, err := r.ObjectReader.Stat()
+	info.Key = r.objectName
+	return info, err
+}
+
+func (s *shardedStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	obj, err := s.ObjectStore.GetObject(ctx, bucketName, s.key(objectName), opts)
+	if err != nil {
+		return nil, err
+	}
+	return shardedReader{ObjectReader: obj, objectName: objectName}, nil
+}
+
+func (s *shardedStore) StatObject(ctx context.Context, bucketName, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	info, err := s.ObjectStore.StatObject(ctx, bucketName, s.key(objectName), opts)
+	info.Key = objectName
+	return info, err
+}
+
+func (s *shardedStore) GetObjectTagging(ctx context.Context, bucketName, objectName string, opts minio.GetObjectTaggingOptions) (*tags.Tags, error) {
+	return s.ObjectStore.GetObjectTagging(ctx, bucketName, s.key(objectName), opts)
+}
+
+func (s *shardedStore) PutObjectTagging(ctx context.Context, bucketName, objectName string, otags *tags.Tags, opts minio.PutObjectTaggingOptions) error {
+	return s.ObjectStore.PutObjectTagging(ctx, bucketName, s.key(objectName), otags, opts)
+}
+
+func (s *shardedStore) CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	objectName := dst.Object
+	dst.Object, src.Object = s.key(dst.Object), s.key(src.Object)
+	info, err := s.ObjectStore.CopyObject(ctx, dst, src)
+	info.Key = objectName
+	return info, err
+}
+
+func (s *shardedStore) RemoveObject(ctx context.Context, bucketName, objectName string, opts minio.RemoveObjectOptions) error {
+	return s.ObjectStore.RemoveObject(ctx, bucketName, s.key(objectName), opts)
+}
+
+func (s *shardedStore) RemoveObjects(ctx context.Context, bucketName string, objectsCh <-chan minio.ObjectInfo, opts minio.RemoveObjectsOptions) <-chan minio.RemoveObjectError {
+	keys := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(keys)
+		for object := range objectsCh {
+			object.Key = s.key(object.Key)
+			select {
+			case keys <- object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	errs := make(chan minio.RemoveObjectError)
+	go func() {
+		defer close(errs)
+		for rErr := range s.ObjectStore.RemoveObjects(ctx, bucketName, keys, opts) {
+			rErr.ObjectName = s.name(rErr.ObjectName)
+			errs <- rErr
+		}
+	}()
+	return errs
+}
+
+// shardListing is one shard's listing and its next entry.
+type shardListing struct {
+	next minio.ObjectInfo
+	// name is next.Key without the shard; empty for listing errors.
+	name string
+	ch   <-chan minio.ObjectInfo
+}
+
+// advance reads the next entry, reporting false at the end of the listing.
+func (l *shardListing) advance(width int) bool {
+	next, ok := <-l.ch
+	l.next, l.name = next, ""
+	if len(next.Key) > width {
+		l.name = next.Key[width+1:]
+	}
+	return ok
+}
+
+// listingHeap orders shard listings by the name of their next entry, so
+// listing errors come out first.
+type listingHeap []*shardListing
+
+func (h listingHeap) Len() int           { return len(h) }
+func (h listingHeap) Less(i, j int) bool { return h[i].name < h[j].name }
+func (h listingHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *listingHeap) Push(x any)        { *h = append(*h, x.(*shardListing)) }
+func (h *listingHeap) Pop() any {
+	old := *h
+	l := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return l
+}
+
+// ListObjects lists every shard with opts and merges the results into one
+// listing in name order, as an unsharded bucket would return it. Folder
+// entries that occur in several shards are reported once.
+func (s *shardedStore) ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	out := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		listings := &listingHeap{}
+		for i := range 1 << (4 * s.width) {
+			shard := fmt.Sprintf("%0*x/", s.width, i)
+			shardOpts := opts
+			shardOpts.Prefix = shard + opts.Prefix
+			if opts.StartAfter != "" {
+				shardOpts.StartAfter = shard + opts.StartAfter
+			}
+			l := &shardListing{ch: s.ObjectStore.ListObjects(ctx, bucketName, shardOpts)}
+			if l.advance(s.width) {
+				*listings = append(*listings, l)
+			}
+		}
+		heap.Init(listings)
+
+		sent, last := 0, ""
+		for listings.Len() > 0 {
+			l := (*listings)[0]
+			object, name := l.next, l.name
+			if l.advance(s.width) {
+				heap.Fix(listings, 0)
+			} else {
+				heap.Pop(listings)
+			}
+			if object.Err == nil {
+				// Entries stored under the wrong shard aren't part of the
+				// sharded namespace until /admin/reshard moves them.
+				if _, ok := unshardKey(object.Key, s.width); !ok && !isFolderEntry(object, opts) {
+					continue
+				}
+				// A folder is listed once per shard; the versions of an
+				// object all share its name.
+				if sent > 0 && name == last && (!opts.WithVersions || isFolderEntry(object, opts)) {
+					continue
+				}
+				if opts.MaxKeys > 0 && sent == opts.MaxKeys {
+					return
+				}
+				object.Key, last = name, name
+			}
+			select {
+			case out <- object:
+				sent++
+			case <-ctx.Done():
+				return
+			}
+			if object.Err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// isFolderEntry reports whether object is a common prefix of a
+// non-recursive listing, which carries no object of its own to hash.
+func isFolderEntry(object minio.ObjectInfo, opts minio.ListObjectsOptions) bool {
+	return !opts.Recursive && object.ETag == "" && len(object.Key) > 0 && object.Key[len(object.Key)-1] == '/'
+}
+
+func (s *shardedStore) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error) {
+	return s.ObjectStore.PresignedGetObject(ctx, bucketName, s.key(objectName), expires, reqParams)
+}
+
+func (s *shardedStore) PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error) {
+	return s.ObjectStore.PresignHeader(ctx, method, bucketName, s.key(objectName), expires, reqParams, extraHeaders)
+}
+
+func (s *shardedStore) PresignedPostPolicy(context.Context, *minio.PostPolicy) (*url.URL, map[string]string, error) {
+	return nil, nil, errShardedPostPolicy
+}
+
+// ListenBucketNotification reports events under the names callers use.
+// Event keys arrive URL-encoded.
+func (s *shardedStore) ListenBucketNotification(ctx context.Context, bucketName, prefix, suffix string, events []string) <-chan notification.Info {
+	out := make(chan notification.Info)
+	go func() {
+		defer close(out)
+		for info := range s.ObjectStore.ListenBucketNotification(ctx, bucketName, prefix, suffix, events) {
+			for i, rec := range info.Records {
+				if key, err := url.QueryUnescape(rec.S3.Object.Key); err == nil {
+					if name, ok := unshardKey(key, s.width); ok {
+						info.Records[i].S3.Object.Key = url.QueryEscape(name)
+					}
+				}
+			}
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *shardedStore) NewMultipartUpload(ctx context.Context, bucket, object string, opts minio.PutObjectOptions) (string, error) {
+	return s.ObjectStore.NewMultipartUpload(ctx, bucket, s.key(object), opts)
+}
+
+func (s *shardedStore) CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string, partID int, startOffset, length int64, metadata map[string]string) (minio.CompletePart, error) {
+	return s.ObjectStore.CopyObjectPart(ctx, srcBucket, s.key(srcObject), destBucket, s.key(destObject), uploadID, partID, startOffset, length, metadata)
+}
+
+func (s *shardedStore) PutObjectPart(ctx context.Context, bucket, object, uploadID string, partID int, data io.Reader, size int64, opts minio.PutObjectPartOptions) (minio.ObjectPart, error) {
+	return s.ObjectStore.PutObjectPart(ctx, bucket, s.key(object), uploadID, partID, data, size, opts)
+}
+
+func (s *shardedStore) CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	info, err := s.ObjectStore.CompleteMultipartUpload(ctx, bucket, s.key(object), uploadID, parts, opts)
+	info.Key = object
+	return info, err
+}
+
+func (s *shardedStore) AbortMultipartUpload(ctx context.Context, bucket, object, uploadID string) error {
+	return s.ObjectStore.AbortMultipartUpload(ctx, bucket, s.key(object), uploadID)
+}
+
+func (s *shardedStore) ListObjectParts(ctx context.Context, bucket, object, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	result, err := s.ObjectStore.ListObjectParts(ctx, bucket, s.key(object), uploadID, partNumberMarker, maxParts)
+	result.Key = object
+	return result, err
+}
+
+// ListIncompleteUploads lists the uploads of each shard in turn, so unlike
+// ListObjects its results are not in name order.
+func (s *shardedStore) ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo {
+	out := make(chan minio.ObjectMultipartInfo)
+	go func() {
+		defer close(out)
+		for i := range 1 << (4 * s.width) {
+			shard := fmt.Sprintf("%0*x/", s.width, i)
+			for upload := range s.ObjectStore.ListIncompleteUploads(ctx, bucketName, shard+objectPrefix, recursive) {
+				if upload.Err == nil {
+					name, ok := unshardKey(upload.Key, s.width)
+					if !ok {
+						continue
+					}
+					upload.Key = name
+				}
+				select {
+				case out <- upload:
+				case <-ctx.Done():
+					return
+				}
+				if upload.Err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// shardedPresigner signs links for the shard a name is stored under, for
+// MINIO_PUBLIC_ENDPOINT links.
+type shardedPresigner struct {
+	presigner
+	width int
+}
+
+func (p shardedPresigner) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error) {
+	return p.presigner.PresignedGetObject(ctx, bucketName, shardOf(objectName, p.width)+objectName, expires, reqParams)
+}
+
+func (p shardedPresigner) PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error) {
+	return p.presigner.PresignHeader(ctx, method, bucketName, shardOf(objectName, p.width)+objectName, expires, reqParams, extraHeaders)
+}
+
+func (p shardedPresigner) PresignedPostPolicy(context.Context, *minio.PostPolicy) (*url.URL, map[string]string, error) {
+	return nil, nil, errShardedPostPolicy
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func newShardedHandler(t *testing.T, width int) (*MinioHandler, *fakeStore) {
+	t.Helper()
+	h, store := newTestHandler(t)
+	sharded, err := newShardedStore(store, width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.store, h.sharding = sharded, sharded
+	return h, store
+}
+
+func TestShardedUploadAndFetch(t *testing.T) {
+	h, store := newShardedHandler(t, 2)
+
+	if rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload", "a.txt", "abc")); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	key := shardOf("a.txt", 2) + "a.txt"
+	if _, ok := store.object(testBucket, key); !ok {
+		t.Fatalf("object not stored under %q", key)
+	}
+	if _, ok := store.object(testBucket, "a.txt"); ok {
+		t.Fatal("object stored without its shard")
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/fetch/a.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+		t.Errorf("fetch = %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestShardedListMergesShards(t *testing.T) {
+	h, store := newShardedHandler(t, 1)
+	for _, name := range []string{"a.txt", "b/one.txt", "b/two.txt", "c.txt", "d.txt", "e/f.txt"} {
+		store.put(testBucket, shardOf(name, 1)+name, []byte("x"), "text/plain")
+	}
+	// Unmigrated, and so not part of the listing.
+	store.put(testBucket, "legacy.txt", []byte("x"), "text/plain")
+	h.listLimits.maxKeys = 3
+
+	var pages []string
+	after := ""
+	for range 5 {
+		var got listResponse
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/list?after="+after, nil)), &got)
+		pages = append(pages, strings.Join(got.Files, ","))
+		if !got.Truncated {
+			break
+		}
+		after = got.Next
+	}
+	if want := "a.txt,b/,c.txt|d.txt,e/"; strings.Join(pages, "|") != want {
+		t.Errorf("pages = %q, want %q", strings.Join(pages, "|"), want)
+	}
+}
+
+// runReshard starts /admin/reshard with query and returns the progress of
+// the finished job.
+func runReshard(t *testing.T, h *MinioHandler, query string) reshardProgress {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/reshard"+query, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+	var status struct {
+		jobStatus
+		Progress reshardProgress `json:"progress"`
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil)), &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+	return status.Progress
+}
+
+func TestReshardMovesObjectsUnderTheirShard(t *testing.T) {
+	h, store := newShardedHandler(t, 2)
+	store.put(testBucket, "legacy.txt", []byte("old"), "text/plain")
+	store.put(testBucket, shardOf("narrow.txt", 1)+"narrow.txt", []byte("narrow"), "text/plain")
+	current := shardOf("new.txt", 2) + "new.txt"
+	store.put(testBucket, current, []byte("new"), "text/plain")
+
+	if p := runReshard(t, h, "?from_width=1"); p.Listed != 3 || p.Moved != 2 || p.AlreadySharded != 1 || p.Failed != 0 {
+		t.Errorf("progress = %+v", p)
+	}
+
+	for name, want := range map[string]string{"legacy.txt": "old", "narrow.txt": "narrow", "new.txt": "new"} {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/fetch/"+name, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("fetch %s = %d %q, want %q", name, rec.Code, rec.Body, want)
+		}
+	}
+	if _, ok := store.object(testBucket, "legacy.txt"); ok {
+		t.Error("legacy key was not removed")
+	}
+}
+
+func TestReshardDisabled(t *testing.T) {
+	h, _ := newTestHandler(t)
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/reshard", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestShardedListsEveryVersion(t *testing.T) {
+	h, store := newShardedHandler(t, 1)
+	store.versioning[testBucket] = "Enabled"
+	key := shardOf("doc.txt", 1) + "doc.txt"
+	store.put(testBucket, key, []byte("v1"), "text/plain")
+	store.put(testBucket, key, []byte("v2"), "text/plain")
+
+	var got struct {
+		Versions []objectVersion `json:"versions"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/versions/doc.txt", nil)), &got)
+	if len(got.Versions) != 2 {
+		t.Errorf("versions = %+v, want 2", got.Versions)
+	}
+}
+
+func TestReshardSkipsCustomerKeyObjects(t *testing.T) {
+	h, store := newShardedHandler(t, 1)
+	store.put(testBucket, "plain.txt", []byte("plain"), "text/plain")
+	key, _ := encrypt.NewSSEC(bytes.Repeat([]byte{7}, 32))
+	store.PutObject(t.Context(), testBucket, "secret.txt", strings.NewReader("secret"), 6, minio.PutObjectOptions{ServerSideEncryption: key})
+
+	p := runReshard(t, h, "")
+	if p.Moved != 1 || p.Failed != 0 || p.SkippedCustomerKey != 1 || len(p.Errors) != 0 {
+		t.Errorf("progress = %+v", p)
+	}
+	if len(p.CustomerKeyObjects) != 1 || p.CustomerKeyObjects[0] != "secret.txt" {
+		t.Errorf("customer_key_objects = %v", p.CustomerKeyObjects)
+	}
+	if _, ok := store.object(testBucket, "secret.txt"); !ok {
+		t.Error("SSE-C object was removed")
+	}
+}
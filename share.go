@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// shareRedirectExpiry is how long the presigned URL behind a share link
+// redirect stays valid; the share link itself can live much longer.
+const shareRedirectExpiry = 5 * time.Minute
+
+// shareLink is what a share token stands for.
+type shareLink struct {
+	Object    string
+	ExpiresAt time.Time
+	SingleUse bool
+}
+
+// shareLinks maps short random tokens to objects. Tokens are kept in memory
+// only, so they stop working when the service restarts.
+type shareLinks struct {
+	defaultExpiry time.Duration
+	maxExpiry     time.Duration
+
+	mu    sync.Mutex
+	links map[string]shareLink
+}
+
+// newShareLinks reads MINIO_SHARE_DEFAULT_EXPIRY and MINIO_SHARE_MAX_EXPIRY.
+func newShareLinks() *shareLinks {
+	return &shareLinks{
+		defaultExpiry: envDuration("MINIO_SHARE_DEFAULT_EXPIRY", 24*time.Hour),
+		maxExpiry:     envDuration("MINIO_SHARE_MAX_EXPIRY", 7*24*time.Hour),
+		links:         make(map[string]shareLink),
+	}
+}
+
+// newShareToken returns 96 random bits as 16 URL-safe characters: short,
+// but far too many to enumerate.
+func newShareToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// add stores link under a new token, dropping expired links on the way.
+func (s *shareLinks) add(link shareLink) string {
+	token := newShareToken()
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, l := range s.links {
+		if !now.Before(l.ExpiresAt) {
+			delete(s.links, t)
+		}
+	}
+	s.links[token] = link
+	return token
+}
+
+// use looks up token, consuming it if it is single-use.
+func (s *shareLinks) use(token string) (shareLink, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[token]
+	if !ok {
+		return link, false
+	}
+	if !time.Now().Before(link.ExpiresAt) {
+		delete(s.links, token)
+		return link, false
+	}
+	if link.SingleUse {
+		delete(s.links, token)
+	}
+	return link, true
+}
+
+type shareRequest struct {
+	Object string `json:"object"`
+	// ExpiresIn is a Go duration such as "24h"; empty means
+	// MINIO_SHARE_DEFAULT_EXPIRY.
+	ExpiresIn string `json:"expires_in"`
+	SingleUse bool   `json:"single_use"`
+}
+
+type shareResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SingleUse bool      `json:"single_use"`
+}
+
+// shareHandler creates a short link, /s/{token}, to an existing object. The
+// link hides the object name and stays private: each visit is redirected to
+// a freshly presigned URL.
+func (h *MinioHandler) shareHandler(w http.ResponseWriter, r *http.Request) {
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Object == "" {
+		http.Error(w, `Request body must be JSON like {"object": "reports/q1.pdf", "expires_in": "24h", "single_use": false}`, http.StatusBadRequest)
+		return
+	}
+	expiry := h.shares.defaultExpiry
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "expires_in must be a positive duration such as 30m or 24h", http.StatusBadRequest)
+			return
+		}
+		expiry = d
+	}
+	if expiry > h.shares.maxExpiry {
+		http.Error(w, "expires_in exceeds the limit of "+h.shares.maxExpiry.String(), http.StatusBadRequest)
+		return
+	}
+	if _, err := h.statObject(r.Context(), req.Object, minio.StatObjectOptions{}); err != nil || h.trash.holds(req.Object) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	link := shareLink{Object: req.Object, ExpiresAt: time.Now().Add(expiry).UTC(), SingleUse: req.SingleUse}
+	token := h.shares.add(link)
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeJSON(w, r, http.StatusCreated, shareResponse{
+		Token:     token,
+		URL:       scheme + "://" + r.Host + h.basePath + "/s/" + token,
+		ExpiresAt: link.ExpiresAt,
+		SingleUse: link.SingleUse,
+	})
+}
+
+// shareRedirectHandler resolves /s/{token} and redirects to a presigned URL
+// for the object it stands for. Unknown, expired and used-up tokens all get
+// the same 404.
+func (h *MinioHandler) shareRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	link, ok := h.shares.use(token)
+	if !ok {
+		http.Error(w, "Link not found or expired", http.StatusNotFound)
+		return
+	}
+	if _, err := h.statObject(r.Context(), link.Object, minio.StatObjectOptions{}); err != nil {
+		http.Error(w, "Link not found or expired", http.StatusNotFound)
+		return
+	}
+	reqParams := h.downloadOnlyParams(r.Context(), link.Object)
+	u, err := h.links().PresignedGetObject(context.Background(), h.bucketName, link.Object, shareRedirectExpiry, reqParams)
+	if err != nil {
+		logger(r.Context()).Error("Error generating presigned URL for share link", "object", link.Object, "err", err)
+		http.Error(w, "Failed to resolve link", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
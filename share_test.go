@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createShare(t *testing.T, h *MinioHandler, body string) (*httptest.ResponseRecorder, shareResponse) {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/share", strings.NewReader(body)))
+	var got shareResponse
+	if rec.Code == http.StatusCreated {
+		decodeJSON(t, rec, &got)
+	}
+	return rec, got
+}
+
+func TestShareLinkRedirects(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "reports/q1.pdf", []byte("pdf"), "application/pdf")
+
+	rec, share := createShare(t, h, `{"object": "reports/q1.pdf"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if len(share.Token) != 16 || strings.Contains(share.URL, "q1.pdf") || !strings.HasSuffix(share.URL, "/s/"+share.Token) {
+		t.Errorf("share = %+v", share)
+	}
+	if d := time.Until(share.ExpiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v, want the 1h default", d)
+	}
+
+	for range 2 {
+		rec = serve(h, httptest.NewRequest(http.MethodGet, "/s/"+share.Token, nil))
+		if rec.Code != http.StatusFound || !strings.Contains(rec.Header().Get("Location"), "reports/q1.pdf") {
+			t.Errorf("status = %d, Location = %q", rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestShareLinkSingleUseAndExpiry(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	_, share := createShare(t, h, `{"object": "a.txt", "single_use": true}`)
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/s/"+share.Token, nil)); rec.Code != http.StatusFound {
+		t.Fatalf("first use: status = %d", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/s/"+share.Token, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("second use: status = %d, want 404", rec.Code)
+	}
+
+	_, share = createShare(t, h, `{"object": "a.txt"}`)
+	h.shares.links[share.Token] = shareLink{Object: "a.txt", ExpiresAt: time.Now().Add(-time.Second)}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/s/"+share.Token, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("expired: status = %d, want 404", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/s/nope", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestShareRejectsBadRequests(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	for body, want := range map[string]int{
+		`{"object": "missing.txt"}`:                 http.StatusNotFound,
+		`{"object": "a.txt", "expires_in": "48h"}`:  http.StatusBadRequest,
+		`{"object": "a.txt", "expires_in": "soon"}`: http.StatusBadRequest,
+		`{}`: http.StatusBadRequest,
+	} {
+		if rec, _ := createShare(t, h, body); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// sidecarLimits bounds one /sidecars request.
+type sidecarLimits struct {
+	// maxBytes is the largest sidecar fetched; bigger ones are reported as
+	// errors.
+	maxBytes int64
+	// maxCount is the most sidecars returned before the response is
+	// truncated.
+	maxCount int
+}
+
+// loadSidecarLimits reads MINIO_SIDECAR_MAX_BYTES and
+// MINIO_SIDECAR_MAX_COUNT.
+func loadSidecarLimits() sidecarLimits {
+	return sidecarLimits{
+		maxBytes: max(envInt64("MINIO_SIDECAR_MAX_BYTES", 1<<20), 1),
+		maxCount: int(max(envInt64("MINIO_SIDECAR_MAX_COUNT", 500), 1)),
+	}
+}
+
+// sidecarsResponse maps each sidecar's key to its JSON content. Sidecars
+// that couldn't be fetched or parsed are listed in Errors instead.
+type sidecarsResponse struct {
+	Prefix   string                     `json:"prefix"`
+	Suffix   string                     `json:"suffix"`
+	Count    int                        `json:"count"`
+	Sidecars map[string]json.RawMessage `json:"sidecars"`
+	Errors   map[string]string          `json:"errors"`
+	// Next is passed back as ?after= to continue a truncated response.
+	Truncated bool   `json:"truncated"`
+	Next      string `json:"next,omitempty"`
+}
+
+// sidecarsHandler returns the parsed content of every JSON sidecar under
+// ?prefix= whose name ends in ?suffix= (default ".meta.json"), so clients
+// can assemble an asset's metadata in one call. Sidecars are fetched up to
+// bulkConcurrency at a time; at most MINIO_SIDECAR_MAX_COUNT are returned
+// per request, and ones over MINIO_SIDECAR_MAX_BYTES or that aren't valid
+// JSON are reported per key without failing the rest.
+func (h *MinioHandler) sidecarsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	suffix := q.Get("suffix")
+	if suffix == "" {
+		suffix = ".meta.json"
+	}
+	limits := h.sidecars
+	if limits.maxCount == 0 {
+		limits = sidecarLimits{maxBytes: 1 << 20, maxCount: 500}
+	}
+	resp := sidecarsResponse{Prefix: q.Get("prefix"), Suffix: suffix, Sidecars: map[string]json.RawMessage{}, Errors: map[string]string{}}
+
+	var keys, fetch []string
+	objectCh := h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: resp.Prefix, Recursive: true, StartAfter: q.Get("after")})
+	for object := range objectCh {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing sidecars", "prefix", resp.Prefix, "err", object.Err)
+			h.storeFailed(w, "Failed to list files", object.Err)
+			return
+		}
+		if !strings.HasSuffix(object.Key, suffix) || h.hiddenKey(object.Key) {
+			continue
+		}
+		if len(keys) == limits.maxCount {
+			resp.Truncated, resp.Next = true, keys[len(keys)-1]
+			break
+		}
+		keys = append(keys, object.Key)
+		if object.Size > limits.maxBytes {
+			resp.Errors[object.Key] = fmt.Sprintf("sidecar is %d bytes, over the %d byte limit", object.Size, limits.maxBytes)
+			continue
+		}
+		fetch = append(fetch, object.Key)
+	}
+
+	var mu sync.Mutex
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for _, key := range fetch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			content, err := h.readSidecar(r, key, limits.maxBytes)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				resp.Errors[key] = err.Error()
+				return
+			}
+			resp.Sidecars[key] = content
+		}()
+	}
+	wg.Wait()
+	resp.Count = len(resp.Sidecars)
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// readSidecar fetches objectName and checks that it is JSON of at most
+// maxBytes.
+func (h *MinioHandler) readSidecar(r *http.Request, objectName string, maxBytes int64) (json.RawMessage, error) {
+	rc, _, err := h.openObject(r.Context(), objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("sidecar is over the %d byte limit", maxBytes)
+	}
+	var content json.RawMessage
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return content, nil
+}
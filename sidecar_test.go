@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSidecars(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.sidecars = sidecarLimits{maxBytes: 64, maxCount: 3}
+	for key, data := range map[string]string{
+		"assets/a.bin":           "binary",
+		"assets/a.bin.meta.json": `{"width": 10}`,
+		"assets/b.bin.meta.json": `{"width": `,
+		"assets/c.bin.meta.json": `{"notes": "` + string(make([]byte, 80)) + `"}`,
+		"assets/d.bin.meta.json": `[1, 2]`,
+		"other/e.bin.meta.json":  `{}`,
+	} {
+		store.put(testBucket, key, []byte(data), "application/json")
+	}
+
+	var got sidecarsResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/sidecars?prefix=assets/", nil)), &got)
+	if got.Count != 1 || string(got.Sidecars["assets/a.bin.meta.json"]) != `{"width":10}` {
+		t.Errorf("sidecars = %v", got.Sidecars)
+	}
+	if len(got.Errors) != 2 || got.Errors["assets/b.bin.meta.json"] == "" || got.Errors["assets/c.bin.meta.json"] == "" {
+		t.Errorf("errors = %v, want b (invalid) and c (too large)", got.Errors)
+	}
+	if !got.Truncated || got.Next != "assets/c.bin.meta.json" {
+		t.Errorf("truncated = %v, next = %q; want to continue after c", got.Truncated, got.Next)
+	}
+
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/sidecars?prefix=assets/&after="+got.Next, nil)), &got)
+	if got.Truncated || got.Count != 1 || string(got.Sidecars["assets/d.bin.meta.json"]) != `[1,2]` {
+		t.Errorf("second page = %+v", got)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// The request headers that choose an upload's encryption, as S3 names them.
+const (
+	sseHeader            = "X-Amz-Server-Side-Encryption"
+	sseKMSKeyIDHeader    = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	sseCAlgorithmHeader  = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	sseCKeyHeader        = "X-Amz-Server-Side-Encryption-Customer-Key"
+	sseCKeyMD5Header     = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+	sseCustomerAlgorithm = "AES256"
+)
+
+// sseSettings configure the server-side encryption uploads ask MinIO for.
+// Unlike the bucket's default encryption (see encryption.go), which MinIO
+// applies on its own, these are sent with every write the service makes.
+type sseSettings struct {
+	// algorithm is sseS3Algorithm or sseKMSAlgorithm, or "" to leave
+	// uploads without encryption headers to the bucket default.
+	algorithm string
+	kmsKeyID  string
+}
+
+// loadSSESettings reads MINIO_SSE and MINIO_SSE_KMS_KEY_ID. A key ID on its
+// own implies SSE-KMS.
+func loadSSESettings() (sseSettings, error) {
+	s := sseSettings{algorithm: os.Getenv("MINIO_SSE"), kmsKeyID: os.Getenv("MINIO_SSE_KMS_KEY_ID")}
+	if s.algorithm == "" && s.kmsKeyID != "" {
+		s.algorithm = sseKMSAlgorithm
+	}
+	switch {
+	case s.algorithm != "" && s.algorithm != sseS3Algorithm && s.algorithm != sseKMSAlgorithm:
+		return s, fmt.Errorf(`MINIO_SSE must be "AES256" (SSE-S3) or "aws:kms" (SSE-KMS), got %q`, s.algorithm)
+	case s.algorithm == sseS3Algorithm && s.kmsKeyID != "":
+		return s, errors.New("MINIO_SSE_KMS_KEY_ID only applies with MINIO_SSE=aws:kms")
+	}
+	return s, nil
+}
+
+// fallback is the encryption of uploads that don't choose one: the
+// configured default, or nil for none.
+func (s sseSettings) fallback() encrypt.ServerSide {
+	switch s.algorithm {
+	case sseS3Algorithm:
+		return encrypt.NewSSE()
+	case sseKMSAlgorithm:
+		// NewSSEKMS only fails to encode a context, and there is none.
+		sse, _ := encrypt.NewSSEKMS(s.kmsKeyID, nil)
+		return sse
+	}
+	return nil
+}
+
+// forUpload returns the encryption an upload asks for with the S3 request
+// headers: a customer-provided key (SSE-C), or the X-Amz-Server-Side-Encryption
+// algorithm, with the KMS key ID header choosing the key for aws:kms.
+// Without them it is the configured default. With MINIO_SSE=aws:kms an
+// upload can pick another KMS key or its own, but not SSE-S3.
+func (s sseSettings) forUpload(r *http.Request) (encrypt.ServerSide, error) {
+	if sse, err := customerKey(r); sse != nil || err != nil {
+		return sse, err
+	}
+	algorithm, keyID := r.Header.Get(sseHeader), r.Header.Get(sseKMSKeyIDHeader)
+	if algorithm == "" && keyID != "" {
+		algorithm = sseKMSAlgorithm
+	}
+	switch algorithm {
+	case "":
+		return s.fallback(), nil
+	case sseS3Algorithm:
+		if keyID != "" {
+			return nil, errors.New(sseKMSKeyIDHeader + " only applies to the aws:kms algorithm")
+		}
+		if s.algorithm == sseKMSAlgorithm {
+			return nil, errors.New("uploads must be encrypted with a KMS key or a customer-provided key, not AES256")
+		}
+		return encrypt.NewSSE(), nil
+	case sseKMSAlgorithm:
+		if keyID == "" {
+			keyID = s.kmsKeyID
+		}
+		return encrypt.NewSSEKMS(keyID, nil)
+	}
+	return nil, fmt.Errorf(`%s must be "AES256" or "aws:kms"`, sseHeader)
+}
+
+// copyEncryption is the encryption of a server-side copy.
+type copyEncryption struct {
+	// src is the SSE-C key the source was stored with, as the copy-source
+	// headers send it, or nil.
+	src encrypt.ServerSide
+	// dst is the encryption the copy is stored with.
+	dst encrypt.ServerSide
+}
+
+// forCopy returns the encryption of a copy of an object stored with the
+// SSE-C key, which the copy keeps, or, for a nil key, of one stored
+// without: the copy gets the configured default, as uploads do.
+func (s sseSettings) forCopy(key encrypt.ServerSide) copyEncryption {
+	if key != nil {
+		return copyEncryption{src: encrypt.SSECopy(key), dst: key}
+	}
+	return copyEncryption{dst: s.fallback()}
+}
+
+// addPartHeaders adds the headers a CopyObjectPart of an SSE-C object
+// needs to headers. Parts of other uploads take none: their encryption was
+// set when the upload started.
+func (c copyEncryption) addPartHeaders(headers map[string]string) {
+	if c.src == nil {
+		return
+	}
+	h := http.Header{}
+	c.src.Marshal(h)
+	c.dst.Marshal(h)
+	for name := range h {
+		headers[name] = h.Get(name)
+	}
+}
+
+// customerKey returns the SSE-C key a request carries, which reading an
+// object stored with one takes as well, or nil if it has none. The key is
+// the base64 of 32 bytes; the optional MD5 header is checked against it.
+func customerKey(r *http.Request) (encrypt.ServerSide, error) {
+	algorithm, encoded := r.Header.Get(sseCAlgorithmHeader), r.Header.Get(sseCKeyHeader)
+	if algorithm == "" && encoded == "" {
+		return nil, nil
+	}
+	if algorithm != sseCustomerAlgorithm {
+		return nil, fmt.Errorf("%s must be %s", sseCAlgorithmHeader, sseCustomerAlgorithm)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64-encoded 256-bit key", sseCKeyHeader)
+	}
+	if want := r.Header.Get(sseCKeyMD5Header); want != "" {
+		sum := md5.Sum(key)
+		if want != base64.StdEncoding.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("%s doesn't match the key", sseCKeyMD5Header)
+		}
+	}
+	return encrypt.NewSSEC(key)
+}
+
+// sseCKeyRequired answers for reading an SSE-C object without its key.
+const sseCKeyRequired = "File is encrypted with a customer-provided key; send the key it was uploaded with in the X-Amz-Server-Side-Encryption-Customer-* headers"
+
+// wrongCustomerKey reports whether S3 refused a read for lacking the
+// object's SSE-C key (400), or, when key was sent, for it being the wrong
+// one (403).
+func wrongCustomerKey(err error, key encrypt.ServerSide) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusBadRequest && resp.Code != "InvalidArgument" ||
+		key != nil && resp.StatusCode == http.StatusForbidden
+}
+
+// serveSSECLink answers /get-download-link/{objectName} for an object
+// stored with the SSE-C key sent. S3 only takes the key as headers, so they
+// are signed into the link and returned with it, for the client to send
+// when following it. These links aren't cached, since they embed the key.
+func (h *MinioHandler) serveSSECLink(w http.ResponseWriter, r *http.Request, objectName string, expiry time.Duration, reqParams url.Values, key encrypt.ServerSide) {
+	headers := http.Header{}
+	key.Marshal(headers)
+	u, err := h.links().PresignHeader(r.Context(), http.MethodGet, h.bucketName, objectName, expiry, reqParams, headers)
+	if err != nil {
+		logger(r.Context()).Error("Error generating presigned URL", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to generate download link", err)
+		return
+	}
+	resp := map[string]any{"url": u.String(), "headers": flattenHeaders(headers)}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// flattenHeaders returns the first value of each header, for JSON.
+func flattenHeaders(headers http.Header) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for name := range headers {
+		flat[name] = headers.Get(name)
+	}
+	return flat
+}
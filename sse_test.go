@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestLoadSSESettings(t *testing.T) {
+	tests := []struct {
+		sse, keyID string
+		want       sseSettings
+		wantErr    bool
+	}{
+		{"", "", sseSettings{}, false},
+		{"AES256", "", sseSettings{algorithm: sseS3Algorithm}, false},
+		{"", "compliance", sseSettings{algorithm: sseKMSAlgorithm, kmsKeyID: "compliance"}, false},
+		{"AES256", "compliance", sseSettings{}, true},
+		{"rot13", "", sseSettings{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("MINIO_SSE", tt.sse)
+		t.Setenv("MINIO_SSE_KMS_KEY_ID", tt.keyID)
+		got, err := loadSSESettings()
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("MINIO_SSE=%q MINIO_SSE_KMS_KEY_ID=%q: %+v, %v", tt.sse, tt.keyID, got, err)
+		}
+	}
+}
+
+func TestUploadUsesConfiguredKMSKey(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.sse = sseSettings{algorithm: sseKMSAlgorithm, kmsKeyID: "compliance"}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/a.txt", strings.NewReader("a"))); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "a.txt", minio.StatObjectOptions{})
+	if info.Metadata.Get(sseHeader) != sseKMSAlgorithm || info.Metadata.Get(sseKMSKeyIDHeader) != "compliance" {
+		t.Errorf("metadata = %v, want SSE-KMS with the compliance key", info.Metadata)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/raw/b.txt", strings.NewReader("b"))
+	req.Header.Set(sseHeader, sseS3Algorithm)
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("SSE-S3 with MINIO_SSE=aws:kms: status = %d, want 400", rec.Code)
+	}
+
+	var link uploadLinkResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-upload-link/c.txt?size=1", nil)), &link)
+	if link.Headers[sseKMSKeyIDHeader] != "compliance" || !strings.Contains(link.URL, strings.ToLower(sseKMSKeyIDHeader)) {
+		t.Errorf("upload link = %+v, want the KMS key headers signed", link)
+	}
+}
+
+func TestCopiesUseConfiguredEncryption(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.sse = sseSettings{algorithm: sseS3Algorithm}
+	h.trash = trashSettings{retention: time.Hour}
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/copy", strings.NewReader(`{"source": "a.txt", "destination": "b.txt"}`))); rec.Code != http.StatusOK {
+		t.Fatalf("copy status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodDelete, "/delete/a.txt", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, key := range append([]string{"b.txt"}, trashKeys(t, store)...) {
+		info, _ := store.StatObject(t.Context(), testBucket, key, minio.StatObjectOptions{})
+		if info.Metadata.Get(sseHeader) != sseS3Algorithm {
+			t.Errorf("%s metadata = %v, want SSE-S3", key, info.Metadata)
+		}
+	}
+}
+
+func TestTusStateUsesConfiguredEncryption(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.sse = sseSettings{algorithm: sseS3Algorithm}
+	location := createTusUpload(t, h, "videos/big.mp4", minPartSize)
+	if rec := serve(h, tusRequest(http.MethodPatch, location, strings.NewReader("partial"), 0)); rec.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %d: %s", rec.Code, rec.Body)
+	}
+	var keys []string
+	for object := range store.ListObjects(t.Context(), testBucket, minio.ListObjectsOptions{Prefix: tusStatePrefix, Recursive: true}) {
+		keys = append(keys, object.Key)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("tus state = %v, want the upload info and its pending data", keys)
+	}
+	for _, key := range keys {
+		info, _ := store.StatObject(t.Context(), testBucket, key, minio.StatObjectOptions{})
+		if info.Metadata.Get(sseHeader) != sseS3Algorithm {
+			t.Errorf("%s metadata = %v, want SSE-S3", key, info.Metadata)
+		}
+	}
+}
+
+func TestCopiesKeepCustomerKey(t *testing.T) {
+	h, _ := newTestHandler(t)
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	withKey := func(req *http.Request) *http.Request {
+		req.Header.Set(sseCAlgorithmHeader, sseCustomerAlgorithm)
+		req.Header.Set(sseCKeyHeader, key)
+		return req
+	}
+	if rec := serve(h, withKey(httptest.NewRequest(http.MethodPut, "/raw/secret.txt", strings.NewReader("top secret")))); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+
+	body := `{"source": "secret.txt", "destination": "copy.txt"}`
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/copy", strings.NewReader(body))); rec.Code != http.StatusBadRequest {
+		t.Errorf("copy without the key: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, withKey(httptest.NewRequest(http.MethodPost, "/copy", strings.NewReader(body)))); rec.Code != http.StatusOK {
+		t.Fatalf("copy with the key: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/copy.txt", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("download of the copy without the key: status = %d, want 400", rec.Code)
+	}
+	rec := serve(h, withKey(httptest.NewRequest(http.MethodGet, "/download/copy.txt", nil)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "top secret" {
+		t.Errorf("download of the copy with the key: %d %q", rec.Code, rec.Body)
+	}
+
+	req := withKey(httptest.NewRequest(http.MethodPut, "/meta/copy.txt", strings.NewReader(`{"metadata": {"owner": "ana"}}`)))
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Errorf("metadata update with the key: status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/copy.txt", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("download after the metadata update without the key: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCustomerKeyUploadAndDownload(t *testing.T) {
+	h, _ := newTestHandler(t)
+	key := []byte(strings.Repeat("k", 32))
+	sum := md5.Sum(key)
+	withKey := func(req *http.Request, key []byte) *http.Request {
+		req.Header.Set(sseCAlgorithmHeader, sseCustomerAlgorithm)
+		req.Header.Set(sseCKeyHeader, base64.StdEncoding.EncodeToString(key))
+		return req
+	}
+
+	req := withKey(httptest.NewRequest(http.MethodPut, "/raw/secret.txt", strings.NewReader("top secret")), key)
+	req.Header.Set(sseCKeyMD5Header, base64.StdEncoding.EncodeToString(sum[:]))
+	if rec := serve(h, req); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := serve(h, withKey(httptest.NewRequest(http.MethodGet, "/download/secret.txt", nil), key))
+	if rec.Code != http.StatusOK || rec.Body.String() != "top secret" {
+		t.Errorf("download with the key: %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/secret.txt", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("download without the key: status = %d, want 400", rec.Code)
+	}
+	other := []byte(strings.Repeat("x", 32))
+	if rec := serve(h, withKey(httptest.NewRequest(http.MethodGet, "/download/secret.txt", nil), other)); rec.Code != http.StatusBadRequest {
+		t.Errorf("download with another key: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, withKey(httptest.NewRequest(http.MethodGet, "/download/secret.txt", nil), key[:16])); rec.Code != http.StatusBadRequest {
+		t.Errorf("download with a short key: status = %d, want 400", rec.Code)
+	}
+
+	var link struct {
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+	}
+	decodeJSON(t, serve(h, withKey(httptest.NewRequest(http.MethodGet, "/get-download-link/secret.txt", nil), key)), &link)
+	if link.Headers[sseCAlgorithmHeader] != sseCustomerAlgorithm || !strings.Contains(link.URL, strings.ToLower(sseCKeyHeader)) {
+		t.Errorf("download link = %+v, want the key headers signed", link)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// objectStat describes an object for /stat. The ETag is MinIO's own tag (an
+// MD5 for simple uploads, something else for multipart ones) and is not a
+// reliable checksum; Checksums carries the x-amz-checksum-* values S3
+// stored, each null when the object has none for that algorithm.
+type objectStat struct {
+	Key          string           `json:"key"`
+	Size         int64            `json:"size"`
+	ContentType  string           `json:"content_type"`
+	LastModified time.Time        `json:"last_modified"`
+	ETag         string           `json:"etag"`
+	Checksums    objectStatHashes `json:"checksums"`
+	// ChecksumType is FULL_OBJECT or COMPOSITE (a checksum of the part
+	// checksums of a multipart upload), when MinIO reports it.
+	ChecksumType *string `json:"checksum_type"`
+	// Metadata is the x-amz-meta-* metadata, keyed without the prefix.
+	Metadata map[string]string `json:"metadata"`
+	// VersionID is null unless the bucket is versioned.
+	VersionID    *string `json:"version_id"`
+	StorageClass string  `json:"storage_class"`
+}
+
+type objectStatHashes struct {
+	CRC32     *string `json:"crc32"`
+	CRC32C    *string `json:"crc32c"`
+	SHA1      *string `json:"sha1"`
+	SHA256    *string `json:"sha256"`
+	CRC64NVME *string `json:"crc64nvme"`
+}
+
+// nullable returns nil for the empty string.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func statOf(info minio.ObjectInfo) objectStat {
+	metadata := info.UserMetadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return objectStat{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+		ETag:         strings.Trim(info.ETag, `"`),
+		Checksums: objectStatHashes{
+			CRC32:     nullable(info.ChecksumCRC32),
+			CRC32C:    nullable(info.ChecksumCRC32C),
+			SHA1:      nullable(info.ChecksumSHA1),
+			SHA256:    nullable(info.ChecksumSHA256),
+			CRC64NVME: nullable(info.ChecksumCRC64NVME),
+		},
+		ChecksumType: nullable(info.ChecksumMode),
+		Metadata:     metadata,
+		VersionID:    nullable(info.VersionID),
+		// S3 leaves out x-amz-storage-class for STANDARD objects.
+		StorageClass: cmp.Or(info.StorageClass, "STANDARD"),
+	}
+}
+
+// statHandler returns the metadata of /stat/{objectName} as JSON, including
+// the checksums S3 stored with it, of the version named by ?version_id= or
+// the latest one. StatObject runs in checksum mode, since S3 only returns
+// x-amz-checksum-* headers when asked for them.
+func (h *MinioHandler) statHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/stat/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /stat/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{Checksum: true, VersionID: r.URL.Query().Get("version_id")})
+	if err != nil {
+		if code := minio.ToErrorResponse(err).Code; code != "NoSuchKey" && code != "NoSuchVersion" {
+			logger(r.Context()).Error("Error stating object", "object", objectName, "err", err)
+		}
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	h.setPropagatedHeaders(w, info)
+	writeJSON(w, r, http.StatusOK, statOf(info))
+}
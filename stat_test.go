@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestStatChecksums(t *testing.T) {
+	h, store := newTestHandler(t)
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/sum.txt?checksum=crc32c", strings.NewReader("hello"))); rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	store.put(testBucket, "plain.txt", []byte("hi"), "text/plain")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/stat/sum.txt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got objectStat
+	decodeJSON(t, rec, &got)
+	want := minio.ChecksumCRC32C.ChecksumBytes([]byte("hello")).Encoded()
+	if got.Checksums.CRC32C == nil || *got.Checksums.CRC32C != want {
+		t.Errorf("crc32c = %v, want %s", got.Checksums.CRC32C, want)
+	}
+	if got.Checksums.SHA256 != nil || got.Size != 5 || got.ETag == "" {
+		t.Errorf("stat = %+v", got)
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/stat/plain.txt", nil))
+	if !strings.Contains(rec.Body.String(), `"crc32c":null`) || !strings.Contains(rec.Body.String(), `"sha256":null`) {
+		t.Errorf("object without checksums: %s", rec.Body)
+	}
+
+	if rec = serve(h, httptest.NewRequest(http.MethodGet, "/stat/missing", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object status = %d", rec.Code)
+	}
+}
+
+func TestStatMetadata(t *testing.T) {
+	h, store := newTestHandler(t)
+	_, err := store.PutObject(t.Context(), testBucket, "report.pdf", strings.NewReader("%PDF"), 4,
+		minio.PutObjectOptions{ContentType: "application/pdf", UserMetadata: map[string]string{"Owner": "ana"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/stat/report.pdf", nil))
+	var got objectStat
+	decodeJSON(t, rec, &got)
+	if got.Metadata["Owner"] != "ana" || got.ContentType != "application/pdf" {
+		t.Errorf("stat = %+v, want owner metadata", got)
+	}
+	if got.StorageClass != "STANDARD" || got.VersionID != nil {
+		t.Errorf("storage class %q, version %v; want STANDARD and no version", got.StorageClass, got.VersionID)
+	}
+
+	h.store = versionedStore{store}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/stat/report.pdf?version_id=v1", nil)); rec.Code != http.StatusOK {
+		t.Errorf("known version: status = %d", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/stat/report.pdf?version_id=v2", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown version: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/minio/minio-go/v7/pkg/replication"
+	"github.com/minio/minio-go/v7/pkg/sse"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// ObjectStore is the set of object storage operations the handlers rely on.
+// The method signatures follow minio-go so the production implementation is
+// a thin wrapper, while tests can substitute an in-memory fake.
+type ObjectStore interface {
+	MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error
+	BucketExists(ctx context.Context, bucketName string) (bool, error)
+	GetBucketPolicy(ctx context.Context, bucketName string) (string, error)
+	SetBucketPolicy(ctx context.Context, bucketName, policy string) error
+	GetBucketEncryption(ctx context.Context, bucketName string) (*sse.Configuration, error)
+	SetBucketEncryption(ctx context.Context, bucketName string, config *sse.Configuration) error
+	GetBucketReplication(ctx context.Context, bucketName string) (replication.Config, error)
+	SetBucketReplication(ctx context.Context, bucketName string, cfg replication.Config) error
+	GetBucketVersioning(ctx context.Context, bucketName string) (minio.BucketVersioningConfiguration, error)
+	SetBucketVersioning(ctx context.Context, bucketName string, config minio.BucketVersioningConfiguration) error
+
+	PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error)
+	StatObject(ctx context.Context, bucketName, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	GetObjectTagging(ctx context.Context, bucketName, objectName string, opts minio.GetObjectTaggingOptions) (*tags.Tags, error)
+	PutObjectTagging(ctx context.Context, bucketName, objectName string, otags *tags.Tags, opts minio.PutObjectTaggingOptions) error
+	CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error)
+	RemoveObject(ctx context.Context, bucketName, objectName string, opts minio.RemoveObjectOptions) error
+	RemoveObjects(ctx context.Context, bucketName string, objectsCh <-chan minio.ObjectInfo, opts minio.RemoveObjectsOptions) <-chan minio.RemoveObjectError
+	ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
+
+	PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration, reqParams url.Values) (*url.URL, error)
+	PresignHeader(ctx context.Context, method, bucketName, objectName string, expires time.Duration, reqParams url.Values, extraHeaders http.Header) (*url.URL, error)
+	PresignedPostPolicy(ctx context.Context, policy *minio.PostPolicy) (*url.URL, map[string]string, error)
+	ListenBucketNotification(ctx context.Context, bucketName, prefix, suffix string, events []string) <-chan notification.Info
+	EndpointURL() *url.URL
+
+	// Low-level multipart operations, used where the handler needs to drive
+	// the parts itself (e.g. to report copy progress).
+	NewMultipartUpload(ctx context.Context, bucket, object string, opts minio.PutObjectOptions) (string, error)
+	CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string, partID int, startOffset, length int64, metadata map[string]string) (minio.CompletePart, error)
+	PutObjectPart(ctx context.Context, bucket, object, uploadID string, partID int, data io.Reader, size int64, opts minio.PutObjectPartOptions) (minio.ObjectPart, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	AbortMultipartUpload(ctx context.Context, bucket, object, uploadID string) error
+	ListObjectParts(ctx context.Context, bucket, object, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error)
+	ListIncompleteUploads(ctx context.Context, bucketName, objectPrefix string, recursive bool) <-chan minio.ObjectMultipartInfo
+}
+
+// ObjectReader is an object body as returned by ObjectStore.GetObject. Like
+// *minio.Object, errors such as a missing key surface on the first Read or
+// Stat rather than from GetObject itself.
+type ObjectReader interface {
+	io.ReadCloser
+	io.ReaderAt
+	io.Seeker
+	Stat() (minio.ObjectInfo, error)
+}
+
+// minioStore is the ObjectStore backed by a minio-go client. Most methods are
+// promoted from the embedded client; the rest adapt minio-go's Core API.
+type minioStore struct {
+	*minio.Client
+}
+
+func newMinioStore(client *minio.Client) *minioStore {
+	return &minioStore{Client: client}
+}
+
+func (s *minioStore) GetObject(ctx context.Context, bucketName, objectName string, opts minio.GetObjectOptions) (ObjectReader, error) {
+	return s.Client.GetObject(ctx, bucketName, objectName, opts)
+}
+
+func (s *minioStore) core() minio.Core {
+	return minio.Core{Client: s.Client}
+}
+
+func (s *minioStore) NewMultipartUpload(ctx context.Context, bucket, object string, opts minio.PutObjectOptions) (string, error) {
+	return s.core().NewMultipartUpload(ctx, bucket, object, opts)
+}
+
+func (s *minioStore) CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string, partID int, startOffset, length int64, metadata map[string]string) (minio.CompletePart, error) {
+	return s.core().CopyObjectPart(ctx, srcBucket, srcObject, destBucket, destObject, uploadID, partID, startOffset, length, metadata)
+}
+
+func (s *minioStore) PutObjectPart(ctx context.Context, bucket, object, uploadID string, partID int, data io.Reader, size int64, opts minio.PutObjectPartOptions) (minio.ObjectPart, error) {
+	return s.core().PutObjectPart(ctx, bucket, object, uploadID, partID, data, size, opts)
+}
+
+func (s *minioStore) CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return s.core().CompleteMultipartUpload(ctx, bucket, object, uploadID, parts, opts)
+}
+
+func (s *minioStore) AbortMultipartUpload(ctx context.Context, bucket, object, uploadID string) error {
+	return s.core().AbortMultipartUpload(ctx, bucket, object, uploadID)
+}
+
+func (s *minioStore) ListObjectParts(ctx context.Context, bucket, object, uploadID string, partNumberMarker, maxParts int) (minio.ListObjectPartsResult, error) {
+	return s.core().ListObjectParts(ctx, bucket, object, uploadID, partNumberMarker, maxParts)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// STS bounds on MINIO_STS_DURATION, as MinIO and AWS enforce them.
+const (
+	minSTSDuration = 15 * time.Minute
+	maxSTSDuration = 12 * time.Hour
+)
+
+// stsLinks signs download links with short-lived credentials from the STS
+// AssumeRole API, restricted by an inline policy to reading the one object
+// linked. A leaked link, or the credentials in it, can't be used to reach
+// anything else, and stop working when the credentials expire.
+type stsLinks struct {
+	endpoint        string // STS endpoint URL
+	accessKeyID     string
+	secretAccessKey string
+	duration        time.Duration
+	client          *http.Client
+	// signer returns the presigner signing links with creds.
+	signer func(creds *credentials.Credentials) (presigner, error)
+}
+
+// errUnscopableName is returned for object names the policy language would
+// read as wildcards.
+var errUnscopableName = errors.New("object names containing '*', '?' or '$' can't be scoped by a policy")
+
+// objectReadPolicy returns an IAM policy allowing only reads of key, and of
+// its older versions if versioned.
+func objectReadPolicy(bucket, key string, versioned bool) (string, error) {
+	if strings.ContainsAny(key, "*?$") {
+		return "", errUnscopableName
+	}
+	actions := []string{"s3:GetObject"}
+	if versioned {
+		actions = append(actions, "s3:GetObjectVersion")
+	}
+	policy, err := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":   "Allow",
+			"Action":   actions,
+			"Resource": []string{"arn:aws:s3:::" + bucket + "/" + key},
+		}},
+	})
+	return string(policy), err
+}
+
+// presign obtains credentials scoped to key and signs a link to it with
+// them. The link expires after expiry, or when the credentials do if that
+// is sooner; the expiry applied is returned.
+func (s *stsLinks) presign(ctx context.Context, bucket, key string, expiry time.Duration, reqParams url.Values) (*url.URL, time.Duration, error) {
+	policy, err := objectReadPolicy(bucket, key, reqParams.Get("versionId") != "")
+	if err != nil {
+		return nil, 0, err
+	}
+	creds, err := credentials.NewSTSAssumeRole(s.endpoint, credentials.STSAssumeRoleOptions{
+		AccessKey:       s.accessKeyID,
+		SecretKey:       s.secretAccessKey,
+		Policy:          policy,
+		DurationSeconds: int(s.duration / time.Second),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	v, err := creds.GetWithContext(&credentials.CredContext{Client: s.client})
+	if err != nil {
+		return nil, 0, fmt.Errorf("assuming role: %w", err)
+	}
+	if !v.Expiration.IsZero() {
+		expiry = min(expiry, time.Until(v.Expiration).Truncate(time.Second))
+	}
+	if expiry < time.Second {
+		return nil, 0, fmt.Errorf("STS credentials expire at %s, too soon to sign a link", v.Expiration.Format(time.RFC3339))
+	}
+	signer, err := s.signer(credentials.NewStaticV4(v.AccessKeyID, v.SecretAccessKey, v.SessionToken))
+	if err != nil {
+		return nil, 0, err
+	}
+	u, err := signer.PresignedGetObject(ctx, bucket, key, expiry, reqParams)
+	return u, expiry, err
+}
+
+// serveScopedLink answers /get-download-link/{objectName}?scoped=true with
+// a link signed by stsLinks, and how many seconds it is valid for. Scoped
+// links aren't cached: each gets its own credentials.
+func (h *MinioHandler) serveScopedLink(w http.ResponseWriter, r *http.Request, objectName string, expiry time.Duration, reqParams url.Values) {
+	if h.stsLinks == nil {
+		http.Error(w, "Scoped links are disabled; set MINIO_STS_LINKS=true to enable them", http.StatusBadRequest)
+		return
+	}
+	key := objectName
+	if h.sharding != nil {
+		key = h.sharding.key(objectName)
+	}
+	u, expiry, err := h.stsLinks.presign(r.Context(), h.bucketName, key, expiry, reqParams)
+	if errors.Is(err, errUnscopableName) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger(r.Context()).Error("Error generating scoped link", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to generate scoped download link", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"url":        u.String(),
+		"expires_in": int64(expiry / time.Second),
+	})
+}
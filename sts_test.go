@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// newFakeSTS answers AssumeRole with credentials expiring after lifetime
+// and records the policy each request asked for.
+func newFakeSTS(t *testing.T, lifetime time.Duration, policies *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*policies = append(*policies, r.Form.Get("Policy"))
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>`+
+			`<AccessKeyId>TEMPKEY</AccessKeyId><SecretAccessKey>tempsecret</SecretAccessKey><SessionToken>TOKEN</SessionToken>`+
+			`<Expiration>%s</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`,
+			time.Now().Add(lifetime).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScopedLink(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "secret.pdf", []byte("pdf"), "application/pdf")
+	var policies []string
+	sts := newFakeSTS(t, 2*time.Minute, &policies)
+	h.stsLinks = &stsLinks{
+		endpoint:        sts.URL,
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "secret",
+		duration:        minSTSDuration,
+		client:          sts.Client(),
+		signer: func(creds *credentials.Credentials) (presigner, error) {
+			return newPublicPresigner("https://files.example.invalid", creds, "us-east-1", true)
+		},
+	}
+
+	var got struct {
+		URL       string `json:"url"`
+		ExpiresIn int64  `json:"expires_in"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/secret.pdf?scoped=true", nil)), &got)
+	u, err := url.Parse(got.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("X-Amz-Security-Token") != "TOKEN" || !strings.HasPrefix(q.Get("X-Amz-Credential"), "TEMPKEY/") {
+		t.Errorf("link not signed with the STS credentials: %s", got.URL)
+	}
+	// Bounded by the two minute credentials rather than the usual five.
+	if got.ExpiresIn <= 0 || got.ExpiresIn > 120 || q.Get("X-Amz-Expires") != fmt.Sprint(got.ExpiresIn) {
+		t.Errorf("expires_in = %d, X-Amz-Expires = %s", got.ExpiresIn, q.Get("X-Amz-Expires"))
+	}
+	if len(policies) != 1 || !strings.Contains(policies[0], `"Resource":["arn:aws:s3:::`+testBucket+`/secret.pdf"]`) {
+		t.Errorf("policies = %q", policies)
+	}
+
+	store.put(testBucket, "a*b", []byte("x"), "text/plain")
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/a*b?scoped=true", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("wildcard name: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestScopedLinkDisabled(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "secret.pdf", []byte("pdf"), "application/pdf")
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/secret.pdf?scoped=true", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// swapStep is one step of a swap as reported to the client.
+type swapStep struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// swapResult reports what a swap did. When a step fails, the steps already
+// done are undone and RolledBack says whether that worked.
+type swapResult struct {
+	Current       string     `json:"current"`
+	Staged        string     `json:"staged"`
+	Backup        string     `json:"backup"`
+	StagedRemoved bool       `json:"staged_removed"`
+	Steps         []swapStep `json:"steps"`
+	RolledBack    bool       `json:"rolled_back"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// run runs one step and records its outcome.
+func (res *swapResult) run(step string, do func() error) error {
+	err := do()
+	s := swapStep{Step: step, Status: "done"}
+	if err != nil {
+		slog.Error("Swap failed", "object", res.Current, "step", step, "err", err)
+		s.Status, s.Error = "failed", err.Error()
+	}
+	res.Steps = append(res.Steps, s)
+	return err
+}
+
+// swapHandler replaces a "current" object with a "staged" one, keeping the
+// previous content as a backup, for blue/green style config switches. The
+// body is {"current": "live.json", "staged": "staged.json", "backup":
+// "live.bak.json", "remove_staged": true}.
+//
+// The swap is three server-side steps, not one atomic operation: current is
+// copied to backup, staged is copied over current, and staged is removed if
+// asked. Each copy is all-or-nothing and pinned to the ETag read up front,
+// so an object changed mid-swap fails the swap instead of being copied.
+// Readers may still see current change between steps. When a step fails,
+// the earlier ones are undone: current is restored from backup if it was
+// already replaced, and backup is removed if it didn't exist before (an
+// existing backup that was overwritten can't be restored).
+func (h *MinioHandler) swapHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Current      string `json:"current"`
+		Staged       string `json:"staged"`
+		Backup       string `json:"backup"`
+		RemoveStaged bool   `json:"remove_staged"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Current == "" || req.Staged == "" || req.Backup == "" {
+		http.Error(w, `Request body must be JSON like {"current": "live.json", "staged": "staged.json", "backup": "live.bak.json"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Current == req.Staged || req.Current == req.Backup || req.Staged == req.Backup {
+		http.Error(w, "current, staged and backup must be three different objects", http.StatusBadRequest)
+		return
+	}
+	for _, dst := range []string{req.Current, req.Backup} {
+		if status, reason := h.uploadRules.check(h.bucketName, dst); status != 0 {
+			http.Error(w, reason, status)
+			return
+		}
+	}
+
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	current, err := h.store.StatObject(r.Context(), h.bucketName, req.Current, minio.StatObjectOptions{ServerSideEncryption: key})
+	if err != nil {
+		http.Error(w, "Current object not found: "+req.Current, http.StatusNotFound)
+		return
+	}
+	staged, err := h.store.StatObject(r.Context(), h.bucketName, req.Staged, minio.StatObjectOptions{ServerSideEncryption: key})
+	if err != nil {
+		http.Error(w, "Staged object not found: "+req.Staged, http.StatusNotFound)
+		return
+	}
+	_, err = h.store.StatObject(r.Context(), h.bucketName, req.Backup, minio.StatObjectOptions{})
+	backupExisted := err == nil
+
+	// Steps stop at the client's disconnect, but a started rollback doesn't.
+	ctx := r.Context()
+	rollbackCtx := context.WithoutCancel(ctx)
+	enc := h.sse.forCopy(key)
+	copyTo := func(ctx context.Context, dst, src, etag string) func() error {
+		return func() error {
+			_, err := h.store.CopyObject(ctx,
+				minio.CopyDestOptions{Bucket: h.bucketName, Object: dst, Encryption: enc.dst},
+				minio.CopySrcOptions{Bucket: h.bucketName, Object: src, MatchETag: etag, Encryption: enc.src})
+			return err
+		}
+	}
+	res := swapResult{Current: req.Current, Staged: req.Staged, Backup: req.Backup, Steps: []swapStep{}}
+	backedUp := false
+	fail := func(err error, currentReplaced bool) {
+		res.Error = err.Error()
+		res.RolledBack = true
+		if currentReplaced {
+			if res.run("restore_current", copyTo(rollbackCtx, req.Current, req.Backup, "")) != nil {
+				res.RolledBack = false
+			}
+			h.cache.invalidate(req.Current)
+		}
+		if backedUp && !backupExisted && res.RolledBack {
+			if res.run("remove_backup", func() error {
+				return h.store.RemoveObject(rollbackCtx, h.bucketName, req.Backup, minio.RemoveObjectOptions{})
+			}) != nil {
+				res.RolledBack = false
+			}
+		}
+		status := http.StatusInternalServerError
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			status = http.StatusPreconditionFailed
+		}
+		writeJSON(w, r, status, res)
+	}
+
+	if err := res.run("backup", copyTo(ctx, req.Backup, req.Current, current.ETag)); err != nil {
+		fail(err, false)
+		return
+	}
+	backedUp = true
+	h.cache.invalidate(req.Backup)
+	if err := res.run("promote", copyTo(ctx, req.Current, req.Staged, staged.ETag)); err != nil {
+		fail(err, false)
+		return
+	}
+	h.cache.invalidate(req.Current)
+	if req.RemoveStaged {
+		if err := res.run("remove_staged", func() error {
+			return h.store.RemoveObject(ctx, h.bucketName, req.Staged, minio.RemoveObjectOptions{})
+		}); err != nil {
+			fail(err, true)
+			return
+		}
+		h.cache.invalidate(req.Staged)
+		res.StagedRemoved = true
+	}
+	writeJSON(w, r, http.StatusOK, res)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// failingSwapStore fails copies to failCopyTo and removals of failRemove.
+type failingSwapStore struct {
+	*fakeStore
+	failCopyTo, failRemove string
+}
+
+func (s failingSwapStore) CopyObject(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+	if dst.Object == s.failCopyTo {
+		return minio.UploadInfo{}, errors.New("copy failed")
+	}
+	return s.fakeStore.CopyObject(ctx, dst, src)
+}
+
+func (s failingSwapStore) RemoveObject(ctx context.Context, bucketName, objectName string, opts minio.RemoveObjectOptions) error {
+	if objectName == s.failRemove {
+		return errors.New("remove failed")
+	}
+	return s.fakeStore.RemoveObject(ctx, bucketName, objectName, opts)
+}
+
+func swapRequest(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/swap", strings.NewReader(body))
+}
+
+func TestSwap(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "live.json", []byte("blue"), "application/json")
+	store.put(testBucket, "staged.json", []byte("green"), "application/json")
+
+	rec := serve(h, swapRequest(`{"current": "live.json", "staged": "staged.json", "backup": "live.bak.json", "remove_staged": true}`))
+	var res swapResult
+	decodeJSON(t, rec, &res)
+	if rec.Code != http.StatusOK || !res.StagedRemoved || len(res.Steps) != 3 {
+		t.Fatalf("swap = %d %+v", rec.Code, res)
+	}
+	for key, want := range map[string]string{"live.json": "green", "live.bak.json": "blue"} {
+		if got, _ := store.object(testBucket, key); string(got) != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := store.object(testBucket, "staged.json"); ok {
+		t.Error("staged.json was not removed")
+	}
+
+	for _, body := range []string{`{"current": "live.json"}`, `{"current": "a", "staged": "a", "backup": "b"}`} {
+		if rec := serve(h, swapRequest(body)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := serve(h, swapRequest(`{"current": "live.json", "staged": "missing.json", "backup": "b.json"}`)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing staged: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSwapRollsBack(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		store func(*fakeStore) failingSwapStore
+	}{
+		{"promote fails", func(f *fakeStore) failingSwapStore { return failingSwapStore{fakeStore: f, failCopyTo: "live.json"} }},
+		{"remove staged fails", func(f *fakeStore) failingSwapStore { return failingSwapStore{fakeStore: f, failRemove: "staged.json"} }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, store := newTestHandler(t)
+			h.store = tc.store(store)
+			store.put(testBucket, "live.json", []byte("blue"), "application/json")
+			store.put(testBucket, "staged.json", []byte("green"), "application/json")
+
+			rec := serve(h, swapRequest(`{"current": "live.json", "staged": "staged.json", "backup": "live.bak.json", "remove_staged": true}`))
+			var res swapResult
+			decodeJSON(t, rec, &res)
+			if rec.Code != http.StatusInternalServerError || !res.RolledBack || res.Error == "" {
+				t.Errorf("swap = %d %+v, want a rolled back failure", rec.Code, res)
+			}
+			if got, _ := store.object(testBucket, "live.json"); string(got) != "blue" {
+				t.Errorf("live.json = %q, want blue restored", got)
+			}
+			if _, ok := store.object(testBucket, "live.bak.json"); ok {
+				t.Error("new backup was left behind")
+			}
+		})
+	}
+}
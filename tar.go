@@ -0,0 +1,109 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// tarHandler streams every object under ?prefix= as a tar archive, gzipped
+// with ?gz=true. Entries keep their full object names and are written one at
+// a time, so memory use doesn't grow with the archive. Once streaming has
+// started an error can only end the response early, leaving a truncated
+// archive that tar reports as unexpected EOF.
+func (h *MinioHandler) tarHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	gz := r.URL.Query().Get("gz") == "true"
+
+	objectCh := h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	// Peek at the listing so a bad prefix or bucket still gets a proper error.
+	first, ok := <-objectCh
+	for ok && first.Err == nil && h.hiddenKey(first.Key) {
+		first, ok = <-objectCh
+	}
+	if ok && first.Err != nil {
+		logger(r.Context()).Error("Error listing objects for tar", "err", first.Err)
+		h.storeFailed(w, "Failed to list files", first.Err)
+		return
+	}
+	if !ok {
+		http.Error(w, "No objects found under prefix", http.StatusNotFound)
+		return
+	}
+
+	name := h.prefixArchiveName(prefix)
+	var out io.Writer = w
+	if gz {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", attachmentDisposition(name+".tar.gz"))
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		out = zw
+	} else {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", attachmentDisposition(name+".tar"))
+	}
+	tw := tar.NewWriter(out)
+
+	for object := first; ok; object, ok = <-objectCh {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing objects for tar", "err", object.Err)
+			return
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		if err := h.writeTarEntry(r, tw, object.Key); err != nil {
+			logger(r.Context()).Error("Error adding object to tar", "object", object.Key, "err", err)
+			return
+		}
+	}
+	if err := tw.Close(); err != nil {
+		logger(r.Context()).Error("Error finishing tar", "err", err)
+	}
+}
+
+// prefixArchiveName is the file name, without extension, of an archive of
+// prefix: its last folder, or the bucket's name for the whole bucket.
+func (h *MinioHandler) prefixArchiveName(prefix string) string {
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(prefix, "/")), ".")
+	if name == "" || name == "/" {
+		name = h.bucketName
+	}
+	return name
+}
+
+// writeTarEntry copies one object into tw, as a directory entry for folder
+// markers.
+func (h *MinioHandler) writeTarEntry(r *http.Request, tw *tar.Writer, key string) error {
+	if strings.HasSuffix(key, "/") {
+		return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: key, Mode: 0o755})
+	}
+	obj, info, err := h.openObject(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     key,
+		Size:     info.Size,
+		Mode:     0o644,
+		ModTime:  info.LastModified,
+		Format:   tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	n, err := io.Copy(tw, obj)
+	if err == nil && n != info.Size {
+		err = fmt.Errorf("object changed while archiving: read %d of %d bytes", n, info.Size)
+	}
+	return err
+}
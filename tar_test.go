@@ -0,0 +1,66 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTarHandler(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "logs/2024/a.log", []byte("alpha"), "text/plain")
+	store.put(testBucket, "logs/b.log", []byte("bravo!"), "text/plain")
+	store.put(testBucket, "other.txt", []byte("x"), "text/plain")
+
+	for _, gz := range []bool{false, true} {
+		target := "/download-tar?prefix=logs/"
+		if gz {
+			target += "&gz=true"
+		}
+		rec := serve(h, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("gz=%v: status = %d: %s", gz, rec.Code, rec.Body)
+		}
+		var body io.Reader = rec.Body
+		wantName := `attachment; filename=logs.tar`
+		if gz {
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+			wantName += ".gz"
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != wantName {
+			t.Errorf("gz=%v: Content-Disposition = %q, want %q", gz, got, wantName)
+		}
+
+		var got []string
+		tr := tar.NewReader(body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("gz=%v: %v", gz, err)
+			}
+			data, _ := io.ReadAll(tr)
+			got = append(got, hdr.Name+"="+string(data))
+		}
+		if want := "logs/2024/a.log=alpha,logs/b.log=bravo!"; strings.Join(got, ",") != want {
+			t.Errorf("gz=%v: entries = %v, want %s", gz, got, want)
+		}
+	}
+}
+
+func TestTarHandlerEmptyPrefix(t *testing.T) {
+	h, _ := newTestHandler(t)
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-tar?prefix=nothing/", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
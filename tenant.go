@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/sync/singleflight"
+)
+
+// tenantPathPrefix selects a tenant by path: /tenants/acme/list is /list
+// for tenant "acme".
+const tenantPathPrefix = "/tenants/"
+
+// tenantNamePattern restricts tenant names to something safe in a path.
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// tenantConfig is one entry of MINIO_TENANTS_FILE: the MinIO endpoint,
+// credentials and bucket a tenant's objects live in.
+type tenantConfig struct {
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region"`
+	// UseSSL defaults to true, as for the main endpoint.
+	UseSSL *bool `json:"use_ssl"`
+	// BucketLookup is path (the default), dns or auto, as for
+	// MINIO_BUCKET_LOOKUP.
+	BucketLookup string `json:"bucket_lookup"`
+}
+
+func (c tenantConfig) secure() bool {
+	return c.UseSSL == nil || *c.UseSSL
+}
+
+// lookup returns the addressing mode of the tenant's bucket. It was
+// validated by loadTenantConfigs.
+func (c tenantConfig) lookup() minio.BucketLookupType {
+	if c.BucketLookup == "" {
+		return minio.BucketLookupPath
+	}
+	t, _ := parseBucketLookup(c.BucketLookup)
+	return t
+}
+
+// loadTenantConfigs reads a JSON object mapping tenant names to their
+// configuration, such as {"acme": {"endpoint": "minio.acme.internal:9000",
+// "access_key": "...", "secret_key": "...", "bucket": "files"}}.
+func loadTenantConfigs(path string) (map[string]tenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string]tenantConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for name, c := range configs {
+		if !tenantNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("tenant name %q must be lowercase letters, digits, '-' and '_'", name)
+		}
+		if c.Endpoint == "" || c.AccessKey == "" || c.SecretKey == "" || c.Bucket == "" {
+			return nil, fmt.Errorf("tenant %q needs endpoint, access_key, secret_key and bucket", name)
+		}
+		if c.BucketLookup != "" {
+			if _, err := parseBucketLookup(c.BucketLookup); err != nil {
+				return nil, fmt.Errorf("tenant %q: %w", name, err)
+			}
+		}
+	}
+	return configs, nil
+}
+
+// tenantRouter sends requests that name a tenant, by header or by a
+// /tenants/{name}/ path prefix, to the routes of that tenant's handler.
+// Each tenant handler is created on first use and kept, so its MinIO client
+// is reused across requests.
+type tenantRouter struct {
+	header   string
+	configs  map[string]tenantConfig
+	newStore func(tenantConfig) (ObjectStore, error)
+
+	mu     sync.Mutex
+	routes map[string]http.Handler
+}
+
+func newTenantRouter(header string, configs map[string]tenantConfig, newStore func(tenantConfig) (ObjectStore, error)) *tenantRouter {
+	return &tenantRouter{header: header, configs: configs, newStore: newStore, routes: make(map[string]http.Handler)}
+}
+
+// tenantFor returns the tenant named by r and, for a path-selected tenant,
+// the path prefix to strip. It fails if the header and path disagree.
+func (t *tenantRouter) tenantFor(r *http.Request) (name, prefix string, err error) {
+	name = r.Header.Get(t.header)
+	if rest, ok := strings.CutPrefix(r.URL.Path, tenantPathPrefix); ok {
+		fromPath, _, _ := strings.Cut(rest, "/")
+		if name != "" && name != fromPath {
+			return "", "", fmt.Errorf("%s header %q doesn't match tenant %q in the path", t.header, name, fromPath)
+		}
+		return fromPath, tenantPathPrefix + fromPath, nil
+	}
+	return name, "", nil
+}
+
+// handler returns the routes of tenant name, creating its store on first
+// use and checking that the tenant's bucket can be reached. ok is false for
+// a tenant that isn't configured.
+func (t *tenantRouter) handler(ctx context.Context, base *MinioHandler, name string) (h http.Handler, ok bool, err error) {
+	cfg, ok := t.configs[name]
+	if !ok {
+		return nil, false, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.routes[name]; ok {
+		return h, true, nil
+	}
+	store, err := t.newStore(cfg)
+	if err != nil {
+		return nil, true, err
+	}
+	exists, err := store.BucketExists(ctx, cfg.Bucket)
+	if err == nil && !exists {
+		err = fmt.Errorf("bucket '%s' not found", cfg.Bucket)
+	}
+	if err != nil {
+		if hint := addressingHint(err, cfg.Bucket, cfg.lookup()); hint != "" {
+			err = fmt.Errorf("%w (%s)", err, hint)
+		}
+		return nil, true, err
+	}
+	view := base.tenantView(name, store, cfg.Bucket)
+	if base.lifecycle != nil {
+		view.startTusSweeper(base.lifecycle.ctx)
+		view.startTrashPurger(base.lifecycle.ctx)
+	}
+	h = view.routes()
+	t.routes[name] = h
+	return h, true, nil
+}
+
+// wrap returns next with tenant routing in front of it. Requests that name
+// no tenant go to next; a nil router routes everything to next.
+func (t *tenantRouter) wrap(base *MinioHandler, next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, prefix, err := t.tenantFor(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant, ok, err := t.handler(r.Context(), base, name)
+		switch {
+		case !ok:
+			http.Error(w, fmt.Sprintf("Unknown tenant '%s'", name), http.StatusNotFound)
+			return
+		case err != nil:
+			logger(r.Context()).Error("Error connecting to tenant storage", "tenant", name, "err", err)
+			http.Error(w, "Failed to connect to tenant storage", http.StatusBadGateway)
+			return
+		}
+		if prefix != "" {
+			tenant = http.StripPrefix(prefix, tenant)
+		}
+		tenant.ServeHTTP(w, r)
+	})
+}
+
+// tenantView returns a copy of h that serves the given tenant's bucket.
+// Settings are shared, as is the read-only switch, but everything keyed by
+// object name or ID (caches, jobs, share links) is fresh so that nothing
+// leaks between tenants. Links are signed with the tenant's own client, and
+// the main bucket's visibility rules and quotas don't apply.
+func (h *MinioHandler) tenantView(name string, store ObjectStore, bucket string) *MinioHandler {
+	view := h.scopedView(store, bucket, tenantPathPrefix+name)
+	view.publicLinks = nil
+	view.stsLinks = nil
+	view.sharding = nil
+	view.breaker = nil
+	view.peers = nil
+	return view
+}
+
+// scopedView returns a copy of h serving bucket through store, with the
+// state keyed by object name or ID made fresh and the main bucket's rules
+// dropped. basePath is the path prefix its routes are served under.
+func (h *MinioHandler) scopedView(store ObjectStore, bucket, basePath string) *MinioHandler {
+	view := *h
+	view.store, view.bucketName = store, bucket
+	view.basePath = basePath
+	view.tenants = nil
+	view.buckets = nil
+	// Tokens were checked before the view was picked.
+	view.auth = nil
+	view.visibility = nil
+	view.quotas = nil
+	view.cache = nil
+	view.recent = nil
+	view.expiryNotices = nil
+	view.jobs = newJobRegistry()
+	view.variantBuilds = new(singleflight.Group)
+	if h.presigned != nil {
+		view.presigned = newPresignCache(h.presigned.maxEntries, h.presigned.margin)
+	}
+	if h.dataURIs != nil {
+		view.dataURIs = newDataURICache(h.dataURIs.ttl)
+	}
+	if h.usage != nil {
+		view.usage = newUsageCache(h.usage.ttl)
+	}
+	if h.usageTrees != nil {
+		view.usageTrees = newUsageTreeCache(h.usageTrees.ttl)
+	}
+	if h.shares != nil {
+		view.shares = &shareLinks{defaultExpiry: h.shares.defaultExpiry, maxExpiry: h.shares.maxExpiry, links: make(map[string]shareLink)}
+	}
+	return &view
+}
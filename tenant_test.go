@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTenantConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "tenants.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	configs, err := loadTenantConfigs(write(`{"acme": {"endpoint": "minio.acme:9000", "access_key": "a", "secret_key": "s", "bucket": "files", "use_ssl": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := configs["acme"]; c.Bucket != "files" || c.secure() {
+		t.Errorf("acme = %+v", c)
+	}
+	for _, bad := range []string{
+		`{"acme": {"endpoint": "minio.acme:9000", "access_key": "a", "secret_key": "s"}}`,
+		`{"Acme/1": {"endpoint": "e", "access_key": "a", "secret_key": "s", "bucket": "b"}}`,
+		`not json`,
+	} {
+		if _, err := loadTenantConfigs(write(bad)); err == nil {
+			t.Errorf("loadTenantConfigs(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestTenantRouting(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "main.txt", []byte("main"), "text/plain")
+	acme := newFakeStore("acme-files")
+	acme.put("acme-files", "acme.txt", []byte("acme"), "text/plain")
+	created := 0
+	h.tenants = newTenantRouter("X-Tenant", map[string]tenantConfig{"acme": {Bucket: "acme-files"}}, func(tenantConfig) (ObjectStore, error) {
+		created++
+		return acme, nil
+	})
+
+	get := func(target, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		return serve(h, req)
+	}
+
+	if rec := get("/stat/acme.txt", "acme"); rec.Code != http.StatusOK {
+		t.Errorf("header-selected tenant: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/tenants/acme/stat/acme.txt", ""); rec.Code != http.StatusOK {
+		t.Errorf("path-selected tenant: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/stat/main.txt", "acme"); rec.Code != http.StatusNotFound {
+		t.Errorf("main bucket object through tenant: status %d", rec.Code)
+	}
+	if rec := get("/stat/main.txt", ""); rec.Code != http.StatusOK {
+		t.Errorf("no tenant: status %d", rec.Code)
+	}
+	if rec := get("/stat/acme.txt", "globex"); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Unknown tenant") {
+		t.Errorf("unknown tenant: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/tenants/acme/stat/acme.txt", "globex"); rec.Code != http.StatusBadRequest {
+		t.Errorf("header and path disagree: status %d", rec.Code)
+	}
+	if created != 1 {
+		t.Errorf("tenant store created %d times, want 1", created)
+	}
+}
+
+func TestTenantShareLinks(t *testing.T) {
+	h, _ := newTestHandler(t)
+	acme := newFakeStore("acme-files")
+	acme.put("acme-files", "acme.txt", []byte("acme"), "text/plain")
+	h.tenants = newTenantRouter("X-Tenant", map[string]tenantConfig{"acme": {Bucket: "acme-files"}}, func(tenantConfig) (ObjectStore, error) {
+		return acme, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/share", strings.NewReader(`{"object": "acme.txt"}`))
+	req.Header.Set("X-Tenant", "acme")
+	rec := serve(h, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share status = %d: %s", rec.Code, rec.Body)
+	}
+	var share shareResponse
+	decodeJSON(t, rec, &share)
+	if !strings.Contains(share.URL, "/tenants/acme/s/"+share.Token) {
+		t.Errorf("share URL = %q, want it under /tenants/acme/", share.URL)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/s/"+share.Token, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("tenant token redeemed without the tenant: status %d", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/tenants/acme/s/"+share.Token, nil)); rec.Code != http.StatusFound {
+		t.Errorf("tenant share link: status %d: %s", rec.Code, rec.Body)
+	}
+}
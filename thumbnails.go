@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// thumbnailDir is the folder, next to each image, that holds its thumbnail:
+// photos/cat.jpg gets photos/thumbs/cat.jpg.
+const thumbnailDir = "thumbs"
+
+// maxThumbnailFailures caps the failed keys a thumbnail job reports.
+const maxThumbnailFailures = 100
+
+// thumbnailLimits sizes thumbnails and bounds the images decoded for them.
+type thumbnailLimits struct {
+	size      int
+	maxBytes  int64
+	maxPixels int64
+}
+
+// loadThumbnailLimits reads MINIO_THUMBNAIL_SIZE, MINIO_THUMBNAIL_MAX_BYTES
+// and MINIO_THUMBNAIL_MAX_PIXELS.
+func loadThumbnailLimits() thumbnailLimits {
+	return thumbnailLimits{
+		size:      int(max(envInt64("MINIO_THUMBNAIL_SIZE", 256), 1)),
+		maxBytes:  envInt64("MINIO_THUMBNAIL_MAX_BYTES", 50<<20),
+		maxPixels: envInt64("MINIO_THUMBNAIL_MAX_PIXELS", 40_000_000),
+	}
+}
+
+// thumbnailKey returns where the thumbnail of objectName is stored.
+func thumbnailKey(objectName string) string {
+	dir, base := path.Split(objectName)
+	return dir + thumbnailDir + "/" + base
+}
+
+// isThumbnailSource reports whether objectName is an image that gets a
+// thumbnail: a JPEG, PNG or GIF that isn't itself in a thumbs/ folder.
+func isThumbnailSource(objectName string) bool {
+	dir, _ := path.Split(objectName)
+	if slices.Contains(strings.Split(dir, "/"), thumbnailDir) {
+		return false
+	}
+	switch strings.ToLower(path.Ext(objectName)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// errPixelLimit is returned for images over MINIO_THUMBNAIL_MAX_PIXELS.
+var errPixelLimit = errors.New("image is over the MINIO_THUMBNAIL_MAX_PIXELS limit")
+
+// decodeImage decodes a JPEG, PNG or GIF, returning its format, after
+// checking its dimensions against maxPixels so a small file can't expand
+// into a huge bitmap. Other data fails with image.ErrFormat.
+func decodeImage(data []byte, maxPixels int64) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d", errPixelLimit, cfg.Width, cfg.Height)
+	}
+	var img image.Image
+	switch format {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	return img, format, err
+}
+
+// makeThumbnail decodes an image and scales it to fit in a size×size box.
+// JPEGs stay JPEG; PNGs and GIFs become PNG to keep their transparency.
+func makeThumbnail(data []byte, limits thumbnailLimits) ([]byte, string, error) {
+	img, format, err := decodeImage(data, limits.maxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var out bytes.Buffer
+	thumb := scaleToFit(img, limits.size)
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, thumb, &jpeg.Options{Quality: 85})
+		return out.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&out, thumb)
+	return out.Bytes(), "image/png", err
+}
+
+// scaleToFit shrinks src to fit in a size×size box, averaging the source
+// pixels behind each thumbnail pixel. Smaller images are returned as is.
+func scaleToFit(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	tw, th := size, max(h*size/w, 1)
+	if h > w {
+		tw, th = max(w*size/h, 1), size
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := range th {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := range tw {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// thumbnailProgress is the progress payload of a thumbnail backfill job. In
+// a dry run, Created counts the thumbnails that would be created.
+type thumbnailProgress struct {
+	Prefix  string `json:"prefix"`
+	DryRun  bool   `json:"dry_run"`
+	Images  int    `json:"images"`
+	Created int    `json:"created"`
+	Skipped int    `json:"skipped"`
+	Failed  int    `json:"failed"`
+	// FailedKeys lists up to maxThumbnailFailures images that failed.
+	FailedKeys []string `json:"failed_keys,omitempty"`
+}
+
+// generateThumbnailsHandler starts a background job that creates the
+// missing thumbnails of the images under ?prefix=, skipping those whose
+// thumbs/ sibling already exists. With ?dry_run=true it only counts them.
+func (h *MinioHandler) generateThumbnailsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	progress := thumbnailProgress{Prefix: q.Get("prefix"), DryRun: q.Get("dry_run") == "true"}
+	j := h.jobs.start("thumbnails", progress)
+	id := j.snapshot().ID
+	go func() {
+		err := h.backfillThumbnails(context.Background(), j, progress)
+		if err != nil {
+			logger(r.Context()).Error("Thumbnail job failed", "job", id, "prefix", progress.Prefix, "err", err)
+		}
+		j.finish(err)
+	}()
+
+	writeJSON(w, r, http.StatusAccepted, map[string]string{
+		"id":         id,
+		"status_url": "/jobs/" + id,
+		"events_url": "/jobs/" + id + "/events",
+	})
+}
+
+// backfillThumbnails lists the images under p.Prefix and creates missing
+// thumbnails with up to bulkConcurrency at a time, reporting progress to j
+// after each image. Failures of single images are counted, not returned.
+func (h *MinioHandler) backfillThumbnails(ctx context.Context, j *job, p thumbnailProgress) error {
+	var mu sync.Mutex
+	record := func(update func(*thumbnailProgress)) {
+		mu.Lock()
+		defer mu.Unlock()
+		update(&p)
+		snapshot := p
+		snapshot.FailedKeys = slices.Clone(p.FailedKeys)
+		j.setProgress(snapshot)
+	}
+
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	var listErr error
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: p.Prefix, Recursive: true}) {
+		if object.Err != nil {
+			listErr = object.Err
+			break
+		}
+		if !isThumbnailSource(object.Key) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+			created, err := h.ensureThumbnail(ctx, key, p.DryRun)
+			record(func(p *thumbnailProgress) {
+				p.Images++
+				switch {
+				case err != nil:
+					logger(ctx).Error("Error creating thumbnail", "object", key, "err", err)
+					p.Failed++
+					if len(p.FailedKeys) < maxThumbnailFailures {
+						p.FailedKeys = append(p.FailedKeys, key)
+					}
+				case created:
+					p.Created++
+				default:
+					p.Skipped++
+				}
+			})
+		}(object.Key)
+	}
+	wg.Wait()
+	if listErr != nil {
+		return fmt.Errorf("listing '%s': %w", p.Prefix, listErr)
+	}
+	return nil
+}
+
+// ensureThumbnail creates the thumbnail of objectName unless it already
+// exists, reporting whether it did (or, in a dry run, would).
+func (h *MinioHandler) ensureThumbnail(ctx context.Context, objectName string, dryRun bool) (bool, error) {
+	thumbKey := thumbnailKey(objectName)
+	if _, err := h.store.StatObject(ctx, h.bucketName, thumbKey, minio.StatObjectOptions{}); err == nil {
+		return false, nil
+	} else if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return false, err
+	}
+	if dryRun {
+		return true, nil
+	}
+	// The build outlives a caller that gives up, since others may wait on it.
+	_, err, _ := h.variantBuilds.Do(thumbKey, func() (any, error) {
+		return nil, h.buildThumbnail(context.WithoutCancel(ctx), objectName, thumbKey)
+	})
+	return err == nil, err
+}
+
+// buildThumbnail makes the thumbnail of objectName and stores it as
+// thumbKey.
+func (h *MinioHandler) buildThumbnail(ctx context.Context, objectName, thumbKey string) error {
+	obj, err := h.store.GetObject(ctx, h.bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(io.LimitReader(obj, h.thumbnails.maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > h.thumbnails.maxBytes {
+		return fmt.Errorf("image is over the %d byte limit", h.thumbnails.maxBytes)
+	}
+	thumb, contentType, err := makeThumbnail(data, h.thumbnails)
+	if err != nil {
+		return err
+	}
+	opts, _ := h.uploadOptions(thumbKey, contentType)
+	if _, err := h.store.PutObject(ctx, h.bucketName, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), opts); err != nil {
+		return err
+	}
+	h.cache.invalidate(thumbKey)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnailKey(t *testing.T) {
+	for in, want := range map[string]string{"cat.jpg": "thumbs/cat.jpg", "photos/2024/cat.jpg": "photos/2024/thumbs/cat.jpg"} {
+		if got := thumbnailKey(in); got != want {
+			t.Errorf("thumbnailKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if isThumbnailSource("photos/thumbs/cat.jpg") || isThumbnailSource("notes.txt") || !isThumbnailSource("a/B.PNG") {
+		t.Error("isThumbnailSource misclassified a key")
+	}
+}
+
+// runThumbnailJob starts a backfill and waits for it to finish.
+func runThumbnailJob(t *testing.T, h *MinioHandler, query string) thumbnailProgress {
+	t.Helper()
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/admin/generate-thumbnails"+query, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+	}
+	var started map[string]string
+	decodeJSON(t, rec, &started)
+	var status jobStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, started["status_url"], nil)), &status)
+		if status.State != jobRunning {
+			break
+		}
+	}
+	if status.State != jobDone {
+		t.Fatalf("job = %+v, want done", status)
+	}
+	var p thumbnailProgress
+	raw, _ := json.Marshal(status.Progress)
+	json.Unmarshal(raw, &p)
+	return p
+}
+
+func TestGenerateThumbnails(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "photos/wide.png", testPNG(t, 64, 32), "image/png")
+	store.put(testBucket, "photos/done.png", testPNG(t, 8, 8), "image/png")
+	store.put(testBucket, "photos/thumbs/done.png", testPNG(t, 8, 8), "image/png")
+	store.put(testBucket, "photos/broken.jpg", []byte("not a jpeg"), "image/jpeg")
+	store.put(testBucket, "photos/notes.txt", []byte("x"), "text/plain")
+	store.put(testBucket, "other/skip.png", testPNG(t, 8, 8), "image/png")
+
+	p := runThumbnailJob(t, h, "?prefix=photos/&dry_run=true")
+	if p.Images != 3 || p.Created != 2 || p.Skipped != 1 || p.Failed != 0 {
+		t.Errorf("dry run = %+v", p)
+	}
+	if _, ok := store.object(testBucket, "photos/thumbs/wide.png"); ok {
+		t.Fatal("dry run created a thumbnail")
+	}
+
+	p = runThumbnailJob(t, h, "?prefix=photos/")
+	if p.Images != 3 || p.Created != 1 || p.Skipped != 1 || p.Failed != 1 || p.FailedKeys[0] != "photos/broken.jpg" {
+		t.Errorf("progress = %+v", p)
+	}
+	data, ok := store.object(testBucket, "photos/thumbs/wide.png")
+	if !ok {
+		t.Fatal("thumbnail not created")
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 16 || cfg.Height != 8 {
+		t.Errorf("thumbnail is %dx%d (%v), want 16x8", cfg.Width, cfg.Height, err)
+	}
+	if _, ok := store.object(testBucket, "other/thumbs/skip.png"); ok {
+		t.Error("image outside the prefix got a thumbnail")
+	}
+}
+
+func TestThumbnailBuildsSeparateTenants(t *testing.T) {
+	h, _ := newTestHandler(t)
+	stores := map[string]*fakeStore{"acme": newFakeStore("files"), "globex": newFakeStore("files")}
+	views := map[string]*MinioHandler{}
+	for name, store := range stores {
+		store.put("files", "a.png", testPNG(t, 32, 32), "image/png")
+		views[name] = h.tenantView(name, store, "files")
+	}
+
+	// A build still running for acme must not be joined by globex, whose
+	// bucket has the same name but lives in another store.
+	started, release := make(chan struct{}), make(chan struct{})
+	go views["acme"].variantBuilds.Do(thumbnailKey("a.png"), func() (any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	defer close(release)
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := views["globex"].ensureThumbnail(t.Context(), "a.png", false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("globex waited on acme's build")
+	}
+	if _, ok := stores["globex"].object("files", thumbnailKey("a.png")); !ok {
+		t.Error("globex thumbnail not stored in globex's store")
+	}
+	if _, ok := stores["acme"].object("files", thumbnailKey("a.png")); ok {
+		t.Error("acme's store got globex's thumbnail")
+	}
+}
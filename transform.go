@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// maxTransformFilters caps the length of a /transform pipeline.
+	maxTransformFilters = 8
+	// maxTransformLine is the longest line head and grep accept.
+	maxTransformLine = 1 << 20
+	// maxGrepPattern caps the length of a grep expression.
+	maxGrepPattern = 256
+)
+
+// errTransformLimit ends a transform whose gunzip output passed
+// MINIO_TRANSFORM_MAX_BYTES.
+var errTransformLimit = errors.New("decompressed data is over MINIO_TRANSFORM_MAX_BYTES")
+
+// transformFilter wraps a stream in one step of a /transform pipeline.
+type transformFilter struct {
+	name string
+	// text is set for filters whose output is text.
+	text bool
+	wrap func(src io.Reader, maxBytes int64) (io.Reader, error)
+}
+
+// parsePipeline parses a comma-separated pipeline such as
+// "gunzip,grep:ERROR,head:100". Filters:
+//
+//	gunzip      decompress gzip
+//	head:N      the first N lines
+//	grep:RE     lines matching the regular expression RE (no commas)
+//	base64      encode as standard base64
+func parsePipeline(spec string) ([]transformFilter, error) {
+	if spec == "" {
+		return nil, errors.New("pipeline is required, e.g. ?pipeline=gunzip,head:1000")
+	}
+	steps := strings.Split(spec, ",")
+	if len(steps) > maxTransformFilters {
+		return nil, fmt.Errorf("pipeline has %d filters, at most %d are allowed", len(steps), maxTransformFilters)
+	}
+	var filters []transformFilter
+	for _, step := range steps {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(step), ":")
+		f := transformFilter{name: name}
+		switch {
+		case name == "gunzip" && !hasArg:
+			f.wrap = func(src io.Reader, maxBytes int64) (io.Reader, error) {
+				zr, err := gzip.NewReader(src)
+				if err != nil {
+					return nil, fmt.Errorf("gunzip: %w", err)
+				}
+				return &capReader{r: zr, remaining: maxBytes}, nil
+			}
+		case name == "head" && hasArg:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("head:%s: the line count must be a positive number", arg)
+			}
+			f.text = true
+			f.wrap = func(src io.Reader, _ int64) (io.Reader, error) {
+				lines := 0
+				return newLineFilter(src, func([]byte) (bool, bool) {
+					lines++
+					return true, lines == n
+				}), nil
+			}
+		case name == "grep" && hasArg:
+			if arg == "" || len(arg) > maxGrepPattern {
+				return nil, fmt.Errorf("grep: the expression must be 1 to %d bytes", maxGrepPattern)
+			}
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("grep: %v", err)
+			}
+			f.text = true
+			f.wrap = func(src io.Reader, _ int64) (io.Reader, error) {
+				return newLineFilter(src, func(line []byte) (bool, bool) {
+					return re.Match(bytes.TrimRight(line, "\r\n")), false
+				}), nil
+			}
+		case name == "base64" && !hasArg:
+			f.text = true
+			f.wrap = func(src io.Reader, _ int64) (io.Reader, error) {
+				return newBase64Reader(src), nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown filter %q (use gunzip, head:N, grep:RE or base64)", step)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// capReader fails once more than remaining bytes have been read from r.
+type capReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		// Data that ends right at the cap is still fine.
+		var probe [1]byte
+		if n, err := c.r.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errTransformLimit
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// lineFilter passes on the lines of src that keep accepts, stopping after
+// the line for which it reports done.
+type lineFilter struct {
+	src     *bufio.Reader
+	keep    func(line []byte) (emit, done bool)
+	pending []byte
+	done    bool
+	err     error
+}
+
+func newLineFilter(src io.Reader, keep func([]byte) (bool, bool)) *lineFilter {
+	return &lineFilter{src: bufio.NewReaderSize(src, maxTransformLine), keep: keep}
+}
+
+func (f *lineFilter) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		switch {
+		case f.done:
+			return 0, io.EOF
+		case f.err != nil:
+			return 0, f.err
+		}
+		line, err := f.src.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return 0, fmt.Errorf("line longer than %d bytes", maxTransformLine)
+		}
+		if len(line) > 0 {
+			emit, done := f.keep(line)
+			if emit {
+				f.pending = line
+			}
+			f.done = done
+		}
+		f.err = err
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+// base64Reader encodes src as standard base64, without line breaks.
+type base64Reader struct {
+	src     io.Reader
+	in      [3 * 1024]byte
+	out     []byte
+	pending []byte
+	eof     bool
+}
+
+func newBase64Reader(src io.Reader) *base64Reader {
+	return &base64Reader{src: src, out: make([]byte, base64.StdEncoding.EncodedLen(3*1024))}
+}
+
+func (b *base64Reader) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.eof {
+			return 0, io.EOF
+		}
+		// Whole 3-byte groups are encoded until the end, so padding only
+		// appears there.
+		n, err := io.ReadFull(b.src, b.in[:])
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			b.eof = true
+		case err != nil:
+			return 0, err
+		}
+		base64.StdEncoding.Encode(b.out, b.in[:n])
+		b.pending = b.out[:base64.StdEncoding.EncodedLen(n)]
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// transformHandler streams /transform/{objectName} through the filters in
+// ?pipeline=, so clients can slice huge objects without downloading them.
+// Invalid pipelines, and objects gunzip can't read, are rejected with 400
+// before anything is sent. Decompressed data is capped at
+// MINIO_TRANSFORM_MAX_BYTES; a stream that passes it ends early.
+func (h *MinioHandler) transformHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/transform/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /transform/logs/app.log.gz?pipeline=gunzip,head:100)", http.StatusBadRequest)
+		return
+	}
+	filters, err := parsePipeline(r.URL.Query().Get("pipeline"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, _, err := h.openObject(r.Context(), objectName)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			logger(r.Context()).Error("Error opening object for transform", "object", objectName, "err", err)
+		}
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+
+	maxBytes := h.transformMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 1 << 30
+	}
+	var out io.Reader = body
+	contentType := "application/octet-stream"
+	for _, f := range filters {
+		if out, err = f.wrap(out, maxBytes); err != nil {
+			http.Error(w, fmt.Sprintf("Can't transform '%s': %v", objectName, err), http.StatusBadRequest)
+			return
+		}
+		if f.text {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, out); err != nil {
+		logger(r.Context()).Error("Error transforming object", "object", objectName, "pipeline", r.URL.Query().Get("pipeline"), "err", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestTransformPipeline(t *testing.T) {
+	h, store := newTestHandler(t)
+	var log strings.Builder
+	for i := range 100 {
+		level := "INFO"
+		if i%10 == 0 {
+			level = "ERROR"
+		}
+		fmt.Fprintf(&log, "%s line %d\n", level, i)
+	}
+	store.put(testBucket, "app.log.gz", gzipped(t, log.String()), "application/gzip")
+
+	for _, tc := range []struct {
+		pipeline, want string
+	}{
+		{"gunzip,head:2", "ERROR line 0\nINFO line 1\n"},
+		{"gunzip,grep:^ERROR,head:3", "ERROR line 0\nERROR line 10\nERROR line 20\n"},
+		{"gunzip,grep:line 9[89]$", "INFO line 98\nINFO line 99\n"},
+		{"gunzip,head:1,base64", base64.StdEncoding.EncodeToString([]byte("ERROR line 0\n"))},
+	} {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/transform/app.log.gz?pipeline="+strings.ReplaceAll(tc.pipeline, " ", "+"), nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Errorf("%s = %d %q, want %q", tc.pipeline, rec.Code, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestTransformRejectsBadPipelines(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, "plain.txt", []byte("not gzip\n"), "text/plain")
+
+	for _, pipeline := range []string{"", "rot13", "head:0", "head", "grep:(", "gunzip:1", strings.Repeat("base64,", 8) + "base64", "gunzip"} {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/transform/plain.txt?pipeline="+pipeline, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("pipeline %q: status = %d, want 400", pipeline, rec.Code)
+		}
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/transform/missing.txt?pipeline=base64", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing object: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestTransformCapsDecompressedBytes(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.transformMaxBytes = 1000
+	store.put(testBucket, "bomb.gz", gzipped(t, strings.Repeat("a", 100000)), "application/gzip")
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/transform/bomb.gz?pipeline=gunzip", nil))
+	if rec.Body.Len() > 1000 {
+		t.Errorf("streamed %d bytes, want at most 1000", rec.Body.Len())
+	}
+}
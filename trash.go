@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// trashIDLayout names the trash entries of an object by deletion time, so
+// they sort oldest first.
+const trashIDLayout = "20060102T150405.000000000Z"
+
+// maxListedTrash caps the entries one /trash/list response returns.
+const maxListedTrash = 1000
+
+// trashSettings configure soft deletes. Deleted objects are moved to
+// {prefix}{key}/{id}, where id is the deletion time: the entry's name is
+// its tombstone, recording what was deleted and when.
+type trashSettings struct {
+	prefix string
+	// retention is how long entries are kept before the purger removes
+	// them; zero disables the trash and deletes are permanent.
+	retention time.Duration
+}
+
+// loadTrashSettings reads MINIO_TRASH_RETENTION and MINIO_TRASH_PREFIX.
+func loadTrashSettings() (trashSettings, error) {
+	s := trashSettings{prefix: ".trash/", retention: envDuration("MINIO_TRASH_RETENTION", 0)}
+	if v, ok := os.LookupEnv("MINIO_TRASH_PREFIX"); ok {
+		if v == "" || v == "/" || !strings.HasSuffix(v, "/") {
+			return s, fmt.Errorf("MINIO_TRASH_PREFIX must be a folder such as .trash/, got %q", v)
+		}
+		s.prefix = v
+	}
+	return s, nil
+}
+
+func (s trashSettings) root() string {
+	if s.prefix == "" {
+		return ".trash/"
+	}
+	return s.prefix
+}
+
+// holds reports whether key is in the trash while it is enabled. Such keys
+// are only reached through the /trash endpoints.
+func (s trashSettings) holds(key string) bool {
+	return s.retention > 0 && strings.HasPrefix(key, s.root())
+}
+
+// trashReserved answers for naming an object under the trash folder root.
+func trashReserved(root string) string {
+	return "Objects under " + root + " are in the trash; use /trash/list and /trash/restore/"
+}
+
+// hiddenKey reports whether key is /tus upload state or in the trash, which
+// listings, archives and usage totals leave out.
+func (h *MinioHandler) hiddenKey(key string) bool {
+	return isTusState(key) || h.trash.holds(key)
+}
+
+// outsideTrash serves next unless the object named after prefix is in the
+// trash, which answers 404 as if it were gone.
+func (h *MinioHandler) outsideTrash(prefix string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.trash.holds(objectNameFromPath(r, prefix)) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// entry splits the name of a trash entry into the object it holds and its
+// deletion time, returning false for other names.
+func (s trashSettings) entry(name string) (key string, deleted time.Time, ok bool) {
+	rest, ok := strings.CutPrefix(name, s.root())
+	i := strings.LastIndex(rest, "/")
+	if !ok || i <= 0 {
+		return "", time.Time{}, false
+	}
+	deleted, err := time.Parse(trashIDLayout, rest[i+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return rest[:i], deleted, true
+}
+
+// removeObject deletes objectName, moving it to the trash when the trash
+// is enabled, unless permanent is set or it is in the trash already. key is
+// the SSE-C key the request carries, which an object stored with one needs
+// to be moved to the trash, and keeps there.
+func (h *MinioHandler) removeObject(ctx context.Context, objectName string, permanent bool, key encrypt.ServerSide) error {
+	if h.trash.retention <= 0 || permanent || strings.HasPrefix(objectName, h.trash.root()) {
+		return h.store.RemoveObject(ctx, h.bucketName, objectName, minio.RemoveObjectOptions{})
+	}
+	src, err := h.store.StatObject(ctx, h.bucketName, objectName, minio.StatObjectOptions{ServerSideEncryption: key})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		// Deleting a missing object succeeds, as in S3.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dst := h.trash.root() + objectName + "/" + time.Now().UTC().Format(trashIDLayout)
+	if _, err := h.copyObject(ctx, src, dst, h.sse.forCopy(key)); err != nil {
+		return fmt.Errorf("moving to trash: %w", err)
+	}
+	if err := h.store.RemoveObject(ctx, h.bucketName, objectName, minio.RemoveObjectOptions{}); err != nil {
+		// Without the copy a retry starts clean, instead of leaving two
+		// entries to restore from.
+		h.store.RemoveObject(context.WithoutCancel(ctx), h.bucketName, dst, minio.RemoveObjectOptions{})
+		return err
+	}
+	return nil
+}
+
+// removeObjectsToTrash is removeObject for a batch of keys, bulkConcurrency
+// at a time, returning the errors by key.
+func (h *MinioHandler) removeObjectsToTrash(ctx context.Context, keys []string, sseKey encrypt.ServerSide) map[string]error {
+	var mu sync.Mutex
+	errs := map[string]error{}
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if err := h.removeObject(ctx, key, false, sseKey); err != nil {
+				mu.Lock()
+				errs[key] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// copyObject copies src to dst within the bucket server side, part by part
+// for objects over 5 GiB, as long as src keeps its ETag. The copy is
+// encrypted as enc says.
+func (h *MinioHandler) copyObject(ctx context.Context, src minio.ObjectInfo, dst string, enc copyEncryption) (minio.UploadInfo, error) {
+	if parts := copyParts(src.Size); parts > 1 {
+		return h.multipartCopy(ctx, h.bucketName, src, h.bucketName, dst, parts, time.Time{}, enc, func(int, int64) {})
+	}
+	return h.store.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: h.bucketName, Object: dst, Encryption: enc.dst},
+		minio.CopySrcOptions{Bucket: h.bucketName, Object: src.Key, MatchETag: src.ETag, Encryption: enc.src})
+}
+
+type trashEntry struct {
+	Key       string    `json:"key"`
+	ID        string    `json:"id"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// PurgeAt is when the purger removes the entry, unset while the
+	// trash is disabled.
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// trashListHandler lists deleted objects under ?prefix= of their original
+// names (GET /trash/list), up to maxListedTrash entries from ?after=.
+// An object deleted more than once has an entry per deletion, oldest
+// first.
+func (h *MinioHandler) trashListHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	resp := struct {
+		Prefix    string       `json:"prefix,omitempty"`
+		Items     []trashEntry `json:"items"`
+		Truncated bool         `json:"truncated"`
+		Next      string       `json:"next,omitempty"`
+	}{Prefix: q.Get("prefix"), Items: []trashEntry{}}
+	root := h.trash.root()
+	after := ""
+	if v := q.Get("after"); v != "" {
+		after = root + v
+	}
+	for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: root + resp.Prefix, Recursive: true, StartAfter: after}) {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing trash", "prefix", resp.Prefix, "err", object.Err)
+			h.storeFailed(w, "Failed to list trash", object.Err)
+			return
+		}
+		key, deleted, ok := h.trash.entry(object.Key)
+		if !ok {
+			continue
+		}
+		if len(resp.Items) == maxListedTrash {
+			resp.Truncated = true
+			break
+		}
+		item := trashEntry{Key: key, ID: deleted.Format(trashIDLayout), Size: object.Size, DeletedAt: deleted}
+		if h.trash.retention > 0 {
+			purge := deleted.Add(h.trash.retention)
+			item.PurgeAt = &purge
+		}
+		resp.Items = append(resp.Items, item)
+		resp.Next = strings.TrimPrefix(object.Key, root)
+	}
+	if !resp.Truncated {
+		resp.Next = ""
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// trashRestoreHandler puts a deleted object back under its name (POST
+// /trash/restore/{objectName}), from the entry ?id= or else the latest
+// one, and removes that entry. An object that exists again under the name
+// is only replaced with ?overwrite=true.
+func (h *MinioHandler) trashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/trash/restore/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /trash/restore/docs/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Entries of the object are the files right under its trash folder;
+	// deeper ones belong to objects named below it.
+	folder := h.trash.root() + objectName + "/"
+	var src minio.ObjectInfo
+	for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: folder}) {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing trash", "object", objectName, "err", object.Err)
+			h.storeFailed(w, "Failed to list trash", object.Err)
+			return
+		}
+		key, deleted, ok := h.trash.entry(object.Key)
+		if !ok || key != objectName {
+			continue
+		}
+		if id := q.Get("id"); id == "" || id == deleted.Format(trashIDLayout) {
+			src = object
+		}
+	}
+	if src.Key == "" {
+		http.Error(w, "Object not found in trash", http.StatusNotFound)
+		return
+	}
+	if q.Get("overwrite") != "true" {
+		_, err := h.store.StatObject(r.Context(), h.bucketName, objectName, minio.StatObjectOptions{})
+		if err == nil {
+			http.Error(w, fmt.Sprintf("'%s' exists again; add ?overwrite=true to replace it", objectName), http.StatusConflict)
+			return
+		}
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			logger(r.Context()).Error("Error checking object before restore", "object", objectName, "err", err)
+			h.storeFailed(w, "Failed to restore file", err)
+			return
+		}
+	}
+
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	info, err := h.copyObject(ctx, src, objectName, h.sse.forCopy(key))
+	if err != nil {
+		logger(r.Context()).Error("Error restoring object from trash", "object", objectName, "entry", src.Key, "err", err)
+		h.storeFailed(w, "Failed to restore file", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	if err := h.store.RemoveObject(ctx, h.bucketName, src.Key, minio.RemoveObjectOptions{}); err != nil {
+		// The object is back; the stale entry is purged in due course.
+		logger(r.Context()).Warn("Error removing restored trash entry", "entry", src.Key, "err", err)
+	}
+	_, deleted, _ := h.trash.entry(src.Key)
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"key":  objectName,
+		"id":   deleted.Format(trashIDLayout),
+		"size": src.Size,
+		"etag": info.ETag,
+	})
+}
+
+// startTrashPurger runs runTrashPurger for h's bucket until ctx is done,
+// if the trash is enabled.
+func (h *MinioHandler) startTrashPurger(ctx context.Context) {
+	if h.trash.retention > 0 {
+		go h.runTrashPurger(ctx, min(h.trash.retention, time.Hour))
+	}
+}
+
+// runTrashPurger removes trash entries older than the retention period
+// every interval until ctx is done.
+func (h *MinioHandler) runTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if h.readOnly.Load() {
+				continue
+			}
+			purged, err := h.purgeTrash(ctx, time.Now())
+			if err != nil {
+				logger(ctx).Error("Error purging trash", "err", err)
+			}
+			if purged > 0 {
+				logger(ctx).Info("Purged trash", "objects", purged)
+			}
+		}
+	}
+}
+
+// purgeTrash permanently removes the trash entries deleted at least the
+// retention period before now.
+func (h *MinioHandler) purgeTrash(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: h.trash.root(), Recursive: true}) {
+		if object.Err != nil {
+			return purged, object.Err
+		}
+		_, deleted, ok := h.trash.entry(object.Key)
+		if !ok || now.Sub(deleted) < h.trash.retention {
+			continue
+		}
+		if err := h.store.RemoveObject(ctx, h.bucketName, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			logger(ctx).Error("Error purging trash entry", "entry", object.Key, "err", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func trashKeys(t *testing.T, store *fakeStore) []string {
+	t.Helper()
+	var keys []string
+	for object := range store.ListObjects(t.Context(), testBucket, minio.ListObjectsOptions{Prefix: ".trash/", Recursive: true}) {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func TestDeleteMovesToTrash(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.trash = trashSettings{retention: 24 * time.Hour}
+	store.put(testBucket, "docs/report.pdf", []byte("v1"), "application/pdf")
+
+	if rec := serve(h, httptest.NewRequest(http.MethodDelete, "/delete/docs/report.pdf", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := store.object(testBucket, "docs/report.pdf"); ok {
+		t.Fatal("object still exists after delete")
+	}
+	keys := trashKeys(t, store)
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], ".trash/docs/report.pdf/") {
+		t.Fatalf("trash = %v", keys)
+	}
+
+	var listed struct {
+		Items []trashEntry `json:"items"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/trash/list?prefix=docs/", nil)), &listed)
+	if len(listed.Items) != 1 || listed.Items[0].Key != "docs/report.pdf" || listed.Items[0].Size != 2 || listed.Items[0].PurgeAt == nil {
+		t.Errorf("trash list = %+v", listed.Items)
+	}
+
+	// A new object under the name blocks the restore unless overwritten.
+	store.put(testBucket, "docs/report.pdf", []byte("v2"), "application/pdf")
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/trash/restore/docs/report.pdf", nil)); rec.Code != http.StatusConflict {
+		t.Errorf("restore over an object: status = %d, want 409", rec.Code)
+	}
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/trash/restore/docs/report.pdf?overwrite=true&id="+listed.Items[0].ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := store.object(testBucket, "docs/report.pdf"); string(data) != "v1" {
+		t.Errorf("restored %q, want v1", data)
+	}
+	if keys := trashKeys(t, store); len(keys) != 0 {
+		t.Errorf("trash after restore = %v", keys)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/trash/restore/docs/report.pdf", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("restore with an empty trash: status = %d, want 404", rec.Code)
+	}
+
+	serve(h, httptest.NewRequest(http.MethodDelete, "/delete/docs/report.pdf?permanent=true", nil))
+	if keys := trashKeys(t, store); len(keys) != 0 {
+		t.Errorf("permanent delete went to the trash: %v", keys)
+	}
+}
+
+func TestDeleteBatchToTrashAndPurge(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.trash = trashSettings{retention: time.Hour}
+	store.put(testBucket, "tmp/a.txt", []byte("a"), "text/plain")
+	store.put(testBucket, "tmp/b.txt", []byte("b"), "text/plain")
+
+	var report batchDeleteReport
+	decodeJSON(t, postJSON(h, "/delete-batch", `{"prefix": "tmp/"}`), &report)
+	if report.Deleted != 2 || len(trashKeys(t, store)) != 2 {
+		t.Fatalf("report = %+v, trash = %v", report, trashKeys(t, store))
+	}
+
+	if n, err := h.purgeTrash(t.Context(), time.Now()); err != nil || n != 0 {
+		t.Errorf("purge before retention = %d, %v", n, err)
+	}
+	if n, err := h.purgeTrash(t.Context(), time.Now().Add(2*time.Hour)); err != nil || n != 2 {
+		t.Errorf("purge after retention = %d, %v; want 2", n, err)
+	}
+	if keys := trashKeys(t, store); len(keys) != 0 {
+		t.Errorf("trash after purge = %v", keys)
+	}
+}
+
+func TestTrashHiddenOutsideTrashEndpoints(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.trash = trashSettings{retention: time.Hour}
+	h.uploadRules.trashRoot = h.trash.root()
+	h.usage = newUsageCache(time.Hour)
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+	store.put(testBucket, "b.txt", []byte("b"), "text/plain")
+
+	serve(h, httptest.NewRequest(http.MethodDelete, "/delete/a.txt", nil))
+	keys := trashKeys(t, store)
+	if len(keys) != 1 {
+		t.Fatalf("trash = %v", keys)
+	}
+	for _, target := range []string{"/list", "/list?recursive=true", "/browse", "/export.csv"} {
+		if body := serve(h, httptest.NewRequest(http.MethodGet, target, nil)).Body.String(); strings.Contains(body, "a.txt") {
+			t.Errorf("%s lists the deleted object: %s", target, body)
+		}
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/download/"+keys[0], nil),
+		httptest.NewRequest(http.MethodGet, "/stat/"+keys[0], nil),
+		httptest.NewRequest(http.MethodDelete, "/delete/"+keys[0], nil),
+	} {
+		if rec := serve(h, req); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: status = %d, want 404", req.Method, req.URL, rec.Code)
+		}
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/raw/.trash/c.txt", strings.NewReader("c"))); rec.Code != http.StatusBadRequest {
+		t.Errorf("write into the trash: status = %d, want 400", rec.Code)
+	}
+	if rec := postJSON(h, "/copy", `{"source": "`+keys[0]+`", "destination": "c.txt"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("copy out of the trash: status = %d, want 400", rec.Code)
+	}
+	if u, err := h.computeUsage(t.Context(), ""); err != nil || u.Objects != 1 {
+		t.Errorf("usage = %+v, %v; want only b.txt", u, err)
+	}
+	if body := serve(h, httptest.NewRequest(http.MethodGet, "/trash/list", nil)).Body.String(); !strings.Contains(body, "a.txt") {
+		t.Errorf("/trash/list = %s", body)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// expiresAtMeta is the user metadata key (stored as x-amz-meta-expires-at)
+// holding an object's RFC 3339 expiry time.
+const expiresAtMeta = "Expires-At"
+
+// errExpired is returned in place of an expired object, shaped like S3's
+// own missing-key error so callers treat both the same way.
+func errExpired(objectName string) error {
+	return minio.ErrorResponse{
+		Code:       "NoSuchKey",
+		Message:    "The specified key has expired.",
+		Key:        objectName,
+		StatusCode: http.StatusNotFound,
+	}
+}
+
+// applyTTL stamps opts with an expiry time when the upload carries ?ttl=
+// (a Go duration such as 24h).
+func applyTTL(r *http.Request, opts *minio.PutObjectOptions) error {
+	v := r.URL.Query().Get("ttl")
+	if v == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return fmt.Errorf("ttl must be a positive duration such as 30m or 24h, got %q", v)
+	}
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = map[string]string{}
+	}
+	opts.UserMetadata["X-Amz-Meta-"+expiresAtMeta] = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	return nil
+}
+
+// objectExpiry returns the expiry stamped on an object, if any. Stat results
+// name the key without the x-amz-meta- prefix; MinIO listings keep it.
+func objectExpiry(info minio.ObjectInfo) (time.Time, bool) {
+	for k, v := range info.UserMetadata {
+		k = strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-")
+		if k != strings.ToLower(expiresAtMeta) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// expired reports whether info has an expiry time that has passed.
+func expired(info minio.ObjectInfo, now time.Time) bool {
+	t, ok := objectExpiry(info)
+	return ok && !now.Before(t)
+}
+
+// statObject is StatObject for reads: an object past its expiry is reported
+// as missing, even before the sweeper has removed it.
+func (h *MinioHandler) statObject(ctx context.Context, objectName string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	info, err := h.store.StatObject(ctx, h.bucketName, objectName, opts)
+	if err == nil && expired(info, time.Now()) {
+		return minio.ObjectInfo{}, errExpired(objectName)
+	}
+	return info, err
+}
+
+// runTTLSweeper removes expired objects every interval until ctx is done.
+func (h *MinioHandler) runTTLSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if h.readOnly.Load() {
+				continue
+			}
+			removed, err := h.sweepExpired(ctx)
+			if err != nil {
+				logger(ctx).Error("Error sweeping expired objects", "err", err)
+			}
+			if removed > 0 {
+				logger(ctx).Info("Removed expired objects", "objects", removed)
+			}
+		}
+	}
+}
+
+// sweepExpired lists the bucket and removes every object whose expiry has
+// passed. Listings that don't include metadata fall back to a stat per
+// object. Each object is stat'ed again right before removal so one that was
+// replaced in the meantime is left alone. Trash entries keep the expiry of
+// the object they were, for a restore to bring back, and are left to the
+// trash purger.
+func (h *MinioHandler) sweepExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+	removed := 0
+	objectCh := h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Recursive: true, WithMetadata: true})
+	for object := range objectCh {
+		if object.Err != nil {
+			return removed, object.Err
+		}
+		if strings.HasPrefix(object.Key, h.trash.root()) {
+			continue
+		}
+		if object.UserMetadata == nil {
+			info, err := h.store.StatObject(ctx, h.bucketName, object.Key, minio.StatObjectOptions{})
+			if err != nil {
+				continue
+			}
+			object.UserMetadata = info.UserMetadata
+		}
+		if !expired(object, now) {
+			continue
+		}
+		current, err := h.store.StatObject(ctx, h.bucketName, object.Key, minio.StatObjectOptions{})
+		if err != nil || !expired(current, now) {
+			continue
+		}
+		if err := h.store.RemoveObject(ctx, h.bucketName, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			logger(ctx).Error("Error removing expired object", "object", object.Key, "err", err)
+			continue
+		}
+		h.cache.invalidate(object.Key)
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// putWithExpiry stores an object stamped to expire at t.
+func putWithExpiry(t *testing.T, store *fakeStore, name string, at time.Time) {
+	t.Helper()
+	opts := minio.PutObjectOptions{UserMetadata: map[string]string{"X-Amz-Meta-Expires-At": at.UTC().Format(time.RFC3339)}}
+	if _, err := store.PutObject(t.Context(), testBucket, name, strings.NewReader(name), int64(len(name)), opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadWithTTL(t *testing.T) {
+	h, store := newTestHandler(t)
+	rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload?ttl=24h", "tmp.txt", "x"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	info, _ := store.StatObject(t.Context(), testBucket, "tmp.txt", minio.StatObjectOptions{})
+	at, ok := objectExpiry(info)
+	if want := time.Now().Add(24 * time.Hour); !ok || at.Before(want.Add(-time.Minute)) || at.After(want.Add(time.Minute)) {
+		t.Errorf("expiry = %v (set %v), want about %v", at, ok, want)
+	}
+
+	if rec := serve(h, newUploadRequest(t, http.MethodPost, "/upload?ttl=-1h", "bad.txt", "x")); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative ttl status = %d, want 400", rec.Code)
+	}
+}
+
+func TestExpiredObjectsAreNotFound(t *testing.T) {
+	h, store := newTestHandler(t)
+	putWithExpiry(t, store, "old.txt", time.Now().Add(-time.Minute))
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		if rec := serve(h, httptest.NewRequest(method, "/get-download-link/old.txt", nil)); rec.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d, want 404", method, rec.Code)
+		}
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/datauri/old.txt", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("datauri status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	h, store := newTestHandler(t)
+	putWithExpiry(t, store, "old.txt", time.Now().Add(-time.Minute))
+	putWithExpiry(t, store, "fresh.txt", time.Now().Add(time.Hour))
+	store.put(testBucket, "forever.txt", []byte("x"), "text/plain")
+	// Trash entries are the purger's to remove.
+	trashed := h.trash.root() + "gone.txt/" + time.Now().UTC().Format(trashIDLayout)
+	putWithExpiry(t, store, trashed, time.Now().Add(-time.Minute))
+
+	removed, err := h.sweepExpired(t.Context())
+	if err != nil || removed != 1 {
+		t.Fatalf("sweepExpired = %d, %v; want 1, nil", removed, err)
+	}
+	for name, want := range map[string]bool{"old.txt": false, "fresh.txt": true, "forever.txt": true, trashed: true} {
+		if _, ok := store.object(testBucket, name); ok != want {
+			t.Errorf("%s exists = %v, want %v", name, ok, want)
+		}
+	}
+}
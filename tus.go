@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// tusVersion is the tus protocol version /tus speaks.
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+
+	// tusStatePrefix holds the state of unfinished /tus uploads: {id}.info,
+	// a JSON tusUpload, and {id}.part, the data received since the last
+	// part was sent to MinIO.
+	tusStatePrefix = "_tus/"
+
+	// maxObjectSize is the largest object S3 stores.
+	maxObjectSize = 5 << 40
+)
+
+// tusStateReserved answers for naming an object under tusStatePrefix.
+const tusStateReserved = "Objects under " + tusStatePrefix + " are reserved for upload state"
+
+// isTusState reports whether key is /tus upload state, which only /tus
+// writes and which listings leave out.
+func isTusState(key string) bool {
+	return strings.HasPrefix(key, tusStatePrefix)
+}
+
+// tusSettings configures /tus uploads.
+type tusSettings struct {
+	// maxSize caps Upload-Length; zero allows up to maxObjectSize.
+	maxSize int64
+	// expiry is how long an unfinished upload is kept; zero keeps it until
+	// it is finished or terminated.
+	expiry time.Duration
+}
+
+// loadTusSettings reads MINIO_TUS_MAX_SIZE (a size such as 50GiB) and
+// MINIO_TUS_EXPIRY.
+func loadTusSettings() (tusSettings, error) {
+	s := tusSettings{maxSize: 50 << 30, expiry: envDuration("MINIO_TUS_EXPIRY", 24*time.Hour)}
+	if v := os.Getenv("MINIO_TUS_MAX_SIZE"); v != "" {
+		n, err := parseByteSize(v)
+		if err != nil || n <= 0 || n > maxObjectSize {
+			return s, fmt.Errorf("MINIO_TUS_MAX_SIZE must be a size between 1B and 5TiB, got %q", v)
+		}
+		s.maxSize = n
+	}
+	return s, nil
+}
+
+func (s tusSettings) limit() int64 {
+	if s.maxSize == 0 {
+		return maxObjectSize
+	}
+	return s.maxSize
+}
+
+// tusUpload is the state of an unfinished upload, kept in MinIO so that it
+// survives restarts and can be resumed through any replica.
+type tusUpload struct {
+	ID     string `json:"id"`
+	Object string `json:"object"`
+	Length int64  `json:"length"`
+	Offset int64  `json:"offset"`
+	// PartSize is the size of every part but the last, grown past S3's
+	// 5 MiB minimum for uploads that would otherwise need over 10,000.
+	PartSize int64 `json:"part_size"`
+	// UploadID is the MinIO multipart upload the parts belong to.
+	UploadID string               `json:"upload_id"`
+	Parts    []minio.CompletePart `json:"parts"`
+	// Pending counts the bytes in {id}.part, less than PartSize.
+	Pending int64 `json:"pending"`
+	// Metadata is the Upload-Metadata header, echoed on HEAD.
+	Metadata string    `json:"metadata,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+}
+
+func tusInfoKey(id string) string { return tusStatePrefix + id + ".info" }
+func tusPartKey(id string) string { return tusStatePrefix + id + ".part" }
+
+// tusActive holds the IDs of uploads a PATCH is writing to, so a second
+// PATCH to the same upload is refused rather than interleaved. Across
+// replicas, conditional writes of the .info object catch the same race.
+var tusActive sync.Map
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated
+// pairs of a key and an optional base64 value.
+func parseTusMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	for pair := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata value of %q is not base64", key)
+		}
+		meta[key] = string(decoded)
+	}
+	return meta, nil
+}
+
+// tusHandler serves resumable uploads over the tus protocol (tus.io):
+// POST /tus/ creates an upload, HEAD /tus/{id} reports how much of it the
+// server has, PATCH /tus/{id} appends data at that offset and DELETE
+// /tus/{id} abandons it. Data is sent to MinIO as the parts of a
+// multipart upload, which is completed into the object once all of it
+// has arrived.
+func (h *MinioHandler) tusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.tus.limit(), 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "Tus-Resumable must be "+tusVersion, http.StatusPreconditionFailed)
+		return
+	}
+	if r.Method != http.MethodHead && h.rejectIfReadOnly(w) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/tus/")
+	if r.Method == http.MethodPost {
+		if id != "" {
+			http.Error(w, "Uploads are created with POST /tus/", http.StatusMethodNotAllowed)
+			return
+		}
+		h.tusCreate(w, r)
+		return
+	}
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 16 {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		h.tusStatus(w, r, id)
+	case http.MethodPatch:
+		h.tusPatch(w, r, id)
+	case http.MethodDelete:
+		h.tusTerminate(w, r, id)
+	}
+}
+
+// tusCreate starts an upload of Upload-Length bytes. The object is named by
+// the "filename" (or "name") in Upload-Metadata, and "filetype" (or
+// "type") sets its content type.
+func (h *MinioHandler) tusCreate(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Upload-Length must be the size of the upload in bytes", http.StatusBadRequest)
+		return
+	}
+	if length > h.tus.limit() {
+		http.Error(w, fmt.Sprintf("Upload-Length exceeds the maximum of %d bytes", h.tus.limit()), http.StatusRequestEntityTooLarge)
+		return
+	}
+	meta, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	objectName := meta["filename"]
+	if objectName == "" {
+		objectName = meta["name"]
+	}
+	if objectName == "" {
+		http.Error(w, "Upload-Metadata must name the object with a filename key", http.StatusBadRequest)
+		return
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	contentType := meta["filetype"]
+	if contentType == "" {
+		contentType = meta["type"]
+	}
+	opts, _ := h.uploadOptions(objectName, contentType)
+
+	ctx := r.Context()
+	up := tusUpload{
+		ID:       newID(),
+		Object:   objectName,
+		Length:   length,
+		PartSize: max(minPartSize, (length+maxCopyParts-1)/maxCopyParts),
+		Metadata: r.Header.Get("Upload-Metadata"),
+	}
+	if h.tus.expiry > 0 {
+		up.Expires = time.Now().Add(h.tus.expiry).UTC().Truncate(time.Second)
+	}
+	location := h.basePath + "/tus/" + up.ID
+	if length == 0 {
+		if _, err := h.store.PutObject(ctx, h.bucketName, objectName, http.NoBody, 0, opts); err != nil {
+			logger(ctx).Error("Error uploading empty tus upload", "object", objectName, "err", err)
+			h.storeFailed(w, "Failed to upload file", err)
+			return
+		}
+		h.cache.invalidate(objectName)
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	up.UploadID, err = h.store.NewMultipartUpload(ctx, h.bucketName, objectName, opts)
+	if err != nil {
+		logger(ctx).Error("Error starting multipart upload", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to start upload", err)
+		return
+	}
+	if _, err := h.saveTusUpload(ctx, up, ""); err != nil {
+		logger(ctx).Error("Error saving tus upload state", "upload", up.ID, "err", err)
+		h.store.AbortMultipartUpload(ctx, h.bucketName, objectName, up.UploadID)
+		h.storeFailed(w, "Failed to start upload", err)
+		return
+	}
+	logger(ctx).Info("Started tus upload", "upload", up.ID, "object", objectName, "length", length)
+	if !up.Expires.IsZero() {
+		w.Header().Set("Upload-Expires", up.Expires.Format(http.TimeFormat))
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// loadTusUpload reads the state of upload id and the ETag to make the next
+// save conditional on. An expired upload is reported as not found.
+func (h *MinioHandler) loadTusUpload(ctx context.Context, id string) (tusUpload, string, error) {
+	var up tusUpload
+	obj, err := h.store.GetObject(ctx, h.bucketName, tusInfoKey(id), minio.GetObjectOptions{})
+	if err != nil {
+		return up, "", err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return up, "", err
+	}
+	if err := json.NewDecoder(obj).Decode(&up); err != nil {
+		return up, "", fmt.Errorf("decoding upload state: %w", err)
+	}
+	if !up.Expires.IsZero() && time.Now().After(up.Expires) {
+		return up, "", minio.ErrorResponse{Code: "NoSuchKey", Key: tusInfoKey(id), StatusCode: http.StatusNotFound}
+	}
+	return up, info.ETag, nil
+}
+
+// saveTusUpload writes the state of up, on the condition that it still has
+// etag when that is set, and returns its new ETag.
+func (h *MinioHandler) saveTusUpload(ctx context.Context, up tusUpload, etag string) (string, error) {
+	data, err := json.Marshal(up)
+	if err != nil {
+		return "", err
+	}
+	opts := minio.PutObjectOptions{ContentType: "application/json", ServerSideEncryption: h.sse.fallback()}
+	if etag != "" {
+		opts.SetMatchETag(etag)
+	}
+	info, err := h.store.PutObject(ctx, h.bucketName, tusInfoKey(up.ID), bytes.NewReader(data), int64(len(data)), opts)
+	return info.ETag, err
+}
+
+// tusLoadFailed answers a failed loadTusUpload: 404 for a missing or
+// expired upload, storeFailed otherwise.
+func (h *MinioHandler) tusLoadFailed(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	logger(r.Context()).Error("Error reading tus upload state", "upload", id, "err", err)
+	h.storeFailed(w, "Failed to read upload", err)
+}
+
+func (h *MinioHandler) setTusHeaders(w http.ResponseWriter, up tusUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	if !up.Expires.IsZero() {
+		w.Header().Set("Upload-Expires", up.Expires.Format(http.TimeFormat))
+	}
+}
+
+// tusStatus reports the offset a client resumes from.
+func (h *MinioHandler) tusStatus(w http.ResponseWriter, r *http.Request, id string) {
+	up, _, err := h.loadTusUpload(r.Context(), id)
+	if err != nil {
+		h.tusLoadFailed(w, r, id, err)
+		return
+	}
+	h.setTusHeaders(w, up)
+	w.Header().Set("Upload-Length", strconv.FormatInt(up.Length, 10))
+	if up.Metadata != "" {
+		w.Header().Set("Upload-Metadata", up.Metadata)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends the body to upload id at Upload-Offset. Whole parts go
+// to MinIO as they fill up, and the rest is kept in {id}.part, so an
+// interrupted PATCH loses nothing the server received. The state is saved
+// after every part, and the upload completed once all of it has arrived.
+func (h *MinioHandler) tusPatch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, busy := tusActive.LoadOrStore(id, struct{}{}); busy {
+		http.Error(w, "Upload is already receiving data", http.StatusLocked)
+		return
+	}
+	defer tusActive.Delete(id)
+
+	// Whatever arrives is stored even if the client goes away meanwhile.
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	up, etag, err := h.loadTusUpload(ctx, id)
+	if err != nil {
+		h.tusLoadFailed(w, r, id, err)
+		return
+	}
+	if offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64); err != nil || offset != up.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+		http.Error(w, fmt.Sprintf("Upload-Offset must be %d", up.Offset), http.StatusConflict)
+		return
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, up.PartSize))
+	if up.Pending > 0 {
+		part, err := h.store.GetObject(ctx, h.bucketName, tusPartKey(id), minio.GetObjectOptions{})
+		if err == nil {
+			_, err = io.Copy(buf, part)
+			part.Close()
+		}
+		if err != nil {
+			logger(ctx).Error("Error reading pending tus data", "upload", id, "err", err)
+			h.storeFailed(w, "Failed to read upload", err)
+			return
+		}
+	}
+	// A client may not send more than it announced.
+	body := io.LimitReader(r.Body, up.Length-up.Offset+1)
+	var readErr error
+	for readErr == nil {
+		var n int64
+		n, readErr = io.CopyN(buf, body, up.PartSize-int64(buf.Len()))
+		up.Offset += n
+		if up.Offset > up.Length {
+			http.Error(w, "Upload exceeds its Upload-Length", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if int64(buf.Len()) < up.PartSize && up.Offset < up.Length {
+			continue
+		}
+		// A full part, or the last one.
+		part, err := h.store.PutObjectPart(ctx, h.bucketName, up.Object, up.UploadID, len(up.Parts)+1, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectPartOptions{})
+		if err != nil {
+			logger(ctx).Error("Error uploading tus part", "upload", id, "part", len(up.Parts)+1, "err", err)
+			h.storeFailed(w, "Failed to upload part", err)
+			return
+		}
+		up.Parts = append(up.Parts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+		up.Pending = 0
+		buf.Reset()
+		if up.Offset == up.Length {
+			h.tusComplete(ctx, w, r, up)
+			return
+		}
+		if etag, err = h.saveTusUpload(ctx, up, etag); err != nil {
+			h.tusSaveFailed(w, r, id, err)
+			return
+		}
+	}
+	if !errors.Is(readErr, io.EOF) && !clientDisconnected(r, readErr) {
+		logger(ctx).Warn("Error reading tus data", "upload", id, "err", readErr)
+	}
+
+	if buf.Len() > 0 {
+		_, err := h.store.PutObject(ctx, h.bucketName, tusPartKey(id), bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{ServerSideEncryption: h.sse.fallback()})
+		if err != nil {
+			logger(ctx).Error("Error saving pending tus data", "upload", id, "err", err)
+			h.storeFailed(w, "Failed to save upload data", err)
+			return
+		}
+		up.Pending = int64(buf.Len())
+	}
+	if _, err := h.saveTusUpload(ctx, up, etag); err != nil {
+		h.tusSaveFailed(w, r, id, err)
+		return
+	}
+	h.setTusHeaders(w, up)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusSaveFailed answers a failed saveTusUpload: 409 when another PATCH
+// changed the upload in the meantime, storeFailed otherwise.
+func (h *MinioHandler) tusSaveFailed(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		http.Error(w, "Upload was changed by another request; check its offset with HEAD", http.StatusConflict)
+		return
+	}
+	logger(r.Context()).Error("Error saving tus upload state", "upload", id, "err", err)
+	h.storeFailed(w, "Failed to save upload", err)
+}
+
+// tusComplete assembles the parts of up into its object and removes the
+// upload's state.
+func (h *MinioHandler) tusComplete(ctx context.Context, w http.ResponseWriter, r *http.Request, up tusUpload) {
+	if _, err := h.store.CompleteMultipartUpload(ctx, h.bucketName, up.Object, up.UploadID, up.Parts, minio.PutObjectOptions{ServerSideEncryption: h.sse.fallback()}); err != nil {
+		logger(ctx).Error("Error completing tus upload", "upload", up.ID, "object", up.Object, "err", err)
+		h.storeFailed(w, "Failed to complete upload", err)
+		return
+	}
+	h.cache.invalidate(up.Object)
+	h.quotaAfterUpload(w, up.Object, up.Length)
+	h.removeTusState(ctx, up.ID)
+	logger(r.Context()).Info("Completed tus upload", "upload", up.ID, "object", up.Object, "length", up.Length)
+	h.setTusHeaders(w, up)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MinioHandler) removeTusState(ctx context.Context, id string) {
+	for _, key := range []string{tusPartKey(id), tusInfoKey(id)} {
+		if err := h.store.RemoveObject(ctx, h.bucketName, key, minio.RemoveObjectOptions{}); err != nil {
+			logger(ctx).Warn("Error removing tus upload state", "object", key, "err", err)
+		}
+	}
+}
+
+// tusTerminate abandons upload id, aborting its multipart upload.
+func (h *MinioHandler) tusTerminate(w http.ResponseWriter, r *http.Request, id string) {
+	if _, busy := tusActive.Load(id); busy {
+		http.Error(w, "Upload is receiving data", http.StatusLocked)
+		return
+	}
+	up, _, err := h.loadTusUpload(r.Context(), id)
+	if err != nil {
+		h.tusLoadFailed(w, r, id, err)
+		return
+	}
+	if err := h.store.AbortMultipartUpload(r.Context(), h.bucketName, up.Object, up.UploadID); err != nil && minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+		logger(r.Context()).Error("Error aborting tus upload", "upload", id, "err", err)
+		h.storeFailed(w, "Failed to terminate upload", err)
+		return
+	}
+	h.removeTusState(r.Context(), id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startTusSweeper runs runTusSweeper for h's bucket until ctx is done, if
+// tus uploads expire.
+func (h *MinioHandler) startTusSweeper(ctx context.Context) {
+	if h.tus.expiry > 0 {
+		go h.runTusSweeper(ctx, min(h.tus.expiry, time.Hour))
+	}
+}
+
+// runTusSweeper aborts expired uploads every interval until ctx is done,
+// so their parts don't linger in MinIO.
+func (h *MinioHandler) runTusSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.sweepTusUploads(ctx, time.Now())
+		}
+	}
+}
+
+func (h *MinioHandler) sweepTusUploads(ctx context.Context, now time.Time) {
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: tusStatePrefix}) {
+		if object.Err != nil {
+			logger(ctx).Error("Error listing tus uploads", "err", object.Err)
+			return
+		}
+		id, ok := strings.CutSuffix(strings.TrimPrefix(object.Key, tusStatePrefix), ".info")
+		if !ok {
+			continue
+		}
+		obj, err := h.store.GetObject(ctx, h.bucketName, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			continue
+		}
+		var up tusUpload
+		err = json.NewDecoder(obj).Decode(&up)
+		obj.Close()
+		if err != nil || up.Expires.IsZero() || now.Before(up.Expires) {
+			continue
+		}
+		if err := h.store.AbortMultipartUpload(ctx, h.bucketName, up.Object, up.UploadID); err != nil && minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+			logger(ctx).Warn("Error aborting expired tus upload", "upload", id, "err", err)
+			continue
+		}
+		h.removeTusState(ctx, id)
+		logger(ctx).Info("Removed expired tus upload", "upload", id, "object", up.Object)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// tusRequest builds a tus request; PATCH bodies are sent from offset.
+func tusRequest(method, target string, body io.Reader, offset int64) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	}
+	return req
+}
+
+// createTusUpload starts an upload of length bytes named name and returns
+// its URL.
+func createTusUpload(t *testing.T, h *MinioHandler, name string, length int) string {
+	t.Helper()
+	req := tusRequest(http.MethodPost, "/tus/", nil, 0)
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(name))+",filetype "+base64.StdEncoding.EncodeToString([]byte("video/mp4")))
+	rec := serve(h, req)
+	if rec.Code != http.StatusCreated || !strings.HasPrefix(rec.Header().Get("Location"), "/tus/") {
+		t.Fatalf("create: status = %d, Location %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	return rec.Header().Get("Location")
+}
+
+func tusOffset(t *testing.T, h *MinioHandler, location string) int64 {
+	t.Helper()
+	rec := serve(h, tusRequest(http.MethodHead, location, nil, 0))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HEAD status = %d", rec.Code)
+	}
+	n, _ := strconv.ParseInt(rec.Header().Get("Upload-Offset"), 10, 64)
+	return n
+}
+
+func TestTusOptions(t *testing.T) {
+	h, _ := newTestHandler(t)
+	rec := serve(h, httptest.NewRequest(http.MethodOptions, "/tus/", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Tus-Version") != tusVersion || !strings.Contains(rec.Header().Get("Tus-Extension"), "creation") {
+		t.Errorf("OPTIONS: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodHead, "/tus/"+newID(), nil)); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("without Tus-Resumable: status = %d, want 412", rec.Code)
+	}
+}
+
+func TestTusUpload(t *testing.T) {
+	h, store := newTestHandler(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), (minPartSize+minPartSize/4)/16)
+	location := createTusUpload(t, h, "videos/big.mp4", len(data))
+
+	// The first PATCH breaks off partway, short of a whole part.
+	first := 3 << 20
+	body := io.MultiReader(bytes.NewReader(data[:first]), iotest.ErrReader(io.ErrUnexpectedEOF))
+	serve(h, tusRequest(http.MethodPatch, location, body, 0))
+	if got := tusOffset(t, h, location); got != int64(first) {
+		t.Fatalf("offset after interrupted PATCH = %d, want %d", got, first)
+	}
+
+	if rec := serve(h, tusRequest(http.MethodPatch, location, bytes.NewReader(data[1:]), 1)); rec.Code != http.StatusConflict {
+		t.Errorf("wrong offset: status = %d, want 409", rec.Code)
+	}
+
+	rec := serve(h, tusRequest(http.MethodPatch, location, bytes.NewReader(data[first:]), int64(first)))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Upload-Offset") != strconv.Itoa(len(data)) {
+		t.Fatalf("final PATCH: status = %d, offset %q: %s", rec.Code, rec.Header().Get("Upload-Offset"), rec.Body)
+	}
+	got, ok := store.object(testBucket, "videos/big.mp4")
+	if !ok || !bytes.Equal(got, data) {
+		t.Fatalf("stored %d bytes, want %d", len(got), len(data))
+	}
+	if info, _ := store.StatObject(t.Context(), testBucket, "videos/big.mp4", minio.StatObjectOptions{}); info.ContentType != "video/mp4" {
+		t.Errorf("content type = %q", info.ContentType)
+	}
+	for object := range store.ListObjects(t.Context(), testBucket, minio.ListObjectsOptions{Prefix: tusStatePrefix}) {
+		t.Errorf("state left behind: %s", object.Key)
+	}
+	if rec := serve(h, tusRequest(http.MethodHead, location, nil, 0)); rec.Code != http.StatusNotFound {
+		t.Errorf("HEAD after completion: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestTusTerminateAndExpire(t *testing.T) {
+	h, store := newTestHandler(t)
+	location := createTusUpload(t, h, "a.mp4", 10)
+	if rec := serve(h, tusRequest(http.MethodPatch, location, strings.NewReader("0123456789ab"), 0)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH past Upload-Length: status = %d, want 413", rec.Code)
+	}
+	if rec := serve(h, tusRequest(http.MethodDelete, location, nil, 0)); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d", rec.Code)
+	}
+	if rec := serve(h, tusRequest(http.MethodHead, location, nil, 0)); rec.Code != http.StatusNotFound {
+		t.Errorf("HEAD after DELETE: status = %d, want 404", rec.Code)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("multipart uploads left: %d", len(store.uploads))
+	}
+
+	h.tus.expiry = time.Hour
+	location = createTusUpload(t, h, "b.mp4", 10)
+	serve(h, tusRequest(http.MethodPatch, location, strings.NewReader("01234"), 0))
+	h.sweepTusUploads(t.Context(), time.Now())
+	if got := tusOffset(t, h, location); got != 5 {
+		t.Errorf("offset = %d, want 5 before expiry", got)
+	}
+	h.sweepTusUploads(t.Context(), time.Now().Add(2*time.Hour))
+	if rec := serve(h, tusRequest(http.MethodHead, location, nil, 0)); rec.Code != http.StatusNotFound {
+		t.Errorf("HEAD after expiry: status = %d, want 404", rec.Code)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("expired multipart uploads left: %d", len(store.uploads))
+	}
+
+	h.tus.maxSize = 100
+	req := tusRequest(http.MethodPost, "/tus/", nil, 0)
+	req.Header.Set("Upload-Length", "101")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("c.mp4")))
+	if rec := serve(h, req); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Upload-Length over the limit: status = %d, want 413", rec.Code)
+	}
+}
+
+func TestTusStateReserved(t *testing.T) {
+	h, store := newTestHandler(t)
+	store.put(testBucket, tusStatePrefix+"x.info", []byte("{}"), "application/json")
+	store.put(testBucket, "a.txt", []byte("a"), "text/plain")
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/raw/"+tusStatePrefix+"x.info", strings.NewReader("{}")),
+		httptest.NewRequest(http.MethodDelete, "/delete/"+tusStatePrefix+"x.info", nil),
+		httptest.NewRequest(http.MethodPost, "/copy", strings.NewReader(`{"source": "a.txt", "destination": "`+tusStatePrefix+`y.info"}`)),
+		httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(`{"source": "`+tusStatePrefix+`x.info", "destination": "x.info"}`)),
+	} {
+		if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want 400", req.Method, req.URL, rec.Code)
+		}
+	}
+	if _, ok := store.object(testBucket, tusStatePrefix+"x.info"); !ok {
+		t.Error("upload state was removed")
+	}
+
+	for _, target := range []string{"/list", "/list?recursive=true", "/browse"} {
+		if body := serve(h, httptest.NewRequest(http.MethodGet, target, nil)).Body.String(); strings.Contains(body, tusStatePrefix) {
+			t.Errorf("%s lists upload state: %s", target, body)
+		}
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download-zip?prefix="+tusStatePrefix, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("zip of the upload state: status = %d, want 404", rec.Code)
+	}
+}
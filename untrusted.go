@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// defaultUntrustedTypes are content types a browser will execute or render
+// as active content when opened directly from a presigned link.
+const defaultUntrustedTypes = "text/html,application/xhtml+xml,image/svg+xml,text/xml,application/xml,text/javascript,application/javascript"
+
+// untrustedRules decides which presigned download links must force a file
+// download instead of letting the browser render the stored content type.
+type untrustedRules struct {
+	prefixes []string
+	types    []string
+}
+
+// loadUntrustedRules reads MINIO_UNTRUSTED_PREFIXES (key prefixes whose
+// objects are always forced to download) and MINIO_UNTRUSTED_CONTENT_TYPES
+// (stored types that are forced to download anywhere; set it to "none" to
+// disable the type check).
+func loadUntrustedRules() untrustedRules {
+	rules := untrustedRules{prefixes: envList("MINIO_UNTRUSTED_PREFIXES")}
+	switch v := os.Getenv("MINIO_UNTRUSTED_CONTENT_TYPES"); v {
+	case "":
+		rules.types = strings.Split(defaultUntrustedTypes, ",")
+	case "none":
+	default:
+		rules.types = envList("MINIO_UNTRUSTED_CONTENT_TYPES")
+	}
+	return rules
+}
+
+// untrustedPrefix reports whether objectName lives under an untrusted prefix.
+func (u untrustedRules) untrustedPrefix(objectName string) bool {
+	for _, p := range u.prefixes {
+		if strings.HasPrefix(objectName, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// untrustedType reports whether contentType is on the untrusted list,
+// ignoring parameters such as charset.
+func (u untrustedRules) untrustedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, t := range u.types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadOnlyParams returns the presign response overrides that make S3
+// serve objectName as an opaque attachment, or nil if the object may be
+// served as stored. When the stored type must be checked but cannot be
+// read, the override is applied anyway so the check fails closed.
+func (h *MinioHandler) downloadOnlyParams(ctx context.Context, objectName string) url.Values {
+	force := h.untrusted.untrustedPrefix(objectName)
+	if !force && len(h.untrusted.types) > 0 {
+		info, err := h.store.StatObject(ctx, h.bucketName, objectName, minio.StatObjectOptions{})
+		force = err != nil || h.untrusted.untrustedType(info.ContentType)
+	}
+	if !force {
+		return nil
+	}
+	return attachmentParams(objectName)
+}
+
+// attachmentParams are the presign parameters that serve objectName as an
+// opaque download.
+func attachmentParams(objectName string) url.Values {
+	params := url.Values{}
+	params.Set("response-content-type", "application/octet-stream")
+	params.Set("response-content-disposition", attachmentDisposition(path.Base(objectName)))
+	return params
+}
+
+// attachmentDisposition builds a Content-Disposition value that forces a
+// download, with an RFC 6266 UTF-8 file name for non-ASCII names.
+func attachmentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPresignForcesDownloadForUntrustedContent(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.untrusted = untrustedRules{prefixes: []string{"user-content/"}, types: strings.Split(defaultUntrustedTypes, ",")}
+	// Links to objects that aren't uploaded yet take MINIO_PRESIGN_CHECK_EXISTS=false.
+	h.presignMissing = true
+	store.put(testBucket, "page.html", []byte("<script>"), "text/html; charset=utf-8")
+	store.put(testBucket, "photo.png", []byte("png"), "image/png")
+	store.put(testBucket, "user-content/photo.png", []byte("png"), "image/png")
+
+	tests := []struct {
+		object    string
+		wantForce bool
+	}{
+		{"page.html", true},
+		{"photo.png", false},
+		{"user-content/photo.png", true},
+		{"not-uploaded-yet.png", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.object, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-download-link/"+tt.object, nil))
+			var resp map[string]string
+			decodeJSON(t, rec, &resp)
+			u, err := url.Parse(resp["url"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := u.Query()
+			forced := q.Get("response-content-type") == "application/octet-stream" &&
+				strings.HasPrefix(q.Get("response-content-disposition"), "attachment")
+			if forced != tt.wantForce {
+				t.Errorf("forced download = %v, want %v (query %v)", forced, tt.wantForce, q)
+			}
+		})
+	}
+}
+
+func TestAttachmentDispositionEncodesUnicode(t *testing.T) {
+	if got := attachmentDisposition("report.pdf"); got != "attachment; filename=report.pdf" {
+		t.Errorf("ascii = %q", got)
+	}
+	if got := attachmentDisposition("résumé.pdf"); !strings.Contains(got, "filename*=utf-8''r%C3%A9sum%C3%A9.pdf") {
+		t.Errorf("unicode = %q", got)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// unpackLimits bound what one /upload-archive request may expand to.
+type unpackLimits struct {
+	maxEntries int
+	// maxBytes caps both the archive and the total size of its entries.
+	maxBytes int64
+}
+
+// loadUnpackLimits reads MINIO_UNPACK_MAX_ENTRIES and MINIO_UNPACK_MAX_BYTES.
+func loadUnpackLimits() (unpackLimits, error) {
+	l := unpackLimits{maxEntries: int(envInt64("MINIO_UNPACK_MAX_ENTRIES", 10000))}
+	if v := os.Getenv("MINIO_UNPACK_MAX_BYTES"); v != "" {
+		n, err := parseByteSize(v)
+		if err != nil || n <= 0 {
+			return l, fmt.Errorf("MINIO_UNPACK_MAX_BYTES must be a size such as 10GiB, got %q", v)
+		}
+		l.maxBytes = n
+	}
+	return l, nil
+}
+
+func (l unpackLimits) entries() int {
+	if l.maxEntries <= 0 {
+		return 10000
+	}
+	return l.maxEntries
+}
+
+func (l unpackLimits) bytes() int64 {
+	if l.maxBytes <= 0 {
+		return 10 << 30
+	}
+	return l.maxBytes
+}
+
+// errUnpackLimit ends an archive that exceeds unpackLimits.
+var errUnpackLimit = errors.New("unpack limit reached")
+
+type archiveUploadReport struct {
+	multiUploadReport
+	Format string `json:"format"`
+	// Error says why the archive wasn't unpacked in full. Entries
+	// reported as uploaded were stored all the same.
+	Error string `json:"error,omitempty"`
+}
+
+// archiveUploader stores the entries of one archive under prefix and
+// records a result for each.
+type archiveUploader struct {
+	h        *MinioHandler
+	w        http.ResponseWriter
+	r        *http.Request
+	report   *archiveUploadReport
+	expanded int64
+}
+
+// uploadArchiveHandler unpacks a zip or tar.gz request body into the bucket
+// (POST /upload-archive?prefix=), one object per file entry, keeping the
+// paths inside the archive below prefix. The format is told from the first
+// bytes of the body. A tar.gz is read as it arrives; a zip keeps its index
+// at the end, so it is spooled to a temporary file first. Folders,
+// symlinks and other special entries are skipped, and entries whose path is
+// absolute or climbs out with ".." are reported as failed and never stored.
+func (h *MinioHandler) uploadArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, h.unpack.bytes()))
+	magic, _ := body.Peek(4)
+	report := &archiveUploadReport{multiUploadReport: multiUploadReport{Bucket: h.bucketName, Prefix: prefix, Results: []fileUploadResult{}}}
+	u := &archiveUploader{h: h, w: w, r: r, report: report}
+
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		report.Format = "zip"
+		f, ferr := os.CreateTemp("", "upload-archive-*.zip")
+		if ferr != nil {
+			logger(r.Context()).Error("Error creating a temporary file for a zip upload", "err", ferr)
+			http.Error(w, "Failed to store archive", http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		err = u.unzip(f, body)
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		report.Format = "tar.gz"
+		err = u.untar(body)
+	default:
+		http.Error(w, "Request body must be a zip or tar.gz archive", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	status := http.StatusCreated
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnpackLimit) || errors.As(err, &maxErr):
+		status = http.StatusRequestEntityTooLarge
+		report.Error = fmt.Sprintf("Archive exceeds the limit of %d entries or %d bytes", h.unpack.entries(), h.unpack.bytes())
+	case err != nil && clientDisconnected(r, err):
+		h.uploadAbandoned(w, r, err)
+		return
+	case err != nil:
+		status = http.StatusBadRequest
+		report.Error = "Archive is corrupt: " + err.Error()
+	case report.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, r, status, report)
+}
+
+// unzip spools the archive to f and checks its entry count and sizes
+// against the limits before storing anything. The sizes are the ones the
+// zip declares; archive/zip fails an entry that expands further.
+func (u *archiveUploader) unzip(f *os.File, body io.Reader) error {
+	size, err := io.Copy(f, body)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	if len(zr.File) > u.h.unpack.entries() {
+		return errUnpackLimit
+	}
+	var total uint64
+	for _, zf := range zr.File {
+		total += zf.UncompressedSize64
+	}
+	if total > uint64(u.h.unpack.bytes()) {
+		return errUnpackLimit
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		err := u.store(zf.Name, int64(zf.UncompressedSize64), func() (io.ReadCloser, error) { return zf.Open() })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// untar stores the entries of a gzipped tar as they are read, stopping once
+// the limits are exceeded.
+func (u *archiveUploader) untar(body io.Reader) error {
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	entries := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entries++; entries > u.h.unpack.entries() {
+			return errUnpackLimit
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := u.store(hdr.Name, hdr.Size, func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }); err != nil {
+			return err
+		}
+	}
+}
+
+// store uploads one entry of size bytes under the report's prefix. Failures
+// of the entry itself are recorded in the report; the error returned is one
+// that ends the whole archive, such as a limit or a failed read.
+func (u *archiveUploader) store(name string, size int64, open func() (io.ReadCloser, error)) error {
+	h, r, report := u.h, u.r, u.report
+	key, ok := archiveEntryKey(report.Prefix, name)
+	res := fileUploadResult{File: name, Key: key, Status: "failed", Size: size}
+	defer func() {
+		if res.Status == "uploaded" {
+			report.Uploaded++
+			h.cache.invalidate(key)
+			h.quotaAfterUpload(u.w, key, size)
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, res)
+	}()
+	if !ok {
+		res.Key, res.Error = "", "unsafe path"
+		return nil
+	}
+	if u.expanded += size; u.expanded > h.unpack.bytes() {
+		res.Error = "unpack limit reached"
+		return errUnpackLimit
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, key); status != 0 {
+		res.Error = reason
+		return nil
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	opts, _ := h.uploadOptions(key, contentType)
+	if err := h.applyUploadParams(r, &opts); err != nil {
+		res.Error = err.Error()
+		return nil
+	}
+	entry, err := open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	info, err := h.store.PutObject(ctx, h.bucketName, key, entry, size, opts)
+	if err != nil {
+		// A failed read of the archive ends it; a failed upload only
+		// fails the entry.
+		var maxErr *http.MaxBytesError
+		if clientDisconnected(r, err) || errors.As(err, &maxErr) || errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) {
+			res.Error = err.Error()
+			return err
+		}
+		logger(r.Context()).Error("Error uploading archive entry to MinIO", "object", key, "err", err)
+		res.Error = err.Error()
+		return nil
+	}
+	res.Status, res.ETag = "uploaded", info.ETag
+	return nil
+}
+
+// archiveEntryKey returns the object name of the archive entry name under
+// prefix, or false for a name that is absolute or has a ".." segment.
+// Backslashes, which some Windows tools write into zips, count as "/".
+func archiveEntryKey(prefix, name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	for seg := range strings.SplitSeq(name, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", false
+	}
+	return prefix + name, true
+}
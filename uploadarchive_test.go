@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func zipArchive(files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	return &buf
+}
+
+// tarGzArchive writes the entries in order; a name ending in "/" is a
+// folder.
+func tarGzArchive(names []string, content string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		if name[len(name)-1] == '/' {
+			tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o755})
+			continue
+		}
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0o644})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestUploadArchiveZip(t *testing.T) {
+	h, store := newTestHandler(t)
+	body := zipArchive(map[string]string{"site/index.html": "<h1>hi</h1>", "site/css/app.css": "body{}", "../escape.txt": "x"})
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive?prefix=www", body))
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207: %s", rec.Code, rec.Body)
+	}
+	var report archiveUploadReport
+	decodeJSON(t, rec, &report)
+	if report.Format != "zip" || report.Uploaded != 2 || report.Failed != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if data, _ := store.object(testBucket, "www/site/css/app.css"); string(data) != "body{}" {
+		t.Errorf("app.css stored as %q", data)
+	}
+	for _, key := range []string{"escape.txt", "www/escape.txt"} {
+		if _, ok := store.object(testBucket, key); ok {
+			t.Errorf("unsafe entry stored as %s", key)
+		}
+	}
+}
+
+func TestUploadArchiveTarGz(t *testing.T) {
+	h, store := newTestHandler(t)
+	body := tarGzArchive([]string{"photos/", "photos/a.jpg", "photos/b.jpg"}, "jpeg")
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive?prefix=import/", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var report archiveUploadReport
+	decodeJSON(t, rec, &report)
+	if report.Format != "tar.gz" || report.Uploaded != 2 || report.Results[1].Key != "import/photos/b.jpg" {
+		t.Errorf("report = %+v", report)
+	}
+	if data, _ := store.object(testBucket, "import/photos/a.jpg"); string(data) != "jpeg" {
+		t.Errorf("a.jpg stored as %q", data)
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive", bytes.NewReader([]byte("plain text")))); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("not an archive: status = %d, want 415", rec.Code)
+	}
+}
+
+func TestUploadArchiveLimits(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.unpack = unpackLimits{maxEntries: 2, maxBytes: 1 << 20}
+
+	// A zip is checked before anything is stored.
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive", zipArchive(map[string]string{"a": "1", "b": "2", "c": "3"})))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("zip status = %d, want 413", rec.Code)
+	}
+	if _, ok := store.object(testBucket, "a"); ok {
+		t.Error("zip over the entry limit was partly stored")
+	}
+
+	// A tar.gz stops at the limit and keeps what came before.
+	rec = serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive", tarGzArchive([]string{"a", "b", "c"}, "x")))
+	var report archiveUploadReport
+	decodeJSON(t, rec, &report)
+	if rec.Code != http.StatusRequestEntityTooLarge || report.Uploaded != 2 || report.Error == "" {
+		t.Errorf("tar.gz status %d, report %+v", rec.Code, report)
+	}
+
+	h.unpack = unpackLimits{maxEntries: 10, maxBytes: 1 << 10}
+	rec = serve(h, httptest.NewRequest(http.MethodPost, "/upload-archive", tarGzArchive([]string{"big1", "big2"}, string(make([]byte, 600)))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expanded size over the limit: status = %d, want 413", rec.Code)
+	}
+	if _, ok := store.object(testBucket, "big2"); ok {
+		t.Error("entry past the size limit was stored")
+	}
+}
+
+func TestArchiveEntryKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"a/b.txt":          "p/a/b.txt",
+		"./a//b.txt":       "p/a/b.txt",
+		`docs\readme.md`:   "p/docs/readme.md",
+		"../etc/passwd":    "",
+		"a/../../b":        "",
+		"/etc/passwd":      "",
+		`..\windows\x.dll`: "",
+		".":                "",
+	} {
+		got, ok := archiveEntryKey("p/", name)
+		if ok != (want != "") || got != want {
+			t.Errorf("archiveEntryKey(%q) = %q, %v; want %q", name, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type uploadLinkResponse struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	// Headers must be sent with the upload exactly as given; they are part
+	// of the signature.
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	// Post is a POST policy for the same object, with ?post=true.
+	Post *uploadPolicyResponse `json:"post,omitempty"`
+}
+
+// uploadLinkHandler returns a presigned PUT URL for a client to upload
+// /get-upload-link/{objectName} straight to MinIO. A PUT URL can't carry a
+// size range the way a POST policy does, so the upload's exact ?size= is
+// signed as its Content-Length (at most MINIO_UPLOAD_POLICY_MAX_BYTES), and
+// ?content_type=, when given, as its Content-Type. ?expires_in= shortens
+// the link's lifetime from the MINIO_UPLOAD_POLICY_EXPIRY default.
+// ?post=true adds a POST policy for the same object, for browser forms.
+// The upload's encryption, from the configured default or the request's
+// encryption headers (see sse.go), is signed into both.
+func (h *MinioHandler) uploadLinkHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/get-upload-link/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /get-upload-link/my-image.jpg?size=1024)", http.StatusBadRequest)
+		return
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+
+	q := r.URL.Query()
+	size, err := strconv.ParseInt(q.Get("size"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "size must be the upload's length in bytes", http.StatusBadRequest)
+		return
+	}
+	if size > h.uploadPolicy.maxBytes {
+		http.Error(w, "size exceeds the upload limit of "+strconv.FormatInt(h.uploadPolicy.maxBytes, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType := ""
+	if v := q.Get("content_type"); v != "" {
+		if contentType, _, err = mime.ParseMediaType(v); err != nil {
+			http.Error(w, "content_type must be a valid media type", http.StatusBadRequest)
+			return
+		}
+	}
+	if contentType == "" && len(h.uploadPolicy.types) > 0 {
+		http.Error(w, "content_type is required, since only some content types may be uploaded", http.StatusBadRequest)
+		return
+	}
+	if contentType != "" && !h.uploadPolicy.allowsType(contentType) {
+		http.Error(w, "Content type '"+contentType+"' is not allowed for uploads", http.StatusUnsupportedMediaType)
+		return
+	}
+	expiry := h.uploadPolicy.expiry
+	if v := q.Get("expires_in"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second || d > h.uploadPolicy.expiry {
+			http.Error(w, "expires_in must be a duration from 1s to "+h.uploadPolicy.expiry.String(), http.StatusBadRequest)
+			return
+		}
+		expiry = d
+	}
+	// Presigned URLs have whole-second expiries.
+	expiry = expiry.Truncate(time.Second)
+	expiresAt := time.Now().Add(expiry).UTC()
+
+	sse, err := h.sse.forUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Length", strconv.FormatInt(size, 10))
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	if sse != nil {
+		sse.Marshal(headers)
+	}
+	u, err := h.links().PresignHeader(r.Context(), http.MethodPut, h.bucketName, objectName, expiry, nil, headers)
+	if err != nil {
+		logger(r.Context()).Error("Error generating upload link", "object", objectName, "err", err)
+		http.Error(w, "Failed to generate upload link", http.StatusInternalServerError)
+		return
+	}
+	resp := uploadLinkResponse{URL: u.String(), Method: http.MethodPut, Headers: flattenHeaders(headers), ExpiresAt: expiresAt}
+	if q.Get("post") == "true" {
+		if resp.Post = h.signUploadPolicy(w, r, objectName, contentType, expiresAt); resp.Post == nil {
+			return
+		}
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
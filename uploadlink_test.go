@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUploadLinkHandler(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	var resp uploadLinkResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-upload-link/photos/cat.png?size=2048&content_type=image/png&expires_in=30s&post=true", nil)), &resp)
+	u, err := url.Parse(resp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/"+testBucket+"/photos/cat.png" || q.Get("X-Fake-Method") != http.MethodPut || q.Get("X-Amz-Expires") != "30" {
+		t.Errorf("url = %s, want a 30s PUT link to photos/cat.png", resp.URL)
+	}
+	if q.Get("X-Amz-SignedHeaders") != "content-length;content-type;host" {
+		t.Errorf("signed headers = %q, want the length and type pinned", q.Get("X-Amz-SignedHeaders"))
+	}
+	if resp.Method != http.MethodPut || resp.Headers["Content-Length"] != "2048" || resp.Headers["Content-Type"] != "image/png" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Post == nil || !strings.Contains(resp.Post.Fields["policy"], `["eq","$key","photos/cat.png"]`) || !resp.Post.ExpiresAt.Equal(resp.ExpiresAt) {
+		t.Errorf("post = %+v, want a policy for the same object and expiry", resp.Post)
+	}
+
+	// Without a type, only the length is pinned.
+	var untyped uploadLinkResponse
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/get-upload-link/notes.txt?size=0", nil)), &untyped)
+	if u, _ := url.Parse(untyped.URL); u.Query().Get("X-Amz-SignedHeaders") != "content-length;host" || untyped.Post != nil {
+		t.Errorf("untyped link = %+v", untyped)
+	}
+}
+
+func TestUploadLinkHandlerRejects(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.uploadPolicy.types = []string{"image/*"}
+
+	for _, tt := range []struct {
+		target string
+		want   int
+	}{
+		{"/get-upload-link/cat.png?content_type=image/png", http.StatusBadRequest},
+		{"/get-upload-link/cat.png?size=99999999&content_type=image/png", http.StatusRequestEntityTooLarge},
+		{"/get-upload-link/cat.png?size=10", http.StatusBadRequest},
+		{"/get-upload-link/a.html?size=10&content_type=text/html", http.StatusUnsupportedMediaType},
+		{"/get-upload-link/cat.png?size=10&content_type=image/png&expires_in=2h", http.StatusBadRequest},
+		{"/get-upload-link/cat.png?size=10&content_type=image/png&post=true&max_size=5&min_size=6", http.StatusBadRequest},
+	} {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, tt.target, nil)); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.want)
+		}
+	}
+}
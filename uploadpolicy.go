@@ -0,0 +1,144 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// uploadPolicyLimits bounds what a presigned POST policy may allow. MinIO
+// enforces the policy itself, so browser uploads can't exceed it even though
+// the bytes never pass through this service.
+type uploadPolicyLimits struct {
+	maxBytes int64
+	// types are allowed content types; patterns such as "image/*" are
+	// accepted. Empty allows any type.
+	types  []string
+	expiry time.Duration
+}
+
+// loadUploadPolicyLimits reads MINIO_UPLOAD_POLICY_MAX_BYTES,
+// MINIO_UPLOAD_POLICY_CONTENT_TYPES and MINIO_UPLOAD_POLICY_EXPIRY.
+func loadUploadPolicyLimits() uploadPolicyLimits {
+	return uploadPolicyLimits{
+		maxBytes: envInt64("MINIO_UPLOAD_POLICY_MAX_BYTES", 100<<20),
+		types:    envList("MINIO_UPLOAD_POLICY_CONTENT_TYPES"),
+		expiry:   envDuration("MINIO_UPLOAD_POLICY_EXPIRY", 15*time.Minute),
+	}
+}
+
+// allowsType reports whether contentType may be uploaded under a policy.
+func (l uploadPolicyLimits) allowsType(contentType string) bool {
+	if len(l.types) == 0 {
+		return true
+	}
+	for _, p := range l.types {
+		if ok, _ := path.Match(strings.ToLower(p), contentType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type uploadPolicyResponse struct {
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// uploadPolicyHandler returns a presigned POST policy for a browser to upload
+// /get-upload-policy/{objectName} straight to MinIO. The policy pins the key,
+// the ?content_type= (checked against MINIO_UPLOAD_POLICY_CONTENT_TYPES) and
+// a size range of ?min_size= to ?max_size= bytes, capped at
+// MINIO_UPLOAD_POLICY_MAX_BYTES.
+func (h *MinioHandler) uploadPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/get-upload-policy/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /get-upload-policy/my-image.jpg)", http.StatusBadRequest)
+		return
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+
+	q := r.URL.Query()
+	contentType, _, err := mime.ParseMediaType(q.Get("content_type"))
+	if err != nil {
+		http.Error(w, "A valid content_type query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if !h.uploadPolicy.allowsType(contentType) {
+		http.Error(w, "Content type '"+contentType+"' is not allowed for uploads", http.StatusUnsupportedMediaType)
+		return
+	}
+	if policy := h.signUploadPolicy(w, r, objectName, contentType, time.Now().Add(h.uploadPolicy.expiry).UTC()); policy != nil {
+		writeJSON(w, r, http.StatusOK, policy)
+	}
+}
+
+// signUploadPolicy signs a POST policy for objectName that expires at
+// expiresAt, allowing a size range of r's ?min_size= to ?max_size= bytes
+// and, when contentType is set, only that type. The upload's encryption
+// (see sse.go) is added to the form fields. On failure it writes the error
+// response and returns nil.
+func (h *MinioHandler) signUploadPolicy(w http.ResponseWriter, r *http.Request, objectName, contentType string, expiresAt time.Time) *uploadPolicyResponse {
+	q := r.URL.Query()
+	var err error
+	minSize, maxSize := int64(0), h.uploadPolicy.maxBytes
+	if v := q.Get("min_size"); v != "" {
+		if minSize, err = strconv.ParseInt(v, 10, 64); err != nil || minSize < 0 {
+			http.Error(w, "min_size must be a non-negative number of bytes", http.StatusBadRequest)
+			return nil
+		}
+	}
+	if v := q.Get("max_size"); v != "" {
+		if maxSize, err = strconv.ParseInt(v, 10, 64); err != nil || maxSize <= 0 {
+			http.Error(w, "max_size must be a positive number of bytes", http.StatusBadRequest)
+			return nil
+		}
+		if maxSize > h.uploadPolicy.maxBytes {
+			http.Error(w, "max_size exceeds the upload limit of "+strconv.FormatInt(h.uploadPolicy.maxBytes, 10)+" bytes", http.StatusBadRequest)
+			return nil
+		}
+	}
+	if minSize > maxSize {
+		http.Error(w, "min_size must not exceed max_size", http.StatusBadRequest)
+		return nil
+	}
+
+	sse, err := h.sse.forUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	policy := minio.NewPostPolicy()
+	policy.SetEncryption(sse)
+	conditions := []error{
+		policy.SetBucket(h.bucketName),
+		policy.SetKey(objectName),
+		policy.SetExpires(expiresAt),
+		policy.SetContentLengthRange(minSize, maxSize),
+	}
+	if contentType != "" {
+		conditions = append(conditions, policy.SetContentType(contentType))
+	}
+	for _, err := range conditions {
+		if err != nil {
+			http.Error(w, "Invalid upload policy: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+	}
+	u, fields, err := h.links().PresignedPostPolicy(r.Context(), policy)
+	if err != nil {
+		logger(r.Context()).Error("Error generating upload policy", "object", objectName, "err", err)
+		http.Error(w, "Failed to generate upload policy", http.StatusInternalServerError)
+		return nil
+	}
+	return &uploadPolicyResponse{URL: u.String(), Fields: fields, ExpiresAt: expiresAt}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadPolicyHandler(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.uploadPolicy.types = []string{"image/*"}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantInPol  []string
+	}{
+		{
+			name:       "defaults to configured maximum",
+			target:     "/get-upload-policy/photos/cat.png?content_type=image/png",
+			wantStatus: http.StatusOK,
+			wantInPol:  []string{`["eq","$key","photos/cat.png"]`, `["eq","$Content-Type","image/png"]`, `["content-length-range", 0, 10485760]`},
+		},
+		{
+			name:       "client narrows the range",
+			target:     "/get-upload-policy/cat.png?content_type=image/png&min_size=1&max_size=1024",
+			wantStatus: http.StatusOK,
+			wantInPol:  []string{`["content-length-range", 1, 1024]`},
+		},
+		{name: "type outside allow list", target: "/get-upload-policy/a.html?content_type=text/html", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing type", target: "/get-upload-policy/cat.png", wantStatus: http.StatusBadRequest},
+		{name: "above configured maximum", target: "/get-upload-policy/cat.png?content_type=image/png&max_size=999999999", wantStatus: http.StatusBadRequest},
+		{name: "inverted range", target: "/get-upload-policy/cat.png?content_type=image/png&min_size=10&max_size=5", wantStatus: http.StatusBadRequest},
+		{name: "missing object name", target: "/get-upload-policy/?content_type=image/png", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got uploadPolicyResponse
+			decodeJSON(t, rec, &got)
+			for _, want := range tt.wantInPol {
+				if !strings.Contains(got.Fields["policy"], want) {
+					t.Errorf("policy %s missing %s", got.Fields["policy"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadPolicyRespectsUploadRulesAndReadOnly(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.uploadRules.defaults.Deny = []string{"*.exe"}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-upload-policy/a.exe?content_type=application/octet-stream", nil)); rec.Code != http.StatusForbidden {
+		t.Errorf("denied key status = %d, want 403", rec.Code)
+	}
+	h.setReadOnly(true)
+	t.Cleanup(func() { h.setReadOnly(false) })
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/get-upload-policy/a.png?content_type=image/png", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("read-only status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// prefixUsage is the number and total size of the objects under a prefix.
+type prefixUsage struct {
+	Objects    int64     `json:"objects"`
+	Bytes      int64     `json:"bytes"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// usageCache holds per-prefix usage totals. Computing them means listing
+// every object under the prefix, so figures are reused for ttl and then
+// refreshed in the background; uploads through this service adjust the
+// cached totals in between.
+type usageCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]prefixUsage
+	refreshing map[string]bool
+}
+
+func newUsageCache(ttl time.Duration) *usageCache {
+	return &usageCache{ttl: ttl, entries: make(map[string]prefixUsage), refreshing: make(map[string]bool)}
+}
+
+func (c *usageCache) get(prefix string) (prefixUsage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.entries[prefix]
+	return u, ok
+}
+
+func (c *usageCache) set(prefix string, u prefixUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[prefix] = u
+}
+
+// added counts a newly stored object of size bytes in every cached prefix
+// that contains key. Overwrites are counted as new objects until the next
+// refresh.
+func (c *usageCache) added(key string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for prefix, u := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			u.Objects++
+			u.Bytes += size
+			c.entries[prefix] = u
+		}
+	}
+}
+
+// prefixes returns the prefixes with cached totals.
+func (c *usageCache) prefixes() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var prefixes []string
+	for prefix := range c.entries {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// expire marks the totals of prefix as stale, so the next read refreshes
+// them in the background.
+func (c *usageCache) expire(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.entries[prefix]; ok {
+		u.ComputedAt = time.Time{}
+		c.entries[prefix] = u
+	}
+}
+
+// computeUsage lists everything under prefix, stores the totals in the
+// cache and returns them.
+func (h *MinioHandler) computeUsage(ctx context.Context, prefix string) (prefixUsage, error) {
+	u := prefixUsage{}
+	objectCh := h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for object := range objectCh {
+		if object.Err != nil {
+			return u, object.Err
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		u.Objects++
+		u.Bytes += object.Size
+	}
+	u.ComputedAt = time.Now().UTC()
+	h.usage.set(prefix, u)
+	return u, nil
+}
+
+// cachedUsage returns the cached totals for prefix without listing. When
+// they are missing or older than the cache TTL, a background refresh is
+// started; until it finishes, stale figures (or none) are returned.
+func (h *MinioHandler) cachedUsage(prefix string) (prefixUsage, bool) {
+	c := h.usage
+	c.mu.Lock()
+	u, ok := c.entries[prefix]
+	if (!ok || time.Since(u.ComputedAt) > c.ttl) && !c.refreshing[prefix] {
+		c.refreshing[prefix] = true
+		go func() {
+			defer func() {
+				c.mu.Lock()
+				delete(c.refreshing, prefix)
+				c.mu.Unlock()
+			}()
+			if _, err := h.computeUsage(context.Background(), prefix); err != nil {
+				slog.Error("Error computing usage of prefix", "prefix", prefix, "err", err)
+			}
+		}()
+	}
+	c.mu.Unlock()
+	return u, ok
+}
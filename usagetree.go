@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// usageTreeNode is the number and total size of the objects under one
+// child prefix.
+type usageTreeNode struct {
+	Prefix  string `json:"prefix"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// usageTree is a du-style rollup of a prefix: totals for the whole prefix,
+// for the objects directly in it, and for each child prefix one path
+// segment down, largest first.
+type usageTree struct {
+	Prefix     string          `json:"prefix"`
+	Objects    int64           `json:"objects"`
+	Bytes      int64           `json:"bytes"`
+	Files      usageTreeNode   `json:"files"`
+	Children   []usageTreeNode `json:"children"`
+	ComputedAt time.Time       `json:"computed_at"`
+	Cached     bool            `json:"cached"`
+}
+
+// usageTreeCache keeps computed rollups for ttl. Unlike usageCache it isn't
+// adjusted on upload, so figures may lag by up to ttl.
+type usageTreeCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]usageTree
+}
+
+func newUsageTreeCache(ttl time.Duration) *usageTreeCache {
+	return &usageTreeCache{ttl: ttl, entries: make(map[string]usageTree)}
+}
+
+// get returns the rollup of prefix if one was computed within the TTL.
+func (c *usageTreeCache) get(prefix string) (usageTree, bool) {
+	if c == nil {
+		return usageTree{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.entries[prefix]
+	if ok && time.Since(t.ComputedAt) > c.ttl {
+		delete(c.entries, prefix)
+		return usageTree{}, false
+	}
+	return t, ok
+}
+
+func (c *usageTreeCache) set(t usageTree) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[t.Prefix] = t
+}
+
+// invalidate drops the rollups of prefix, of prefixes under it, and of
+// prefixes containing it, all of which count objects under prefix.
+func (c *usageTreeCache) invalidate(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.entries {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			delete(c.entries, p)
+		}
+	}
+}
+
+// usageTreeBuilder rolls up listed objects into a usageTree.
+type usageTreeBuilder struct {
+	tree     usageTree
+	children map[string]*usageTreeNode
+}
+
+func newUsageTreeBuilder(prefix string) *usageTreeBuilder {
+	return &usageTreeBuilder{
+		tree:     usageTree{Prefix: prefix, Files: usageTreeNode{Prefix: prefix}, Children: []usageTreeNode{}},
+		children: map[string]*usageTreeNode{},
+	}
+}
+
+// add counts an object under the child prefix of its next path segment,
+// or among the files when it has none. Keys outside the prefix are ignored.
+func (b *usageTreeBuilder) add(key string, size int64) {
+	prefix := b.tree.Prefix
+	if !strings.HasPrefix(key, prefix) {
+		return
+	}
+	b.tree.Objects++
+	b.tree.Bytes += size
+	node := &b.tree.Files
+	if i := strings.Index(key[len(prefix):], "/"); i >= 0 {
+		child := key[:len(prefix)+i+1]
+		if node = b.children[child]; node == nil {
+			node = &usageTreeNode{Prefix: child}
+			b.children[child] = node
+		}
+	}
+	node.Objects++
+	node.Bytes += size
+}
+
+// result returns the rollup, children largest first.
+func (b *usageTreeBuilder) result() usageTree {
+	t := b.tree
+	t.Children = []usageTreeNode{}
+	for _, node := range b.children {
+		t.Children = append(t.Children, *node)
+	}
+	slices.SortFunc(t.Children, func(a, b usageTreeNode) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), strings.Compare(a.Prefix, b.Prefix))
+	})
+	t.ComputedAt = time.Now().UTC()
+	return t
+}
+
+// usageTreePrefix is prefix as a folder, the way /usage-tree rolls it up.
+func usageTreePrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// computeUsageTree lists everything under prefix and rolls it up by the
+// next path segment. It stops with ctx's error when ctx is done.
+func (h *MinioHandler) computeUsageTree(ctx context.Context, prefix string) (usageTree, error) {
+	b := newUsageTreeBuilder(prefix)
+	for object := range h.store.ListObjects(ctx, h.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if err := ctx.Err(); err != nil {
+			return b.tree, err
+		}
+		if object.Err != nil {
+			return b.tree, object.Err
+		}
+		if h.hiddenKey(object.Key) {
+			continue
+		}
+		b.add(object.Key, object.Size)
+	}
+	if err := ctx.Err(); err != nil {
+		return b.tree, err
+	}
+	return b.result(), nil
+}
+
+// usageTreeHandler reports the object count and total bytes under each
+// child prefix of ?prefix=, for drill-down storage views. Rollups are
+// cached for MINIO_USAGE_TREE_TTL; ?refresh=true recomputes one. Listing
+// stops as soon as the client goes away.
+func (h *MinioHandler) usageTreeHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := usageTreePrefix(q.Get("prefix"))
+	if q.Get("refresh") != "true" {
+		if t, ok := h.usageTrees.get(prefix); ok {
+			t.Cached = true
+			writeJSON(w, r, http.StatusOK, t)
+			return
+		}
+	}
+
+	t, err := h.computeUsageTree(r.Context(), prefix)
+	if err != nil {
+		if r.Context().Err() != nil {
+			logger(r.Context()).Info("Usage tree abandoned by the client", "prefix", prefix, "objects", t.Objects)
+			return
+		}
+		logger(r.Context()).Error("Error computing usage tree of prefix", "prefix", prefix, "err", err)
+		h.storeFailed(w, "Failed to list files", err)
+		return
+	}
+	h.usageTrees.set(t)
+	writeJSON(w, r, http.StatusOK, t)
+}
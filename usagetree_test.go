@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUsageTree(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.usageTrees = newUsageTreeCache(time.Hour)
+	for key, size := range map[string]int{
+		"media/readme.txt":         3,
+		"media/photos/a.jpg":       10,
+		"media/photos/2024/b.jpg":  20,
+		"media/videos/c.mp4":       100,
+		"media/videos/d.mp4":       50,
+		"mediakit/not-a-child.txt": 7,
+	} {
+		store.put(testBucket, key, make([]byte, size), "application/octet-stream")
+	}
+
+	var got usageTree
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/usage-tree?prefix=media", nil)), &got)
+	want := []usageTreeNode{
+		{Prefix: "media/videos/", Objects: 2, Bytes: 150},
+		{Prefix: "media/photos/", Objects: 2, Bytes: 30},
+	}
+	if got.Objects != 5 || got.Bytes != 183 || got.Files.Objects != 1 || got.Files.Bytes != 3 || got.Cached {
+		t.Errorf("tree = %+v", got)
+	}
+	if len(got.Children) != len(want) || got.Children[0] != want[0] || got.Children[1] != want[1] {
+		t.Errorf("children = %+v, want %+v", got.Children, want)
+	}
+
+	store.put(testBucket, "media/videos/e.mp4", make([]byte, 1), "video/mp4")
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/usage-tree?prefix=media/", nil)), &got)
+	if !got.Cached || got.Objects != 5 {
+		t.Errorf("second tree = %+v, want the cached rollup", got)
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/usage-tree?prefix=media/&refresh=true", nil)), &got)
+	if got.Cached || got.Objects != 6 {
+		t.Errorf("refreshed tree = %+v, want 6 objects", got)
+	}
+}
+
+func TestUsageTreeCancelled(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.usageTrees = newUsageTreeCache(time.Hour)
+	store.put(testBucket, "a/b.txt", []byte("x"), "text/plain")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	serve(h, httptest.NewRequest(http.MethodGet, "/usage-tree", nil).WithContext(ctx))
+	if _, ok := h.usageTrees.get(""); ok {
+		t.Error("cancelled rollup was cached")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// bulkConcurrency bounds the parallel MinIO calls made by endpoints that
+// act on a list of objects, such as /verify and /organize.
+const bulkConcurrency = 8
+
+// Verification outcomes reported per entry by /verify.
+const (
+	verifyOK           = "ok"
+	verifyMissing      = "missing"
+	verifySizeMismatch = "size_mismatch"
+	verifyETagMismatch = "etag_mismatch"
+	verifyError        = "error"
+)
+
+type verifyEntry struct {
+	Object       string `json:"object"`
+	ExpectedSize *int64 `json:"expected_size,omitempty"`
+	ExpectedETag string `json:"expected_etag,omitempty"`
+}
+
+type verifyResult struct {
+	Object       string `json:"object"`
+	Status       string `json:"status"`
+	ExpectedSize *int64 `json:"expected_size,omitempty"`
+	ActualSize   *int64 `json:"actual_size,omitempty"`
+	ExpectedETag string `json:"expected_etag,omitempty"`
+	ActualETag   string `json:"actual_etag,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+// verifyHandler stats each listed object and reports whether it exists with
+// the expected size and ETag, so bulk upload pipelines can audit what was
+// actually stored.
+func (h *MinioHandler) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	var entries []verifyEntry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil || len(entries) == 0 {
+		http.Error(w, `Request body must be a JSON array like [{"object": "a.csv", "expected_size": 123, "expected_etag": "..."}]`, http.StatusBadRequest)
+		return
+	}
+
+	results := make([]verifyResult, len(entries))
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = h.verifyObject(r, e)
+		}()
+	}
+	wg.Wait()
+
+	counts := map[string]int{}
+	for _, res := range results {
+		counts[res.Status]++
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"checked":    len(results),
+		"ok":         counts[verifyOK],
+		"mismatches": len(results) - counts[verifyOK],
+		"counts":     counts,
+		"results":    results,
+	})
+}
+
+func (h *MinioHandler) verifyObject(r *http.Request, e verifyEntry) verifyResult {
+	res := verifyResult{Object: e.Object, ExpectedSize: e.ExpectedSize, ExpectedETag: e.ExpectedETag}
+	if e.Object == "" {
+		res.Status, res.Note = verifyError, "object name is required"
+		return res
+	}
+	info, err := h.store.StatObject(r.Context(), h.bucketName, e.Object, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			res.Status = verifyMissing
+		} else {
+			res.Status, res.Note = verifyError, err.Error()
+		}
+		return res
+	}
+	res.ActualSize = &info.Size
+	res.ActualETag = strings.Trim(info.ETag, `"`)
+	res.Status = verifyOK
+
+	if e.ExpectedSize != nil && *e.ExpectedSize != info.Size {
+		res.Status = verifySizeMismatch
+		return res
+	}
+	expected := strings.Trim(e.ExpectedETag, `"`)
+	if expected == "" {
+		return res
+	}
+	// A multipart upload's ETag is "<md5 of part md5s>-<parts>", never the
+	// MD5 of the content, so a plain MD5 can only be checked via the size.
+	if strings.Contains(res.ActualETag, "-") != strings.Contains(expected, "-") {
+		res.Note = "ETag not comparable (multipart upload); compared size only"
+		if e.ExpectedSize == nil {
+			res.Note = "ETag not comparable (multipart upload) and no expected_size given"
+		}
+		return res
+	}
+	if !strings.EqualFold(expected, res.ActualETag) {
+		res.Status = verifyETagMismatch
+	}
+	return res
+}
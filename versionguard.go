@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// overwriteConflict is the 409 body of a /modify that would silently add a
+// version, describing the version the client would be stacking on.
+type overwriteConflict struct {
+	Error        string    `json:"error"`
+	Key          string    `json:"key"`
+	VersionID    string    `json:"version_id,omitempty"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// guardOverwrite enforces MINIO_PROTECT_VERSIONS for a /modify of
+// objectName. On a bucket with versioning enabled, replacing an existing
+// object takes ?new_version=true or an If-Match with its ETag; otherwise
+// the current version is reported with 409 Conflict. It returns the ETag
+// the upload must still match (so a concurrent change fails with 412) and
+// false once it has written a response.
+func (h *MinioHandler) guardOverwrite(w http.ResponseWriter, r *http.Request, objectName string) (string, bool) {
+	if !h.protectVersions || r.URL.Query().Get("new_version") == "true" {
+		return "", true
+	}
+	if match := strings.Trim(r.Header.Get("If-Match"), `"`); match != "" {
+		return match, true
+	}
+	cfg, err := h.store.GetBucketVersioning(r.Context(), h.bucketName)
+	if err != nil {
+		logger(r.Context()).Error("Error reading versioning of bucket", "bucket", h.bucketName, "err", err)
+		h.storeFailed(w, "Failed to check bucket versioning", err)
+		return "", false
+	}
+	if !cfg.Enabled() {
+		return "", true
+	}
+	info, err := h.statObject(r.Context(), objectName, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return "", true
+	}
+	if err != nil {
+		logger(r.Context()).Error("Error stating before modify", "object", objectName, "err", err)
+		h.storeFailed(w, "Failed to check the current version", err)
+		return "", false
+	}
+	writeJSON(w, r, http.StatusConflict, overwriteConflict{
+		Error:        "Object exists in a versioned bucket; add ?new_version=true or an If-Match header with its ETag to create a new version",
+		Key:          objectName,
+		VersionID:    info.VersionID,
+		ETag:         info.ETag,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+	})
+	return "", false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestModifyProtectsVersions(t *testing.T) {
+	h, store := newTestHandler(t)
+	h.protectVersions = true
+	store.versioning[testBucket] = "Enabled"
+	store.put(testBucket, "doc.txt", []byte("v1"), "text/plain")
+	info, _ := store.StatObject(t.Context(), testBucket, "doc.txt", minio.StatObjectOptions{})
+	current := info.ETag
+
+	rec := serve(h, newUploadRequest(t, http.MethodPut, "/modify/doc.txt", "doc.txt", "v2"))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("plain modify: status = %d, want 409", rec.Code)
+	}
+	var conflict overwriteConflict
+	decodeJSON(t, rec, &conflict)
+	if conflict.ETag != current || conflict.Size != 2 {
+		t.Errorf("conflict = %+v, want the current version", conflict)
+	}
+
+	tests := []struct {
+		name, target, ifMatch string
+		want                  int
+	}{
+		{"wrong If-Match", "/modify/doc.txt", `"0123"`, http.StatusPreconditionFailed},
+		{"matching If-Match", "/modify/doc.txt", `"` + current + `"`, http.StatusCreated},
+		{"new_version", "/modify/doc.txt?new_version=true", "", http.StatusCreated},
+		{"new object", "/modify/other.txt", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		req := newUploadRequest(t, http.MethodPut, tt.target, "doc.txt", "v2")
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		if rec := serve(h, req); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// Without versioning an overwrite loses nothing, so it isn't guarded.
+	store.versioning[testBucket] = "Suspended"
+	if rec := serve(h, newUploadRequest(t, http.MethodPut, "/modify/doc.txt", "doc.txt", "v3")); rec.Code != http.StatusCreated {
+		t.Errorf("unversioned modify: status = %d, want 201", rec.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// bucketVersioningHandler reports (GET) or changes (PUT, body
+// {"enabled": true}) whether the bucket keeps every version of its
+// objects. S3 can't turn versioning off again once it was on, so
+// {"enabled": false} suspends it: existing versions are kept, and new
+// writes replace the current version as in an unversioned bucket.
+func (h *MinioHandler) bucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		if h.rejectIfReadOnly(w) {
+			return
+		}
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			http.Error(w, `Request body must be JSON like {"enabled": true}`, http.StatusBadRequest)
+			return
+		}
+		config := minio.BucketVersioningConfiguration{Status: minio.Suspended}
+		if *req.Enabled {
+			config.Status = minio.Enabled
+		}
+		if err := h.store.SetBucketVersioning(r.Context(), h.bucketName, config); err != nil {
+			logger(r.Context()).Error("Error setting versioning of bucket", "bucket", h.bucketName, "err", err)
+			h.storeFailed(w, "Failed to set bucket versioning", err)
+			return
+		}
+	}
+
+	config, err := h.store.GetBucketVersioning(r.Context(), h.bucketName)
+	if err != nil {
+		logger(r.Context()).Error("Error reading versioning of bucket", "bucket", h.bucketName, "err", err)
+		h.storeFailed(w, "Failed to read bucket versioning", err)
+		return
+	}
+	status := config.Status
+	if status == "" {
+		// A bucket that never had versioning reports no status at all.
+		status = "Off"
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"enabled": config.Enabled(), "status": status})
+}
+
+type objectVersion struct {
+	VersionID    string    `json:"version_id"`
+	IsLatest     bool      `json:"is_latest"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// versionsHandler lists the versions of an object, newest first (GET
+// /versions/{objectName}), or makes an older one current again (POST
+// /versions/{objectName}?version_id=).
+func (h *MinioHandler) versionsHandler(w http.ResponseWriter, r *http.Request) {
+	objectName := objectNameFromPath(r, "/versions/")
+	if objectName == "" {
+		http.Error(w, "Object name is required in the URL path (e.g., /versions/docs/report.pdf)", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if h.rejectIfReadOnly(w) {
+			return
+		}
+		h.restoreVersion(w, r, objectName)
+		return
+	}
+
+	// The listing is by prefix; objects named below this one are skipped.
+	versions := []objectVersion{}
+	for object := range h.store.ListObjects(r.Context(), h.bucketName, minio.ListObjectsOptions{Prefix: objectName, WithVersions: true}) {
+		if object.Err != nil {
+			logger(r.Context()).Error("Error listing versions", "object", objectName, "err", object.Err)
+			h.storeFailed(w, "Failed to list versions", object.Err)
+			return
+		}
+		if object.Key != objectName {
+			continue
+		}
+		versions = append(versions, objectVersion{
+			VersionID:    object.VersionID,
+			IsLatest:     object.IsLatest,
+			DeleteMarker: object.IsDeleteMarker,
+			Size:         object.Size,
+			ETag:         object.ETag,
+			LastModified: object.LastModified,
+		})
+	}
+	if len(versions) == 0 {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"key": objectName, "versions": versions})
+}
+
+// restoreVersion copies version ?version_id= of objectName over the
+// object, which adds it as the newest version; the versions in between
+// are kept. CopyObjectPart can't name a source version, so versions over
+// 5 GiB can't be restored here.
+func (h *MinioHandler) restoreVersion(w http.ResponseWriter, r *http.Request, objectName string) {
+	versionID := r.URL.Query().Get("version_id")
+	if versionID == "" {
+		http.Error(w, "version_id is required (see GET /versions/"+objectName+")", http.StatusBadRequest)
+		return
+	}
+	if status, reason := h.uploadRules.check(h.bucketName, objectName); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+	key, err := customerKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := h.store.StatObject(r.Context(), h.bucketName, objectName, minio.StatObjectOptions{VersionID: versionID, ServerSideEncryption: key})
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey", "NoSuchVersion", "InvalidArgument":
+			http.Error(w, "Version not found", http.StatusNotFound)
+		case "MethodNotAllowed":
+			http.Error(w, "Version is a delete marker; restore a version before it", http.StatusBadRequest)
+		default:
+			logger(r.Context()).Error("Error stating version", "object", objectName, "version", versionID, "err", err)
+			h.storeFailed(w, "Failed to restore version", err)
+		}
+		return
+	}
+	if src.Size > maxSingleCopySize {
+		http.Error(w, "Versions over 5 GiB can't be restored", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := h.lifecycle.detach(r.Context())
+	defer cancel()
+	enc := h.sse.forCopy(key)
+	info, err := h.store.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: h.bucketName, Object: objectName, Encryption: enc.dst},
+		minio.CopySrcOptions{Bucket: h.bucketName, Object: objectName, VersionID: versionID, MatchETag: src.ETag, Encryption: enc.src})
+	if err != nil {
+		logger(r.Context()).Error("Error restoring version", "object", objectName, "version", versionID, "err", err)
+		h.storeFailed(w, "Failed to restore version", err)
+		return
+	}
+	h.cache.invalidate(objectName)
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"key":           objectName,
+		"restored_from": versionID,
+		"version_id":    info.VersionID,
+		"size":          src.Size,
+		"etag":          info.ETag,
+	})
+}
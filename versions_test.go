@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBucketVersioning(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	var got struct {
+		Enabled bool   `json:"enabled"`
+		Status  string `json:"status"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/bucket/versioning", nil)), &got)
+	if got.Enabled || got.Status != "Off" {
+		t.Errorf("new bucket = %+v, want off", got)
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPut, "/bucket/versioning", strings.NewReader(`{"enabled": true}`))), &got)
+	if !got.Enabled || got.Status != "Enabled" {
+		t.Errorf("after enabling = %+v", got)
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodPut, "/bucket/versioning", strings.NewReader(`{"enabled": false}`))), &got)
+	if got.Enabled || got.Status != "Suspended" {
+		t.Errorf("after disabling = %+v, want suspended", got)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPut, "/bucket/versioning", strings.NewReader(`{}`))); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty body: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestObjectVersions(t *testing.T) {
+	h, store := newTestHandler(t)
+	serve(h, httptest.NewRequest(http.MethodPut, "/bucket/versioning", strings.NewReader(`{"enabled": true}`)))
+	store.put(testBucket, "doc.txt", []byte("first"), "text/plain")
+	store.put(testBucket, "doc.txt", []byte("second"), "text/plain")
+	store.put(testBucket, "doc.txt/notes.txt", []byte("other"), "text/plain")
+
+	var listed struct {
+		Versions []objectVersion `json:"versions"`
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/versions/doc.txt", nil)), &listed)
+	if len(listed.Versions) != 2 || !listed.Versions[0].IsLatest || listed.Versions[1].IsLatest || listed.Versions[1].Size != 5 {
+		t.Fatalf("versions = %+v", listed.Versions)
+	}
+	first := listed.Versions[1].VersionID
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/doc.txt?version_id="+first, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "first" {
+		t.Errorf("download of the first version: %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/download/doc.txt?version_id=nope", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("download of an unknown version: status = %d, want 404", rec.Code)
+	}
+
+	rec = serve(h, httptest.NewRequest(http.MethodPost, "/versions/doc.txt?version_id="+first, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := store.object(testBucket, "doc.txt"); string(data) != "first" {
+		t.Errorf("current version = %q after restore, want first", data)
+	}
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/versions/doc.txt", nil)), &listed)
+	if len(listed.Versions) != 3 {
+		t.Errorf("versions after restore = %+v, want 3", listed.Versions)
+	}
+
+	// Deleting leaves a delete marker, and the object can be restored from
+	// behind it.
+	serve(h, httptest.NewRequest(http.MethodDelete, "/delete/doc.txt", nil))
+	decodeJSON(t, serve(h, httptest.NewRequest(http.MethodGet, "/versions/doc.txt", nil)), &listed)
+	if len(listed.Versions) != 4 || !listed.Versions[0].DeleteMarker || !listed.Versions[0].IsLatest {
+		t.Fatalf("versions after delete = %+v", listed.Versions)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/versions/doc.txt?version_id="+listed.Versions[0].VersionID, nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("restore of a delete marker: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodPost, "/versions/doc.txt?version_id="+first, nil)); rec.Code != http.StatusOK {
+		t.Errorf("restore after delete: status = %d", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/versions/missing.txt", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("versions of a missing object: status = %d, want 404", rec.Code)
+	}
+}
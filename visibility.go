@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	visibilityPublic  = "public-read"
+	visibilityPrivate = "private"
+
+	// visibilitySidPrefix marks the bucket policy statements this service
+	// manages, so they can be replaced without touching anything else.
+	visibilitySidPrefix = "GoMinioVisibility"
+)
+
+// prefixVisibility maps an object key prefix to its default visibility.
+type prefixVisibility struct {
+	Prefix     string
+	Visibility string
+}
+
+// visibilityRules is ordered longest prefix first so the most specific rule
+// wins. Keys matching no rule are private.
+type visibilityRules []prefixVisibility
+
+// loadVisibilityRules parses MINIO_PREFIX_VISIBILITY, a comma-separated list
+// of prefix=visibility pairs such as "public/=public-read,public/drafts/=private".
+// Public prefixes can't be combined with MINIO_KEY_SHARD_WIDTH: a sharded
+// object is stored under its shard, outside the prefix the policy grants,
+// and an unsigned URL can't name the shard for the client.
+func loadVisibilityRules() (visibilityRules, error) {
+	var rules visibilityRules
+	for _, item := range envList("MINIO_PREFIX_VISIBILITY") {
+		prefix, vis, ok := strings.Cut(item, "=")
+		if !ok || (vis != visibilityPublic && vis != visibilityPrivate) {
+			return nil, fmt.Errorf("MINIO_PREFIX_VISIBILITY: %q must be prefix=%s or prefix=%s", item, visibilityPublic, visibilityPrivate)
+		}
+		rules = append(rules, prefixVisibility{Prefix: strings.TrimSpace(prefix), Visibility: vis})
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	if rules.hasPublic() && envInt64("MINIO_KEY_SHARD_WIDTH", 0) != 0 {
+		return nil, fmt.Errorf("MINIO_PREFIX_VISIBILITY can't make prefixes %s while MINIO_KEY_SHARD_WIDTH is set", visibilityPublic)
+	}
+	return rules, nil
+}
+
+// forKey returns the visibility that applies to objectName.
+func (v visibilityRules) forKey(objectName string) string {
+	for _, rule := range v {
+		if strings.HasPrefix(objectName, rule.Prefix) {
+			return rule.Visibility
+		}
+	}
+	return visibilityPrivate
+}
+
+// hasPublic reports whether any rule makes objects public.
+func (v visibilityRules) hasPublic() bool {
+	for _, rule := range v {
+		if rule.Visibility == visibilityPublic {
+			return true
+		}
+	}
+	return false
+}
+
+// statements builds the anonymous-read policy statements for the rules:
+// an Allow for every public prefix, and a Deny for every private prefix that
+// is nested inside a public one.
+func (v visibilityRules) statements(bucket string) []map[string]any {
+	var stmts []map[string]any
+	for i, rule := range v {
+		effect := "Allow"
+		if rule.Visibility == visibilityPrivate {
+			if v.parentVisibility(i) != visibilityPublic {
+				continue
+			}
+			effect = "Deny"
+		}
+		stmts = append(stmts, map[string]any{
+			"Sid":       fmt.Sprintf("%s%d", visibilitySidPrefix, i),
+			"Effect":    effect,
+			"Principal": map[string]any{"AWS": []string{"*"}},
+			"Action":    []string{"s3:GetObject"},
+			"Resource":  []string{fmt.Sprintf("arn:aws:s3:::%s/%s*", bucket, rule.Prefix)},
+		})
+	}
+	return stmts
+}
+
+// parentVisibility returns the visibility of the closest shorter prefix
+// enclosing rule i.
+func (v visibilityRules) parentVisibility(i int) string {
+	for _, rule := range v[i+1:] {
+		if strings.HasPrefix(v[i].Prefix, rule.Prefix) {
+			return rule.Visibility
+		}
+	}
+	return visibilityPrivate
+}
+
+// syncVisibilityPolicy rewrites the statements this service owns in the
+// bucket policy to match the rules, leaving any other statements intact.
+func syncVisibilityPolicy(ctx context.Context, client ObjectStore, bucket string, rules visibilityRules) error {
+	current, err := client.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	policy := map[string]any{"Version": "2012-10-17"}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &policy); err != nil {
+			return fmt.Errorf("parsing existing bucket policy: %w", err)
+		}
+	}
+
+	var kept []any
+	if existing, ok := policy["Statement"].([]any); ok {
+		for _, s := range existing {
+			if m, ok := s.(map[string]any); ok {
+				if sid, _ := m["Sid"].(string); strings.HasPrefix(sid, visibilitySidPrefix) {
+					continue
+				}
+			}
+			kept = append(kept, s)
+		}
+	}
+	for _, s := range rules.statements(bucket) {
+		kept = append(kept, s)
+	}
+	if len(kept) == 0 {
+		if current == "" {
+			return nil
+		}
+		return client.SetBucketPolicy(ctx, bucket, "")
+	}
+	policy["Statement"] = kept
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return client.SetBucketPolicy(ctx, bucket, string(data))
+}
+
+// publicObjectURL returns the unsigned URL anonymous clients can use to read
+// a public object, using the path-style addressing the client is set up for.
+func publicObjectURL(endpoint *url.URL, bucket, objectName string) string {
+	u := url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: "/" + bucket + "/" + objectName}
+	return u.String()
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestLoadVisibilityRulesRefusesSharding(t *testing.T) {
+	t.Setenv("MINIO_PREFIX_VISIBILITY", "public/="+visibilityPublic)
+	t.Setenv("MINIO_KEY_SHARD_WIDTH", "1")
+	if _, err := loadVisibilityRules(); err == nil {
+		t.Error("public prefix with sharding: no error")
+	}
+
+	t.Setenv("MINIO_PREFIX_VISIBILITY", "drafts/="+visibilityPrivate)
+	if _, err := loadVisibilityRules(); err != nil {
+		t.Errorf("private prefix with sharding: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+// notificationRelay decouples a bucket notification subscription from a
+// possibly slow SSE client with a bounded buffer. When the buffer is full,
+// new events are dropped and counted instead of blocking the subscription;
+// if the client stays that far behind for longer than the stall timeout the
+// relay cancels the subscription so nothing is left running for it.
+type notificationRelay struct {
+	events  chan notification.Info
+	dropped atomic.Int64
+}
+
+// relayNotifications starts relaying from in until in is closed or the
+// client stalls, calling cancel in the latter case. The returned relay's
+// events channel is closed when relaying stops.
+func relayNotifications(in <-chan notification.Info, buffer int, stallTimeout time.Duration, cancel context.CancelFunc) *notificationRelay {
+	relay := &notificationRelay{events: make(chan notification.Info, buffer)}
+	go func() {
+		defer close(relay.events)
+		var stalledSince time.Time
+		for info := range in {
+			if info.Err != nil {
+				// Errors end the stream, so they are worth waiting for.
+				select {
+				case relay.events <- info:
+				case <-time.After(stallTimeout):
+				}
+				return
+			}
+			select {
+			case relay.events <- info:
+				stalledSince = time.Time{}
+			default:
+				relay.dropped.Add(1)
+				if stalledSince.IsZero() {
+					stalledSince = time.Now()
+				} else if time.Since(stalledSince) > stallTimeout {
+					slog.Warn("SSE client too slow; closing watch", "timeout", stallTimeout)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return relay
+}
+
+// takeDropped returns and resets the number of events dropped since the
+// last call.
+func (r *notificationRelay) takeDropped() int64 {
+	return r.dropped.Swap(0)
+}
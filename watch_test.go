@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+func TestRelayDropsWhenBufferFull(t *testing.T) {
+	in := make(chan notification.Info)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	relay := relayNotifications(in, 2, time.Minute, cancel)
+
+	for range 5 {
+		in <- notification.Info{}
+	}
+	close(in)
+
+	received := 0
+	for range relay.events {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("received %d events, want the 2 that fit the buffer", received)
+	}
+	if dropped := relay.takeDropped(); dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+	if relay.takeDropped() != 0 {
+		t.Error("takeDropped did not reset the counter")
+	}
+	if ctx.Err() != nil {
+		t.Error("relay cancelled the subscription without a persistent stall")
+	}
+}
+
+func TestRelayCancelsOnPersistentStall(t *testing.T) {
+	in := make(chan notification.Info)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	relay := relayNotifications(in, 1, 20*time.Millisecond, cancel)
+
+	in <- notification.Info{} // fills the buffer; nobody reads it
+	deadline := time.After(2 * time.Second)
+	for ctx.Err() == nil {
+		select {
+		case in <- notification.Info{}:
+			time.Sleep(5 * time.Millisecond)
+		case <-ctx.Done():
+		case <-deadline:
+			t.Fatal("relay never gave up on a stalled client")
+		}
+	}
+
+	// The relay must close its output so the SSE writer returns.
+	for range relay.events {
+	}
+}